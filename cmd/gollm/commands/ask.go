@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"github.com/zerobang-dev/gollm/pkg/rag"
+)
+
+var (
+	askIndexFlag        string
+	askTopFlag          int
+	askShowThinkingFlag bool
+)
+
+// askCmd represents the ask command
+var askCmd = &cobra.Command{
+	Use:   "ask --index <name> <question>",
+	Short: "Answer a question from an index built with gollm index",
+	Long: `Find the chunks of an index most similar to a question and ask the model to
+answer from them, citing them as [1], [2], ... The sources are listed with their
+file and line range after the answer.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		question := args[0]
+		if askTopFlag <= 0 {
+			return fmt.Errorf("--top must be at least 1")
+		}
+
+		path, err := rag.Path(config.GetConfigDir(), askIndexFlag)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("index %s not found, create it with: gollm index <dir> --name %s", askIndexFlag, askIndexFlag)
+		}
+
+		// Load config
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		// Resolve model, system prompt and sampling settings from flags, env and config
+		settings, err := resolveQuerySettings(cfg, flagOverrides(cmd))
+		if err != nil {
+			return err
+		}
+		renderer, err := newResponseRenderer(settings)
+		if err != nil {
+			return err
+		}
+
+		index, err := rag.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := index.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing index: %v\n", err)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(cfg, settings.Model, false))
+		defer cancel()
+
+		// Find the chunks to answer from
+		service, err := newEmbeddingService(cfg, &http.Client{Timeout: embeddingTimeout(cfg)})
+		if err != nil {
+			return err
+		}
+		matches, err := index.Search(ctx, service, question, askTopFlag)
+		if err != nil {
+			return fmt.Errorf("error searching index %s: %w", askIndexFlag, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("index %s is empty", askIndexFlag)
+		}
+
+		result, err := queryLLM(ctx, rag.Prompt(question, matches), cfg, settings, false)
+		if err != nil {
+			return err
+		}
+		response, ok := result.(*queryResult)
+		if !ok {
+			return nil
+		}
+
+		for _, warning := range response.Response.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+		displaySimpleResult(response.Response, response.ElapsedTime, askShowThinkingFlag, renderer)
+
+		// List the sources the answer cites
+		fmt.Println("\nSources:")
+		for i, match := range matches {
+			fmt.Printf("  [%d] %s (%.2f)\n", i+1, match.Citation(), match.Score)
+		}
+		return nil
+	},
+}
+
+func init() {
+	askCmd.Flags().StringVar(&askIndexFlag, "index", "", "Name of the index to answer from (required)")
+	askCmd.Flags().IntVarP(&askTopFlag, "top", "k", 5, "Number of chunks to answer from")
+	addConfigFlag(askCmd, "system", "System prompt to provide context")
+	addConfigFlag(askCmd, "temperature", "Temperature for response generation")
+	addConfigFlag(askCmd, "max-tokens", "Maximum number of tokens to generate")
+	askCmd.Flags().BoolVar(&askShowThinkingFlag, "show-thinking", false, "Display the model's reasoning before the answer")
+	addConfigFlag(askCmd, "render", "Render the markdown answer: auto (on a terminal), always or never")
+	addConfigFlag(askCmd, "theme", "Colors of the rendered answer: auto, dark or light")
+	if err := askCmd.MarkFlagRequired("index"); err != nil {
+		panic(fmt.Sprintf("Failed to mark index flag as required: %v", err))
+	}
+
+	rootCmd.AddCommand(askCmd)
+}
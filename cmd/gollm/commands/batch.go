@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/batch"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+	"github.com/zerobang-dev/gollm/pkg/logger"
+	"golang.org/x/term"
+)
+
+var (
+	batchOutputFlag      string
+	batchTemplateFlag    string
+	batchConcurrencyFlag int
+)
+
+// batchCmd represents the batch command
+var batchCmd = &cobra.Command{
+	Use:   "batch <input.jsonl>",
+	Short: "Run prompts from a JSONL file",
+	Long: `Run every prompt in a JSONL file and write one JSON result per line to the output.
+
+Each input line is an object with a "prompt", or "vars" to render the --template
+with, and optionally an "id", "model", "system", "temperature" and "max_tokens".
+Items without an id are identified by their line number.
+
+Failed items are recorded in the output with an "error" and do not stop the run.
+Running the same command again skips items already answered in the output, so an
+interrupted or partly failed batch can be resumed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Read the items
+		input, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("error opening batch input: %w", err)
+		}
+		items, err := batch.ReadItems(input)
+		if closeErr := input.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error closing batch input: %w", closeErr)
+		}
+		if err != nil {
+			return err
+		}
+
+		var tmpl *template.Template
+		if batchTemplateFlag != "" {
+			tmpl, err = template.New("prompt").Option("missingkey=error").Parse(batchTemplateFlag)
+			if err != nil {
+				return fmt.Errorf("error parsing template: %w", err)
+			}
+		}
+
+		// Load config
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		// Resolve the defaults for items from flags, env and config
+		settings, err := resolveQuerySettings(cfg, flagOverrides(cmd))
+		if err != nil {
+			return err
+		}
+
+		// Skip items answered by an earlier run
+		completed, err := batch.CompletedIDs(batchOutputFlag)
+		if err != nil {
+			return err
+		}
+
+		output, err := os.OpenFile(batchOutputFlag, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("error opening batch output: %w", err)
+		}
+		defer func() {
+			if err := output.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing batch output: %v\n", err)
+			}
+		}()
+
+		// Initialize logger
+		queryLogger, err := logger.NewLogger(config.GetConfigDir())
+		if err != nil {
+			// Just log a warning but continue without logging
+			fmt.Fprintf(os.Stderr, "Warning: Query logging disabled - %v\n", err)
+		} else {
+			defer func() {
+				if err := queryLogger.Close(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error closing logger: %v\n", err)
+				}
+			}()
+		}
+
+		// Items may choose any model, so configure every provider with a key
+		service, err := newConfiguredService(cfg, &http.Client{Timeout: queryTimeout(cfg, "", true)}, queryLogger)
+		if err != nil {
+			return err
+		}
+
+		options := []llm.Option{llm.WithMaxTokens(settings.MaxTokens)}
+		if settings.SystemPrompt != "" {
+			options = append(options, llm.WithCustomParam("system", settings.SystemPrompt))
+		}
+
+		runner := &batch.Runner{
+			Querier:     service,
+			Model:       settings.Model,
+			Temperature: settings.Temperature,
+			Options:     options,
+			Template:    tmpl,
+			Concurrency: batchConcurrencyFlag,
+		}
+
+		// Show progress on a terminal
+		showProgress := term.IsTerminal(int(os.Stderr.Fd()))
+		if showProgress {
+			runner.Progress = func(progress batch.Progress) {
+				fmt.Fprintf(os.Stderr, "\r[%d/%d] %d failed", progress.Done, progress.Total, progress.Failed)
+			}
+		}
+
+		// Stop starting items on interrupt, the output keeps what finished
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		startTime := time.Now()
+		summary, err := runner.Run(ctx, items, completed, output)
+		if showProgress && summary.Succeeded+summary.Failed > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+
+		displayBatchSummary(summary, time.Since(startTime))
+		if ctx.Err() != nil {
+			return fmt.Errorf("batch interrupted, run the same command again to resume")
+		}
+		if err != nil {
+			return err
+		}
+		if summary.Failed > 0 {
+			return fmt.Errorf("%d of %d items failed, see the errors in %s and run again to retry them", summary.Failed, summary.Total, batchOutputFlag)
+		}
+		return nil
+	},
+}
+
+// displayBatchSummary prints the outcome of a batch run
+func displayBatchSummary(summary batch.Summary, elapsedTime time.Duration) {
+	fmt.Printf("Items:          %d\n", summary.Total)
+	if summary.Skipped > 0 {
+		fmt.Printf("Skipped:        %d (already completed)\n", summary.Skipped)
+	}
+	fmt.Printf("Succeeded:      %d\n", summary.Succeeded)
+	fmt.Printf("Failed:         %d\n", summary.Failed)
+	fmt.Printf("Time:           %.2f seconds\n", elapsedTime.Seconds())
+	fmt.Printf("Tokens:         %d input, %d output\n", summary.Usage.InputTokens, summary.Usage.OutputTokens+summary.Usage.ThinkingTokens)
+	fmt.Printf("Estimated cost: $%.4f\n", summary.Cost)
+}
+
+func init() {
+	batchCmd.Flags().StringVarP(&batchOutputFlag, "output", "o", "", "JSONL file to append results to (required)")
+	batchCmd.Flags().StringVar(&batchTemplateFlag, "template", "", "Prompt template rendered with the vars of each item, e.g. 'Summarize: {{.text}}'")
+	batchCmd.Flags().IntVarP(&batchConcurrencyFlag, "concurrency", "c", 4, "Number of items processed at the same time")
+	addConfigFlag(batchCmd, "system", "System prompt for items that do not set one")
+	addConfigFlag(batchCmd, "temperature", "Temperature for items that do not set one")
+	addConfigFlag(batchCmd, "max-tokens", "Maximum tokens per response")
+	if err := batchCmd.MarkFlagRequired("output"); err != nil {
+		panic(fmt.Sprintf("Failed to mark output flag as required: %v", err))
+	}
+
+	rootCmd.AddCommand(batchCmd)
+}
@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+	"github.com/zerobang-dev/gollm/pkg/logger"
+	"github.com/zerobang-dev/gollm/pkg/shell"
+	"golang.org/x/term"
+)
+
+var (
+	cmdShellFlag string
+	cmdNoRunFlag bool
+)
+
+// cmdCmd represents the cmd command
+var cmdCmd = &cobra.Command{
+	Use:   "cmd <request>",
+	Short: "Suggest a shell command for a request and run it after confirmation",
+	Long: `Ask the model for a single shell command doing what the request describes,
+written for the current operating system and shell, with an explanation.
+
+The command is printed on stdout and the explanation on stderr. On a terminal
+gollm then offers to run it; commands matching destructive patterns such as
+rm -rf, dd or a force push are flagged and only run after typing yes.
+Without a terminal the command is never run. The command and its outcome are
+recorded in the query log, see gollm history --commands.`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		request := strings.Join(args, " ")
+
+		env := shell.DetectEnvironment()
+		if cmdShellFlag != "" {
+			env.Shell = cmdShellFlag
+		}
+
+		// Load config
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		// Resolve model, system prompt and max tokens from flags, env and config
+		settings, err := resolveQuerySettings(cfg, flagOverrides(cmd))
+		if err != nil {
+			return err
+		}
+
+		// Initialize logger
+		queryLogger, err := logger.NewLogger(config.GetConfigDir())
+		if err != nil {
+			// Just log a warning but continue without logging
+			fmt.Fprintf(os.Stderr, "Warning: Query logging disabled - %v\n", err)
+		} else {
+			defer func() {
+				if err := queryLogger.Close(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error closing logger: %v\n", err)
+				}
+			}()
+		}
+
+		timeout := queryTimeout(cfg, settings.Model, false)
+		service, _, err := newModelService(settings.Model, cfg, &http.Client{Timeout: timeout}, queryLogger)
+		if err != nil {
+			return err
+		}
+
+		options := []llm.Option{llm.WithMaxTokens(settings.MaxTokens)}
+		if llm.SupportsSampling(settings.Model) {
+			options = append(options, llm.WithTemperature(settings.Temperature))
+		}
+		if settings.SystemPrompt != "" {
+			options = append(options, llm.WithCustomParam("system", settings.SystemPrompt))
+		}
+
+		// Ask for the command
+		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+		s.Suffix = fmt.Sprintf(" Asking %s for a %s command...", settings.Model, env.Shell)
+		s.Start()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		suggestion, response, err := shell.Suggest(ctx, service, settings.Model, request, env, options...)
+		cancel()
+		s.Stop()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(suggestion.Command)
+		if suggestion.Explanation != "" {
+			fmt.Fprintf(os.Stderr, "\n%s\n", suggestion.Explanation)
+		}
+		for _, risk := range suggestion.Risks {
+			fmt.Fprintf(os.Stderr, "Warning: this command %s\n", risk)
+		}
+
+		record := logger.Command{
+			Request:  request,
+			Command:  suggestion.Command,
+			Model:    settings.Model,
+			ExitCode: -1,
+		}
+
+		// An answer cut off at the token limit may hold an incomplete command
+		truncated := response.Metadata.Truncated()
+		if truncated {
+			fmt.Fprintf(os.Stderr, "Warning: the answer was truncated at the %d token limit, so the command is not run\n", settings.MaxTokens)
+		}
+
+		// Only run the command when someone confirmed it
+		run := false
+		if !cmdNoRunFlag && !truncated && term.IsTerminal(int(os.Stdin.Fd())) {
+			run, err = confirmCommand(os.Stdin, len(suggestion.Risks) > 0)
+			if err != nil {
+				return err
+			}
+		}
+		if !run {
+			logCommand(queryLogger, record)
+			return nil
+		}
+
+		startTime := time.Now()
+		exitCode, runErr := shell.Run(context.Background(), env, suggestion.Command, os.Stdin, os.Stdout, os.Stderr)
+		record.Executed = true
+		record.ExitCode = exitCode
+		record.Duration = time.Since(startTime).Milliseconds()
+		if runErr != nil {
+			record.Error = runErr.Error()
+		}
+		logCommand(queryLogger, record)
+
+		if runErr != nil {
+			return runErr
+		}
+		if exitCode != 0 {
+			return fmt.Errorf("command exited with status %d", exitCode)
+		}
+		return nil
+	},
+}
+
+// confirmCommand asks whether to run the suggested command. Destructive
+// commands need yes typed out rather than y.
+func confirmCommand(input io.Reader, destructive bool) (bool, error) {
+	if destructive {
+		fmt.Fprint(os.Stderr, "\nThis command may be destructive. Type yes to run it: ")
+	} else {
+		fmt.Fprint(os.Stderr, "\nRun this command? [y/N] ")
+	}
+
+	answer, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("error reading answer: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if destructive {
+		return answer == "yes", nil
+	}
+	return answer == "y" || answer == "yes", nil
+}
+
+// logCommand records a suggested command in the query log if logging is enabled
+func logCommand(queryLogger *logger.Logger, command logger.Command) {
+	if queryLogger == nil {
+		return
+	}
+	if err := queryLogger.LogCommand(command); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to log command: %v\n", err)
+	}
+}
+
+func init() {
+	cmdCmd.Flags().StringVar(&cmdShellFlag, "shell", "", "Shell to write the command for (default: detected from $SHELL)")
+	cmdCmd.Flags().BoolVar(&cmdNoRunFlag, "no-run", false, "Only print the command, never offer to run it")
+	addConfigFlag(cmdCmd, "system", "System prompt to provide context")
+	addConfigFlag(cmdCmd, "temperature", "Temperature for response generation")
+	addConfigFlag(cmdCmd, "max-tokens", "Maximum number of tokens to generate")
+
+	rootCmd.AddCommand(cmdCmd)
+}
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zerobang-dev/gollm/pkg/codeblock"
+)
+
+// extractCodeAll is the value of --extract-code given without languages
+const extractCodeAll = "*"
+
+// codeOutputRequested reports whether only the code blocks of the response are wanted
+func codeOutputRequested() bool {
+	return extractCodeFlag != "" || writeToFlag != ""
+}
+
+// validateCodeFlags checks the code output flags before a query is sent
+func validateCodeFlags() error {
+	if dryRunFlag && writeToFlag == "" {
+		return fmt.Errorf("--dry-run requires --write-to")
+	}
+	if forceFlag && writeToFlag == "" {
+		return fmt.Errorf("--force requires --write-to")
+	}
+	if codeOutputRequested() && queryAllFlag {
+		return fmt.Errorf("--extract-code and --write-to cannot be combined with --all")
+	}
+	return nil
+}
+
+// outputCode prints the code blocks of a response, or writes them to files
+// in the --write-to directory, instead of the response itself
+func outputCode(text string) error {
+	var languages []string
+	if extractCodeFlag != "" && extractCodeFlag != extractCodeAll {
+		languages = strings.Split(extractCodeFlag, ",")
+	}
+
+	blocks := codeblock.Filter(codeblock.Parse(text), languages...)
+	if len(blocks) == 0 {
+		if len(languages) > 0 {
+			return fmt.Errorf("no %s code blocks in the response", strings.Join(languages, ", "))
+		}
+		return fmt.Errorf("no code blocks in the response")
+	}
+	for _, block := range blocks {
+		if !block.Closed {
+			fmt.Fprintf(os.Stderr, "Warning: the code block at line %d of the response is incomplete\n", block.Line)
+		}
+	}
+
+	if writeToFlag == "" {
+		for i, block := range blocks {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Print(block.Content)
+		}
+		return nil
+	}
+
+	// Refuse to replace files before writing anything, unless forced
+	files := codeblock.Plan(writeToFlag, blocks)
+	var existing []string
+	for _, file := range files {
+		if file.Exists {
+			existing = append(existing, filepath.Join(writeToFlag, filepath.FromSlash(file.Path)))
+		}
+	}
+	if len(existing) == 1 && !forceFlag {
+		return fmt.Errorf("%s already exists, use --force to replace it", existing[0])
+	}
+	if len(existing) > 1 && !forceFlag {
+		return fmt.Errorf("%s already exist, use --force to replace them", strings.Join(existing, ", "))
+	}
+	if !dryRunFlag {
+		if err := codeblock.Write(writeToFlag, files, forceFlag); err != nil {
+			return err
+		}
+	}
+
+	action := "Wrote"
+	if dryRunFlag {
+		action = "Would write"
+	}
+	for _, file := range files {
+		details := fmt.Sprintf("%d lines", file.Block.Lines())
+		if file.Block.Language != "" {
+			details = file.Block.Language + ", " + details
+		}
+		if file.Exists {
+			details += ", replacing the existing file"
+		}
+		fmt.Printf("%s %s (%s)\n", action, filepath.Join(writeToFlag, filepath.FromSlash(file.Path)), details)
+	}
+	return nil
+}
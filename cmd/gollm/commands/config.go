@@ -0,0 +1,477 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"golang.org/x/term"
+)
+
+var (
+	configShowSecretsFlag bool
+	configOriginFlag      bool
+	configProjectFlag     bool
+	configBackendFlag     string
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "View, validate and edit configuration",
+	Long: `Manage gollm configuration settings.
+
+Values are resolved in order of precedence: command-line flags, environment
+variables, the nearest project .gollm.yml, the user config file and finally
+built-in defaults.`,
+}
+
+// configGetCmd prints the effective value of a key
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a configuration key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		setting, err := cfg.Lookup(args[0], flagOverrides(cmd))
+		if err != nil {
+			return err
+		}
+		if setting.Err != nil {
+			return setting.Err
+		}
+
+		if setting.Source == config.SourceUnset {
+			return fmt.Errorf("%s is not set", args[0])
+		}
+
+		fmt.Println(setting.Value)
+		return nil
+	},
+}
+
+// configSetCmd stores a value in the config file
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a configuration key in the config file",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Project files are shared, keep credentials and user settings out of them
+		if configProjectFlag {
+			if err := config.CheckProjectKey(args[0]); err != nil {
+				return err
+			}
+		}
+
+		configPath, err := configTargetPath()
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.LoadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		if err := cfg.Set(args[0], args[1]); err != nil {
+			return err
+		}
+
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("error saving config: %w", err)
+		}
+
+		fmt.Printf("%s has been set.\n", args[0])
+		fmt.Printf("Configuration saved to %s\n", configPath)
+
+		return nil
+	},
+}
+
+// configUnsetCmd removes a value from the config file
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a configuration key from the config file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, err := configTargetPath()
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.LoadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		if err := cfg.Unset(args[0]); err != nil {
+			return err
+		}
+
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("error saving config: %w", err)
+		}
+
+		fmt.Printf("%s has been unset in %s.\n", args[0], configPath)
+		return nil
+	},
+}
+
+// configListCmd prints every key with its effective value and source
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all configuration keys with their effective values",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		header, separator := "KEY\tVALUE\tSOURCE", "---\t-----\t------"
+		if configOriginFlag {
+			header, separator = header+"\tORIGIN", separator+"\t------"
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if _, err := fmt.Fprintln(w, header); err != nil {
+			return fmt.Errorf("error writing header: %w", err)
+		}
+		if _, err := fmt.Fprintln(w, separator); err != nil {
+			return fmt.Errorf("error writing header: %w", err)
+		}
+
+		for _, setting := range cfg.Settings(flagOverrides(cmd)) {
+			value := setting.Value
+			if setting.Key.Secret && !configShowSecretsFlag {
+				value = config.Mask(value)
+			}
+			if setting.Err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", setting.Err)
+				value = "<error>"
+			}
+			if value == "" {
+				value = "-"
+			}
+
+			row := fmt.Sprintf("%s\t%s\t%s", setting.Key.Name, truncateString(value, 60), setting.Source)
+			if configOriginFlag {
+				origin := setting.Origin
+				if origin == "" {
+					origin = "-"
+				}
+				row += "\t" + origin
+			}
+
+			if _, err := fmt.Fprintln(w, row); err != nil {
+				return fmt.Errorf("error writing setting: %w", err)
+			}
+		}
+
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("error flushing tabwriter: %w", err)
+		}
+
+		return nil
+	},
+}
+
+// configPathCmd prints the location of the config file
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the path of the config file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, err := configTargetPath()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(configPath)
+		return nil
+	},
+}
+
+// configEditCmd opens the config file in the user's editor
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file in $EDITOR and validate it afterwards",
+	Args:  cobra.NoArgs,
+	// Validation problems are reported in detail, usage would only add noise
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, err := configTargetPath()
+		if err != nil {
+			return err
+		}
+
+		// Create the file from the current configuration so the editor has something to open
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			cfg, err := config.LoadFile(configPath)
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("error saving config: %w", err)
+			}
+		}
+
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if editor == "" {
+			editor = "vi"
+		}
+
+		// Run through the shell so editors configured with arguments work
+		editCmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", configPath)
+		editCmd.Stdin = os.Stdin
+		editCmd.Stdout = os.Stdout
+		editCmd.Stderr = os.Stderr
+		if err := editCmd.Run(); err != nil {
+			return fmt.Errorf("error running editor: %w", err)
+		}
+
+		return validateConfigFile(configPath)
+	},
+}
+
+// configValidateCmd checks a config file against the known keys
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Validate the config file",
+	Args:  cobra.MaximumNArgs(1),
+	// Validation problems are reported in detail, usage would only add noise
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, err := configTargetPath()
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			configPath = args[0]
+		}
+
+		return validateConfigFile(configPath)
+	},
+}
+
+// configMigrateSecretsCmd moves plaintext API keys into a secret store
+var configMigrateSecretsCmd = &cobra.Command{
+	Use:   "migrate-secrets",
+	Short: "Move plaintext API keys from the config file into a secret store",
+	Long: `Move API keys stored in plaintext in the config file into the secret store
+selected with secrets.backend (or --backend): an encrypted secrets file in
+the config directory, or the OS keyring.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadUser()
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+
+		if configBackendFlag != "" {
+			if err := cfg.Set("secrets.backend", configBackendFlag); err != nil {
+				return err
+			}
+		}
+
+		store, err := cfg.SecretStore()
+		if err != nil {
+			return err
+		}
+		if store == nil {
+			return fmt.Errorf("no secret store configured, use --backend %s or --backend %s", config.BackendFile, config.BackendKeyring)
+		}
+
+		migrated, err := cfg.MigrateSecrets(store)
+
+		// Save even after a partial failure so migrated keys are not left in plaintext
+		if len(migrated) > 0 || configBackendFlag != "" {
+			if saveErr := cfg.Save(); saveErr != nil {
+				return fmt.Errorf("error saving config: %w", saveErr)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(migrated) == 0 {
+			fmt.Println("No plaintext API keys to migrate.")
+			return nil
+		}
+
+		for _, provider := range migrated {
+			fmt.Printf("Moved %s API key to %s\n", provider, store.Name())
+		}
+		return nil
+	},
+}
+
+// validateConfigFile validates a config file and prints any problems found
+func validateConfigFile(configPath string) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("No config file at %s, using defaults.\n", configPath)
+			return nil
+		}
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	validationErrors, err := config.Validate(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", configPath, err)
+	}
+
+	if len(validationErrors) == 0 {
+		fmt.Printf("%s is valid.\n", configPath)
+		return nil
+	}
+
+	for _, validationErr := range validationErrors {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", configPath, validationErr.Line, validationErr.Column, validationErr.Message)
+	}
+
+	return fmt.Errorf("%s has %d error(s)", configPath, len(validationErrors))
+}
+
+// loadConfig loads the effective configuration and reports any warnings on stderr
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+
+	for _, warning := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	return cfg, nil
+}
+
+// configTargetPath returns the config file edited by config subcommands: the
+// user config by default, or the nearest project file with --project
+func configTargetPath() (string, error) {
+	if !configProjectFlag {
+		return config.Path()
+	}
+
+	projectPath, err := config.FindProjectConfig()
+	if err != nil {
+		return "", err
+	}
+	if projectPath != "" {
+		return projectPath, nil
+	}
+
+	// No project file yet, create one in the working directory
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("error getting working directory: %w", err)
+	}
+	return filepath.Join(dir, config.ProjectConfigName), nil
+}
+
+// promptPassphrase asks for the secrets file passphrase on the terminal
+func promptPassphrase(confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("cannot prompt for the secrets passphrase without a terminal, set GOLLM_SECRETS_PASSPHRASE")
+	}
+
+	fmt.Fprint(os.Stderr, "Secrets passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("error reading passphrase: %w", err)
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		confirmation, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("error reading passphrase: %w", err)
+		}
+		if string(confirmation) != string(passphrase) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+
+	return string(passphrase), nil
+}
+
+// flagOverrides returns config values explicitly set through command-line flags, keyed by flag name
+func flagOverrides(cmd *cobra.Command) map[string]string {
+	overrides := make(map[string]string)
+	for _, key := range config.Keys() {
+		if key.Flag == "" {
+			continue
+		}
+		if flag := cmd.Flags().Lookup(key.Flag); flag != nil && flag.Changed {
+			overrides[key.Flag] = flag.Value.String()
+		}
+	}
+	return overrides
+}
+
+// configFlagShorthands holds the one-letter names of flags overriding config keys
+var configFlagShorthands = map[string]string{"model": "m", "system": "s", "temperature": "t"}
+
+// addConfigFlag registers a flag overriding the config key with the same
+// flag name. It has no variable or default of its own: flagOverrides reads
+// it by name when it is set, and the config value applies otherwise.
+func addConfigFlag(cmd *cobra.Command, name, usage string) {
+	for _, key := range config.Keys() {
+		if key.Flag == name {
+			usage = fmt.Sprintf("%s (defaults to %s from config)", usage, key.Name)
+			break
+		}
+	}
+
+	shorthand := configFlagShorthands[name]
+	switch name {
+	case "temperature":
+		cmd.Flags().Float64P(name, shorthand, 0, usage)
+	case "max-tokens":
+		cmd.Flags().IntP(name, shorthand, 0, usage)
+	default:
+		cmd.Flags().StringP(name, shorthand, "", usage)
+	}
+}
+
+func init() {
+	config.PassphraseFunc = promptPassphrase
+
+	configListCmd.Flags().BoolVar(&configShowSecretsFlag, "show-secrets", false, "Show secret values such as API keys unmasked")
+	configListCmd.Flags().BoolVar(&configOriginFlag, "origin", false, "Show the file, environment variable or flag each value came from")
+
+	// Editing commands can target the project config instead of the user config
+	for _, cmd := range []*cobra.Command{configSetCmd, configUnsetCmd, configPathCmd, configEditCmd, configValidateCmd} {
+		cmd.Flags().BoolVar(&configProjectFlag, "project", false, "Use the nearest project "+config.ProjectConfigName+" instead of the user config")
+	}
+
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	configMigrateSecretsCmd.Flags().StringVar(&configBackendFlag, "backend", "", "Secret store to migrate to and enable (file or keyring)")
+	configCmd.AddCommand(configMigrateSecretsCmd)
+
+	rootCmd.AddCommand(configCmd)
+}
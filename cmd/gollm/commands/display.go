@@ -9,11 +9,14 @@ import (
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/zerobang-dev/gollm/pkg/config"
 	"github.com/zerobang-dev/gollm/pkg/llm"
+	"github.com/zerobang-dev/gollm/pkg/markdown"
+	"golang.org/x/term"
 )
 
 // displayProviderResults displays the results from multiple providers
-func displayProviderResults(results map[string]llm.ProviderResponse) error {
+func displayProviderResults(results map[string]llm.ProviderResponse, showThinking bool, renderer *markdown.Renderer) error {
 	// Create a new tabwriter for formatted output
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
@@ -80,7 +83,10 @@ func displayProviderResults(results map[string]llm.ProviderResponse) error {
 		if result.Error != nil {
 			fmt.Printf("ERROR: %v\n", result.Error)
 		} else {
-			fmt.Println(result.Response)
+			if showThinking {
+				displayThinking(result.Thinking)
+			}
+			fmt.Println(renderText(result.Response, renderer))
 		}
 	}
 
@@ -88,7 +94,7 @@ func displayProviderResults(results map[string]llm.ProviderResponse) error {
 }
 
 // displayVerboseResult displays a verbose result for a single provider
-func displayVerboseResult(prompt string, modelFlag string, response string, elapsedTime time.Duration) error {
+func displayVerboseResult(prompt string, modelFlag string, response *llm.Response, elapsedTime time.Duration, showThinking bool, renderer *markdown.Renderer) error {
 	// Create a new tabwriter for formatted output with colors
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
@@ -112,7 +118,7 @@ func displayVerboseResult(prompt string, modelFlag string, response string, elap
 	}
 
 	// Format response for display (handle multiline)
-	responseText := response
+	responseText := response.Text
 	responseLines := strings.Split(responseText, "\n")
 	if len(responseLines) > 1 {
 		responseText = responseLines[0] + " [...]"
@@ -142,19 +148,152 @@ func displayVerboseResult(prompt string, modelFlag string, response string, elap
 		return fmt.Errorf("error flushing tabwriter: %w", err)
 	}
 
+	// Print token usage when the provider reported it
+	if usage := response.Usage; usage.Total() > 0 {
+		fmt.Printf("\nTokens: %d input, %d output", usage.InputTokens, usage.OutputTokens)
+		if usage.ThinkingTokens > 0 {
+			fmt.Printf(", %d thinking", usage.ThinkingTokens)
+		}
+		fmt.Println()
+	}
+
+	// Print why the model stopped and any elevated safety ratings
+	if response.FinishReason != "" {
+		fmt.Printf("Finish reason: %s\n", response.FinishReason)
+	}
+	displayMetadata(response.Metadata)
+	for _, rating := range response.SafetyRatings {
+		if rating.Probability != "NEGLIGIBLE" {
+			fmt.Printf("Safety: %s %s\n", rating.Category, rating.Probability)
+		}
+	}
+
+	// Print the reasoning before the answer if requested
+	if showThinking && response.Thinking != "" {
+		fmt.Println()
+		displayThinking(response.Thinking)
+	}
+
 	// Print full response after the table
 	fmt.Println("\nFull response:")
 	fmt.Println("-------------")
-	fmt.Println(response)
+	displayResponseText(response, renderer)
 
 	return nil
 }
 
+// displayMetadata prints the normalized stop reason and how the request was served
+func displayMetadata(metadata llm.Metadata) {
+	if metadata.StopReason != "" {
+		fmt.Printf("Stop reason: %s\n", metadata.StopReason)
+	}
+	if metadata.RequestID != "" {
+		fmt.Printf("Request ID: %s\n", metadata.RequestID)
+	}
+	if metadata.ModelVersion != "" {
+		fmt.Printf("Model version: %s\n", metadata.ModelVersion)
+	}
+	if !metadata.Created.IsZero() {
+		fmt.Printf("Created: %s\n", metadata.Created.Format(time.RFC3339))
+	}
+}
+
 // displaySimpleResult displays a simple result for a single provider
-func displaySimpleResult(response string, elapsedTime time.Duration) {
+func displaySimpleResult(response *llm.Response, elapsedTime time.Duration, showThinking bool, renderer *markdown.Renderer) {
 	// Print timing information
 	fmt.Printf("Time: %dms\n\n", elapsedTime.Milliseconds())
 
+	// Print the reasoning before the answer if requested
+	if showThinking {
+		displayThinking(response.Thinking)
+	}
+
 	// Print response
-	fmt.Println(response)
+	displayResponseText(response, renderer)
+}
+
+// displayMapReduceResult displays the combined answer of a chunked query with total usage and cost
+func displayMapReduceResult(result *llm.MapReduceResult, showThinking bool, renderer *markdown.Renderer) {
+	// Print timing, chunking and usage information
+	fmt.Printf("Time: %dms\n", result.ElapsedTime.Milliseconds())
+	fmt.Printf("Chunks: %d (%d requests)\n", result.Chunks, result.Calls)
+
+	usage := result.Response.Usage
+	fmt.Printf("Tokens: %d input, %d output", usage.InputTokens, usage.OutputTokens)
+	if usage.ThinkingTokens > 0 {
+		fmt.Printf(", %d thinking", usage.ThinkingTokens)
+	}
+	fmt.Println()
+	fmt.Printf("Estimated cost: $%.4f\n\n", result.Cost)
+
+	// Print the reasoning of the combine step if requested
+	if showThinking {
+		displayThinking(result.Response.Thinking)
+	}
+
+	// Print response
+	displayResponseText(result.Response, renderer)
+}
+
+// displayResponseText prints the response, listing every candidate when several were returned
+func displayResponseText(response *llm.Response, renderer *markdown.Renderer) {
+	if len(response.Candidates) <= 1 {
+		fmt.Println(renderText(response.Text, renderer))
+		return
+	}
+
+	for i, candidate := range response.Candidates {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("## Candidate %d (%s)\n\n", i+1, candidate.FinishReason)
+		fmt.Println(renderText(candidate.Text, renderer))
+	}
+}
+
+// newResponseRenderer returns the renderer for markdown responses, or nil
+// when they are printed as they are. In auto mode responses are rendered
+// when stdout is a terminal that supports colors.
+func newResponseRenderer(settings *querySettings) (*markdown.Renderer, error) {
+	theme, err := markdown.ThemeNamed(settings.Theme)
+	if err != nil {
+		return nil, fmt.Errorf("invalid render.theme: %w", err)
+	}
+
+	stdout := int(os.Stdout.Fd())
+	switch settings.RenderMode {
+	case config.RenderNever:
+		return nil, nil
+	case config.RenderAuto:
+		if !term.IsTerminal(stdout) || os.Getenv("TERM") == "dumb" || os.Getenv("NO_COLOR") != "" {
+			return nil, nil
+		}
+	}
+
+	// Wrap to the terminal, or to 80 columns when rendering into a pipe
+	width, _, err := term.GetSize(stdout)
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	return markdown.New(width, theme), nil
+}
+
+// renderText renders markdown text if a renderer is set
+func renderText(text string, renderer *markdown.Renderer) string {
+	if renderer == nil {
+		return text
+	}
+	return renderer.Render(text)
+}
+
+// displayThinking prints the model's reasoning dimmed so it stands apart from the answer
+func displayThinking(thinking string) {
+	if thinking == "" {
+		return
+	}
+
+	thinkingColor := color.New(color.Faint)
+	fmt.Println(thinkingColor.Sprint("Thinking:"))
+	fmt.Println(thinkingColor.Sprint(strings.TrimSpace(thinking)))
+	fmt.Println()
 }
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+var embedModelFlag string
+
+// embedding is one line of the embed command's output
+type embedding struct {
+	ID         string    `json:"id"`
+	Model      string    `json:"model"`
+	Dimensions int       `json:"dimensions"`
+	Embedding  []float32 `json:"embedding"`
+}
+
+// embedCmd represents the embed command
+var embedCmd = &cobra.Command{
+	Use:   "embed [file...]",
+	Short: "Turn text into embedding vectors",
+	Long: `Embed text with an embedding model and print one JSON object per line with the
+"id", "model", "dimensions" and "embedding" vector.
+
+Each file given is embedded as a whole and identified by its path. Without files,
+every non-empty line of stdin is embedded and identified by its line number.
+
+Embedding models are served by Google (text-embedding-004), OpenAI or any
+OpenAI-compatible API (set providers.openai.base_url) and a local Ollama server.
+List them with gollm models.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids, texts, err := readEmbedInput(args)
+		if err != nil {
+			return err
+		}
+		if len(texts) == 0 {
+			return fmt.Errorf("nothing to embed, give files or pipe lines of text")
+		}
+
+		// Load config
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		service, err := newEmbeddingService(cfg, &http.Client{Timeout: embeddingTimeout(cfg)})
+		if err != nil {
+			return err
+		}
+
+		model := embedModelFlag
+		if model == "" {
+			model, err = service.DefaultEmbeddingModel(context.Background())
+			if err != nil {
+				return err
+			}
+		}
+		info, _, ok := llm.GetEmbeddingModel(model)
+		if !ok {
+			return fmt.Errorf("unknown embedding model: %s. Run 'gollm models' to see available models", model)
+		}
+
+		vectors, err := service.Embed(context.Background(), model, texts)
+		if err != nil {
+			return err
+		}
+
+		// Write one JSON object per line
+		encoder := json.NewEncoder(os.Stdout)
+		for i, vector := range vectors {
+			if err := encoder.Encode(embedding{ID: ids[i], Model: model, Dimensions: len(vector), Embedding: vector}); err != nil {
+				return fmt.Errorf("error writing embedding: %w", err)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "Embedded %d texts with %s (%d dimensions)\n", len(vectors), model, info.Dimensions)
+		return nil
+	},
+}
+
+// readEmbedInput returns the texts to embed with their IDs: the content of
+// each file, or each non-empty line of stdin
+func readEmbedInput(files []string) ([]string, []string, error) {
+	var ids, texts []string
+
+	if len(files) > 0 {
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error reading %s: %w", file, err)
+			}
+			if strings.TrimSpace(string(content)) == "" {
+				return nil, nil, fmt.Errorf("%s is empty", file)
+			}
+			ids = append(ids, file)
+			texts = append(texts, string(content))
+		}
+		return ids, texts, nil
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		ids = append(ids, strconv.Itoa(lineNumber))
+		texts = append(texts, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading stdin: %w", err)
+	}
+	return ids, texts, nil
+}
+
+// newEmbeddingService creates a service with every provider that has an API
+// key, plus a local Ollama server, which needs none
+func newEmbeddingService(cfg *config.Config, httpClient *http.Client) (*llm.Service, error) {
+	apiKeys := make(map[string]string)
+	for _, provider := range llm.AllProviders() {
+		if !llm.UsesAPIKey(provider) {
+			continue
+		}
+		if apiKey := cfg.GetAPIKey(provider); apiKey != "" {
+			apiKeys[provider] = apiKey
+		}
+	}
+
+	settings, err := providerSettings(cfg, apiKeys)
+	if err != nil {
+		return nil, err
+	}
+	ollamaSettings, err := cfg.ProviderSettings("ollama")
+	if err != nil {
+		return nil, err
+	}
+	settings["ollama"] = ollamaSettings
+
+	return llm.NewServiceWithSettings(apiKeys, httpClient, settings), nil
+}
+
+func init() {
+	embedCmd.Flags().StringVarP(&embedModelFlag, "model", "m", "", "Embedding model (defaults to the first of Google and OpenAI with an API key, then a running Ollama server)")
+
+	rootCmd.AddCommand(embedCmd)
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/zerobang-dev/gollm/pkg/attach"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// readFileFlags collects the files named by --file patterns and returns them
+// as delimited blocks. Unless budget is false, they must fit in the model's
+// context window with room left for max tokens of output.
+func readFileFlags(patterns []string, model string, maxTokens int, budget bool) (string, error) {
+	files, skipped, err := attach.Collect(patterns)
+	for _, reason := range skipped {
+		fmt.Fprintf(os.Stderr, "Warning: skipped %s\n", reason)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	limit := 0
+	if window := llm.ContextWindow(model); budget && window > 0 {
+		limit = window - maxTokens
+	}
+	if _, err := attach.CheckBudget(files, model, limit); err != nil {
+		return "", fmt.Errorf("%w, use --chunk or fewer files", err)
+	}
+
+	return attach.Format(files), nil
+}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"github.com/zerobang-dev/gollm/pkg/git"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+	"github.com/zerobang-dev/gollm/pkg/logger"
+)
+
+var (
+	gitWriteFlag       bool
+	gitInstallHookFlag bool
+	gitQuietFlag       bool
+	gitStagedFlag      bool
+)
+
+// gitCmd groups the commands working on the git repository of the current directory
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Write commit messages and review changes in the current git repository",
+}
+
+// gitCommitMsgCmd represents the git commit-msg command
+var gitCommitMsgCmd = &cobra.Command{
+	Use:   "commit-msg",
+	Short: "Propose a Conventional Commits message for the staged changes",
+	Long: `Propose a commit message in the Conventional Commits format for the changes
+staged with git add.
+
+With --write the message is written to .git/COMMIT_EDITMSG, where git commit
+picks it up as the initial message in the editor. --install-hook installs a
+prepare-commit-msg hook doing this for every git commit without -m.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		if gitInstallHookFlag {
+			path, err := git.InstallHook(ctx, ".")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Installed %s, git commit will propose a message in the editor\n", path)
+			return nil
+		}
+
+		diff, stat, err := git.StagedDiff(ctx, ".")
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(diff) == "" {
+			return fmt.Errorf("no staged changes, stage them with git add")
+		}
+
+		return withGitService(cmd, func(ctx context.Context, service *llm.Service, model string, options []llm.Option) error {
+			stop := startGitSpinner(fmt.Sprintf(" Writing commit message with %s...", model))
+			message, err := git.CommitMessage(ctx, service, model, diff, stat, options...)
+			stop()
+			if err != nil {
+				return err
+			}
+			if !git.IsConventional(message) {
+				fmt.Fprintln(os.Stderr, "Warning: the message does not follow the Conventional Commits format")
+			}
+
+			if gitWriteFlag {
+				path, err := git.Path(ctx, ".", "COMMIT_EDITMSG")
+				if err != nil {
+					return err
+				}
+				if err := git.WriteMessage(path, message); err != nil {
+					return err
+				}
+			}
+			if !gitQuietFlag {
+				fmt.Println(message)
+			}
+			return nil
+		})
+	},
+}
+
+// gitReviewCmd represents the git review command
+var gitReviewCmd = &cobra.Command{
+	Use:   "review [range]",
+	Short: "Review changes hunk by hunk and report findings with file:line references",
+	Long: `Review a diff file by file, hunk by hunk, and print each finding as
+path:line: severity: message, with severities error, warning and suggestion.
+
+Without a range the uncommitted changes are reviewed, with --staged only the
+staged ones. A range is anything git diff accepts, such as main..feature or
+HEAD~3..HEAD, and a single revision such as a commit hash reviews the changes
+made by that commit.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		revisions := ""
+		if len(args) == 1 {
+			revisions = args[0]
+		}
+		if revisions != "" && gitStagedFlag {
+			return fmt.Errorf("--staged cannot be combined with a range")
+		}
+
+		diff, err := git.RangeDiff(context.Background(), ".", revisions, gitStagedFlag)
+		if err != nil {
+			return err
+		}
+		files, err := git.ParseDiff(diff)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no changes to review")
+		}
+
+		return withGitService(cmd, func(ctx context.Context, service *llm.Service, model string, options []llm.Option) error {
+			startTime := time.Now()
+			review, err := git.ReviewDiff(ctx, service, model, files, func(path string) {
+				fmt.Fprintf(os.Stderr, "Reviewing %s...\n", path)
+			}, options...)
+			if err != nil {
+				return err
+			}
+
+			for _, finding := range review.Findings {
+				fmt.Println(finding)
+			}
+			for _, skipped := range review.Skipped {
+				fmt.Fprintf(os.Stderr, "Skipped %s (%s)\n", skipped.Path, skipped.Reason)
+			}
+			fmt.Fprintf(os.Stderr, "%d findings in %d files reviewed with %s (%d input, %d output tokens, %.2fs)\n",
+				len(review.Findings), review.Files, model, review.Usage.InputTokens, review.Usage.OutputTokens, time.Since(startTime).Seconds())
+			return nil
+		})
+	},
+}
+
+// withGitService runs fn with a service for the configured model, the
+// options for its queries and the query log
+func withGitService(cmd *cobra.Command, fn func(ctx context.Context, service *llm.Service, model string, options []llm.Option) error) error {
+	// Load config
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Resolve model, system prompt and max tokens from flags, env and config
+	settings, err := resolveQuerySettings(cfg, flagOverrides(cmd))
+	if err != nil {
+		return err
+	}
+
+	// Initialize logger
+	queryLogger, err := logger.NewLogger(config.GetConfigDir())
+	if err != nil {
+		// Just log a warning but continue without logging
+		fmt.Fprintf(os.Stderr, "Warning: Query logging disabled - %v\n", err)
+	} else {
+		defer func() {
+			if err := queryLogger.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing logger: %v\n", err)
+			}
+		}()
+	}
+
+	timeout := queryTimeout(cfg, settings.Model, false)
+	service, _, err := newModelService(settings.Model, cfg, &http.Client{Timeout: timeout}, queryLogger)
+	if err != nil {
+		return err
+	}
+
+	options := []llm.Option{llm.WithMaxTokens(settings.MaxTokens)}
+	if llm.SupportsSampling(settings.Model) {
+		options = append(options, llm.WithTemperature(settings.Temperature))
+	}
+	if settings.SystemPrompt != "" {
+		options = append(options, llm.WithCustomParam("system", settings.SystemPrompt))
+	}
+
+	// Reviews send a request per file, so only each request is limited
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	return fn(ctx, service, settings.Model, options)
+}
+
+// startGitSpinner shows a spinner unless output is quiet, returning a function stopping it
+func startGitSpinner(suffix string) func() {
+	if gitQuietFlag {
+		return func() {}
+	}
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+	s.Suffix = suffix
+	s.Start()
+	return s.Stop
+}
+
+func init() {
+	gitCommitMsgCmd.Flags().BoolVar(&gitWriteFlag, "write", false, "Write the message to .git/COMMIT_EDITMSG for git commit to use")
+	gitCommitMsgCmd.Flags().BoolVar(&gitInstallHookFlag, "install-hook", false, "Install a prepare-commit-msg hook proposing a message for every commit")
+	gitCommitMsgCmd.Flags().BoolVarP(&gitQuietFlag, "quiet", "q", false, "Do not print the message or progress, for use with --write in hooks")
+	gitReviewCmd.Flags().BoolVar(&gitStagedFlag, "staged", false, "Review only the staged changes")
+
+	gitCmd.AddCommand(gitCommitMsgCmd)
+	gitCmd.AddCommand(gitReviewCmd)
+	rootCmd.AddCommand(gitCmd)
+}
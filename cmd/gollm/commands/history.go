@@ -12,9 +12,10 @@ import (
 )
 
 var (
-	historyLimitFlag  int
-	historyDetailFlag bool
-	historySearchFlag string
+	historyLimitFlag    int
+	historyDetailFlag   bool
+	historySearchFlag   string
+	historyCommandsFlag bool
 )
 
 // historyCmd represents the history command
@@ -36,6 +37,10 @@ var historyCmd = &cobra.Command{
 			}
 		}()
 
+		if historyCommandsFlag {
+			return printCommandHistory(queryLogger)
+		}
+
 		var queries []logger.Query
 
 		// Get queries - either search or recent
@@ -98,6 +103,15 @@ var historyCmd = &cobra.Command{
 			fmt.Printf("Model: %s\n", latest.Model)
 			fmt.Printf("Duration: %dms\n", latest.Duration)
 			fmt.Printf("Temperature: %.2f\n", latest.Temperature)
+			if latest.Metadata.StopReason != "" {
+				fmt.Printf("Stop reason: %s\n", latest.Metadata.StopReason)
+			}
+			if latest.Metadata.ModelVersion != "" {
+				fmt.Printf("Model version: %s\n", latest.Metadata.ModelVersion)
+			}
+			if latest.Metadata.RequestID != "" {
+				fmt.Printf("Request ID: %s\n", latest.Metadata.RequestID)
+			}
 
 			fmt.Println("\nPrompt:")
 			fmt.Println(latest.Prompt)
@@ -110,10 +124,50 @@ var historyCmd = &cobra.Command{
 	},
 }
 
+// printCommandHistory displays the shell commands suggested with gollm cmd
+func printCommandHistory(queryLogger *logger.Logger) error {
+	commands, err := queryLogger.GetRecentCommands(historyLimitFlag)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve command history: %w", err)
+	}
+	if len(commands) == 0 {
+		fmt.Println("No command history found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "TIME\tMODEL\tOUTCOME\tCOMMAND"); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	if _, err := fmt.Fprintln(w, "----\t-----\t-------\t-------"); err != nil {
+		return fmt.Errorf("failed to write separator: %w", err)
+	}
+
+	for _, c := range commands {
+		outcome := "not run"
+		switch {
+		case c.Error != "":
+			outcome = "failed to start"
+		case c.Executed:
+			outcome = fmt.Sprintf("exit %d", c.ExitCode)
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			c.Timestamp.Format("2006-01-02 15:04:05"), c.Model, outcome, truncateString(c.Command, 50)); err != nil {
+			return fmt.Errorf("failed to write row: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush writer: %w", err)
+	}
+	return nil
+}
+
 func init() {
 	historyCmd.Flags().IntVarP(&historyLimitFlag, "limit", "l", 10, "Number of queries to show")
 	historyCmd.Flags().BoolVarP(&historyDetailFlag, "detail", "d", false, "Show detailed view of the most recent query")
 	historyCmd.Flags().StringVarP(&historySearchFlag, "search", "s", "", "Search for queries containing text")
+	historyCmd.Flags().BoolVar(&historyCommandsFlag, "commands", false, "Show shell commands suggested with gollm cmd and their outcome")
 
 	rootCmd.AddCommand(historyCmd)
 }
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+	"github.com/zerobang-dev/gollm/pkg/rag"
+)
+
+var (
+	indexNameFlag    string
+	indexModelFlag   string
+	indexRebuildFlag bool
+)
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index <dir>",
+	Short: "Index a directory of documents for gollm ask",
+	Long: `Split the text, markdown and code files of a directory into chunks, embed them
+and store the vectors in an index in the config directory, to answer questions
+about them with gollm ask --index <name>.
+
+Running it again only embeds files that changed since the last run and drops
+files that were removed. Hidden files, dependency directories such as
+node_modules and vendor, binary files and files over 1 MB are skipped.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := filepath.Abs(args[0])
+		if err != nil {
+			return fmt.Errorf("error resolving %s: %w", args[0], err)
+		}
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", args[0])
+		}
+
+		name := indexNameFlag
+		if name == "" {
+			name = filepath.Base(root)
+		}
+		path, err := rag.Path(config.GetConfigDir(), name)
+		if err != nil {
+			return err
+		}
+
+		if indexRebuildFlag {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error removing index: %w", err)
+			}
+		}
+
+		// Load config
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		index, err := rag.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := index.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing index: %v\n", err)
+			}
+		}()
+
+		service, err := newEmbeddingService(cfg, &http.Client{Timeout: embeddingTimeout(cfg)})
+		if err != nil {
+			return err
+		}
+
+		// Keep the model the index was built with unless another one is asked for
+		model := indexModelFlag
+		if model == "" {
+			if model, err = index.Setting("model"); err != nil {
+				return err
+			}
+		}
+		if model == "" {
+			if model, err = service.DefaultEmbeddingModel(context.Background()); err != nil {
+				return err
+			}
+		}
+		if _, _, ok := llm.GetEmbeddingModel(model); !ok {
+			return fmt.Errorf("unknown embedding model: %s. Run 'gollm models' to see available models", model)
+		}
+
+		startTime := time.Now()
+		stats, err := index.Update(context.Background(), service, root, model, func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\rEmbedded %d/%d files", done, total)
+		})
+		if stats.Indexed > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+		if err != nil {
+			return fmt.Errorf("error indexing %s: %w", root, err)
+		}
+
+		fmt.Printf("Index %s: %d files, %d embedded in %d chunks, %d unchanged, %d removed (%s, %.2fs)\n",
+			name, stats.Files, stats.Indexed, stats.Chunks, stats.Unchanged, stats.Removed, model, time.Since(startTime).Seconds())
+		fmt.Printf("Ask with: gollm ask --index %s \"question\"\n", name)
+		return nil
+	},
+}
+
+func init() {
+	indexCmd.Flags().StringVar(&indexNameFlag, "name", "", "Name of the index (defaults to the directory name)")
+	indexCmd.Flags().StringVarP(&indexModelFlag, "model", "m", "", "Embedding model (defaults to the model the index was built with, then the first of Google and OpenAI with an API key, then a running Ollama server)")
+	indexCmd.Flags().BoolVar(&indexRebuildFlag, "rebuild", false, "Embed every file again instead of only changed ones")
+
+	rootCmd.AddCommand(indexCmd)
+}
@@ -0,0 +1,367 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/batch"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+	"github.com/zerobang-dev/gollm/pkg/logger"
+)
+
+var (
+	jobsTemplateFlag string
+	jobsLimitFlag    int
+	jobsOutputFlag   string
+)
+
+// jobsCmd represents the jobs command
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Manage provider batch jobs",
+	Long: `Submit prompts to a provider's batch API and collect the results later.
+
+Batch jobs are answered asynchronously, usually within minutes and at most within
+24 hours, at half the standard price. They are supported by Anthropic (Message
+Batches) and Gemini (batch mode). Submitted jobs are recorded in the query history
+database so they can be listed and checked later.`,
+}
+
+// jobsSubmitCmd represents the jobs submit command
+var jobsSubmitCmd = &cobra.Command{
+	Use:   "submit <input.jsonl>",
+	Short: "Submit a JSONL file of prompts as a batch job",
+	Long: `Submit every prompt in a JSONL file as a batch job to the provider of the model.
+
+The input has the same format as for gollm batch: each line is an object with a
+"prompt", or "vars" to render the --template with, and optionally an "id",
+"system", "temperature" and "max_tokens". All items use the same model.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Read the items
+		input, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("error opening batch input: %w", err)
+		}
+		items, err := batch.ReadItems(input)
+		if closeErr := input.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error closing batch input: %w", closeErr)
+		}
+		if err != nil {
+			return err
+		}
+
+		var tmpl *template.Template
+		if jobsTemplateFlag != "" {
+			tmpl, err = template.New("prompt").Option("missingkey=error").Parse(jobsTemplateFlag)
+			if err != nil {
+				return fmt.Errorf("error parsing template: %w", err)
+			}
+		}
+
+		// Load config
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		// Resolve the defaults for items from flags, env and config
+		settings, err := resolveQuerySettings(cfg, flagOverrides(cmd))
+		if err != nil {
+			return err
+		}
+
+		options := []llm.Option{llm.WithMaxTokens(settings.MaxTokens)}
+		if settings.SystemPrompt != "" {
+			options = append(options, llm.WithCustomParam("system", settings.SystemPrompt))
+		}
+
+		// Build the requests like gollm batch would send them
+		runner := &batch.Runner{
+			Model:       settings.Model,
+			Temperature: settings.Temperature,
+			Options:     options,
+			Template:    tmpl,
+		}
+		requests, err := runner.BatchRequests(items)
+		if err != nil {
+			return err
+		}
+
+		jobLogger, err := logger.NewLogger(config.GetConfigDir())
+		if err != nil {
+			return fmt.Errorf("failed to initialize logger: %w", err)
+		}
+		defer func() {
+			if err := jobLogger.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing logger: %v\n", err)
+			}
+		}()
+
+		service, _, err := newModelService(settings.Model, cfg, &http.Client{Timeout: queryTimeout(cfg, settings.Model, false)}, nil)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(cfg, settings.Model, false))
+		defer cancel()
+
+		job, err := service.SubmitBatch(ctx, settings.Model, requests)
+		if err != nil {
+			return fmt.Errorf("error submitting batch job: %w", err)
+		}
+
+		// Record the job so it can be checked later
+		if err := jobLogger.SaveJob(logger.Job{
+			ID:        job.ID,
+			Provider:  job.Provider,
+			Model:     job.Model,
+			Status:    job.Status,
+			Requests:  len(requests),
+			Input:     args[0],
+			CreatedAt: job.CreatedAt,
+		}); err != nil {
+			return fmt.Errorf("job %s was submitted but could not be recorded: %w", job.ID, err)
+		}
+
+		fmt.Printf("Submitted job %s with %d requests to %s\n", job.ID, len(requests), job.Model)
+		fmt.Printf("Check it with: gollm jobs status %s\n", job.ID)
+		return nil
+	},
+}
+
+// jobsListCmd represents the jobs list command
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List submitted batch jobs",
+	Long:  `List the batch jobs submitted with gollm and their status when last checked.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobLogger, err := logger.NewLogger(config.GetConfigDir())
+		if err != nil {
+			return fmt.Errorf("failed to initialize logger: %w", err)
+		}
+		defer func() {
+			if err := jobLogger.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing logger: %v\n", err)
+			}
+		}()
+
+		jobs, err := jobLogger.ListJobs(jobsLimitFlag)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve jobs: %w", err)
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No batch jobs found.")
+			return nil
+		}
+
+		// Create a tabwriter for clean columnar output
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if _, err := fmt.Fprintln(w, "ID\tPROVIDER\tMODEL\tREQUESTS\tSTATUS\tSUBMITTED"); err != nil {
+			return fmt.Errorf("error writing header: %w", err)
+		}
+		for _, job := range jobs {
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+				job.ID, job.Provider, job.Model, job.Requests, job.Status, job.CreatedAt.Local().Format("2006-01-02 15:04")); err != nil {
+				return fmt.Errorf("error writing job data: %w", err)
+			}
+		}
+
+		// Flush the tabwriter
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("error flushing tabwriter: %w", err)
+		}
+		return nil
+	},
+}
+
+// jobsStatusCmd represents the jobs status command
+var jobsStatusCmd = &cobra.Command{
+	Use:   "status <job-id>",
+	Short: "Check the progress of a batch job",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withJob(args[0], func(ctx context.Context, service *llm.Service, jobLogger *logger.Logger, record *logger.Job) error {
+			job, err := service.GetBatch(ctx, record.Provider, record.ID)
+			if err != nil {
+				return fmt.Errorf("error checking batch job: %w", err)
+			}
+			if err := jobLogger.UpdateJobStatus(record.ID, job.Status); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+
+			job.Model = record.Model
+			displayBatchJob(job)
+			if job.Status == llm.BatchStatusEnded || job.Status == llm.BatchStatusCanceled || job.Status == llm.BatchStatusExpired {
+				fmt.Printf("\nDownload the results with: gollm jobs results %s -o results.jsonl\n", job.ID)
+			}
+			return nil
+		})
+	},
+}
+
+// jobsResultsCmd represents the jobs results command
+var jobsResultsCmd = &cobra.Command{
+	Use:   "results <job-id>",
+	Short: "Download the results of a batch job",
+	Long: `Download the results of a batch job that has ended as JSONL, in the same format
+as gollm batch writes. Failed, cancelled and expired requests are recorded with an
+"error".`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withJob(args[0], func(ctx context.Context, service *llm.Service, jobLogger *logger.Logger, record *logger.Job) error {
+			results, err := service.BatchResults(ctx, record.Provider, record.ID)
+			if err != nil {
+				return fmt.Errorf("error downloading results: %w", err)
+			}
+
+			// Write to the output file or stdout
+			out := os.Stdout
+			if jobsOutputFlag != "" {
+				out, err = os.Create(jobsOutputFlag)
+				if err != nil {
+					return fmt.Errorf("error creating output file: %w", err)
+				}
+				defer func() {
+					if err := out.Close(); err != nil {
+						fmt.Fprintf(os.Stderr, "Error closing output file: %v\n", err)
+					}
+				}()
+			}
+
+			summary := batch.Summary{Total: len(results)}
+			encoder := json.NewEncoder(out)
+			for _, result := range results {
+				line := batch.BatchResult(record.Model, result)
+				if err := encoder.Encode(line); err != nil {
+					return fmt.Errorf("error writing results: %w", err)
+				}
+
+				if result.Response == nil {
+					summary.Failed++
+					continue
+				}
+				summary.Succeeded++
+				summary.Usage.InputTokens += result.Response.Usage.InputTokens
+				summary.Usage.OutputTokens += result.Response.Usage.OutputTokens
+				summary.Usage.ThinkingTokens += result.Response.Usage.ThinkingTokens
+				summary.Cost += line.Cost
+			}
+
+			fmt.Fprintf(os.Stderr, "%d results, %d succeeded, %d failed, %d input and %d output tokens, estimated cost $%.4f\n",
+				summary.Total, summary.Succeeded, summary.Failed,
+				summary.Usage.InputTokens, summary.Usage.OutputTokens+summary.Usage.ThinkingTokens, summary.Cost)
+			return nil
+		})
+	},
+}
+
+// jobsCancelCmd represents the jobs cancel command
+var jobsCancelCmd = &cobra.Command{
+	Use:   "cancel <job-id>",
+	Short: "Cancel a batch job",
+	Long:  `Cancel a batch job. Requests already answered keep their results, which can still be downloaded.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withJob(args[0], func(ctx context.Context, service *llm.Service, jobLogger *logger.Logger, record *logger.Job) error {
+			job, err := service.CancelBatch(ctx, record.Provider, record.ID)
+			if err != nil {
+				return fmt.Errorf("error cancelling batch job: %w", err)
+			}
+			if err := jobLogger.UpdateJobStatus(record.ID, job.Status); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+
+			fmt.Printf("Job %s is %s\n", job.ID, job.Status)
+			return nil
+		})
+	},
+}
+
+// withJob looks up a recorded batch job and calls fn with a service for its provider
+func withJob(id string, fn func(ctx context.Context, service *llm.Service, jobLogger *logger.Logger, record *logger.Job) error) error {
+	jobLogger, err := logger.NewLogger(config.GetConfigDir())
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer func() {
+		if err := jobLogger.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing logger: %v\n", err)
+		}
+	}()
+
+	record, err := jobLogger.GetJob(id)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve job: %w", err)
+	}
+	if record == nil {
+		return fmt.Errorf("job %s not found, see gollm jobs list for submitted jobs", id)
+	}
+
+	// Load config
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	timeout := queryTimeout(cfg, record.Model, false)
+	service, _, err := newModelService(record.Model, cfg, &http.Client{Timeout: timeout}, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return fn(ctx, service, jobLogger, record)
+}
+
+// displayBatchJob prints the state of a batch job
+func displayBatchJob(job *llm.BatchJob) {
+	counts := job.Counts
+
+	fmt.Printf("Job:        %s\n", job.ID)
+	fmt.Printf("Provider:   %s\n", job.Provider)
+	fmt.Printf("Model:      %s\n", job.Model)
+	fmt.Printf("Status:     %s\n", job.Status)
+	fmt.Printf("Requests:   %d (%d succeeded, %d errored, %d processing", counts.Total(), counts.Succeeded, counts.Errored, counts.Processing)
+	if counts.Canceled > 0 {
+		fmt.Printf(", %d canceled", counts.Canceled)
+	}
+	if counts.Expired > 0 {
+		fmt.Printf(", %d expired", counts.Expired)
+	}
+	fmt.Println(")")
+	if !job.CreatedAt.IsZero() {
+		fmt.Printf("Submitted:  %s\n", job.CreatedAt.Local().Format(time.RFC1123))
+	}
+	if !job.EndedAt.IsZero() {
+		fmt.Printf("Ended:      %s\n", job.EndedAt.Local().Format(time.RFC1123))
+	}
+}
+
+func init() {
+	jobsSubmitCmd.Flags().StringVar(&jobsTemplateFlag, "template", "", "Prompt template rendered with the vars of each item, e.g. 'Summarize: {{.text}}'")
+	addConfigFlag(jobsSubmitCmd, "system", "System prompt for items that do not set one")
+	addConfigFlag(jobsSubmitCmd, "temperature", "Temperature for items that do not set one")
+	addConfigFlag(jobsSubmitCmd, "max-tokens", "Maximum tokens per response")
+	jobsListCmd.Flags().IntVarP(&jobsLimitFlag, "limit", "l", 20, "Number of jobs to show")
+	jobsResultsCmd.Flags().StringVarP(&jobsOutputFlag, "output", "o", "", "JSONL file to write results to (defaults to stdout)")
+
+	jobsCmd.AddCommand(jobsSubmitCmd)
+	jobsCmd.AddCommand(jobsListCmd)
+	jobsCmd.AddCommand(jobsStatusCmd)
+	jobsCmd.AddCommand(jobsResultsCmd)
+	jobsCmd.AddCommand(jobsCancelCmd)
+
+	rootCmd.AddCommand(jobsCmd)
+}
@@ -14,16 +14,16 @@ import (
 var listModelsCmd = &cobra.Command{
 	Use:   "models",
 	Short: "List all supported models",
-	Long:  `Display a list of all supported LLM models organized by provider.`,
+	Long:  `Display a list of all supported LLM models organized by provider, followed by the embedding models.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Create a tabwriter for clean columnar output
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
 		// Add header
-		if _, err := fmt.Fprintln(w, "PROVIDER\tMODEL"); err != nil {
+		if _, err := fmt.Fprintln(w, "PROVIDER\tMODEL\tCONTEXT"); err != nil {
 			return fmt.Errorf("error writing header: %w", err)
 		}
-		if _, err := fmt.Fprintln(w, "--------\t-----"); err != nil {
+		if _, err := fmt.Fprintln(w, "--------\t-----\t-------"); err != nil {
 			return fmt.Errorf("error writing header: %w", err)
 		}
 
@@ -45,7 +45,13 @@ var listModelsCmd = &cobra.Command{
 
 			// Print each model
 			for _, model := range models {
-				if _, err := fmt.Fprintf(w, "%s\t%s\n", provider, model); err != nil {
+				// Show the context window in tokens when it is known
+				contextWindow := "-"
+				if tokens := llm.ContextWindow(model); tokens > 0 {
+					contextWindow = fmt.Sprintf("%d", tokens)
+				}
+
+				if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", provider, model, contextWindow); err != nil {
 					return fmt.Errorf("error writing model data: %w", err)
 				}
 			}
@@ -56,6 +62,28 @@ var listModelsCmd = &cobra.Command{
 			return fmt.Errorf("error flushing tabwriter: %w", err)
 		}
 
+		// Embedding models follow in their own table
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if _, err := fmt.Fprintln(w, "PROVIDER\tEMBEDDING MODEL\tDIMENSIONS"); err != nil {
+			return fmt.Errorf("error writing header: %w", err)
+		}
+		if _, err := fmt.Fprintln(w, "--------\t---------------\t----------"); err != nil {
+			return fmt.Errorf("error writing header: %w", err)
+		}
+		embeddingProviders := llm.AllProviders()
+		sort.Strings(embeddingProviders)
+		for _, provider := range embeddingProviders {
+			for _, model := range llm.EmbeddingModelsForProvider(provider) {
+				if _, err := fmt.Fprintf(w, "%s\t%s\t%d\n", provider, model.Name, model.Dimensions); err != nil {
+					return fmt.Errorf("error writing model data: %w", err)
+				}
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("error flushing tabwriter: %w", err)
+		}
+
 		return nil
 	},
 }
@@ -1,10 +1,15 @@
 package commands
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -12,48 +17,172 @@ import (
 	"github.com/zerobang-dev/gollm/pkg/config"
 	"github.com/zerobang-dev/gollm/pkg/llm"
 	"github.com/zerobang-dev/gollm/pkg/logger"
+	"github.com/zerobang-dev/gollm/pkg/markdown"
+	"golang.org/x/term"
 )
 
-// readPromptFromArgs reads the prompt from command arguments or stdin
-func readPromptFromArgs(cmd *cobra.Command, args []string) (string, error) {
-	if len(args) == 1 {
-		return args[0], nil
-	}
+// maxStdinBytes caps the input read from stdin
+const maxStdinBytes = 32 << 20
 
-	// Read from stdin
-	buffer := make([]byte, 0, 4096)
-	temp := make([]byte, 1024)
-	stdin := cmd.InOrStdin()
+// readPromptFromArgs reads the prompt from the argument, stdin or both. Piped
+// input given along with an argument is placed before or after it between
+// delimiter lines, so "git diff | gollm 'review this'" sends both.
+func readPromptFromArgs(cmd *cobra.Command, args []string, settings *querySettings) (string, error) {
+	input, err := readStdin(cmd.InOrStdin())
+	if err != nil {
+		return "", err
+	}
 
-	for {
-		n, err := stdin.Read(temp)
-		if n > 0 {
-			buffer = append(buffer, temp[:n]...)
-		}
-		if err != nil || n < len(temp) {
-			break
+	if len(args) == 1 {
+		if input == "" {
+			return args[0], nil
 		}
+		return combinePrompt(args[0], input, settings.StdinPlacement, settings.StdinDelimiter), nil
 	}
 
-	if len(buffer) == 0 {
+	if input == "" {
 		return "", fmt.Errorf("no input provided via argument or stdin")
 	}
+	return input, nil
+}
+
+// readStdin reads stdin to EOF, returning "" without waiting when it is not
+// piped input, such as a terminal, a socket or a device that may never end
+func readStdin(stdin io.Reader) (string, error) {
+	if file, ok := stdin.(*os.File); ok && !isPipedInput(file) {
+		return "", nil
+	}
 
-	return string(buffer), nil
+	data, err := io.ReadAll(io.LimitReader(stdin, maxStdinBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("error reading stdin: %w", err)
+	}
+	if len(data) > maxStdinBytes {
+		return "", fmt.Errorf("input on stdin is larger than %d MB", maxStdinBytes>>20)
+	}
+	return string(data), nil
 }
 
-// queryLLM sends a prompt to an LLM and returns the response
-func queryLLM(ctx context.Context, prompt string, modelFlag string, systemPromptFlag string, temperatureFlag float64, queryAllFlag bool) (interface{}, error) {
-	// Load config
-	cfg, err := config.Load()
+// isPipedInput reports whether a file is a pipe or a regular file redirected to stdin
+func isPipedInput(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular()
+}
+
+// combinePrompt places the input before or after the prompt, wrapped in delimiter lines
+func combinePrompt(prompt, input, placement, delimiter string) string {
+	block := delimiter + "\n" + strings.TrimRight(input, "\n") + "\n" + delimiter
+	if placement == config.StdinBefore {
+		return block + "\n\n" + prompt
+	}
+	return prompt + "\n\n" + block
+}
+
+// querySettings holds the effective settings for a query after applying flags, environment and config
+type querySettings struct {
+	Model        string
+	SystemPrompt string
+	Temperature  float64
+	MaxTokens    int
+
+	// TemperatureSource records where the temperature came from, so reasoning
+	// models can skip the default while still rejecting an explicit flag
+	TemperatureSource config.Source
+
+	// ThinkingBudget enables extended thinking on models that support it
+	ThinkingBudget int
+
+	// StdinPlacement and StdinDelimiter control how piped input is
+	// combined with a prompt argument
+	StdinPlacement string
+	StdinDelimiter string
+
+	// RenderMode and Theme control how markdown responses are rendered in the terminal
+	RenderMode string
+	Theme      string
+
+	// CandidateCount requests alternative responses on models that support it
+	CandidateCount int
+
+	// Prefill starts the answer, StopSequences end it
+	Prefill       string
+	StopSequences []string
+
+	// Chunk splits Input into chunks of ChunkTokens tokens, 0 for the
+	// largest that fit, and applies the prompt to each
+	Chunk       bool
+	ChunkTokens int
+	Input       string
+
+	// AutoContinue is the number of times a truncated answer is continued automatically
+	AutoContinue int
+
+	// History holds earlier turns of the conversation, used to continue a truncated answer
+	History []llm.Message
+}
+
+// queryResult holds the response of a single provider query
+type queryResult struct {
+	Response    *llm.Response
+	ElapsedTime time.Duration
+}
+
+// resolveQuerySettings resolves the query settings from flags, environment variables and config
+func resolveQuerySettings(cfg *config.Config, flags map[string]string) (*querySettings, error) {
+	values := make(map[string]string)
+	sources := make(map[string]config.Source)
+	for _, name := range []string{"default_model", "system_prompt", "temperature", "max_tokens", "stdin.placement", "stdin.delimiter", "render.mode", "render.theme"} {
+		setting, err := cfg.Lookup(name, flags)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = setting.Value
+		sources[name] = setting.Source
+	}
+
+	temperature, err := strconv.ParseFloat(values["temperature"], 64)
 	if err != nil {
-		return nil, fmt.Errorf("error loading config: %w", err)
+		return nil, fmt.Errorf("invalid temperature: %s", values["temperature"])
+	}
+
+	maxTokens, err := strconv.Atoi(values["max_tokens"])
+	if err != nil || maxTokens <= 0 {
+		return nil, fmt.Errorf("invalid max_tokens: %s", values["max_tokens"])
+	}
+
+	placement := values["stdin.placement"]
+	if placement != config.StdinBefore && placement != config.StdinAfter {
+		return nil, fmt.Errorf("invalid stdin.placement: %s, use %s or %s", placement, config.StdinBefore, config.StdinAfter)
+	}
+
+	renderMode := values["render.mode"]
+	if renderMode != config.RenderAuto && renderMode != config.RenderAlways && renderMode != config.RenderNever {
+		return nil, fmt.Errorf("invalid render.mode: %s, use %s, %s or %s", renderMode, config.RenderAuto, config.RenderAlways, config.RenderNever)
 	}
 
+	return &querySettings{
+		Model:          values["default_model"],
+		SystemPrompt:   values["system_prompt"],
+		Temperature:    temperature,
+		MaxTokens:      maxTokens,
+		StdinPlacement: placement,
+		StdinDelimiter: values["stdin.delimiter"],
+		RenderMode:     renderMode,
+		Theme:          values["render.theme"],
+
+		TemperatureSource: sources["temperature"],
+	}, nil
+}
+
+// queryLLM sends a prompt to an LLM and returns the response
+func queryLLM(ctx context.Context, prompt string, cfg *config.Config, settings *querySettings, queryAllFlag bool) (interface{}, error) {
 	// Initialize logger
 	var queryLogger *logger.Logger
 	configDir := config.GetConfigDir()
-	queryLogger, err = logger.NewLogger(configDir)
+	queryLogger, err := logger.NewLogger(configDir)
 	if err != nil {
 		// Just log a warning but continue without logging
 		fmt.Printf("Warning: Query logging disabled - %v\n", err)
@@ -66,16 +195,60 @@ func queryLLM(ctx context.Context, prompt string, modelFlag string, systemPrompt
 
 	// Set up options
 	options := []llm.Option{
-		llm.WithMaxTokens(1000),
-		llm.WithTemperature(temperatureFlag),
+		llm.WithMaxTokens(settings.MaxTokens),
+	}
+
+	// Reasoning models reject temperature, so only pass it to them when set with --temperature
+	if queryAllFlag || llm.SupportsSampling(settings.Model) || settings.TemperatureSource == config.SourceFlag {
+		options = append(options, llm.WithTemperature(settings.Temperature))
 	}
 
 	// If system prompt is provided, add it as a custom parameter
-	if systemPromptFlag != "" {
-		options = append(options, llm.WithCustomParam("system", systemPromptFlag))
+	if settings.SystemPrompt != "" {
+		options = append(options, llm.WithCustomParam("system", settings.SystemPrompt))
+	}
+
+	// Enable extended thinking if a budget is set
+	if settings.ThinkingBudget > 0 {
+		if provider, _ := llm.GetProviderForModel(settings.Model); !queryAllFlag && provider != "anthropic" {
+			return nil, fmt.Errorf("extended thinking is not supported by %s", settings.Model)
+		}
+		options = append(options, llm.WithThinking(settings.ThinkingBudget))
+	}
+
+	// Request alternative responses if more than one is wanted
+	if settings.CandidateCount > 1 {
+		if queryAllFlag {
+			return nil, fmt.Errorf("multiple candidates cannot be combined with --all, only Gemini models support them")
+		}
+		if provider, _ := llm.GetProviderForModel(settings.Model); provider != "google" {
+			return nil, fmt.Errorf("multiple candidates are not supported by %s", settings.Model)
+		}
+		options = append(options, llm.WithCandidateCount(settings.CandidateCount))
+	}
+
+	// Start the answer with the prefill, which Gemini cannot continue
+	if settings.Prefill != "" {
+		if provider, _ := llm.GetProviderForModel(settings.Model); !queryAllFlag && provider == "google" {
+			return nil, fmt.Errorf("prefilling the response is not supported by %s", settings.Model)
+		}
+		options = append(options, llm.WithPrefill(settings.Prefill))
+	}
+
+	// Stop generation at any of the stop sequences
+	if len(settings.StopSequences) > 0 {
+		options = append(options, llm.WithStopSequences(settings.StopSequences...))
+	}
+
+	// Continue answers cut off at the token limit without asking
+	if settings.AutoContinue > 0 {
+		options = append(options, llm.WithAutoContinue(settings.AutoContinue))
 	}
 
-	// Initialize API keys map - not used directly here
+	// Continue a conversation if there are earlier turns
+	if len(settings.History) > 0 {
+		options = append(options, llm.WithHistory(settings.History))
+	}
 
 	// Close logger when function returns
 	if queryLogger != nil {
@@ -86,17 +259,47 @@ func queryLLM(ctx context.Context, prompt string, modelFlag string, systemPrompt
 		}()
 	}
 
+	// Process oversized input in chunks with a single model
+	if settings.Chunk {
+		if queryAllFlag {
+			return nil, fmt.Errorf("--chunk cannot be combined with --all")
+		}
+		return queryChunked(ctx, prompt, settings, cfg, httpClient, options, queryLogger)
+	}
+
 	if queryAllFlag {
 		// Query all providers flag is set
 		return queryAllProviders(ctx, prompt, cfg, httpClient, options, queryLogger)
 	} else {
 		// Regular single provider query
-		return querySingleProvider(ctx, prompt, modelFlag, cfg, httpClient, options, queryLogger)
+		return querySingleProvider(ctx, prompt, settings.Model, cfg, httpClient, options, queryLogger)
 	}
 }
 
 // queryAllProviders queries all available providers and returns results
 func queryAllProviders(ctx context.Context, prompt string, cfg *config.Config, httpClient *http.Client, options []llm.Option, queryLogger *logger.Logger) (map[string]llm.ProviderResponse, error) {
+	service, err := newConfiguredService(cfg, httpClient, queryLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and start spinner
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Suffix = " Querying all configured providers..."
+	s.Start()
+
+	// Query all providers
+	results := service.QueryAll(ctx, prompt, options...)
+
+	// Stop spinner
+	s.Stop()
+
+	return results, nil
+}
+
+// newConfiguredService creates a service for every provider with an API key
+// and its connection settings
+func newConfiguredService(cfg *config.Config, httpClient *http.Client, queryLogger *logger.Logger) (*llm.Service, error) {
 	// Collect API keys for all available providers
 	allApiKeys := make(map[string]string)
 	for provider := range llm.SupportedProviders {
@@ -111,78 +314,212 @@ func queryAllProviders(ctx context.Context, prompt string, cfg *config.Config, h
 		return nil, fmt.Errorf("no API keys found. Set at least one provider API key with: gollm set <provider> --api-key YOUR_API_KEY")
 	}
 
-	// Create LLM service with all API keys
-	service := llm.NewService(allApiKeys, httpClient)
+	// Create LLM service with all API keys and their connection settings
+	settings, err := providerSettings(cfg, allApiKeys)
+	if err != nil {
+		return nil, err
+	}
+	service := llm.NewServiceWithSettings(allApiKeys, httpClient, settings)
 
 	// Set logger if available
 	if queryLogger != nil {
 		service.SetLogger(queryLogger)
 	}
 
+	return service, nil
+}
+
+// querySingleProvider queries a single provider and returns the result
+func querySingleProvider(ctx context.Context, prompt string, modelFlag string, cfg *config.Config, httpClient *http.Client, options []llm.Option, queryLogger *logger.Logger) (*queryResult, error) {
+	service, providerName, err := newModelService(modelFlag, cfg, httpClient, queryLogger)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create and start spinner
 	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-	s.Suffix = " Querying all configured providers..."
+	s.Suffix = fmt.Sprintf(" Querying %s model %s...", providerName, modelFlag)
 	s.Start()
 
-	// Query all providers
-	results := service.QueryAll(ctx, prompt, options...)
+	// Query the model with timing
+	response, elapsedTime, err := service.QueryDetailed(ctx, prompt, modelFlag, options...)
 
 	// Stop spinner
 	s.Stop()
 
-	return results, nil
+	if err != nil {
+		return nil, fmt.Errorf("error querying model: %w", err)
+	}
+
+	return &queryResult{
+		Response:    response,
+		ElapsedTime: elapsedTime,
+	}, nil
 }
 
-// querySingleProvider queries a single provider and returns the result
-func querySingleProvider(ctx context.Context, prompt string, modelFlag string, cfg *config.Config, httpClient *http.Client, options []llm.Option, queryLogger *logger.Logger) (*struct {
-	Response    string
-	ElapsedTime time.Duration
-}, error) {
+// queryChunked applies the prompt to the input in chunks and combines the results
+func queryChunked(ctx context.Context, prompt string, settings *querySettings, cfg *config.Config, httpClient *http.Client, options []llm.Option, queryLogger *logger.Logger) (*llm.MapReduceResult, error) {
+	service, providerName, err := newModelService(settings.Model, cfg, httpClient, queryLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and start spinner
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Suffix = fmt.Sprintf(" Querying %s model %s in chunks...", providerName, settings.Model)
+	s.Start()
+
+	result, err := service.MapReduce(ctx, prompt, settings.Input, settings.Model, llm.MapReduceOptions{ChunkTokens: settings.ChunkTokens}, options...)
+
+	// Stop spinner
+	s.Stop()
+
+	if err != nil {
+		return nil, fmt.Errorf("error querying model: %w", err)
+	}
+	return result, nil
+}
+
+// newModelService creates a service for the provider of a model with its API
+// key and connection settings, returning the provider name
+func newModelService(model string, cfg *config.Config, httpClient *http.Client, queryLogger *logger.Logger) (*llm.Service, string, error) {
 	// Validate model
-	if !llm.IsValidModel(modelFlag) {
-		return nil, fmt.Errorf("unknown model: %s", modelFlag)
+	if !llm.IsValidModel(model) {
+		return nil, "", fmt.Errorf("unknown model: %s", model)
 	}
 
 	// Get provider for model
-	providerName, _ := llm.GetProviderForModel(modelFlag)
+	providerName, _ := llm.GetProviderForModel(model)
 
-	// Get API key from config or environment
-	apiKey := cfg.GetAPIKey(providerName)
+	// Get API key from the environment, secret store or config
+	apiKey, err := cfg.LookupAPIKey(providerName)
+	if err != nil {
+		return nil, "", err
+	}
 	if apiKey == "" {
-		return nil, fmt.Errorf("%s API key not found. Set it with: gollm set %s --api-key YOUR_API_KEY",
+		return nil, "", fmt.Errorf("%s API key not found. Set it with: gollm set %s --api-key YOUR_API_KEY",
 			providerName, providerName)
 	}
 
-	// Create LLM service with single API key
+	// Create LLM service with single API key and its connection settings
 	apiKeys := make(map[string]string)
 	apiKeys[providerName] = apiKey
-	service := llm.NewService(apiKeys, httpClient)
+	settings, err := providerSettings(cfg, apiKeys)
+	if err != nil {
+		return nil, "", err
+	}
+	service := llm.NewServiceWithSettings(apiKeys, httpClient, settings)
 
 	// Set logger if available
 	if queryLogger != nil {
 		service.SetLogger(queryLogger)
 	}
 
-	// Create and start spinner
-	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-	s.Suffix = fmt.Sprintf(" Querying %s model %s...", providerName, modelFlag)
-	s.Start()
+	return service, providerName, nil
+}
 
-	// Query the model with timing
-	response, elapsedTime, err := service.QueryWithTiming(ctx, prompt, modelFlag, options...)
+// providerSettings returns the configured connection settings for each provider with an API key
+func providerSettings(cfg *config.Config, apiKeys map[string]string) (map[string]llm.ProviderSettings, error) {
+	settings := make(map[string]llm.ProviderSettings)
+	for provider := range apiKeys {
+		providerSettings, err := cfg.ProviderSettings(provider)
+		if err != nil {
+			return nil, err
+		}
+		settings[provider] = providerSettings
+	}
+	return settings, nil
+}
 
-	// Stop spinner
-	s.Stop()
+// queryTimeout returns the overall timeout for a query, extended to the
+// longest timeout configured for the providers involved
+func queryTimeout(cfg *config.Config, model string, queryAll bool) time.Duration {
+	timeout := 120 * time.Second
 
-	if err != nil {
-		return nil, fmt.Errorf("error querying model: %w", err)
+	providers := make([]string, 0, len(llm.SupportedProviders))
+	if queryAll {
+		for provider := range llm.SupportedProviders {
+			providers = append(providers, provider)
+		}
+	} else if provider, ok := llm.GetProviderForModel(model); ok {
+		providers = append(providers, provider)
 	}
 
-	return &struct {
-		Response    string
-		ElapsedTime time.Duration
-	}{
-		Response:    response,
-		ElapsedTime: elapsedTime,
-	}, nil
+	return longestTimeout(cfg, timeout, providers)
+}
+
+// embeddingTimeout returns the timeout for embedding requests, extended to
+// the longest timeout configured for any chat or embedding provider
+func embeddingTimeout(cfg *config.Config) time.Duration {
+	return longestTimeout(cfg, 120*time.Second, llm.AllProviders())
+}
+
+// longestTimeout returns the longest of a timeout and those configured for the providers
+func longestTimeout(cfg *config.Config, timeout time.Duration, providers []string) time.Duration {
+	for _, provider := range providers {
+		// Invalid timeouts are reported when the provider settings are built
+		if providerTimeout, err := cfg.ProviderTimeout(provider); err == nil && providerTimeout > timeout {
+			timeout = providerTimeout
+		}
+	}
+
+	return timeout
+}
+
+// continueTruncated warns when an answer was cut off at the token limit and,
+// on a terminal, offers to ask the model to continue it. Continuations are
+// displayed with the renderer like the answer.
+func continueTruncated(prompt string, cfg *config.Config, settings *querySettings, response *llm.Response, renderer *markdown.Renderer) error {
+	history := []llm.Message{{Role: "user", Content: prompt}}
+
+	for response.Metadata.Truncated() {
+		fmt.Fprintf(os.Stderr, "Warning: the response was truncated at the %d token limit\n", settings.MaxTokens)
+
+		// Only offer to continue when someone can answer
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Fprintln(os.Stderr, "Raise the limit with --max-tokens or the max_tokens setting")
+			return nil
+		}
+
+		fmt.Fprint(os.Stderr, "Continue? [y/N] ")
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("error reading answer: %w", err)
+		}
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Fprintln(os.Stderr, "Raise the limit with --max-tokens or the max_tokens setting")
+			return nil
+		}
+
+		// Send the partial answer back so the model picks up where it stopped
+		history = append(history, llm.Message{Role: "assistant", Content: response.Text})
+
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(cfg, settings.Model, false))
+		result, err := queryLLM(ctx, llm.ContinuePrompt, cfg, continuationSettings(settings, history), false)
+		cancel()
+		if err != nil {
+			return err
+		}
+		continued, ok := result.(*queryResult)
+		if !ok {
+			return nil
+		}
+
+		response = continued.Response
+		displayResponseText(response, renderer)
+		history = append(history, llm.Message{Role: "user", Content: llm.ContinuePrompt})
+	}
+
+	return nil
+}
+
+// continuationSettings returns the settings asking the model to continue a
+// truncated answer. The partial answer sent back already starts with the
+// prefill, and only the answer shown is continued.
+func continuationSettings(settings *querySettings, history []llm.Message) *querySettings {
+	continued := *settings
+	continued.Prefill = ""
+	continued.CandidateCount = 0
+	continued.History = history
+	return &continued
 }
@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+func TestCombinePrompt(t *testing.T) {
+	tests := []struct {
+		name      string
+		placement string
+		delimiter string
+		expected  string
+	}{
+		{"after", config.StdinAfter, "---", "review this\n\n---\nfunc main() {}\n---"},
+		{"before", config.StdinBefore, "---", "---\nfunc main() {}\n---\n\nreview this"},
+		{"custom delimiter", config.StdinAfter, "```", "review this\n\n```\nfunc main() {}\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Trailing newlines of the input are not doubled before the closing delimiter
+			result := combinePrompt("review this", "func main() {}\n\n", tt.placement, tt.delimiter)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestReadPromptFromArgs(t *testing.T) {
+	settings := &querySettings{StdinPlacement: config.StdinAfter, StdinDelimiter: "---"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		expected string
+		err      bool
+	}{
+		{"argument only", []string{"hi"}, "", "hi", false},
+		{"stdin only", nil, "piped\n", "piped\n", false},
+		{"argument and stdin", []string{"review this"}, "diff\n", "review this\n\n---\ndiff\n---", false},
+		{"no input", nil, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.SetIn(strings.NewReader(tt.stdin))
+
+			prompt, err := readPromptFromArgs(cmd, tt.args, settings)
+			if tt.err {
+				if err == nil {
+					t.Errorf("Expected an error, got prompt %q", prompt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if prompt != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, prompt)
+			}
+		})
+	}
+}
+
+func TestReadStdinSizeCap(t *testing.T) {
+	input, err := readStdin(strings.NewReader(strings.Repeat("a", maxStdinBytes)))
+	if err != nil {
+		t.Fatalf("Expected input at the limit to be read, got %v", err)
+	}
+	if len(input) != maxStdinBytes {
+		t.Errorf("Expected %d bytes, got %d", maxStdinBytes, len(input))
+	}
+
+	if _, err := readStdin(strings.NewReader(strings.Repeat("a", maxStdinBytes+1))); err == nil {
+		t.Error("Expected an error for input over the limit")
+	}
+}
+
+func TestReadStdinFiles(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "gollm-stdin-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	// A file redirected to stdin is read
+	path := filepath.Join(tempDir, "input.txt")
+	if err := os.WriteFile(path, []byte("from a file\n"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open input: %v", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Errorf("Failed to close input: %v", err)
+		}
+	}()
+	if input, err := readStdin(file); err != nil || input != "from a file\n" {
+		t.Errorf("Expected the file content, got %q, %v", input, err)
+	}
+
+	// A pipe is read to its end
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Errorf("Failed to close pipe: %v", err)
+		}
+	}()
+	if _, err := writer.WriteString("from a pipe\n"); err != nil {
+		t.Fatalf("Failed to write to pipe: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close pipe: %v", err)
+	}
+	if input, err := readStdin(reader); err != nil || input != "from a pipe\n" {
+		t.Errorf("Expected the piped input, got %q, %v", input, err)
+	}
+
+	// A device such as /dev/null is not read, as it may never end
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+	}
+	defer func() {
+		if err := devNull.Close(); err != nil {
+			t.Errorf("Failed to close %s: %v", os.DevNull, err)
+		}
+	}()
+	if input, err := readStdin(devNull); err != nil || input != "" {
+		t.Errorf("Expected no input from a device, got %q, %v", input, err)
+	}
+}
+
+func TestContinuationSettings(t *testing.T) {
+	settings := &querySettings{Model: "claude-3-5-sonnet-latest", MaxTokens: 100, Prefill: "{", CandidateCount: 2, AutoContinue: 1}
+	history := []llm.Message{
+		{Role: "user", Content: "list the files as JSON"},
+		{Role: "assistant", Content: "{\"files\": ["},
+	}
+
+	continued := continuationSettings(settings, history)
+
+	// The partial answer already starts with the prefill, so it is not sent again
+	if continued.Prefill != "" {
+		t.Errorf("Expected no prefill, got %q", continued.Prefill)
+	}
+	if continued.CandidateCount != 0 {
+		t.Errorf("Expected no candidate count, got %d", continued.CandidateCount)
+	}
+	if len(continued.History) != 2 || continued.MaxTokens != 100 || continued.Model != settings.Model {
+		t.Errorf("Expected other settings to be kept, got %+v", continued)
+	}
+
+	// The original settings are left alone
+	if settings.Prefill != "{" || settings.CandidateCount != 2 || settings.History != nil {
+		t.Errorf("Expected original settings to be unchanged, got %+v", settings)
+	}
+}
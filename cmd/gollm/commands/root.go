@@ -2,20 +2,35 @@ package commands
 
 import (
 	"context"
-	"time"
+	"errors"
+	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/zerobang-dev/gollm/pkg/llm"
 )
 
 var (
-	modelFlag        string
-	systemPromptFlag string
-	temperatureFlag  float64
 	queryAllFlag     bool
 	verboseFlag      bool
+	thinkingFlag     int
+	showThinkingFlag bool
+	candidatesFlag   int
+	autoContinueFlag int
+	prefillFlag      string
+	stopFlag         []string
+	chunkFlag        bool
+	chunkSizeFlag    int
+	fileFlag         []string
+	extractCodeFlag  string
+	writeToFlag      string
+	dryRunFlag       bool
+	forceFlag        bool
 )
 
+// defaultChunkPrompt is applied to piped input in chunk mode when no prompt is given
+const defaultChunkPrompt = "Summarize the following text."
+
 // rootCmd represents the base command
 var rootCmd = &cobra.Command{
 	Use:   "gollm [prompt]",
@@ -24,44 +39,124 @@ var rootCmd = &cobra.Command{
 	Use it to chat, get completions, or stream responses from different LLM providers.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Read prompt from args or stdin
-		prompt, err := readPromptFromArgs(cmd, args)
+		// Load config
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		// Resolve model, system prompt and sampling settings from flags, env and config
+		settings, err := resolveQuerySettings(cfg, flagOverrides(cmd))
+		if err != nil {
+			return err
+		}
+		renderer, err := newResponseRenderer(settings)
+		if err != nil {
+			return err
+		}
+		if err := validateCodeFlags(); err != nil {
+			return err
+		}
+
+		// Read prompt from args, stdin or both. In chunk mode the argument is
+		// the instruction and stdin or the files the input it is applied to.
+		var prompt, input string
+		if chunkFlag {
+			prompt = defaultChunkPrompt
+			if len(args) == 1 {
+				prompt = args[0]
+			}
+			if len(fileFlag) == 0 {
+				input, err = readPromptFromArgs(cmd, nil, settings)
+			}
+		} else {
+			prompt, err = readPromptFromArgs(cmd, args, settings)
+		}
 		if err != nil {
 			return err
 		}
 
-		// Create context with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+		settings.ThinkingBudget = thinkingFlag
+		settings.CandidateCount = candidatesFlag
+		settings.AutoContinue = autoContinueFlag
+		settings.Prefill = prefillFlag
+		settings.StopSequences = stopFlag
+		settings.Chunk = chunkFlag
+		settings.ChunkTokens = chunkSizeFlag
+
+		// Include files before the prompt, or as the input to split in chunk mode
+		if len(fileFlag) > 0 {
+			files, err := readFileFlags(fileFlag, settings.Model, settings.MaxTokens, !chunkFlag)
+			if err != nil {
+				return err
+			}
+			if chunkFlag {
+				input = files
+			} else {
+				prompt = files + "\n" + prompt
+			}
+		}
+		settings.Input = input
+
+		// Create context with timeout, which also bounds all requests of a chunked query
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(cfg, settings.Model, queryAllFlag))
 		defer cancel()
 
 		// Query the LLM
-		result, err := queryLLM(ctx, prompt, modelFlag, systemPromptFlag, temperatureFlag, queryAllFlag)
+		result, err := queryLLM(ctx, prompt, cfg, settings, queryAllFlag)
+		var windowErr *llm.ContextWindowError
+		if errors.As(err, &windowErr) {
+			return fmt.Errorf("%w, use --chunk to process the input in parts", err)
+		}
 		if err != nil {
 			return err
 		}
 
+		// Chunked queries report the combined answer with total usage and cost
+		if mapResult, ok := result.(*llm.MapReduceResult); ok {
+			if codeOutputRequested() {
+				return outputCode(mapResult.Response.Text)
+			}
+			displayMapReduceResult(mapResult, showThinkingFlag, renderer)
+			return nil
+		}
+
 		// Display results based on the query type
 		if queryAllFlag {
 			results, ok := result.(map[string]llm.ProviderResponse)
 			if !ok {
 				return nil
 			}
-			return displayProviderResults(results)
+			return displayProviderResults(results, showThinkingFlag, renderer)
 		} else {
-			response, ok := result.(*struct {
-				Response    string
-				ElapsedTime time.Duration
-			})
+			response, ok := result.(*queryResult)
 			if !ok {
 				return nil
 			}
 
+			// Report ignored parameters and similar problems without failing
+			for _, warning := range response.Response.Warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+			}
+
+			// Only print or write the code when asked to
+			if codeOutputRequested() {
+				if response.Response.Metadata.Truncated() {
+					fmt.Fprintf(os.Stderr, "Warning: the response was truncated at the %d token limit, raise it with --max-tokens or use --auto-continue\n", settings.MaxTokens)
+				}
+				return outputCode(response.Response.Text)
+			}
+
 			if verboseFlag {
-				return displayVerboseResult(prompt, modelFlag, response.Response, response.ElapsedTime)
+				if err := displayVerboseResult(prompt, settings.Model, response.Response, response.ElapsedTime, showThinkingFlag, renderer); err != nil {
+					return err
+				}
 			} else {
-				displaySimpleResult(response.Response, response.ElapsedTime)
-				return nil
+				displaySimpleResult(response.Response, response.ElapsedTime, showThinkingFlag, renderer)
 			}
+
+			// Warn about answers cut off at the token limit and offer to continue them
+			return continueTruncated(prompt, cfg, settings, response.Response, renderer)
 		}
 	},
 }
@@ -73,13 +168,32 @@ func Execute() error {
 
 func init() {
 	// Global flags
-	rootCmd.PersistentFlags().StringVarP(&modelFlag, "model", "m", "claude-3-7-sonnet-latest", "LLM model to use")
+	rootCmd.PersistentFlags().StringP("model", "m", "", "LLM model to use (defaults to default_model from config)")
 
 	// Add flags to the root command
-	rootCmd.Flags().StringVarP(&systemPromptFlag, "system", "s", "", "System prompt to provide context")
-	rootCmd.Flags().Float64VarP(&temperatureFlag, "temperature", "t", 0.7, "Temperature for response generation (0.0-1.0)")
+	addConfigFlag(rootCmd, "system", "System prompt to provide context")
+	addConfigFlag(rootCmd, "temperature", "Temperature for response generation, 0.0 to 2.0 (up to 1.0 for Anthropic)")
+	addConfigFlag(rootCmd, "max-tokens", "Maximum number of tokens to generate")
+	rootCmd.Flags().IntVar(&autoContinueFlag, "auto-continue", 0, "Continue a response cut off at the token limit up to N times")
+	rootCmd.Flags().StringVar(&prefillFlag, "prefill", "", "Start the response with this text, e.g. '{' for JSON (Anthropic, Deepseek)")
+	rootCmd.Flags().StringArrayVar(&stopFlag, "stop", nil, "Stop generation when this sequence is produced (repeatable)")
+	rootCmd.Flags().BoolVar(&chunkFlag, "chunk", false, "Split piped input too large for the model into chunks, apply the prompt to each and combine the results")
+	rootCmd.Flags().IntVar(&chunkSizeFlag, "chunk-size", 0, "Tokens per chunk with --chunk (defaults to what fits the model's context window)")
+	rootCmd.Flags().StringArrayVarP(&fileFlag, "file", "f", nil, "Include a file, directory or glob such as 'src/**/*.go' in the prompt (repeatable)")
+	addConfigFlag(rootCmd, "stdin-placement", "Put piped input before or after the prompt argument")
+	addConfigFlag(rootCmd, "stdin-delimiter", "Line written before and after piped input combined with a prompt argument")
+	addConfigFlag(rootCmd, "render", "Render markdown responses: auto (on a terminal), always or never")
+	addConfigFlag(rootCmd, "theme", "Colors of rendered responses: auto, dark or light")
+	rootCmd.Flags().StringVar(&extractCodeFlag, "extract-code", "", "Print only the code blocks of the response, optionally only those in the given languages, e.g. --extract-code=go,sql")
+	rootCmd.Flags().Lookup("extract-code").NoOptDefVal = extractCodeAll
+	rootCmd.Flags().StringVar(&writeToFlag, "write-to", "", "Write each code block of the response to a file in this directory, named after the file name hinted in the response")
+	rootCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "With --write-to, list the files that would be written without writing them")
+	rootCmd.Flags().BoolVar(&forceFlag, "force", false, "With --write-to, replace files that already exist")
 	rootCmd.Flags().BoolVarP(&queryAllFlag, "all", "a", false, "Query all configured providers and compare responses")
 	rootCmd.Flags().BoolVarP(&verboseFlag, "verbose", "v", false, "Display detailed response information in a colorful table")
+	rootCmd.Flags().IntVar(&thinkingFlag, "thinking", 0, "Extended thinking budget in tokens (Anthropic, at least 1024)")
+	rootCmd.Flags().IntVar(&candidatesFlag, "candidates", 1, "Number of alternative responses to generate (Gemini)")
+	rootCmd.Flags().BoolVar(&showThinkingFlag, "show-thinking", false, "Display the model's reasoning (extended thinking or deepseek-reasoner chain of thought) before the response")
 
 	// Add commands
 	rootCmd.AddCommand(setCmd)
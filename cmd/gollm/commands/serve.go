@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/config"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+	"github.com/zerobang-dev/gollm/pkg/logger"
+	"github.com/zerobang-dev/gollm/pkg/server"
+)
+
+var (
+	serveAddrFlag  string
+	serveTokenFlag string
+	serveAliasFlag map[string]string
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the configured providers over OpenAI- and Anthropic-compatible APIs",
+	Long: `Start a local HTTP server with OpenAI- and Anthropic-compatible APIs, so any
+OpenAI or Anthropic client can use the configured providers with gollm's API keys
+and settings.
+
+Endpoints:
+  GET  /v1/models               Models of the providers with an API key
+  POST /v1/chat/completions     OpenAI chat completions, with "stream": true
+  POST /v1/messages             Anthropic messages, with "stream": true
+
+Streams are buffered: providers are not queried with streaming, so the stream
+only carries keep-alive comments until the complete answer arrives in one
+piece. Clients get no faster first token than without streaming.
+
+Every endpoint answers with any configured provider. Use --alias to answer
+requests for a model a client insists on with another one, e.g.
+--alias claude-3-7-sonnet-latest=gemini-2.0-flash.
+
+Clients must send the token as "Authorization: Bearer <token>" or in the
+x-api-key header when --token or GOLLM_SERVE_TOKEN is set. Successful queries are
+recorded in the query history.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Load config
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		// Requests without a model or max tokens use the configured defaults
+		settings, err := resolveQuerySettings(cfg, flagOverrides(cmd))
+		if err != nil {
+			return err
+		}
+
+		token := serveTokenFlag
+		if token == "" {
+			token = os.Getenv("GOLLM_SERVE_TOKEN")
+		}
+
+		// Initialize logger
+		queryLogger, err := logger.NewLogger(config.GetConfigDir())
+		if err != nil {
+			// Just log a warning but continue without logging
+			fmt.Fprintf(os.Stderr, "Warning: Query logging disabled - %v\n", err)
+		} else {
+			defer func() {
+				if err := queryLogger.Close(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error closing logger: %v\n", err)
+				}
+			}()
+		}
+
+		service, err := newConfiguredService(cfg, &http.Client{Timeout: queryTimeout(cfg, "", true)}, queryLogger)
+		if err != nil {
+			return err
+		}
+
+		options := []server.Option{
+			server.WithToken(token),
+			server.WithDefaultModel(settings.Model),
+			server.WithMaxTokens(settings.MaxTokens),
+			server.WithAccessLog(os.Stderr),
+		}
+		for name, model := range serveAliasFlag {
+			if !llm.IsValidModel(model) {
+				return fmt.Errorf("invalid alias %s: unknown model: %s", name, model)
+			}
+			options = append(options, server.WithModelAlias(name, model))
+		}
+
+		httpServer := &http.Server{
+			Addr:              serveAddrFlag,
+			Handler:           server.New(service, options...),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		// Shut down gracefully on interrupt, letting running requests finish
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- httpServer.ListenAndServe()
+		}()
+
+		fmt.Fprintf(os.Stderr, "Serving %v on http://%s (default model %s)\n", service.ConfiguredProviders(), serveAddrFlag, settings.Model)
+		if token == "" {
+			fmt.Fprintln(os.Stderr, "Warning: no token set, any client that can reach the server can use your API keys")
+		}
+
+		select {
+		case err := <-serveErr:
+			return fmt.Errorf("error running server: %w", err)
+		case <-ctx.Done():
+		}
+
+		fmt.Fprintln(os.Stderr, "Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("error shutting down server: %w", err)
+		}
+		if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("error running server: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddrFlag, "addr", "localhost:8080", "Address to listen on, e.g. :8080 for all interfaces")
+	serveCmd.Flags().StringVar(&serveTokenFlag, "token", "", "Bearer token clients must send (defaults to GOLLM_SERVE_TOKEN)")
+	addConfigFlag(serveCmd, "model", "Model for requests that do not name one")
+	addConfigFlag(serveCmd, "max-tokens", "Maximum tokens for requests that do not set them")
+	serveCmd.Flags().StringToStringVar(&serveAliasFlag, "alias", nil, "Answer requests for a model with another one, e.g. claude-3-7-sonnet-latest=gemini-2.0-flash (repeatable)")
+
+	rootCmd.AddCommand(serveCmd)
+}
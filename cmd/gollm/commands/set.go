@@ -20,7 +20,7 @@ var setCmd = &cobra.Command{
 		providerName := args[0]
 
 		// Validate provider
-		if !llm.IsValidProvider(providerName) {
+		if !llm.UsesAPIKey(providerName) {
 			return fmt.Errorf("unsupported provider: %s", providerName)
 		}
 
@@ -29,14 +29,14 @@ var setCmd = &cobra.Command{
 			return fmt.Errorf("API key is required (use --api-key flag)")
 		}
 
-		// Load config
-		cfg, err := config.Load()
+		// Load the user config only, project values must not leak into it
+		cfg, err := config.LoadUser()
 		if err != nil {
 			return fmt.Errorf("error loading config: %w", err)
 		}
 
-		// Set API key
-		if err := cfg.SetAPIKey(providerName, apiKeyFlag); err != nil {
+		// Set API key, using the secret store when one is configured
+		if err := cfg.StoreAPIKey(providerName, apiKeyFlag); err != nil {
 			return fmt.Errorf("error setting API key: %w", err)
 		}
 
@@ -45,8 +45,13 @@ var setCmd = &cobra.Command{
 			return fmt.Errorf("error saving config: %w", err)
 		}
 
+		configPath, err := config.Path()
+		if err != nil {
+			return err
+		}
+
 		fmt.Printf("API key for %s has been set.\n", providerName)
-		fmt.Printf("Configuration saved to ~/.config/gollm/config.yml\n")
+		fmt.Printf("Configuration saved to %s\n", configPath)
 
 		return nil
 	},
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+var (
+	tokensFileFlag  []string
+	tokensLocalFlag bool
+)
+
+// tokensCmd represents the tokens command
+var tokensCmd = &cobra.Command{
+	Use:   "tokens [prompt]",
+	Short: "Count the tokens of a prompt",
+	Long: `Count the input tokens a prompt or file would use with a model and report the
+headroom left in the model's context window for the output.
+
+Tokens are counted by the provider's API where available (Anthropic and Gemini)
+and estimated locally otherwise, or when --local is given.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Load config
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		// Resolve model, system prompt and max tokens from flags, env and config
+		settings, err := resolveQuerySettings(cfg, flagOverrides(cmd))
+		if err != nil {
+			return err
+		}
+
+		// Read the prompt from the files, arguments or stdin, counting files
+		// that do not fit in the context window too
+		var prompt string
+		if len(tokensFileFlag) > 0 {
+			prompt, err = readFileFlags(tokensFileFlag, settings.Model, settings.MaxTokens, false)
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				prompt += "\n" + args[0]
+			}
+		} else {
+			prompt, err = readPromptFromArgs(cmd, args, settings)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Use the provider's counting API when its key is available
+		apiKeys := make(map[string]string)
+		if providerName, ok := llm.GetProviderForModel(settings.Model); ok && !tokensLocalFlag {
+			if apiKey := cfg.GetAPIKey(providerName); apiKey != "" {
+				apiKeys[providerName] = apiKey
+			}
+		}
+		providers, err := providerSettings(cfg, apiKeys)
+		if err != nil {
+			return err
+		}
+		timeout := queryTimeout(cfg, settings.Model, false)
+		service := llm.NewServiceWithSettings(apiKeys, &http.Client{Timeout: timeout}, providers)
+
+		var options []llm.Option
+		if settings.SystemPrompt != "" {
+			options = append(options, llm.WithCustomParam("system", settings.SystemPrompt))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		count, err := service.CountTokens(ctx, prompt, settings.Model, options...)
+		if err != nil {
+			return fmt.Errorf("error counting tokens: %w", err)
+		}
+
+		displayTokenCount(count, settings.MaxTokens)
+		return nil
+	},
+}
+
+// displayTokenCount prints a token count and the headroom left for the output
+func displayTokenCount(count llm.TokenCount, maxTokens int) {
+	method := "estimated"
+	if count.Exact {
+		method = "counted by the provider"
+	}
+
+	fmt.Printf("Model:          %s\n", count.Model)
+	fmt.Printf("Input tokens:   %d (%s)\n", count.Tokens, method)
+
+	if count.ContextWindow == 0 {
+		fmt.Println("Context window: unknown")
+		return
+	}
+	fmt.Printf("Context window: %d\n", count.ContextWindow)
+
+	headroom := count.Headroom()
+	if headroom < 0 {
+		fmt.Printf("Headroom:       none, the input exceeds the context window by %d tokens\n", -headroom)
+		return
+	}
+	fmt.Printf("Headroom:       %d tokens for output\n", headroom)
+
+	if maxTokens > headroom {
+		fmt.Fprintf(os.Stderr, "Warning: max_tokens %d is more than the headroom, the response may be cut off\n", maxTokens)
+	}
+}
+
+func init() {
+	tokensCmd.Flags().StringArrayVarP(&tokensFileFlag, "file", "f", nil, "Count a file, directory or glob such as 'src/**/*.go', before the prompt if one is given (repeatable)")
+	addConfigFlag(tokensCmd, "system", "System prompt to include in the count")
+	addConfigFlag(tokensCmd, "max-tokens", "Output tokens to compare with the headroom")
+	tokensCmd.Flags().BoolVar(&tokensLocalFlag, "local", false, "Estimate locally instead of calling the provider's counting API")
+
+	rootCmd.AddCommand(tokensCmd)
+}
@@ -0,0 +1,282 @@
+// Package attach collects files named on the command line, expanding
+// directories and globs, to include them in prompts as delimited blocks.
+package attach
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// MaxFileBytes is the size above which files are skipped
+const MaxFileBytes = 10 << 20
+
+// File is a file to include in a prompt
+type File struct {
+	Path    string // Path as found from the pattern, with forward slashes
+	Content string
+}
+
+// Collect returns the files matching the patterns, in order and without
+// duplicates. A pattern is a file, a directory included recursively, or a
+// glob where ** matches any number of directories. Files expanded from
+// directories and globs are skipped when they are hidden or git ignores
+// them. Binary and oversized files are always skipped, with the reasons
+// returned as skipped.
+func Collect(patterns []string) (files []File, skipped []string, err error) {
+	ignore := newGitIgnore()
+	seen := make(map[string]bool)
+
+	for _, pattern := range patterns {
+		paths, err := expand(pattern, ignore)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(paths) == 0 {
+			return nil, nil, fmt.Errorf("no files match %s", pattern)
+		}
+
+		for _, path := range paths {
+			clean := filepath.Clean(path)
+			if seen[clean] {
+				continue
+			}
+			seen[clean] = true
+
+			file, reason, err := readFile(clean)
+			if err != nil {
+				return nil, nil, err
+			}
+			if reason != "" {
+				skipped = append(skipped, fmt.Sprintf("%s: %s", filepath.ToSlash(clean), reason))
+				continue
+			}
+			files = append(files, file)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, skipped, fmt.Errorf("no text files to include, skipped %s", strings.Join(skipped, ", "))
+	}
+	return files, skipped, nil
+}
+
+// expand returns the paths a pattern names
+func expand(pattern string, ignore *gitIgnore) ([]string, error) {
+	if !hasMeta(pattern) {
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", pattern, err)
+		}
+		if !info.IsDir() {
+			// Files named explicitly are included even if git ignores them
+			return []string{pattern}, nil
+		}
+		return walk(pattern, nil, ignore)
+	}
+
+	// Globs with ** match below the directory before the first wildcard
+	if strings.Contains(pattern, "**") {
+		slashed := filepath.ToSlash(filepath.Clean(pattern))
+		base := "."
+		if i := strings.LastIndex(slashed[:strings.IndexAny(slashed, "*?[")], "/"); i >= 0 {
+			base = filepath.FromSlash(slashed[:i])
+			if base == "" {
+				base = "/"
+			}
+		}
+		match, err := regexp.Compile("^" + globExpr(slashed) + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		return walk(base, match, ignore)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+	// Hidden files only match globs asking for them, such as .env*
+	hiddenWanted := strings.HasPrefix(filepath.Base(pattern), ".")
+	var paths []string
+	for _, match := range matches {
+		if isHidden(match) && !hiddenWanted {
+			continue
+		}
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", match, err)
+		}
+		if info.IsDir() {
+			dirPaths, err := walk(match, nil, ignore)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, dirPaths...)
+			continue
+		}
+		ignored, err := isIgnored(match, false, ignore)
+		if err != nil {
+			return nil, err
+		}
+		if !ignored {
+			paths = append(paths, match)
+		}
+	}
+	return paths, nil
+}
+
+// walk returns the files below dir that are not hidden and git does not
+// ignore, only those whose slash-separated path matches when match is set
+func walk(dir string, match *regexp.Regexp, ignore *gitIgnore) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir {
+			// Dotfiles such as .env or .npmrc often hold secrets
+			if isHidden(path) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			ignored, err := isIgnored(path, entry.IsDir(), ignore)
+			if err != nil {
+				return err
+			}
+			if ignored {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if match != nil && !match.MatchString(filepath.ToSlash(path)) {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dir, err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// isHidden reports whether a file or directory name starts with a dot
+func isHidden(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+// isIgnored reports whether git ignores a path, always ignoring .git directories
+func isIgnored(path string, isDir bool, ignore *gitIgnore) (bool, error) {
+	if filepath.Base(path) == ".git" {
+		return true, nil
+	}
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	return ignore.Ignored(absolute, isDir)
+}
+
+// readFile reads a file, returning why it is skipped if it is too large or binary
+func readFile(path string) (File, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return File{}, "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if info.Size() > MaxFileBytes {
+		return File{}, fmt.Sprintf("larger than %d MB", MaxFileBytes>>20), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if bytes.IndexByte(content, 0) >= 0 || !utf8.Valid(content) {
+		return File{}, "binary file", nil
+	}
+	return File{Path: filepath.ToSlash(path), Content: string(content)}, "", nil
+}
+
+// hasMeta reports whether a path contains glob wildcards
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// Format wraps each file in a block delimited by tags with its path
+func Format(files []File) string {
+	var text strings.Builder
+	for i, file := range files {
+		if i > 0 {
+			text.WriteString("\n")
+		}
+		fmt.Fprintf(&text, "<file path=%q>\n", file.Path)
+		text.WriteString(file.Content)
+		if !strings.HasSuffix(file.Content, "\n") {
+			text.WriteString("\n")
+		}
+		text.WriteString("</file>\n")
+	}
+	return text.String()
+}
+
+// BudgetError is returned when files do not fit in the tokens available for them
+type BudgetError struct {
+	Model   string
+	Tokens  int // Estimated tokens of the files
+	Budget  int
+	Largest []string // The largest files with their estimated tokens
+}
+
+// Error implements the error interface
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("files are about %d tokens, more than the %d tokens %s has room for (largest: %s)",
+		e.Tokens, e.Budget, e.Model, strings.Join(e.Largest, ", "))
+}
+
+// CheckBudget estimates the tokens of the formatted files with a model and
+// fails with a BudgetError when they exceed the budget. A budget of 0 or
+// less means no limit.
+func CheckBudget(files []File, model string, budget int) (int, error) {
+	type fileTokens struct {
+		path   string
+		tokens int
+	}
+
+	var total int
+	sizes := make([]fileTokens, 0, len(files))
+	for _, file := range files {
+		tokens := llm.EstimateTokens(model, Format([]File{file}))
+		total += tokens
+		sizes = append(sizes, fileTokens{file.Path, tokens})
+	}
+	if budget <= 0 || total <= budget {
+		return total, nil
+	}
+
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].tokens > sizes[j].tokens
+	})
+	var largest []string
+	for _, size := range sizes[:min(3, len(sizes))] {
+		largest = append(largest, fmt.Sprintf("%s %d", size.path, size.tokens))
+	}
+	return total, &BudgetError{Model: model, Tokens: total, Budget: budget, Largest: largest}
+}
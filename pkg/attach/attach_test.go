@@ -0,0 +1,178 @@
+package attach
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// paths returns the paths of files relative to dir
+func paths(t *testing.T, dir string, files []File) []string {
+	t.Helper()
+	var relative []string
+	for _, file := range files {
+		path, err := filepath.Rel(dir, filepath.FromSlash(file.Path))
+		if err != nil {
+			t.Fatalf("Failed to make %s relative: %v", file.Path, err)
+		}
+		relative = append(relative, filepath.ToSlash(path))
+	}
+	return relative
+}
+
+func TestCollect(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-attach-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	writeFile(t, filepath.Join(tmpDir, ".git", "HEAD"), "ref: refs/heads/main\n")
+	writeFile(t, filepath.Join(tmpDir, ".gitignore"), "# Build output\n*.log\nbuild/\n!keep.log\n/root-only.txt\n")
+	writeFile(t, filepath.Join(tmpDir, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(tmpDir, "debug.log"), "ignored\n")
+	writeFile(t, filepath.Join(tmpDir, "keep.log"), "kept\n")
+	writeFile(t, filepath.Join(tmpDir, "root-only.txt"), "ignored\n")
+	writeFile(t, filepath.Join(tmpDir, "build", "out.go"), "package build\n")
+	writeFile(t, filepath.Join(tmpDir, "pkg", "a.go"), "package pkg\n")
+	writeFile(t, filepath.Join(tmpDir, "pkg", "root-only.txt"), "not anchored here\n")
+	writeFile(t, filepath.Join(tmpDir, "pkg", "sub", "b.go"), "package sub\n")
+	writeFile(t, filepath.Join(tmpDir, "pkg", "sub", ".gitignore"), "b.go\n")
+	writeFile(t, filepath.Join(tmpDir, "pkg", "image.bin"), "\x89PNG\x00\x00")
+	writeFile(t, filepath.Join(tmpDir, ".env"), "API_KEY=secret\n")
+	writeFile(t, filepath.Join(tmpDir, ".aws", "credentials"), "secret\n")
+	writeFile(t, filepath.Join(tmpDir, ".git", "info", "exclude"), "local-notes.txt\n")
+	writeFile(t, filepath.Join(tmpDir, "local-notes.txt"), "excluded\n")
+	writeFile(t, filepath.Join(tmpDir, "global.tmp"), "excluded\n")
+
+	// The user's core.excludesFile applies too
+	globalConfig := filepath.Join(tmpDir, ".config", "gitconfig")
+	writeFile(t, globalConfig, "[core]\n\texcludesFile = "+filepath.ToSlash(filepath.Join(tmpDir, ".config", "ignore"))+"\n")
+	writeFile(t, filepath.Join(tmpDir, ".config", "ignore"), "*.tmp\n")
+	t.Setenv("GIT_CONFIG_GLOBAL", globalConfig)
+
+	// Directories are walked, skipping hidden, ignored and binary files
+	files, skipped, err := Collect([]string{tmpDir})
+	if err != nil {
+		t.Fatalf("Collect returned error: %v", err)
+	}
+	expected := "keep.log,main.go,pkg/a.go,pkg/root-only.txt"
+	if got := strings.Join(paths(t, tmpDir, files), ","); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if len(skipped) != 1 || !strings.HasSuffix(skipped[0], "image.bin: binary file") {
+		t.Errorf("Expected binary file skipped, got %v", skipped)
+	}
+
+	// Globs with ** match at any depth, single * within a directory
+	files, _, err = Collect([]string{filepath.Join(tmpDir, "**", "*.go"), filepath.Join(tmpDir, "*.go")})
+	if err != nil {
+		t.Fatalf("Collect returned error: %v", err)
+	}
+	if got := strings.Join(paths(t, tmpDir, files), ","); got != "main.go,pkg/a.go" {
+		t.Errorf("Expected main.go,pkg/a.go without duplicates, got %s", got)
+	}
+
+	// Files named explicitly are included even if ignored or hidden
+	files, _, err = Collect([]string{filepath.Join(tmpDir, "debug.log"), filepath.Join(tmpDir, ".env")})
+	if err != nil {
+		t.Fatalf("Collect returned error: %v", err)
+	}
+	if len(files) != 2 || files[0].Content != "ignored\n" || files[1].Content != "API_KEY=secret\n" {
+		t.Errorf("Expected debug.log and .env, got %+v", files)
+	}
+
+	// Hidden files only match globs starting with a dot
+	files, _, err = Collect([]string{filepath.Join(tmpDir, "*")})
+	if err != nil {
+		t.Fatalf("Collect returned error: %v", err)
+	}
+	for _, file := range files {
+		if strings.Contains(file.Path, "/.") {
+			t.Errorf("Expected no hidden files from *, got %s", file.Path)
+		}
+	}
+	files, _, err = Collect([]string{filepath.Join(tmpDir, ".env*")})
+	if err != nil || len(files) != 1 {
+		t.Errorf("Expected .env from .env*, got %+v (%v)", files, err)
+	}
+
+	// Patterns without matches and only binary files are errors
+	if _, _, err := Collect([]string{filepath.Join(tmpDir, "*.rs")}); err == nil {
+		t.Error("Expected error for pattern without matches")
+	}
+	if _, _, err := Collect([]string{filepath.Join(tmpDir, "pkg", "image.bin")}); err == nil {
+		t.Error("Expected error when only binary files match")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	text := Format([]File{{Path: "a.go", Content: "package a\n"}, {Path: "b.txt", Content: "no newline"}})
+	expected := "<file path=\"a.go\">\npackage a\n</file>\n\n<file path=\"b.txt\">\nno newline\n</file>\n"
+	if text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	files := []File{
+		{Path: "small.txt", Content: "small"},
+		{Path: "large.txt", Content: strings.Repeat("word ", 1000)},
+	}
+
+	tokens, err := CheckBudget(files, "gemini-2.0-flash", 0)
+	if err != nil || tokens < 1250 {
+		t.Errorf("Expected no limit and over 1250 tokens, got %d (%v)", tokens, err)
+	}
+
+	_, err = CheckBudget(files, "gemini-2.0-flash", 100)
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("Expected BudgetError, got %v", err)
+	}
+	if !strings.HasPrefix(budgetErr.Largest[0], "large.txt ") {
+		t.Errorf("Expected large.txt listed first, got %v", budgetErr.Largest)
+	}
+}
+
+func TestGlobExpr(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "pkg/sub/main.go", true},
+		{"docs/**", "docs/a/b.md", true},
+		{"file?.[ch]", "file1.c", true},
+		{"file?.[!ch]", "file1.c", false},
+	}
+
+	for _, test := range tests {
+		rule, ok := parseIgnoreRule("/" + test.glob)
+		if !ok {
+			t.Fatalf("Failed to parse %s", test.glob)
+		}
+		if match := rule.pattern.MatchString(test.path); match != test.match {
+			t.Errorf("Expected %s matching %s to be %v, got %v", test.glob, test.path, test.match, match)
+		}
+	}
+}
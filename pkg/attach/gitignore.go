@@ -0,0 +1,307 @@
+package attach
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern of a .gitignore file
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool // Pattern starts with !, re-including what earlier rules ignored
+	dirOnly bool // Pattern ends with /, matching directories only
+}
+
+// ignoreFile holds the rules of a .gitignore file, matched against paths
+// relative to its directory
+type ignoreFile struct {
+	dir   string
+	rules []ignoreRule
+}
+
+// gitIgnore decides whether paths are ignored by the .gitignore files of
+// the git repository they are in, its .git/info/exclude and the user's
+// core.excludesFile. Paths outside a repository are never ignored.
+type gitIgnore struct {
+	files    map[string]*ignoreFile // Parsed .gitignore files by directory, nil if none
+	excludes map[string]*ignoreFile // Exclude rules of each repository root
+	roots    map[string]string      // Repository root of each directory, "" if none
+}
+
+// newGitIgnore creates an empty cache of .gitignore files
+func newGitIgnore() *gitIgnore {
+	return &gitIgnore{
+		files:    make(map[string]*ignoreFile),
+		excludes: make(map[string]*ignoreFile),
+		roots:    make(map[string]string),
+	}
+}
+
+// Ignored reports whether an absolute path is ignored by git, either itself
+// or through one of its parent directories
+func (g *gitIgnore) Ignored(path string, isDir bool) (bool, error) {
+	root, err := g.repoRoot(filepath.Dir(path))
+	if err != nil || root == "" {
+		return false, err
+	}
+
+	// Check every parent below the repository root, then the path itself
+	relative, err := filepath.Rel(root, path)
+	if err != nil {
+		return false, err
+	}
+	parts := strings.Split(filepath.ToSlash(relative), "/")
+	current := root
+	for i, part := range parts {
+		current = filepath.Join(current, part)
+		last := i == len(parts)-1
+		if part == ".git" {
+			return true, nil
+		}
+		ignored, err := g.match(root, current, isDir || !last)
+		if err != nil {
+			return false, err
+		}
+		if ignored {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// match applies the .gitignore files from the repository root down to the
+// directory of path, the last matching rule deciding
+func (g *gitIgnore) match(root, path string, isDir bool) (bool, error) {
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	// The exclude files apply first, so .gitignore rules can override them
+	excludes, err := g.loadExcludes(root)
+	if err != nil {
+		return false, err
+	}
+	files := []*ignoreFile{excludes}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		file, err := g.load(dirs[i])
+		if err != nil {
+			return false, err
+		}
+		files = append(files, file)
+	}
+
+	ignored := false
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		relative, err := filepath.Rel(file.dir, path)
+		if err != nil {
+			return false, err
+		}
+		relative = filepath.ToSlash(relative)
+		for _, rule := range file.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.pattern.MatchString(relative) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored, nil
+}
+
+// repoRoot returns the closest directory at or above dir that contains .git
+func (g *gitIgnore) repoRoot(dir string) (string, error) {
+	if root, ok := g.roots[dir]; ok {
+		return root, nil
+	}
+
+	var root string
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		root = dir
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("error looking for git repository: %w", err)
+	} else if parent := filepath.Dir(dir); parent != dir {
+		var err error
+		if root, err = g.repoRoot(parent); err != nil {
+			return "", err
+		}
+	}
+
+	g.roots[dir] = root
+	return root, nil
+}
+
+// load returns the parsed .gitignore file of a directory, nil if it has none
+func (g *gitIgnore) load(dir string) (*ignoreFile, error) {
+	if file, ok := g.files[dir]; ok {
+		return file, nil
+	}
+
+	rules, err := readIgnoreRules(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil, err
+	}
+	var file *ignoreFile
+	if rules != nil {
+		file = &ignoreFile{dir: dir, rules: rules}
+	}
+
+	g.files[dir] = file
+	return file, nil
+}
+
+// loadExcludes returns the rules of the user's core.excludesFile followed by
+// those of the repository's .git/info/exclude, both relative to the root
+func (g *gitIgnore) loadExcludes(root string) (*ignoreFile, error) {
+	if file, ok := g.excludes[root]; ok {
+		return file, nil
+	}
+
+	file := &ignoreFile{dir: root}
+	for _, path := range []string{globalExcludesFile(root), filepath.Join(root, ".git", "info", "exclude")} {
+		if path == "" {
+			continue
+		}
+		rules, err := readIgnoreRules(path)
+		if err != nil {
+			return nil, err
+		}
+		file.rules = append(file.rules, rules...)
+	}
+
+	g.excludes[root] = file
+	return file, nil
+}
+
+// globalExcludesFile returns the path of the user's core.excludesFile, by
+// default git/ignore in the XDG config directory, or "" if there is none
+func globalExcludesFile(root string) string {
+	if out, err := exec.Command("git", "-C", root, "config", "--path", "--get", "core.excludesFile").Output(); err == nil {
+		if path := strings.TrimSpace(string(out)); path != "" {
+			return path
+		}
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "git", "ignore")
+}
+
+// readIgnoreRules parses a file of ignore patterns, returning nil if it does not exist
+func readIgnoreRules(path string) ([]ignoreRule, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing %s: %v\n", path, err)
+		}
+	}()
+
+	rules := []ignoreRule{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// parseIgnoreRule parses a line of a .gitignore file, returning false for
+// blank lines and comments
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, "\\")
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// Patterns with a slash are relative to the .gitignore, others match at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expr := globExpr(line)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+
+	pattern, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.pattern = pattern
+	return rule, true
+}
+
+// globExpr converts a glob with *, ?, [...] and ** into a regular expression
+// matching slash-separated paths
+func globExpr(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String()
+}
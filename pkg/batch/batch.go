@@ -0,0 +1,333 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// Item is one line of a batch input file. Either Prompt or Vars for the
+// batch template must be set; the other fields override the batch defaults.
+type Item struct {
+	ID          string                 `json:"id,omitempty"`
+	Prompt      string                 `json:"prompt,omitempty"`
+	Vars        map[string]interface{} `json:"vars,omitempty"`
+	Model       string                 `json:"model,omitempty"`
+	System      string                 `json:"system,omitempty"`
+	Temperature *float64               `json:"temperature,omitempty"`
+	MaxTokens   int                    `json:"max_tokens,omitempty"`
+}
+
+// Result is one line of a batch output file
+type Result struct {
+	ID           string  `json:"id"`
+	Model        string  `json:"model"`
+	Response     string  `json:"response,omitempty"`
+	Error        string  `json:"error,omitempty"`
+	StopReason   string  `json:"stop_reason,omitempty"`
+	InputTokens  int     `json:"input_tokens,omitempty"`
+	OutputTokens int     `json:"output_tokens,omitempty"`
+	Cost         float64 `json:"cost,omitempty"`
+	ElapsedMS    int64   `json:"elapsed_ms"`
+}
+
+// Summary reports the outcome of a batch run
+type Summary struct {
+	Total     int       // Items in the input
+	Skipped   int       // Items completed by an earlier run
+	Succeeded int       // Items answered in this run
+	Failed    int       // Items that failed in this run
+	Usage     llm.Usage // Tokens used by this run
+	Cost      float64   // Estimated cost of this run in US dollars
+}
+
+// Progress is reported after each item is processed
+type Progress struct {
+	Done   int // Items processed in this run, including failures
+	Failed int // Items that failed in this run
+	Total  int // Items to process in this run
+}
+
+// Querier sends a prompt to a model, as implemented by llm.Service
+type Querier interface {
+	QueryDetailed(ctx context.Context, prompt, modelName string, options ...llm.Option) (*llm.Response, time.Duration, error)
+}
+
+// ReadItems parses a JSONL batch input. Blank lines are skipped and items
+// without an ID are identified by their line number.
+func ReadItems(r io.Reader) ([]Item, error) {
+	var items []Item
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var item Item
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("error parsing line %d: %w", line, err)
+		}
+		if item.ID == "" {
+			item.ID = strconv.Itoa(line)
+		}
+		if item.Prompt == "" && item.Vars == nil {
+			return nil, fmt.Errorf("line %d has neither a prompt nor template vars", line)
+		}
+		if seen[item.ID] {
+			return nil, fmt.Errorf("line %d repeats id %q", line, item.ID)
+		}
+		seen[item.ID] = true
+
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading batch input: %w", err)
+	}
+
+	return items, nil
+}
+
+// CompletedIDs returns the IDs answered successfully in an existing output
+// file, so an interrupted run can be resumed. A missing file has none.
+func CompletedIDs(path string) (map[string]bool, error) {
+	completed := make(map[string]bool)
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return completed, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening batch output: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing batch output: %v\n", err)
+		}
+	}()
+
+	// Later lines win, so an item that failed and was retried counts as completed
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var result Result
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			// A line cut short by an interruption is retried
+			continue
+		}
+		completed[result.ID] = result.Error == ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading batch output: %w", err)
+	}
+
+	for id, ok := range completed {
+		if !ok {
+			delete(completed, id)
+		}
+	}
+	return completed, nil
+}
+
+// Runner processes batch items through a Querier with bounded concurrency
+type Runner struct {
+	Querier     Querier
+	Model       string             // Model for items that do not choose one
+	Temperature float64            // Temperature for items that do not choose one, sent only to models that support it
+	Options     []llm.Option       // Options applied to every item before its own settings
+	Template    *template.Template // Template rendered with the vars of items without a prompt
+	Concurrency int                // Items processed at the same time, at least 1
+	Progress    func(Progress)     // Called after each item, may be nil
+}
+
+// Run processes the items not in skip and writes a result line for each to
+// out. Failed items are recorded in the output rather than stopping the run;
+// items interrupted by cancelling the context are left out so they are retried.
+func (r *Runner) Run(ctx context.Context, items []Item, skip map[string]bool, out io.Writer) (Summary, error) {
+	summary := Summary{Total: len(items)}
+
+	var pending []Item
+	for _, item := range items {
+		if skip[item.ID] {
+			summary.Skipped++
+			continue
+		}
+		pending = append(pending, item)
+	}
+
+	concurrency := max(r.Concurrency, 1)
+	progress := Progress{Total: len(pending)}
+
+	var mutex sync.Mutex
+	var writeErr error
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+
+	for _, item := range pending {
+		// Stop starting items once interrupted
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(item Item) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			result, usage := r.process(ctx, item)
+			if ctx.Err() != nil && result.Error != "" {
+				return
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			line, err := json.Marshal(result)
+			if err == nil {
+				_, err = out.Write(append(line, '\n'))
+			}
+			if err != nil && writeErr == nil {
+				writeErr = fmt.Errorf("error writing batch output: %w", err)
+			}
+
+			progress.Done++
+			if result.Error != "" {
+				summary.Failed++
+				progress.Failed++
+			} else {
+				summary.Succeeded++
+				summary.Usage.InputTokens += usage.InputTokens
+				summary.Usage.OutputTokens += usage.OutputTokens
+				summary.Usage.ThinkingTokens += usage.ThinkingTokens
+				summary.Cost += result.Cost
+			}
+			if r.Progress != nil {
+				r.Progress(progress)
+			}
+		}(item)
+	}
+	wg.Wait()
+
+	if writeErr != nil {
+		return summary, writeErr
+	}
+	return summary, ctx.Err()
+}
+
+// process answers a single item, recording any error in the result
+func (r *Runner) process(ctx context.Context, item Item) (Result, llm.Usage) {
+	model := item.Model
+	if model == "" {
+		model = r.Model
+	}
+	result := Result{ID: item.ID, Model: model}
+
+	prompt, err := r.prompt(item)
+	if err != nil {
+		result.Error = err.Error()
+		return result, llm.Usage{}
+	}
+
+	response, elapsedTime, err := r.Querier.QueryDetailed(ctx, prompt, model, r.options(item, model)...)
+	result.ElapsedMS = elapsedTime.Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result, llm.Usage{}
+	}
+
+	result.Response = response.Text
+	result.StopReason = response.Metadata.StopReason
+	result.InputTokens = response.Usage.InputTokens
+	result.OutputTokens = response.Usage.OutputTokens + response.Usage.ThinkingTokens
+	result.Cost, _ = llm.EstimateCost(model, response.Usage)
+	return result, response.Usage
+}
+
+// options returns the options for an item answered by a model
+func (r *Runner) options(item Item, model string) []llm.Option {
+	// Item settings come after the batch options so they take precedence
+	options := append([]llm.Option(nil), r.Options...)
+	if item.Temperature != nil {
+		options = append(options, llm.WithTemperature(*item.Temperature))
+	} else if llm.SupportsSampling(model) {
+		options = append(options, llm.WithTemperature(r.Temperature))
+	}
+	if item.System != "" {
+		options = append(options, llm.WithCustomParam("system", item.System))
+	}
+	if item.MaxTokens > 0 {
+		options = append(options, llm.WithMaxTokens(item.MaxTokens))
+	}
+	return options
+}
+
+// BatchRequests converts items into requests for a provider batch job on the
+// runner's model, with prompts and options built as Run builds them. A batch
+// job runs on a single model, so items choosing another model are rejected.
+func (r *Runner) BatchRequests(items []Item) ([]llm.BatchRequest, error) {
+	requests := make([]llm.BatchRequest, 0, len(items))
+	for _, item := range items {
+		if item.Model != "" && item.Model != r.Model {
+			return nil, fmt.Errorf("item %s uses %s, but a batch job runs on a single model (%s)", item.ID, item.Model, r.Model)
+		}
+
+		prompt, err := r.prompt(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %s: %w", item.ID, err)
+		}
+		requests = append(requests, llm.BatchRequest{ID: item.ID, Prompt: prompt, Options: r.options(item, r.Model)})
+	}
+	return requests, nil
+}
+
+// BatchResult converts the result of a provider batch job request into an
+// output line, with the cost at batch prices
+func BatchResult(model string, result llm.BatchResult) Result {
+	converted := Result{ID: result.ID, Model: model, Error: result.Error}
+	if result.Response == nil {
+		return converted
+	}
+
+	response := result.Response
+	converted.Response = response.Text
+	converted.StopReason = response.Metadata.StopReason
+	converted.InputTokens = response.Usage.InputTokens
+	converted.OutputTokens = response.Usage.OutputTokens + response.Usage.ThinkingTokens
+	converted.Cost, _ = llm.EstimateBatchCost(model, response.Usage)
+	return converted
+}
+
+// prompt returns the prompt of an item, rendering the template with its vars if it has none
+func (r *Runner) prompt(item Item) (string, error) {
+	if item.Prompt != "" {
+		return item.Prompt, nil
+	}
+	if r.Template == nil {
+		return "", errors.New("item has template vars but no template was given")
+	}
+
+	var prompt strings.Builder
+	if err := r.Template.Execute(&prompt, item.Vars); err != nil {
+		return "", fmt.Errorf("error rendering template: %w", err)
+	}
+	return prompt.String(), nil
+}
@@ -0,0 +1,290 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// mockQuerier answers prompts by echoing them, failing prompts containing "fail"
+type mockQuerier struct {
+	mutex   sync.Mutex
+	prompts []string
+	options []*llm.RequestOptions
+}
+
+func (m *mockQuerier) QueryDetailed(ctx context.Context, prompt, modelName string, options ...llm.Option) (*llm.Response, time.Duration, error) {
+	opts := &llm.RequestOptions{Model: modelName}
+	for _, option := range options {
+		option(opts)
+	}
+
+	m.mutex.Lock()
+	m.prompts = append(m.prompts, prompt)
+	m.options = append(m.options, opts)
+	m.mutex.Unlock()
+
+	if strings.Contains(prompt, "fail") {
+		return nil, 0, errors.New("mock failure")
+	}
+	return &llm.Response{
+		Text:     "echo: " + prompt,
+		Usage:    llm.Usage{InputTokens: 10, OutputTokens: 5},
+		Metadata: llm.Metadata{StopReason: llm.StopReasonEndTurn},
+	}, 20 * time.Millisecond, nil
+}
+
+func TestReadItems(t *testing.T) {
+	input := `{"prompt": "first"}
+
+{"id": "b", "vars": {"text": "second"}, "model": "deepseek-chat", "temperature": 0.2}
+`
+	items, err := ReadItems(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadItems returned error: %v", err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	if items[0].ID != "1" || items[0].Prompt != "first" {
+		t.Errorf("Expected item 1 with its line number as ID, got %+v", items[0])
+	}
+	if items[1].ID != "b" || items[1].Vars["text"] != "second" || items[1].Model != "deepseek-chat" {
+		t.Errorf("Expected item b with vars and model, got %+v", items[1])
+	}
+	if items[1].Temperature == nil || *items[1].Temperature != 0.2 {
+		t.Errorf("Expected temperature 0.2, got %v", items[1].Temperature)
+	}
+}
+
+func TestReadItemsRejectsInvalidLines(t *testing.T) {
+	tests := map[string]string{
+		"invalid JSON":  "{\"prompt\": \"x\"}\nnot json\n",
+		"empty item":    "{\"model\": \"deepseek-chat\"}\n",
+		"duplicate IDs": "{\"id\": \"a\", \"prompt\": \"x\"}\n{\"id\": \"a\", \"prompt\": \"y\"}\n",
+	}
+
+	for name, input := range tests {
+		if _, err := ReadItems(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	querier := &mockQuerier{}
+	temperature := 0.2
+	items := []Item{
+		{ID: "1", Prompt: "hello"},
+		{ID: "2", Vars: map[string]interface{}{"text": "world"}, Model: "deepseek-reasoner"},
+		{ID: "3", Prompt: "please fail"},
+		{ID: "4", Prompt: "done before"},
+		{ID: "5", Prompt: "custom", Temperature: &temperature, System: "Be brief.", MaxTokens: 50},
+	}
+
+	var progress []Progress
+	runner := &Runner{
+		Querier:     querier,
+		Model:       "deepseek-chat",
+		Temperature: 0.7,
+		Options:     []llm.Option{llm.WithMaxTokens(100)},
+		Template:    template.Must(template.New("prompt").Parse("Summarize: {{.text}}")),
+		Concurrency: 2,
+		Progress:    func(p Progress) { progress = append(progress, p) },
+	}
+
+	var out bytes.Buffer
+	summary, err := runner.Run(context.Background(), items, map[string]bool{"4": true}, &out)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if summary.Total != 5 || summary.Skipped != 1 || summary.Succeeded != 3 || summary.Failed != 1 {
+		t.Errorf("Expected 5 items with 1 skipped, 3 succeeded and 1 failed, got %+v", summary)
+	}
+	if summary.Usage.InputTokens != 30 || summary.Usage.OutputTokens != 15 {
+		t.Errorf("Expected usage of 3 successful items, got %+v", summary.Usage)
+	}
+	if summary.Cost == 0 {
+		t.Error("Expected an estimated cost")
+	}
+	if len(progress) != 4 || progress[3].Done != 4 || progress[3].Total != 4 || progress[3].Failed != 1 {
+		t.Errorf("Expected progress for 4 items ending with 1 failure, got %+v", progress)
+	}
+
+	// Every processed item has a result line
+	results := make(map[string]Result)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var result Result
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("Failed to parse result line %q: %v", line, err)
+		}
+		results[result.ID] = result
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	if results["1"].Response != "echo: hello" || results["1"].Model != "deepseek-chat" || results["1"].StopReason != llm.StopReasonEndTurn {
+		t.Errorf("Expected echoed response with default model, got %+v", results["1"])
+	}
+	if results["2"].Response != "echo: Summarize: world" || results["2"].Model != "deepseek-reasoner" {
+		t.Errorf("Expected rendered template with item model, got %+v", results["2"])
+	}
+	if results["3"].Error != "mock failure" || results["3"].Response != "" {
+		t.Errorf("Expected recorded failure, got %+v", results["3"])
+	}
+	if _, ok := results["4"]; ok {
+		t.Error("Expected skipped item to have no result")
+	}
+
+	// Item settings override the batch defaults, reasoning models get no default temperature
+	for i, prompt := range querier.prompts {
+		opts := querier.options[i]
+		switch prompt {
+		case "hello":
+			if opts.Temperature != 0.7 || opts.MaxTokens != 100 {
+				t.Errorf("Expected batch defaults, got temperature %f and max tokens %d", opts.Temperature, opts.MaxTokens)
+			}
+		case "Summarize: world":
+			if opts.Temperature != 0 {
+				t.Errorf("Expected no temperature for reasoning model, got %f", opts.Temperature)
+			}
+		case "custom":
+			if opts.Temperature != 0.2 || opts.MaxTokens != 50 || opts.CustomParams["system"] != "Be brief." {
+				t.Errorf("Expected item settings, got temperature %f, max tokens %d and params %v", opts.Temperature, opts.MaxTokens, opts.CustomParams)
+			}
+		}
+	}
+}
+
+func TestRunnerMissingTemplate(t *testing.T) {
+	runner := &Runner{Querier: &mockQuerier{}, Model: "deepseek-chat"}
+
+	var out bytes.Buffer
+	summary, err := runner.Run(context.Background(), []Item{{ID: "1", Vars: map[string]interface{}{"text": "x"}}}, nil, &out)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if summary.Failed != 1 || !strings.Contains(out.String(), "no template") {
+		t.Errorf("Expected item to fail without a template, got %+v and %q", summary, out.String())
+	}
+}
+
+func TestRunnerCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runner := &Runner{Querier: &mockQuerier{}, Model: "deepseek-chat"}
+
+	var out bytes.Buffer
+	_, err := runner.Run(ctx, []Item{{ID: "1", Prompt: "hello"}}, nil, &out)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no results after cancelling, got %q", out.String())
+	}
+}
+
+func TestCompletedIDs(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "gollm-batch-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	path := filepath.Join(tempDir, "output.jsonl")
+
+	// A missing output has nothing completed
+	completed, err := CompletedIDs(path)
+	if err != nil || len(completed) != 0 {
+		t.Errorf("Expected no completed IDs for missing file, got %v (%v)", completed, err)
+	}
+
+	output := `{"id":"1","model":"deepseek-chat","response":"ok","elapsed_ms":1}
+{"id":"2","model":"deepseek-chat","error":"failed","elapsed_ms":1}
+{"id":"3","model":"deepseek-chat","error":"failed","elapsed_ms":1}
+{"id":"3","model":"deepseek-chat","response":"ok on retry","elapsed_ms":1}
+{"id":"4","model":"deepse`
+	if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+		t.Fatalf("Failed to write output: %v", err)
+	}
+
+	completed, err = CompletedIDs(path)
+	if err != nil {
+		t.Fatalf("CompletedIDs returned error: %v", err)
+	}
+	if len(completed) != 2 || !completed["1"] || !completed["3"] {
+		t.Errorf("Expected items 1 and 3 completed, got %v", completed)
+	}
+}
+
+func TestRunnerBatchRequests(t *testing.T) {
+	runner := &Runner{
+		Model:       "claude-3-7-sonnet-latest",
+		Temperature: 0.5,
+		Options:     []llm.Option{llm.WithMaxTokens(100)},
+		Template:    template.Must(template.New("prompt").Parse("Summarize: {{.text}}")),
+	}
+
+	requests, err := runner.BatchRequests([]Item{
+		{ID: "a", Prompt: "hello", MaxTokens: 20},
+		{ID: "b", Vars: map[string]interface{}{"text": "world"}, Model: "claude-3-7-sonnet-latest"},
+	})
+	if err != nil {
+		t.Fatalf("BatchRequests returned error: %v", err)
+	}
+
+	if len(requests) != 2 || requests[0].ID != "a" || requests[1].Prompt != "Summarize: world" {
+		t.Fatalf("Expected requests with IDs and rendered prompts, got %+v", requests)
+	}
+	opts := &llm.RequestOptions{}
+	for _, option := range requests[0].Options {
+		option(opts)
+	}
+	if opts.MaxTokens != 20 || opts.Temperature != 0.5 {
+		t.Errorf("Expected item max tokens and default temperature, got %d and %f", opts.MaxTokens, opts.Temperature)
+	}
+
+	// A job runs on a single model
+	if _, err := runner.BatchRequests([]Item{{ID: "a", Prompt: "x", Model: "deepseek-chat"}}); err == nil {
+		t.Error("Expected error for item with another model")
+	}
+}
+
+func TestBatchResult(t *testing.T) {
+	result := BatchResult("claude-3-7-sonnet-latest", llm.BatchResult{
+		ID: "a",
+		Response: &llm.Response{
+			Text:     "Paris",
+			Usage:    llm.Usage{InputTokens: 1000000},
+			Metadata: llm.Metadata{StopReason: llm.StopReasonEndTurn},
+		},
+	})
+	if result.ID != "a" || result.Response != "Paris" || result.StopReason != llm.StopReasonEndTurn || result.InputTokens != 1000000 {
+		t.Errorf("Expected converted result, got %+v", result)
+	}
+	if result.Cost != 1.50 {
+		t.Errorf("Expected batch price of $1.50, got $%f", result.Cost)
+	}
+
+	failed := BatchResult("claude-3-7-sonnet-latest", llm.BatchResult{ID: "b", Error: "request expired"})
+	if failed.Error != "request expired" || failed.Response != "" {
+		t.Errorf("Expected recorded error, got %+v", failed)
+	}
+}
@@ -0,0 +1,169 @@
+// Package codeblock finds the fenced code blocks in markdown text, such as
+// model responses, with their language and any file name they are meant for.
+package codeblock
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Block is a fenced code block
+type Block struct {
+	Language string // Normalized language from the info string or file name, e.g. python for py
+	Info     string // Info string after the opening fence
+	Filename string // File name hinted at in the info string or the line before the block
+	Content  string // Code between the fences, ending with a newline unless empty
+	Line     int    // Line of the opening fence, starting at 1
+	Closed   bool   // Whether a closing fence was found, false for text cut off inside the block
+}
+
+var (
+	openingFence = regexp.MustCompile("^(\\s*)(```+|~~~+)\\s*([^`]*)$")
+
+	// infoFilename matches file names given as title=, file=, filename= or path= in an info string
+	infoFilename = regexp.MustCompile(`(?:title|file|filename|name|path)=["']?([^"'\s]+)`)
+
+	// backticked matches file names quoted in code spans in the line before a block
+	backticked = regexp.MustCompile("`([^`\\s]+)`")
+)
+
+// Parse returns the fenced code blocks of text in order. A block left open
+// at the end of the text, as in a truncated response, runs to the end.
+func Parse(text string) []Block {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var blocks []Block
+	for i := 0; i < len(lines); i++ {
+		match := openingFence.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+		indent, fence, info := len(match[1]), match[2], strings.TrimSpace(match[3])
+
+		block := Block{Info: info, Line: i + 1}
+		var content []string
+		for i++; i < len(lines); i++ {
+			closing := strings.TrimSpace(lines[i])
+			if strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]) == "" {
+				block.Closed = true
+				break
+			}
+			// Code in indented blocks, such as inside list items, is dedented as far as the fence
+			line := lines[i]
+			line = line[min(indent, len(line)-len(strings.TrimLeft(line, " \t"))):]
+			content = append(content, line)
+		}
+		// The text after an unclosed fence ends in the newline closing the response
+		for !block.Closed && len(content) > 0 && strings.TrimSpace(content[len(content)-1]) == "" {
+			content = content[:len(content)-1]
+		}
+		if len(content) > 0 {
+			block.Content = strings.Join(content, "\n") + "\n"
+		}
+
+		block.Language, block.Filename = parseInfo(info)
+		if block.Filename == "" {
+			block.Filename = precedingFilename(lines[:block.Line-1])
+		}
+		if block.Language == "" && block.Filename != "" {
+			block.Language = languageOf(block.Filename)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// parseInfo reads the language and any file name from an info string such
+// as "go", "go title=main.go", "python app.py", "go:main.go" or "main.go"
+func parseInfo(info string) (language, filename string) {
+	if match := infoFilename.FindStringSubmatch(info); match != nil && isFilename(match[1]) {
+		filename = match[1]
+	}
+
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return "", filename
+	}
+	first := fields[0]
+	if lang, name, ok := strings.Cut(first, ":"); ok && isFilename(name) {
+		return Normalize(lang), name
+	}
+	if isFilename(first) {
+		return languageOf(first), first
+	}
+	if filename == "" && len(fields) > 1 && isFilename(fields[1]) {
+		filename = fields[1]
+	}
+	return Normalize(first), filename
+}
+
+// precedingFilename finds a file name in the last non-blank line before a
+// block, such as "**main.go**", "### src/app.py", "File: main.go" or
+// "Create `cmd/main.go` with:"
+func precedingFilename(lines []string) string {
+	for i := len(lines) - 1; i >= 0 && i >= len(lines)-2; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+
+		// A line that is only a file name, possibly decorated
+		name := strings.Trim(line, "#*_`:>-/ \t")
+		for _, label := range []string{"File:", "Filename:", "file:", "filename:"} {
+			name = strings.TrimSpace(strings.TrimPrefix(name, label))
+		}
+		name = strings.Trim(name, "*_`: ")
+		if isFilename(name) {
+			return name
+		}
+
+		// A file name quoted in a sentence
+		for _, match := range backticked.FindAllStringSubmatch(line, -1) {
+			if isFilename(match[1]) {
+				return match[1]
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// isFilename reports whether a name looks like a relative file path with a
+// known extension or a well-known extensionless name such as Makefile
+func isFilename(name string) bool {
+	if name == "" || strings.ContainsAny(name, " \t\"'`*?<>|") {
+		return false
+	}
+	base := path.Base(name)
+	if _, ok := knownNames[base]; ok {
+		return true
+	}
+	ext := strings.TrimPrefix(path.Ext(base), ".")
+	if ext == "" || len(base) == len(ext)+1 {
+		return false
+	}
+	_, ok := knownExtensions[strings.ToLower(ext)]
+	return ok
+}
+
+// Filter returns the blocks in one of the languages, compared after
+// normalizing aliases such as py and python. Without languages all blocks
+// are returned.
+func Filter(blocks []Block, languages ...string) []Block {
+	if len(languages) == 0 {
+		return blocks
+	}
+	wanted := make(map[string]bool)
+	for _, language := range languages {
+		wanted[Normalize(language)] = true
+	}
+
+	var filtered []Block
+	for _, block := range blocks {
+		if wanted[block.Language] {
+			filtered = append(filtered, block)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,184 @@
+package codeblock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testResponse = "Here is the server.\n\n" +
+	"**main.go**\n" +
+	"```go\npackage main\n\nfunc main() {}\n```\n\n" +
+	"Add a test in `main_test.go`:\n\n" +
+	"```go\npackage main\n```\n\n" +
+	"Run it:\n\n" +
+	"```sh\ngo test ./...\n```\n\n" +
+	"1. Configure it:\n" +
+	"   ```yaml title=config/app.yml\n   port: 8080\n   ```\n\n" +
+	"Use `os.ReadFile` like this:\n\n" +
+	"````py\nprint(\"```\")\n````\n\n" +
+	"```\nplain\n"
+
+func TestParse(t *testing.T) {
+	blocks := Parse(testResponse)
+	if len(blocks) != 6 {
+		t.Fatalf("Expected 6 blocks, got %d: %+v", len(blocks), blocks)
+	}
+
+	tests := []struct {
+		language string
+		filename string
+		content  string
+	}{
+		{"go", "main.go", "package main\n\nfunc main() {}\n"},
+		{"go", "main_test.go", "package main\n"},
+		{"bash", "", "go test ./...\n"},
+		{"yaml", "config/app.yml", "port: 8080\n"},
+		{"python", "", "print(\"```\")\n"},
+		{"", "", "plain\n"},
+	}
+	for i, tt := range tests {
+		block := blocks[i]
+		if block.Language != tt.language || block.Filename != tt.filename || block.Content != tt.content {
+			t.Errorf("Block %d: expected %s %q %q, got %s %q %q", i+1, tt.language, tt.filename, tt.content, block.Language, block.Filename, block.Content)
+		}
+	}
+
+	if blocks[0].Line != 4 || !blocks[0].Closed {
+		t.Errorf("Expected first block closed at line 4, got %+v", blocks[0])
+	}
+	if blocks[5].Closed {
+		t.Error("Expected block cut off at the end to be open")
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		info     string
+		language string
+		filename string
+	}{
+		{"go", "go", ""},
+		{"Golang", "go", ""},
+		{"python app.py", "python", "app.py"},
+		{"go:cmd/main.go", "go", "cmd/main.go"},
+		{"src/index.ts", "typescript", "src/index.ts"},
+		{`js filename="web/app.js"`, "javascript", "web/app.js"},
+		{"Dockerfile", "dockerfile", "Dockerfile"},
+		{"go example", "go", ""},
+	}
+	for _, tt := range tests {
+		language, filename := parseInfo(tt.info)
+		if language != tt.language || filename != tt.filename {
+			t.Errorf("parseInfo(%q): expected %q %q, got %q %q", tt.info, tt.language, tt.filename, language, filename)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	blocks := Parse(testResponse)
+	if filtered := Filter(blocks, "golang"); len(filtered) != 2 {
+		t.Errorf("Expected 2 go blocks, got %d", len(filtered))
+	}
+	if filtered := Filter(blocks, "sh", "py"); len(filtered) != 2 || filtered[0].Language != "bash" || filtered[1].Language != "python" {
+		t.Errorf("Expected bash and python blocks, got %+v", filtered)
+	}
+	if filtered := Filter(blocks); len(filtered) != len(blocks) {
+		t.Errorf("Expected all blocks without languages, got %d", len(filtered))
+	}
+}
+
+func TestPlanAndWrite(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-codeblock-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	blocks := []Block{
+		{Language: "go", Filename: "main.go", Content: "package main\n"},
+		{Language: "go", Filename: "../../etc/passwd.txt", Content: "x\n"},
+		{Language: "python", Content: "print(1)\n"},
+		{Language: "go", Filename: "main.go", Content: "package other\n"},
+		{Language: "yaml", Filename: "config/app.yml", Content: "port: 1\n"},
+	}
+	files := Plan(tmpDir, blocks)
+
+	want := []string{"main.go", "passwd.txt", "snippet-3.py", "main-2.go", "config/app.yml"}
+	for i, file := range files {
+		if file.Path != want[i] {
+			t.Errorf("Expected file %d at %s, got %s", i+1, want[i], file.Path)
+		}
+	}
+	if !files[0].Exists || files[2].Exists {
+		t.Error("Expected only main.go to exist already")
+	}
+
+	// Existing files are only replaced when asked to, and nothing is written otherwise
+	if err := Write(tmpDir, files, false); err == nil || !strings.Contains(err.Error(), "main.go already exists") {
+		t.Errorf("Expected error for the existing main.go, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "config")); !os.IsNotExist(err) {
+		t.Errorf("Expected no files written after the error, got %v", err)
+	}
+
+	if err := Write(tmpDir, files, true); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(tmpDir, "config", "app.yml"))
+	if err != nil || string(content) != "port: 1\n" {
+		t.Errorf("Expected config/app.yml written, got %q (%v)", content, err)
+	}
+	if content, err := os.ReadFile(filepath.Join(tmpDir, "main.go")); err != nil || string(content) != "package main\n" {
+		t.Errorf("Expected main.go replaced, got %q (%v)", content, err)
+	}
+}
+
+func TestWriteSymlinks(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-codeblock-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	outside := filepath.Join(tmpDir, "outside")
+	dir := filepath.Join(tmpDir, "out")
+	for _, d := range []string{outside, dir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", d, err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(outside, "target.go"), filepath.Join(dir, "link.go")); err != nil {
+		t.Skipf("Symbolic links not supported: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "linked")); err != nil {
+		t.Fatalf("Failed to create link: %v", err)
+	}
+
+	for _, name := range []string{"link.go", "linked/app.go"} {
+		files := Plan(dir, []Block{{Language: "go", Filename: name, Content: "package main\n"}})
+		if err := Write(dir, files, true); err == nil || !strings.Contains(err.Error(), "symbolic link") {
+			t.Errorf("Expected error writing %s through a symbolic link, got %v", name, err)
+		}
+	}
+
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", outside, err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected nothing written outside the directory, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,173 @@
+package codeblock
+
+import (
+	"path"
+	"strings"
+)
+
+// aliases maps alternative names of languages to the names blocks use
+var aliases = map[string]string{
+	"golang":      "go",
+	"py":          "python",
+	"python3":     "python",
+	"js":          "javascript",
+	"node":        "javascript",
+	"mjs":         "javascript",
+	"ts":          "typescript",
+	"sh":          "bash",
+	"shell":       "bash",
+	"zsh":         "bash",
+	"console":     "bash",
+	"yml":         "yaml",
+	"rs":          "rust",
+	"c++":         "cpp",
+	"cc":          "cpp",
+	"cxx":         "cpp",
+	"hpp":         "cpp",
+	"h":           "c",
+	"cs":          "csharp",
+	"c#":          "csharp",
+	"rb":          "ruby",
+	"kt":          "kotlin",
+	"ps1":         "powershell",
+	"pwsh":        "powershell",
+	"md":          "markdown",
+	"htm":         "html",
+	"tf":          "terraform",
+	"hs":          "haskell",
+	"ex":          "elixir",
+	"patch":       "diff",
+	"text":        "plaintext",
+	"txt":         "plaintext",
+	"plain":       "plaintext",
+	"jsonc":       "json",
+	"docker":      "dockerfile",
+	"make":        "makefile",
+	"protobuf":    "proto",
+	"gql":         "graphql",
+	"objective-c": "objc",
+}
+
+// extensions maps languages to the extension of their files
+var extensions = map[string]string{
+	"go":         "go",
+	"python":     "py",
+	"javascript": "js",
+	"typescript": "ts",
+	"jsx":        "jsx",
+	"tsx":        "tsx",
+	"rust":       "rs",
+	"java":       "java",
+	"kotlin":     "kt",
+	"scala":      "scala",
+	"swift":      "swift",
+	"c":          "c",
+	"cpp":        "cpp",
+	"csharp":     "cs",
+	"objc":       "m",
+	"ruby":       "rb",
+	"php":        "php",
+	"perl":       "pl",
+	"lua":        "lua",
+	"r":          "r",
+	"dart":       "dart",
+	"haskell":    "hs",
+	"elixir":     "ex",
+	"erlang":     "erl",
+	"clojure":    "clj",
+	"bash":       "sh",
+	"fish":       "fish",
+	"powershell": "ps1",
+	"bat":        "bat",
+	"sql":        "sql",
+	"html":       "html",
+	"css":        "css",
+	"scss":       "scss",
+	"vue":        "vue",
+	"svelte":     "svelte",
+	"json":       "json",
+	"yaml":       "yaml",
+	"toml":       "toml",
+	"xml":        "xml",
+	"ini":        "ini",
+	"markdown":   "md",
+	"graphql":    "graphql",
+	"proto":      "proto",
+	"terraform":  "tf",
+	"hcl":        "hcl",
+	"diff":       "diff",
+	"csv":        "csv",
+	"plaintext":  "txt",
+}
+
+// knownNames are file names without an extension recognized as file name hints
+var knownNames = map[string]string{
+	"Dockerfile":    "dockerfile",
+	"Makefile":      "makefile",
+	"Containerfile": "dockerfile",
+	"Gemfile":       "ruby",
+	"Rakefile":      "ruby",
+	"Procfile":      "",
+	"Jenkinsfile":   "groovy",
+	".gitignore":    "",
+	".dockerignore": "",
+	".env":          "",
+	"go.mod":        "",
+}
+
+// knownExtensions maps the extensions recognized in file name hints to their languages
+var knownExtensions = map[string]string{
+	"yml":    "yaml",
+	"h":      "c",
+	"hpp":    "cpp",
+	"cc":     "cpp",
+	"mjs":    "javascript",
+	"cjs":    "javascript",
+	"conf":   "",
+	"cfg":    "",
+	"env":    "",
+	"lock":   "",
+	"sum":    "",
+	"mod":    "",
+	"gradle": "groovy",
+	"groovy": "groovy",
+	"zsh":    "bash",
+	"bash":   "bash",
+	"htm":    "html",
+	"patch":  "diff",
+}
+
+func init() {
+	for language, ext := range extensions {
+		if _, ok := knownExtensions[ext]; !ok {
+			knownExtensions[ext] = language
+		}
+	}
+}
+
+// Normalize returns the name blocks use for a language, resolving aliases
+// such as py for python
+func Normalize(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if alias, ok := aliases[language]; ok {
+		return alias
+	}
+	return language
+}
+
+// Extension returns the file extension for a language, txt when it is unknown
+func Extension(language string) string {
+	if ext, ok := extensions[Normalize(language)]; ok {
+		return ext
+	}
+	return "txt"
+}
+
+// languageOf returns the language of a file from its name
+func languageOf(name string) string {
+	base := path.Base(name)
+	if language, ok := knownNames[base]; ok {
+		return language
+	}
+	return knownExtensions[strings.ToLower(strings.TrimPrefix(path.Ext(base), "."))]
+}
@@ -0,0 +1,142 @@
+package codeblock
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// File is a code block to be written to a file
+type File struct {
+	Path   string // Path relative to the output directory
+	Block  Block
+	Exists bool // Whether writing the file replaces an existing one
+}
+
+// Plan decides the files the blocks are written to in dir: the file name
+// hinted for a block, or snippet-<n>.<extension> without one. Hints leaving
+// the directory keep only their base name, and repeated names are numbered.
+func Plan(dir string, blocks []Block) []File {
+	files := make([]File, 0, len(blocks))
+	used := make(map[string]bool)
+	for i, block := range blocks {
+		name := safePath(block.Filename)
+		if name == "" {
+			name = fmt.Sprintf("snippet-%d.%s", i+1, Extension(block.Language))
+		}
+		name = uniqueName(name, used)
+		used[name] = true
+
+		// Symbolic links count as existing files, even when they dangle
+		_, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name)))
+		files = append(files, File{Path: name, Block: block, Exists: err == nil})
+	}
+	return files
+}
+
+// Write writes planned files to dir, creating the directories they are in.
+// Existing files are only replaced when replace is set, and symbolic links
+// are never followed, so a file name from a model cannot write outside dir.
+func Write(dir string, files []File, replace bool) error {
+	// Check every file before writing any
+	for _, file := range files {
+		if err := checkTarget(dir, file.Path, replace); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		target := filepath.Join(dir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("error creating directory for %s: %w", file.Path, err)
+		}
+
+		// Replace a file by creating a new one, which O_EXCL keeps from
+		// following a link created since the check
+		if replace {
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error replacing %s: %w", file.Path, err)
+			}
+		}
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return fmt.Errorf("error writing %s: %w", file.Path, err)
+		}
+		if _, err := out.WriteString(file.Block.Content); err != nil {
+			_ = out.Close()
+			return fmt.Errorf("error writing %s: %w", file.Path, err)
+		}
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("error writing %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// checkTarget makes sure a file can be written to dir: no directory on the
+// way is a symbolic link and the file is either new or, when replacing, a
+// regular file
+func checkTarget(dir, name string, replace bool) error {
+	current := dir
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error checking %s: %w", name, err)
+		}
+
+		switch last := i == len(parts)-1; {
+		case info.Mode()&os.ModeSymlink != 0:
+			return fmt.Errorf("refusing to write %s through the symbolic link %s", name, current)
+		case !last && !info.IsDir():
+			return fmt.Errorf("cannot write %s, %s is not a directory", name, current)
+		case last && !info.Mode().IsRegular():
+			return fmt.Errorf("cannot write %s, it is not a regular file", name)
+		case last && !replace:
+			return fmt.Errorf("%s already exists", name)
+		}
+	}
+	return nil
+}
+
+// Lines counts the lines of a block's content
+func (b Block) Lines() int {
+	return strings.Count(b.Content, "\n")
+}
+
+// safePath cleans a hinted file name into a path inside the output
+// directory, keeping only the base name of absolute paths and paths with ..
+func safePath(name string) string {
+	if name == "" {
+		return ""
+	}
+	cleaned := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") || filepath.VolumeName(cleaned) != "" {
+		cleaned = path.Base(cleaned)
+	}
+	if cleaned == "." || cleaned == ".." || cleaned == "/" {
+		return ""
+	}
+	return cleaned
+}
+
+// uniqueName numbers a name already used, e.g. main-2.go
+func uniqueName(name string, used map[string]bool) string {
+	if !used[name] {
+		return name
+	}
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d%s", stem, n, ext)
+		if !used[candidate] {
+			return candidate
+		}
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 
 	"github.com/zerobang-dev/gollm/pkg/llm"
 	"gopkg.in/yaml.v3"
@@ -12,26 +12,114 @@ import (
 
 // ProviderConfig holds configuration for a specific provider
 type ProviderConfig struct {
-	APIKey string `yaml:"api_key"`
+	APIKey    string            `yaml:"api_key,omitempty"`
+	APIKeyCmd string            `yaml:"api_key_cmd,omitempty"`
+	BaseURL   string            `yaml:"base_url,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	Proxy     string            `yaml:"proxy,omitempty"`
+	CAFile    string            `yaml:"ca_file,omitempty"`
+	Timeout   string            `yaml:"timeout,omitempty"`
+
+	// Gemini generation settings, only valid for the google provider
+	Safety           map[string]string `yaml:"safety,omitempty"`
+	StopSequences    []string          `yaml:"stop_sequences,omitempty"`
+	PresencePenalty  *float64          `yaml:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64          `yaml:"frequency_penalty,omitempty"`
+	Seed             *int64            `yaml:"seed,omitempty"`
+	ResponseMIMEType string            `yaml:"response_mime_type,omitempty"`
+}
+
+// isZero reports whether the provider configuration holds no settings
+func (p ProviderConfig) isZero() bool {
+	return p.APIKey == "" && p.APIKeyCmd == "" && p.BaseURL == "" && len(p.Headers) == 0 &&
+		p.Proxy == "" && p.CAFile == "" && p.Timeout == "" && len(p.Safety) == 0 &&
+		len(p.StopSequences) == 0 && p.PresencePenalty == nil && p.FrequencyPenalty == nil &&
+		p.Seed == nil && p.ResponseMIMEType == ""
+}
+
+// SecretsConfig selects where API keys are stored
+type SecretsConfig struct {
+	Backend string `yaml:"backend,omitempty"`
+}
+
+// StdinConfig controls how piped input is combined with a prompt argument
+type StdinConfig struct {
+	Placement string `yaml:"placement,omitempty"`
+	Delimiter string `yaml:"delimiter,omitempty"`
 }
 
+// RenderConfig controls how responses are rendered in the terminal
+type RenderConfig struct {
+	Mode  string `yaml:"mode,omitempty"`
+	Theme string `yaml:"theme,omitempty"`
+}
+
+// ProjectConfigName is the name of the project-local config file, discovered
+// by walking up from the working directory
+const ProjectConfigName = ".gollm.yml"
+
 // Config represents the application configuration
 type Config struct {
-	Providers map[string]ProviderConfig `yaml:"providers"`
+	DefaultModel        string                    `yaml:"default_model,omitempty"`
+	SystemPrompt        string                    `yaml:"system_prompt,omitempty"`
+	Temperature         *float64                  `yaml:"temperature,omitempty"`
+	MaxTokens           int                       `yaml:"max_tokens,omitempty"`
+	Stdin               StdinConfig               `yaml:"stdin,omitempty"`
+	Render              RenderConfig              `yaml:"render,omitempty"`
+	AllowProjectSecrets bool                      `yaml:"allow_project_secrets,omitempty"`
+	Secrets             SecretsConfig             `yaml:"secrets,omitempty"`
+	Providers           map[string]ProviderConfig `yaml:"providers"`
+
+	path        string          // File the configuration was loaded from
+	projectPath string          // Project file merged over this configuration, if any
+	projectKeys map[string]bool // Keys whose values came from the project file
+	warnings    []string        // Problems encountered while merging the project file
+	secretStore SecretStore     // Secret store opened on first use
 }
 
-// Load loads the configuration from the file system
+// Load loads the user configuration and merges the nearest project config
+// file over it. The result is read-only: use LoadUser or LoadFile to edit.
 func Load() (*Config, error) {
+	config, err := LoadUser()
+	if err != nil {
+		return nil, err
+	}
+
+	projectPath, err := FindProjectConfig()
+	if err != nil {
+		return nil, err
+	}
+	if projectPath == "" {
+		return config, nil
+	}
+
+	project, err := LoadFile(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	config.mergeProject(project)
+
+	return config, nil
+}
+
+// LoadUser loads only the user configuration file
+func LoadUser() (*Config, error) {
 	configPath, err := getConfigPath()
 	if err != nil {
 		return nil, err
 	}
 
+	return LoadFile(configPath)
+}
+
+// LoadFile loads a single configuration file without merging any other file
+func LoadFile(configPath string) (*Config, error) {
 	// Check if config file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// Return empty config
 		return &Config{
 			Providers: make(map[string]ProviderConfig),
+			path:      configPath,
 		}, nil
 	}
 
@@ -44,22 +132,109 @@ func Load() (*Config, error) {
 	// Parse config
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+		return nil, fmt.Errorf("error parsing config file %s: %w", configPath, err)
 	}
 
 	// Initialize providers map if nil
 	if config.Providers == nil {
 		config.Providers = make(map[string]ProviderConfig)
 	}
+	config.path = configPath
 
 	return &config, nil
 }
 
-// Save saves the configuration to the file system
-func (c *Config) Save() error {
-	configPath, err := getConfigPath()
+// FindProjectConfig walks up from the working directory looking for a
+// project config file and returns its path, or "" if there is none
+func FindProjectConfig() (string, error) {
+	dir, err := os.Getwd()
 	if err != nil {
-		return err
+		return "", fmt.Errorf("error getting working directory: %w", err)
+	}
+
+	for {
+		candidate := filepath.Join(dir, ProjectConfigName)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// mergeProject overlays the values of a project config file. Keys holding
+// credentials are only accepted when the user config or environment allows it.
+func (c *Config) mergeProject(project *Config) {
+	c.projectPath = project.path
+	c.projectKeys = make(map[string]bool)
+
+	allowSecrets := c.projectSecretsAllowed()
+
+	for _, key := range expandKeys(project) {
+		value := key.get(project)
+		if value == "" {
+			continue
+		}
+
+		if key.userOnly {
+			c.warnings = append(c.warnings, fmt.Sprintf("ignoring %s in %s: it can only be set in the user config", key.Name, project.path))
+			continue
+		}
+
+		if key.restricted && !allowSecrets {
+			c.warnings = append(c.warnings, fmt.Sprintf("ignoring %s in %s: credentials and connection settings are not read from project files unless allow_project_secrets is enabled", key.Name, project.path))
+			continue
+		}
+
+		// API keys of a project stay in memory instead of the user's secret store
+		set := key.set
+		if key.provider != "" {
+			set = func(c *Config, value string) error { return c.SetAPIKey(key.provider, value) }
+		}
+		if err := set(c, value); err != nil {
+			c.warnings = append(c.warnings, fmt.Sprintf("ignoring %s in %s: %v", key.Name, project.path, err))
+			continue
+		}
+		c.projectKeys[key.Name] = true
+	}
+}
+
+// projectSecretsAllowed reports whether credentials may be read from project files
+func (c *Config) projectSecretsAllowed() bool {
+	if value := os.Getenv("GOLLM_ALLOW_PROJECT_SECRETS"); value != "" {
+		allowed, err := strconv.ParseBool(value)
+		return err == nil && allowed
+	}
+	return c.AllowProjectSecrets
+}
+
+// Warnings returns problems encountered while loading the configuration
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
+
+// ProjectPath returns the path of the project config file merged into this configuration, if any
+func (c *Config) ProjectPath() string {
+	return c.projectPath
+}
+
+// Save saves the configuration back to the file it was loaded from
+func (c *Config) Save() error {
+	if c.projectPath != "" {
+		return fmt.Errorf("cannot save configuration merged with %s, load a single file to edit it", c.projectPath)
+	}
+
+	configPath := c.path
+	if configPath == "" {
+		var err error
+		configPath, err = getConfigPath()
+		if err != nil {
+			return err
+		}
 	}
 
 	// Ensure directory exists
@@ -82,36 +257,43 @@ func (c *Config) Save() error {
 	return nil
 }
 
-// GetAPIKey returns the API key for the specified provider
+// GetAPIKey returns the API key for the specified provider, or "" if it is
+// not set or cannot be resolved
 func (c *Config) GetAPIKey(provider string) string {
-	// Check config file first
-	if providerConfig, ok := c.Providers[provider]; ok && providerConfig.APIKey != "" {
-		return providerConfig.APIKey
+	apiKey, _ := c.LookupAPIKey(provider)
+	return apiKey
+}
+
+// LookupAPIKey resolves the API key for the specified provider from, in order,
+// the <PROVIDER>_API_KEY environment variable, api_key_cmd, the configured
+// secret store and finally the plaintext config files
+func (c *Config) LookupAPIKey(provider string) (string, error) {
+	key, ok := LookupKey("providers." + provider + ".api_key")
+	if !ok {
+		return "", fmt.Errorf("unsupported provider: %s", provider)
 	}
 
-	// Fall back to environment variable (convert to uppercase for env var)
-	envKey := fmt.Sprintf("%s_API_KEY", strings.ToUpper(provider))
-	return os.Getenv(envKey)
+	setting := c.resolve(key, nil)
+	return setting.Value, setting.Err
 }
 
 // SetAPIKey sets the API key for the specified provider
 func (c *Config) SetAPIKey(provider, apiKey string) error {
 	// Validate provider
-	if !llm.IsValidProvider(provider) {
+	if !llm.UsesAPIKey(provider) {
 		return fmt.Errorf("unsupported provider: %s", provider)
 	}
 
-	if c.Providers == nil {
-		c.Providers = make(map[string]ProviderConfig)
-	}
-
-	config := c.Providers[provider]
-	config.APIKey = apiKey
-	c.Providers[provider] = config
+	c.updateProvider(provider, func(p *ProviderConfig) { p.APIKey = apiKey })
 
 	return nil
 }
 
+// Path returns the path to the config file, honoring GOLLM_CONFIG_DIR
+func Path() (string, error) {
+	return getConfigPath()
+}
+
 // getConfigPath returns the path to the config file
 func getConfigPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
@@ -0,0 +1,423 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSetGetUnset tests round-tripping values through the key registry
+func TestSetGetUnset(t *testing.T) {
+	cfg := &Config{}
+
+	if err := cfg.Set("temperature", "0.3"); err != nil {
+		t.Fatalf("Failed to set temperature: %v", err)
+	}
+	if err := cfg.Set("providers.anthropic.api_key", "test-key"); err != nil {
+		t.Fatalf("Failed to set API key: %v", err)
+	}
+
+	value, err := cfg.Get("temperature")
+	if err != nil {
+		t.Fatalf("Failed to get temperature: %v", err)
+	}
+	if value != "0.3" {
+		t.Errorf("Expected temperature '0.3', got %q", value)
+	}
+
+	if cfg.Providers["anthropic"].APIKey != "test-key" {
+		t.Errorf("Expected API key 'test-key', got %q", cfg.Providers["anthropic"].APIKey)
+	}
+
+	if err := cfg.Unset("providers.anthropic.api_key"); err != nil {
+		t.Fatalf("Failed to unset API key: %v", err)
+	}
+	if _, ok := cfg.Providers["anthropic"]; ok {
+		t.Error("Expected empty provider entry to be removed")
+	}
+
+	// Invalid values and unknown keys are rejected
+	if err := cfg.Set("temperature", "hot"); err == nil {
+		t.Error("Expected error for invalid temperature, got nil")
+	}
+	if err := cfg.Set("default_model", "no-such-model"); err == nil {
+		t.Error("Expected error for unknown model, got nil")
+	}
+	if err := cfg.Set("no_such_key", "x"); err == nil {
+		t.Error("Expected error for unknown key, got nil")
+	}
+	if err := cfg.Set("stdin.placement", "middle"); err == nil {
+		t.Error("Expected error for invalid stdin placement, got nil")
+	}
+	if err := cfg.Set("stdin.placement", StdinBefore); err != nil || cfg.Stdin.Placement != StdinBefore {
+		t.Errorf("Expected stdin placement %q, got %q (%v)", StdinBefore, cfg.Stdin.Placement, err)
+	}
+	if err := cfg.Set("render.mode", "sometimes"); err == nil {
+		t.Error("Expected error for invalid render mode, got nil")
+	}
+	if err := cfg.Set("render.theme", "light"); err != nil || cfg.Render.Theme != "light" {
+		t.Errorf("Expected render theme %q, got %q (%v)", "light", cfg.Render.Theme, err)
+	}
+}
+
+// TestLookupPrecedence tests that flags beat env, which beats the file and defaults
+func TestLookupPrecedence(t *testing.T) {
+	cfg := &Config{}
+	if err := cfg.Set("default_model", "deepseek-chat"); err != nil {
+		t.Fatalf("Failed to set default model: %v", err)
+	}
+
+	setting, err := cfg.Lookup("default_model", nil)
+	if err != nil {
+		t.Fatalf("Lookup returned error: %v", err)
+	}
+	if setting.Value != "deepseek-chat" || setting.Source != SourceFile {
+		t.Errorf("Expected deepseek-chat from file, got %q from %s", setting.Value, setting.Source)
+	}
+
+	t.Setenv("GOLLM_MODEL", "gemini-2.0-flash")
+	setting, _ = cfg.Lookup("default_model", nil)
+	if setting.Value != "gemini-2.0-flash" || setting.Source != SourceEnv {
+		t.Errorf("Expected gemini-2.0-flash from env, got %q from %s", setting.Value, setting.Source)
+	}
+
+	setting, _ = cfg.Lookup("default_model", map[string]string{"model": "deepseek-coder"})
+	if setting.Value != "deepseek-coder" || setting.Source != SourceFlag {
+		t.Errorf("Expected deepseek-coder from flag, got %q from %s", setting.Value, setting.Source)
+	}
+
+	setting, _ = cfg.Lookup("max_tokens", nil)
+	if setting.Value != "1000" || setting.Source != SourceDefault {
+		t.Errorf("Expected default max_tokens 1000, got %q from %s", setting.Value, setting.Source)
+	}
+}
+
+// TestValidate tests that validation reports problems with line numbers
+func TestValidate(t *testing.T) {
+	data := []byte(`default_model: claude-3-7-sonnet-latest
+temperature: hot
+colour: blue
+providers:
+  anthropic:
+    api_kye: test
+  unknown:
+    api_key: test
+`)
+
+	errs, err := Validate(data)
+	if err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+
+	expected := map[int]string{
+		2: "invalid temperature",
+		3: `unknown key "colour"`,
+		6: `unknown key "providers.anthropic.api_kye"`,
+		7: `unknown key "providers.unknown"`,
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d validation errors, got %d: %v", len(expected), len(errs), errs)
+	}
+
+	for _, validationErr := range errs {
+		want, ok := expected[validationErr.Line]
+		if !ok {
+			t.Errorf("Unexpected validation error: %v", validationErr)
+			continue
+		}
+		if !strings.Contains(validationErr.Message, want) {
+			t.Errorf("Expected line %d error to contain %q, got %q", validationErr.Line, want, validationErr.Message)
+		}
+	}
+
+	// A valid configuration produces no errors
+	errs, err = Validate([]byte("temperature: 0.5\nproviders:\n  google:\n    api_key: test\n"))
+	if err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+	if len(errs) != 0 {
+		t.Errorf("Expected no validation errors, got %v", errs)
+	}
+}
+
+// TestSaveAndLoad tests persisting the configuration to GOLLM_CONFIG_DIR
+func TestSaveAndLoad(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-config-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+	t.Setenv("GOLLM_CONFIG_DIR", tmpDir)
+
+	cfg, err := LoadUser()
+	if err != nil {
+		t.Fatalf("Failed to load empty config: %v", err)
+	}
+	if err := cfg.Set("max_tokens", "2048"); err != nil {
+		t.Fatalf("Failed to set max_tokens: %v", err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	configPath, err := Path()
+	if err != nil {
+		t.Fatalf("Failed to get config path: %v", err)
+	}
+	if !strings.HasPrefix(configPath, tmpDir) {
+		t.Errorf("Expected config path inside %s, got %s", tmpDir, configPath)
+	}
+
+	loaded, err := LoadUser()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if loaded.MaxTokens != 2048 {
+		t.Errorf("Expected max_tokens 2048, got %d", loaded.MaxTokens)
+	}
+}
+
+// TestLoadMergesProjectConfig tests discovery and merging of .gollm.yml files
+func TestLoadMergesProjectConfig(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-project-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	userDir := filepath.Join(tmpDir, "user")
+	projectDir := filepath.Join(tmpDir, "project")
+	nestedDir := filepath.Join(projectDir, "pkg", "nested")
+	if err := os.MkdirAll(nestedDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	t.Setenv("GOLLM_CONFIG_DIR", userDir)
+	t.Setenv("GOLLM_ALLOW_PROJECT_SECRETS", "")
+	t.Setenv("DEEPSEEK_API_KEY", "")
+
+	user, err := LoadUser()
+	if err != nil {
+		t.Fatalf("Failed to load user config: %v", err)
+	}
+	for name, value := range map[string]string{
+		"default_model": "claude-3-7-sonnet-latest",
+		"temperature":   "0.3",
+	} {
+		if err := user.Set(name, value); err != nil {
+			t.Fatalf("Failed to set %s: %v", name, err)
+		}
+	}
+	if err := user.Save(); err != nil {
+		t.Fatalf("Failed to save user config: %v", err)
+	}
+
+	projectPath := filepath.Join(projectDir, ProjectConfigName)
+	projectData := "default_model: deepseek-coder\nproviders:\n  deepseek:\n    api_key: project-key\n"
+	if err := os.WriteFile(projectPath, []byte(projectData), 0600); err != nil {
+		t.Fatalf("Failed to write project config: %v", err)
+	}
+
+	t.Chdir(nestedDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	setting, _ := cfg.Lookup("default_model", nil)
+	if setting.Value != "deepseek-coder" || setting.Source != SourceProject || setting.Origin != projectPath {
+		t.Errorf("Expected deepseek-coder from %s, got %q from %s (%s)", projectPath, setting.Value, setting.Source, setting.Origin)
+	}
+
+	setting, _ = cfg.Lookup("temperature", nil)
+	if setting.Value != "0.3" || setting.Source != SourceFile {
+		t.Errorf("Expected temperature 0.3 from user file, got %q from %s", setting.Value, setting.Source)
+	}
+
+	// API keys in project files are refused by default
+	if key := cfg.GetAPIKey("deepseek"); key != "" {
+		t.Errorf("Expected project API key to be ignored, got %q", key)
+	}
+	if len(cfg.Warnings()) != 1 {
+		t.Errorf("Expected 1 warning, got %v", cfg.Warnings())
+	}
+
+	// Merged configurations must not be written back to the user file
+	if err := cfg.Save(); err == nil {
+		t.Error("Expected error saving merged config, got nil")
+	}
+
+	// Explicitly allowing project secrets accepts the key
+	t.Setenv("GOLLM_ALLOW_PROJECT_SECRETS", "true")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if key := cfg.GetAPIKey("deepseek"); key != "project-key" {
+		t.Errorf("Expected project API key 'project-key', got %q", key)
+	}
+}
+
+// TestCheckProjectKey tests which keys may be written to project files
+func TestCheckProjectKey(t *testing.T) {
+	for _, name := range []string{"default_model", "temperature", "providers.google.seed"} {
+		if err := CheckProjectKey(name); err != nil {
+			t.Errorf("Expected %s to be allowed in project files, got %v", name, err)
+		}
+	}
+
+	for _, name := range []string{
+		"providers.anthropic.api_key",
+		"providers.anthropic.api_key_cmd",
+		"providers.anthropic.base_url",
+		"providers.anthropic.headers.X-Team",
+		"secrets.backend",
+		"allow_project_secrets",
+		"no_such_key",
+	} {
+		if err := CheckProjectKey(name); err == nil {
+			t.Errorf("Expected %s to be refused in project files, got nil", name)
+		}
+	}
+}
+
+// TestProviderSettings tests building connection settings for a provider
+func TestProviderSettings(t *testing.T) {
+	t.Setenv("ANTHROPIC_BASE_URL", "")
+	cfg := &Config{}
+
+	for name, value := range map[string]string{
+		"providers.anthropic.base_url":           "https://gateway.example.com/anthropic",
+		"providers.anthropic.headers.X-Team":     "platform",
+		"providers.anthropic.proxy":              "http://proxy.example.com:3128",
+		"providers.anthropic.timeout":            "5m",
+		"providers.deepseek.headers.X-Tenant-Id": "42",
+	} {
+		if err := cfg.Set(name, value); err != nil {
+			t.Fatalf("Failed to set %s: %v", name, err)
+		}
+	}
+
+	settings, err := cfg.ProviderSettings("anthropic")
+	if err != nil {
+		t.Fatalf("ProviderSettings returned error: %v", err)
+	}
+	if settings.BaseURL != "https://gateway.example.com/anthropic" {
+		t.Errorf("Expected gateway base URL, got %q", settings.BaseURL)
+	}
+	if settings.Headers["X-Team"] != "platform" {
+		t.Errorf("Expected X-Team header 'platform', got %q", settings.Headers["X-Team"])
+	}
+	if settings.HTTPClient == nil || settings.HTTPClient.Timeout != 5*time.Minute {
+		t.Errorf("Expected HTTP client with 5m timeout, got %+v", settings.HTTPClient)
+	}
+
+	// Providers without transport settings keep the default HTTP client
+	settings, err = cfg.ProviderSettings("deepseek")
+	if err != nil {
+		t.Fatalf("ProviderSettings returned error: %v", err)
+	}
+	if settings.HTTPClient != nil {
+		t.Error("Expected no custom HTTP client for deepseek")
+	}
+	if settings.Headers["X-Tenant-Id"] != "42" {
+		t.Errorf("Expected X-Tenant-Id header '42', got %q", settings.Headers["X-Tenant-Id"])
+	}
+
+	// Invalid values are rejected when set
+	if err := cfg.Set("providers.google.base_url", "not a url"); err == nil {
+		t.Error("Expected error for invalid base URL, got nil")
+	}
+	if err := cfg.Set("providers.google.timeout", "soon"); err == nil {
+		t.Error("Expected error for invalid timeout, got nil")
+	}
+
+	// Ollama needs no API key, only connection settings
+	if _, ok := LookupKey("providers.ollama.api_key"); ok {
+		t.Error("Expected no API key setting for ollama")
+	}
+	if _, ok := LookupKey("providers.ollama.base_url"); !ok {
+		t.Error("Expected a base URL setting for ollama")
+	}
+	if err := cfg.SetAPIKey("ollama", "key"); err == nil {
+		t.Error("Expected error setting an API key for ollama, got nil")
+	}
+	if _, ok := LookupKey("providers.openai.api_key"); !ok {
+		t.Error("Expected an API key setting for openai")
+	}
+}
+
+// TestGeminiSettings tests the Gemini generation setting keys
+func TestGeminiSettings(t *testing.T) {
+	cfg := &Config{}
+
+	for name, value := range map[string]string{
+		"providers.google.safety.harassment":  "block_only_high",
+		"providers.google.stop_sequences":     "END,STOP",
+		"providers.google.presence_penalty":   "0.5",
+		"providers.google.seed":               "42",
+		"providers.google.response_mime_type": "application/json",
+		"providers.google.safety.hate_speech": "block_none",
+		"providers.google.frequency_penalty":  "-1",
+	} {
+		if err := cfg.Set(name, value); err != nil {
+			t.Fatalf("Failed to set %s: %v", name, err)
+		}
+	}
+
+	google := cfg.Providers["google"]
+	if len(google.StopSequences) != 2 || google.StopSequences[1] != "STOP" {
+		t.Errorf("Expected stop sequences [END STOP], got %v", google.StopSequences)
+	}
+	if google.Seed == nil || *google.Seed != 42 {
+		t.Errorf("Expected seed 42, got %v", google.Seed)
+	}
+
+	settings, err := cfg.ProviderSettings("google")
+	if err != nil {
+		t.Fatalf("ProviderSettings returned error: %v", err)
+	}
+	if len(settings.Defaults) != 7 {
+		t.Errorf("Expected 7 default request options, got %d", len(settings.Defaults))
+	}
+
+	// Invalid values and keys for other providers are rejected
+	invalid := map[string]string{
+		"providers.google.safety.spam":         "block_none",
+		"providers.google.safety.harassment":   "sometimes",
+		"providers.google.presence_penalty":    "3",
+		"providers.google.response_mime_type":  "text/html",
+		"providers.anthropic.stop_sequences":   "END",
+		"providers.deepseek.safety.harassment": "block_none",
+	}
+	for name, value := range invalid {
+		if err := cfg.Set(name, value); err == nil {
+			t.Errorf("Expected error setting %s to %q, got nil", name, value)
+		}
+	}
+
+	// Stop sequences must be written as a list in config files
+	errs, err := Validate([]byte("providers:\n  google:\n    stop_sequences: END\n    safety:\n      harassment: block_none\n"))
+	if err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+	if len(errs) != 1 || errs[0].Line != 3 {
+		t.Errorf("Expected 1 validation error on line 3, got %v", errs)
+	}
+	errs, _ = Validate([]byte("providers:\n  google:\n    stop_sequences: [END, STOP]\n"))
+	if len(errs) != 0 {
+		t.Errorf("Expected no validation errors, got %v", errs)
+	}
+}
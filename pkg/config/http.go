@@ -0,0 +1,124 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// ProviderSettings builds the connection settings for a provider from its
+// base_url, headers, proxy, ca_file and timeout configuration, along with
+// default request options such as Gemini safety settings
+func (c *Config) ProviderSettings(provider string) (llm.ProviderSettings, error) {
+	value := func(field string) string {
+		setting, _ := c.Lookup("providers."+provider+"."+field, nil)
+		return setting.Value
+	}
+
+	settings := llm.ProviderSettings{
+		BaseURL:  value("base_url"),
+		Headers:  c.Providers[provider].Headers,
+		Defaults: c.requestDefaults(provider),
+	}
+
+	proxy, caFile := value("proxy"), value("ca_file")
+	timeout, err := c.ProviderTimeout(provider)
+	if err != nil {
+		return settings, err
+	}
+
+	// Without transport settings the caller's HTTP client is used as is
+	if proxy == "" && caFile == "" && timeout == 0 {
+		return settings, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return settings, fmt.Errorf("invalid proxy for %s: %w", provider, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return settings, fmt.Errorf("invalid ca_file for %s: %w", provider, err)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	// Without a timeout of its own the client keeps the caller's timeout
+	settings.HTTPClient = &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+
+	return settings, nil
+}
+
+// requestDefaults returns the request options configured for a provider
+func (c *Config) requestDefaults(provider string) []llm.Option {
+	providerConfig := c.Providers[provider]
+
+	var options []llm.Option
+	for category, threshold := range providerConfig.Safety {
+		options = append(options, llm.WithSafetySetting(category, threshold))
+	}
+	if len(providerConfig.StopSequences) > 0 {
+		options = append(options, llm.WithStopSequences(providerConfig.StopSequences...))
+	}
+	if providerConfig.PresencePenalty != nil {
+		options = append(options, llm.WithPresencePenalty(*providerConfig.PresencePenalty))
+	}
+	if providerConfig.FrequencyPenalty != nil {
+		options = append(options, llm.WithFrequencyPenalty(*providerConfig.FrequencyPenalty))
+	}
+	if providerConfig.Seed != nil {
+		options = append(options, llm.WithSeed(*providerConfig.Seed))
+	}
+	if providerConfig.ResponseMIMEType != "" {
+		options = append(options, llm.WithResponseMIMEType(providerConfig.ResponseMIMEType))
+	}
+	return options
+}
+
+// ProviderTimeout returns the configured request timeout for a provider, or 0 if unset
+func (c *Config) ProviderTimeout(provider string) (time.Duration, error) {
+	setting, err := c.Lookup("providers."+provider+".timeout", nil)
+	if err != nil || setting.Value == "" {
+		return 0, err
+	}
+
+	timeout, err := time.ParseDuration(setting.Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout for %s: %w", provider, err)
+	}
+	return timeout, nil
+}
+
+// loadCertPool returns the system certificate pool extended with the PEM certificates in path
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringService is the service name API keys are stored under in the OS keyring
+const keyringService = "gollm"
+
+// securityNotFound is the exit status of the macOS security tool when no
+// matching item exists (errSecItemNotFound)
+const securityNotFound = 44
+
+// keyringStore keeps API keys in the OS keyring through the platform's
+// command-line tools: security on macOS and secret-tool (libsecret) on Linux
+type keyringStore struct {
+	tool string
+}
+
+// newKeyringStore returns a keyring store if the platform tool is available
+func newKeyringStore() (*keyringStore, error) {
+	var tool string
+	switch runtime.GOOS {
+	case "darwin":
+		tool = "security"
+	case "linux", "freebsd", "openbsd":
+		tool = "secret-tool"
+	default:
+		return nil, fmt.Errorf("keyring backend is not supported on %s", runtime.GOOS)
+	}
+
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return nil, fmt.Errorf("keyring backend requires %s, which was not found in PATH", tool)
+	}
+
+	return &keyringStore{tool: path}, nil
+}
+
+// Name implements the SecretStore interface
+func (s *keyringStore) Name() string {
+	return "keyring"
+}
+
+// Get implements the SecretStore interface
+func (s *keyringStore) Get(provider string) (string, error) {
+	var args []string
+	if runtime.GOOS == "darwin" {
+		args = []string{"find-generic-password", "-s", keyringService, "-a", provider, "-w"}
+	} else {
+		args = []string{"lookup", "service", keyringService, "provider", provider}
+	}
+
+	output, err := s.run("", args...)
+	if err != nil {
+		return "", err
+	}
+
+	apiKey := strings.TrimSpace(output)
+	if apiKey == "" {
+		return "", ErrSecretNotFound
+	}
+	return apiKey, nil
+}
+
+// Set implements the SecretStore interface
+func (s *keyringStore) Set(provider, apiKey string) error {
+	// Both tools read the secret from stdin so it never appears in the
+	// process list. security reads the whole command in interactive mode,
+	// where failures are only reported on stderr.
+	if runtime.GOOS == "darwin" {
+		command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", securityQuote(keyringService), securityQuote(provider), securityQuote(apiKey))
+		_, err := s.run(command, "-i")
+		return err
+	}
+
+	_, err := s.run(apiKey, "store", "--label", fmt.Sprintf("gollm %s API key", provider), "service", keyringService, "provider", provider)
+	return err
+}
+
+// Delete implements the SecretStore interface
+func (s *keyringStore) Delete(provider string) error {
+	var args []string
+	if runtime.GOOS == "darwin" {
+		args = []string{"delete-generic-password", "-s", keyringService, "-a", provider}
+	} else {
+		args = []string{"clear", "service", keyringService, "provider", provider}
+	}
+
+	// Deleting a missing item is not an error
+	if _, err := s.run("", args...); err != nil && !errors.Is(err, ErrSecretNotFound) {
+		return err
+	}
+	return nil
+}
+
+// run executes the keyring tool with optional stdin and returns its stdout.
+// It returns ErrSecretNotFound when the tool reports that no item matched,
+// and other failures, such as a locked keyring, as errors.
+func (s *keyringStore) run(stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(s.tool, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	message := strings.TrimSpace(stderr.String())
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && keyringNotFound(runtime.GOOS, exitErr.ExitCode(), message) {
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		if message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+
+	// Interactive mode of security exits successfully even if the command failed
+	if len(args) > 0 && args[0] == "-i" && message != "" {
+		return "", errors.New(message)
+	}
+
+	return stdout.String(), nil
+}
+
+// keyringNotFound reports whether an exit status of the keyring tool means
+// that no matching item exists. secret-tool exits with 1 and prints nothing
+// in that case, while it explains any other failure on stderr.
+func keyringNotFound(goos string, exitCode int, stderr string) bool {
+	if goos == "darwin" {
+		return exitCode == securityNotFound
+	}
+	return exitCode == 1 && stderr == ""
+}
+
+// securityQuote quotes an argument for a command read by security in
+// interactive mode
+func securityQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
@@ -0,0 +1,768 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// Source identifies where an effective configuration value came from
+type Source string
+
+const (
+	SourceUnset   Source = "unset"
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceProject Source = "project"
+	SourceCommand Source = "command"
+	SourceStore   Source = "store"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Placements of piped input relative to the prompt argument
+const (
+	StdinBefore = "before"
+	StdinAfter  = "after"
+)
+
+// Modes of rendering markdown responses
+const (
+	RenderAuto   = "auto"
+	RenderAlways = "always"
+	RenderNever  = "never"
+)
+
+// Color themes of rendered markdown, matching the names pkg/markdown accepts
+const (
+	ThemeAuto  = "auto"
+	ThemeDark  = "dark"
+	ThemeLight = "light"
+)
+
+// Key describes a configuration setting addressable with `gollm config`
+type Key struct {
+	Name        string // Dotted path of the key, e.g. providers.anthropic.api_key
+	Description string // Short human readable description
+	Env         string // Environment variable overriding the file value, if any
+	Flag        string // Command-line flag overriding the value, if any
+	Default     string // Value used when the key is not set anywhere
+	Secret      bool   // Whether the value is masked when displayed
+
+	userOnly   bool   // Never read from project files
+	restricted bool   // Only read from project files when allow_project_secrets is enabled
+	provider   string // Provider whose API key this is, for keys kept in secret stores
+	list       bool   // Value is a comma-separated list, written as a YAML sequence in files
+	get        func(*Config) string
+	set        func(*Config, string) error
+	unset      func(*Config)
+
+	// lookup resolves values from sources other than the config files, such
+	// as external commands or secret stores
+	lookup func(*Config) (string, Source, string, error)
+
+	// Wildcard keys, named with a trailing "*", stand for a family of keys
+	// such as HTTP headers. expand returns the concrete key for a name and
+	// names lists the names present in a configuration.
+	expand func(name string) Key
+	names  func(*Config) []string
+}
+
+// Setting is the effective value of a key along with where it came from
+type Setting struct {
+	Key    Key
+	Value  string
+	Source Source
+	Origin string // Config file path, environment variable or flag providing the value
+	Err    error  // Error encountered while resolving the value, if any
+}
+
+// Keys returns every known configuration key in display order
+func Keys() []Key {
+	keys := []Key{
+		{
+			Name:        "default_model",
+			Description: "Model used when --model is not given",
+			Env:         "GOLLM_MODEL",
+			Flag:        "model",
+			Default:     "claude-3-7-sonnet-latest",
+			get:         func(c *Config) string { return c.DefaultModel },
+			set: func(c *Config, value string) error {
+				if !llm.IsValidModel(value) {
+					return fmt.Errorf("unknown model %q", value)
+				}
+				c.DefaultModel = value
+				return nil
+			},
+			unset: func(c *Config) { c.DefaultModel = "" },
+		},
+		{
+			Name:        "system_prompt",
+			Description: "System prompt sent with every query",
+			Env:         "GOLLM_SYSTEM_PROMPT",
+			Flag:        "system",
+			get:         func(c *Config) string { return c.SystemPrompt },
+			set: func(c *Config, value string) error {
+				c.SystemPrompt = value
+				return nil
+			},
+			unset: func(c *Config) { c.SystemPrompt = "" },
+		},
+		{
+			Name:        "temperature",
+			Description: "Sampling temperature (0.0-2.0)",
+			Env:         "GOLLM_TEMPERATURE",
+			Flag:        "temperature",
+			Default:     "0.7",
+			get: func(c *Config) string {
+				if c.Temperature == nil {
+					return ""
+				}
+				return strconv.FormatFloat(*c.Temperature, 'f', -1, 64)
+			},
+			set: func(c *Config, value string) error {
+				temperature, err := strconv.ParseFloat(value, 64)
+				if err != nil || temperature < 0 || temperature > 2 {
+					return fmt.Errorf("must be a number between 0.0 and 2.0, got %q", value)
+				}
+				c.Temperature = &temperature
+				return nil
+			},
+			unset: func(c *Config) { c.Temperature = nil },
+		},
+		{
+			Name:        "max_tokens",
+			Description: "Maximum number of tokens to generate",
+			Env:         "GOLLM_MAX_TOKENS",
+			Flag:        "max-tokens",
+			Default:     "1000",
+			get: func(c *Config) string {
+				if c.MaxTokens == 0 {
+					return ""
+				}
+				return strconv.Itoa(c.MaxTokens)
+			},
+			set: func(c *Config, value string) error {
+				maxTokens, err := strconv.Atoi(value)
+				if err != nil || maxTokens <= 0 {
+					return fmt.Errorf("must be a positive integer, got %q", value)
+				}
+				c.MaxTokens = maxTokens
+				return nil
+			},
+			unset: func(c *Config) { c.MaxTokens = 0 },
+		},
+		{
+			Name:        "stdin.placement",
+			Description: "Where piped input goes relative to the prompt argument: before or after",
+			Env:         "GOLLM_STDIN_PLACEMENT",
+			Flag:        "stdin-placement",
+			Default:     StdinAfter,
+			get:         func(c *Config) string { return c.Stdin.Placement },
+			set: func(c *Config, value string) error {
+				if value != StdinBefore && value != StdinAfter {
+					return fmt.Errorf("must be %s or %s, got %q", StdinBefore, StdinAfter, value)
+				}
+				c.Stdin.Placement = value
+				return nil
+			},
+			unset: func(c *Config) { c.Stdin.Placement = "" },
+		},
+		{
+			Name:        "stdin.delimiter",
+			Description: "Line written before and after piped input combined with a prompt argument",
+			Env:         "GOLLM_STDIN_DELIMITER",
+			Flag:        "stdin-delimiter",
+			Default:     "---",
+			get:         func(c *Config) string { return c.Stdin.Delimiter },
+			set: func(c *Config, value string) error {
+				if strings.Contains(value, "\n") {
+					return fmt.Errorf("must be a single line, got %q", value)
+				}
+				c.Stdin.Delimiter = value
+				return nil
+			},
+			unset: func(c *Config) { c.Stdin.Delimiter = "" },
+		},
+		{
+			Name:        "render.mode",
+			Description: "Render markdown responses in the terminal: auto (when stdout is a terminal), always or never",
+			Env:         "GOLLM_RENDER",
+			Flag:        "render",
+			Default:     RenderAuto,
+			get:         func(c *Config) string { return c.Render.Mode },
+			set: func(c *Config, value string) error {
+				if value != RenderAuto && value != RenderAlways && value != RenderNever {
+					return fmt.Errorf("must be %s, %s or %s, got %q", RenderAuto, RenderAlways, RenderNever, value)
+				}
+				c.Render.Mode = value
+				return nil
+			},
+			unset: func(c *Config) { c.Render.Mode = "" },
+		},
+		{
+			Name:        "render.theme",
+			Description: "Colors of rendered responses: auto (from the terminal background), dark or light",
+			Env:         "GOLLM_THEME",
+			Flag:        "theme",
+			Default:     ThemeAuto,
+			get:         func(c *Config) string { return c.Render.Theme },
+			set: func(c *Config, value string) error {
+				if value != ThemeAuto && value != ThemeDark && value != ThemeLight {
+					return fmt.Errorf("must be %s, %s or %s, got %q", ThemeAuto, ThemeDark, ThemeLight, value)
+				}
+				c.Render.Theme = value
+				return nil
+			},
+			unset: func(c *Config) { c.Render.Theme = "" },
+		},
+		{
+			Name:        "allow_project_secrets",
+			Description: "Read API keys from project .gollm.yml files",
+			Env:         "GOLLM_ALLOW_PROJECT_SECRETS",
+			userOnly:    true,
+			get: func(c *Config) string {
+				if !c.AllowProjectSecrets {
+					return ""
+				}
+				return "true"
+			},
+			set: func(c *Config, value string) error {
+				allow, err := strconv.ParseBool(value)
+				if err != nil {
+					return fmt.Errorf("must be true or false, got %q", value)
+				}
+				c.AllowProjectSecrets = allow
+				return nil
+			},
+			unset: func(c *Config) { c.AllowProjectSecrets = false },
+		},
+		{
+			Name:        "secrets.backend",
+			Description: "Where API keys are stored: plain, file or keyring",
+			Default:     BackendPlain,
+			userOnly:    true,
+			get:         func(c *Config) string { return c.Secrets.Backend },
+			set: func(c *Config, value string) error {
+				switch value {
+				case BackendPlain, BackendFile, BackendKeyring:
+					c.Secrets.Backend = value
+					return nil
+				}
+				return fmt.Errorf("must be one of %s, %s or %s, got %q", BackendPlain, BackendFile, BackendKeyring, value)
+			},
+			unset: func(c *Config) { c.Secrets.Backend = "" },
+		},
+	}
+
+	// Provider keys are generated for every supported provider
+	for _, provider := range sortedProviders() {
+		keys = append(keys, providerKeys(provider)...)
+	}
+
+	return keys
+}
+
+// sortedProviders returns the chat and embedding provider names in alphabetical order
+func sortedProviders() []string {
+	providers := llm.AllProviders()
+	sort.Strings(providers)
+	return providers
+}
+
+// providerKeys returns the configuration keys for a single provider, with
+// API key settings only for providers that need one
+func providerKeys(provider string) []Key {
+	prefix := "providers." + provider + "."
+
+	var keys []Key
+	if llm.UsesAPIKey(provider) {
+		keys = append(keys, apiKeyKeys(provider)...)
+	}
+	keys = append(keys, connectionKeys(provider)...)
+
+	// Generation settings only Gemini supports
+	if provider == "google" {
+		keys = append(keys, geminiKeys(prefix)...)
+	}
+
+	return keys
+}
+
+// apiKeyKeys returns the keys setting the API key of a provider
+func apiKeyKeys(provider string) []Key {
+	prefix := "providers." + provider + "."
+
+	return []Key{
+		{
+			Name:        prefix + "api_key",
+			Description: fmt.Sprintf("API key for %s", provider),
+			Env:         fmt.Sprintf("%s_API_KEY", strings.ToUpper(provider)),
+			Secret:      true,
+			restricted:  true,
+			provider:    provider,
+			get:         func(c *Config) string { return c.Providers[provider].APIKey },
+			set: func(c *Config, value string) error {
+				return c.StoreAPIKey(provider, value)
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.APIKey = "" })
+			},
+			lookup: func(c *Config) (string, Source, string, error) {
+				return c.lookupSecret(provider)
+			},
+		},
+		{
+			Name:        prefix + "api_key_cmd",
+			Description: fmt.Sprintf("Command printing the API key for %s", provider),
+			userOnly:    true,
+			get:         func(c *Config) string { return c.Providers[provider].APIKeyCmd },
+			set: func(c *Config, value string) error {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.APIKeyCmd = value })
+				return nil
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.APIKeyCmd = "" })
+			},
+		},
+	}
+}
+
+// connectionKeys returns the keys configuring how a provider is reached
+func connectionKeys(provider string) []Key {
+	prefix := "providers." + provider + "."
+
+	return []Key{
+		{
+			Name:        prefix + "base_url",
+			Description: fmt.Sprintf("API root for %s, e.g. an internal gateway", provider),
+			Env:         fmt.Sprintf("%s_BASE_URL", strings.ToUpper(provider)),
+			restricted:  true,
+			get:         func(c *Config) string { return c.Providers[provider].BaseURL },
+			set: func(c *Config, value string) error {
+				if err := validateURL(value); err != nil {
+					return err
+				}
+				c.updateProvider(provider, func(p *ProviderConfig) { p.BaseURL = value })
+				return nil
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.BaseURL = "" })
+			},
+		},
+		{
+			Name:        prefix + "headers.*",
+			Description: fmt.Sprintf("Extra HTTP header sent to %s", provider),
+			Secret:      true,
+			restricted:  true,
+			names: func(c *Config) []string {
+				names := make([]string, 0, len(c.Providers[provider].Headers))
+				for name := range c.Providers[provider].Headers {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return names
+			},
+			expand: func(header string) Key {
+				return Key{
+					Name:        prefix + "headers." + header,
+					Description: fmt.Sprintf("HTTP header %s sent to %s", header, provider),
+					Secret:      true,
+					restricted:  true,
+					get:         func(c *Config) string { return c.Providers[provider].Headers[header] },
+					set: func(c *Config, value string) error {
+						c.updateProvider(provider, func(p *ProviderConfig) {
+							if p.Headers == nil {
+								p.Headers = make(map[string]string)
+							}
+							p.Headers[header] = value
+						})
+						return nil
+					},
+					unset: func(c *Config) {
+						c.updateProvider(provider, func(p *ProviderConfig) { delete(p.Headers, header) })
+					},
+				}
+			},
+		},
+		{
+			Name:        prefix + "proxy",
+			Description: fmt.Sprintf("HTTP(S) proxy URL for %s requests", provider),
+			restricted:  true,
+			get:         func(c *Config) string { return c.Providers[provider].Proxy },
+			set: func(c *Config, value string) error {
+				if err := validateURL(value); err != nil {
+					return err
+				}
+				c.updateProvider(provider, func(p *ProviderConfig) { p.Proxy = value })
+				return nil
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.Proxy = "" })
+			},
+		},
+		{
+			Name:        prefix + "ca_file",
+			Description: fmt.Sprintf("PEM bundle of extra CA certificates trusted for %s", provider),
+			restricted:  true,
+			get:         func(c *Config) string { return c.Providers[provider].CAFile },
+			set: func(c *Config, value string) error {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.CAFile = value })
+				return nil
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.CAFile = "" })
+			},
+		},
+		{
+			Name:        prefix + "timeout",
+			Description: fmt.Sprintf("Request timeout for %s, e.g. 90s or 5m", provider),
+			get:         func(c *Config) string { return c.Providers[provider].Timeout },
+			set: func(c *Config, value string) error {
+				timeout, err := time.ParseDuration(value)
+				if err != nil || timeout <= 0 {
+					return fmt.Errorf("must be a positive duration such as 90s, got %q", value)
+				}
+				c.updateProvider(provider, func(p *ProviderConfig) { p.Timeout = value })
+				return nil
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.Timeout = "" })
+			},
+		},
+	}
+}
+
+// geminiKeys returns the Gemini generation setting keys under prefix
+func geminiKeys(prefix string) []Key {
+	const provider = "google"
+
+	// penaltyKey returns a key for a presence or frequency penalty field
+	penaltyKey := func(name, description string, field func(*ProviderConfig) **float64) Key {
+		return Key{
+			Name:        prefix + name,
+			Description: description,
+			get: func(c *Config) string {
+				providerConfig := c.Providers[provider]
+				if penalty := *field(&providerConfig); penalty != nil {
+					return strconv.FormatFloat(*penalty, 'f', -1, 64)
+				}
+				return ""
+			},
+			set: func(c *Config, value string) error {
+				penalty, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return fmt.Errorf("must be a number, got %q", value)
+				}
+				if err := llm.ValidatePenalty(penalty); err != nil {
+					return err
+				}
+				c.updateProvider(provider, func(p *ProviderConfig) { *field(p) = &penalty })
+				return nil
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { *field(p) = nil })
+			},
+		}
+	}
+
+	return []Key{
+		{
+			Name:        prefix + "safety.*",
+			Description: "Gemini block threshold for a harm category",
+			names: func(c *Config) []string {
+				names := make([]string, 0, len(c.Providers[provider].Safety))
+				for name := range c.Providers[provider].Safety {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return names
+			},
+			expand: func(category string) Key {
+				return Key{
+					Name:        prefix + "safety." + category,
+					Description: fmt.Sprintf("Gemini block threshold for %s", category),
+					get:         func(c *Config) string { return c.Providers[provider].Safety[category] },
+					set: func(c *Config, value string) error {
+						if _, err := llm.NormalizeHarmCategory(category); err != nil {
+							return err
+						}
+						if _, err := llm.NormalizeBlockThreshold(value); err != nil {
+							return err
+						}
+						c.updateProvider(provider, func(p *ProviderConfig) {
+							if p.Safety == nil {
+								p.Safety = make(map[string]string)
+							}
+							p.Safety[category] = value
+						})
+						return nil
+					},
+					unset: func(c *Config) {
+						c.updateProvider(provider, func(p *ProviderConfig) { delete(p.Safety, category) })
+					},
+				}
+			},
+		},
+		{
+			Name:        prefix + "stop_sequences",
+			Description: "Comma-separated sequences that stop Gemini generation",
+			list:        true,
+			get:         func(c *Config) string { return strings.Join(c.Providers[provider].StopSequences, ",") },
+			set: func(c *Config, value string) error {
+				sequences := strings.Split(value, ",")
+				c.updateProvider(provider, func(p *ProviderConfig) { p.StopSequences = sequences })
+				return nil
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.StopSequences = nil })
+			},
+		},
+		penaltyKey("presence_penalty", "Gemini penalty for tokens already in the output (-2.0 to 2.0)",
+			func(p *ProviderConfig) **float64 { return &p.PresencePenalty }),
+		penaltyKey("frequency_penalty", "Gemini penalty scaled by token frequency (-2.0 to 2.0)",
+			func(p *ProviderConfig) **float64 { return &p.FrequencyPenalty }),
+		{
+			Name:        prefix + "seed",
+			Description: "Gemini sampling seed for reproducible responses",
+			get: func(c *Config) string {
+				if seed := c.Providers[provider].Seed; seed != nil {
+					return strconv.FormatInt(*seed, 10)
+				}
+				return ""
+			},
+			set: func(c *Config, value string) error {
+				seed, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return fmt.Errorf("must be an integer, got %q", value)
+				}
+				c.updateProvider(provider, func(p *ProviderConfig) { p.Seed = &seed })
+				return nil
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.Seed = nil })
+			},
+		},
+		{
+			Name:        prefix + "response_mime_type",
+			Description: "Gemini response MIME type: text/plain, application/json or text/x.enum",
+			get:         func(c *Config) string { return c.Providers[provider].ResponseMIMEType },
+			set: func(c *Config, value string) error {
+				if err := llm.ValidateResponseMIMEType(value); err != nil {
+					return err
+				}
+				c.updateProvider(provider, func(p *ProviderConfig) { p.ResponseMIMEType = value })
+				return nil
+			},
+			unset: func(c *Config) {
+				c.updateProvider(provider, func(p *ProviderConfig) { p.ResponseMIMEType = "" })
+			},
+		},
+	}
+}
+
+// validateURL checks that a value is an absolute http or https URL
+func validateURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("must be an http or https URL, got %q", value)
+	}
+	return nil
+}
+
+// LookupKey returns the key with the given name, expanding wildcard keys
+func LookupKey(name string) (Key, bool) {
+	return findKey(Keys(), name)
+}
+
+// CheckProjectKey returns an error for keys that cannot be written to a
+// project file, which is usually committed: user-only settings, credentials
+// and connection settings
+func CheckProjectKey(name string) error {
+	key, ok := LookupKey(name)
+	if !ok {
+		return fmt.Errorf("unknown config key: %s", name)
+	}
+	if key.userOnly {
+		return fmt.Errorf("%s can only be set in the user config", name)
+	}
+	if key.restricted || key.Secret {
+		return fmt.Errorf("%s holds credentials or connection settings and is not written to project files, set it in the user config instead", name)
+	}
+	return nil
+}
+
+// findKey returns the key with the given name from keys, expanding wildcard keys
+func findKey(keys []Key, name string) (Key, bool) {
+	for _, key := range keys {
+		if key.expand == nil {
+			if key.Name == name {
+				return key, true
+			}
+			continue
+		}
+
+		// Wildcards match exactly one additional path segment
+		prefix := strings.TrimSuffix(key.Name, "*")
+		if suffix, ok := strings.CutPrefix(name, prefix); ok && suffix != "" && !strings.Contains(suffix, ".") {
+			return key.expand(suffix), true
+		}
+	}
+	return Key{}, false
+}
+
+// expandKeys returns every known key with wildcard keys replaced by the
+// concrete keys present in the configuration
+func expandKeys(c *Config) []Key {
+	var keys []Key
+	for _, key := range Keys() {
+		if key.expand == nil {
+			keys = append(keys, key)
+			continue
+		}
+		for _, name := range key.names(c) {
+			keys = append(keys, key.expand(name))
+		}
+	}
+	return keys
+}
+
+// Get returns the value of the named key as stored in the config file
+func (c *Config) Get(name string) (string, error) {
+	key, ok := LookupKey(name)
+	if !ok {
+		return "", fmt.Errorf("unknown config key: %s", name)
+	}
+	return key.get(c), nil
+}
+
+// Set validates and stores a value for the named key. API keys are written
+// to the configured secret store when there is one.
+func (c *Config) Set(name, value string) error {
+	key, ok := LookupKey(name)
+	if !ok {
+		return fmt.Errorf("unknown config key: %s", name)
+	}
+	if key.provider != "" {
+		return key.set(c, value)
+	}
+	if err := key.set(c, value); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+// Unset removes the named key from the configuration
+func (c *Config) Unset(name string) error {
+	key, ok := LookupKey(name)
+	if !ok {
+		return fmt.Errorf("unknown config key: %s", name)
+	}
+	key.unset(c)
+
+	// Also remove API keys from the secret store
+	if key.provider != "" {
+		store, err := c.SecretStore()
+		if err != nil {
+			return err
+		}
+		if store != nil {
+			if err := store.Delete(key.provider); err != nil {
+				return fmt.Errorf("error removing API key from %s: %w", store.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Lookup returns the effective value of the named key. Flags holds values of
+// command-line flags explicitly set by the user, keyed by flag name.
+func (c *Config) Lookup(name string, flags map[string]string) (Setting, error) {
+	key, ok := LookupKey(name)
+	if !ok {
+		return Setting{}, fmt.Errorf("unknown config key: %s", name)
+	}
+	return c.resolve(key, flags), nil
+}
+
+// Settings returns the effective value of every known key
+func (c *Config) Settings(flags map[string]string) []Setting {
+	keys := expandKeys(c)
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		settings = append(settings, c.resolve(key, flags))
+	}
+	return settings
+}
+
+// resolve applies flag, environment, file and default precedence for a key
+func (c *Config) resolve(key Key, flags map[string]string) Setting {
+	if key.Flag != "" {
+		if value, ok := flags[key.Flag]; ok {
+			return Setting{Key: key, Value: value, Source: SourceFlag, Origin: "--" + key.Flag}
+		}
+	}
+
+	if key.Env != "" {
+		if value := os.Getenv(key.Env); value != "" {
+			return Setting{Key: key, Value: value, Source: SourceEnv, Origin: key.Env}
+		}
+	}
+
+	if key.lookup != nil {
+		value, source, origin, err := key.lookup(c)
+		if err != nil {
+			return Setting{Key: key, Source: source, Origin: origin, Err: err}
+		}
+		if value != "" {
+			return Setting{Key: key, Value: value, Source: source, Origin: origin}
+		}
+	}
+
+	if value := key.get(c); value != "" {
+		if c.projectKeys[key.Name] {
+			return Setting{Key: key, Value: value, Source: SourceProject, Origin: c.projectPath}
+		}
+		return Setting{Key: key, Value: value, Source: SourceFile, Origin: c.path}
+	}
+
+	if key.Default != "" {
+		return Setting{Key: key, Value: key.Default, Source: SourceDefault, Origin: "built-in"}
+	}
+
+	return Setting{Key: key, Source: SourceUnset}
+}
+
+// updateProvider applies fn to a provider's configuration, dropping the
+// provider entry entirely once it no longer holds any settings
+func (c *Config) updateProvider(provider string, fn func(*ProviderConfig)) {
+	if c.Providers == nil {
+		c.Providers = make(map[string]ProviderConfig)
+	}
+
+	providerConfig := c.Providers[provider]
+	fn(&providerConfig)
+
+	if providerConfig.isZero() {
+		delete(c.Providers, provider)
+		return
+	}
+	c.Providers[provider] = providerConfig
+}
+
+// Mask hides most of a secret value for display
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", 4) + value[len(value)-4:]
+}
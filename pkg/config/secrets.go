@@ -0,0 +1,371 @@
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Secret store backends selectable with the secrets.backend key
+const (
+	BackendPlain   = "plain"
+	BackendFile    = "file"
+	BackendKeyring = "keyring"
+)
+
+// secretsFileName is the name of the encrypted secrets file in the config directory
+const secretsFileName = "secrets.enc"
+
+// scrypt parameters used to derive the secrets file key from the passphrase
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// ErrSecretNotFound is returned when a secret store holds no value for a provider
+var ErrSecretNotFound = errors.New("secret not found")
+
+// PassphraseFunc returns the passphrase for the encrypted secrets file when
+// GOLLM_SECRETS_PASSPHRASE is not set. Confirm is true when a new file is
+// being created and the passphrase should be entered twice. The CLI replaces
+// it with an interactive prompt.
+var PassphraseFunc = func(confirm bool) (string, error) {
+	return "", errors.New("no passphrase available for the secrets file, set GOLLM_SECRETS_PASSPHRASE")
+}
+
+// SecretStore stores provider API keys outside the plaintext config file
+type SecretStore interface {
+	// Name returns the backend name
+	Name() string
+	// Get returns the API key for a provider or ErrSecretNotFound
+	Get(provider string) (string, error)
+	// Set stores the API key for a provider
+	Set(provider, apiKey string) error
+	// Delete removes the API key for a provider
+	Delete(provider string) error
+}
+
+// SecretStore returns the store configured with secrets.backend, or nil when
+// API keys are kept in plaintext in the config file
+func (c *Config) SecretStore() (SecretStore, error) {
+	if c.secretStore != nil {
+		return c.secretStore, nil
+	}
+
+	var store SecretStore
+	switch c.Secrets.Backend {
+	case "", BackendPlain:
+		return nil, nil
+	case BackendFile:
+		configPath, err := getConfigPath()
+		if err != nil {
+			return nil, err
+		}
+		store = newFileStore(filepath.Join(filepath.Dir(configPath), secretsFileName))
+	case BackendKeyring:
+		keyring, err := newKeyringStore()
+		if err != nil {
+			return nil, err
+		}
+		store = keyring
+	default:
+		return nil, fmt.Errorf("unknown secrets backend: %s", c.Secrets.Backend)
+	}
+
+	c.secretStore = store
+	return store, nil
+}
+
+// StoreAPIKey saves an API key in the configured secret store, falling back
+// to the plaintext config file when no store is configured
+func (c *Config) StoreAPIKey(provider, apiKey string) error {
+	store, err := c.SecretStore()
+	if err != nil {
+		return err
+	}
+	if store == nil {
+		return c.SetAPIKey(provider, apiKey)
+	}
+
+	if err := store.Set(provider, apiKey); err != nil {
+		return fmt.Errorf("error storing API key in %s: %w", store.Name(), err)
+	}
+
+	// Never keep a plaintext copy next to the stored key
+	c.updateProvider(provider, func(p *ProviderConfig) { p.APIKey = "" })
+	return nil
+}
+
+// MigrateSecrets moves plaintext API keys from the config into the secret
+// store and returns the providers that were migrated. The caller must save
+// the configuration afterwards.
+func (c *Config) MigrateSecrets(store SecretStore) ([]string, error) {
+	var migrated []string
+	for _, provider := range sortedProviders() {
+		apiKey := c.Providers[provider].APIKey
+		if apiKey == "" {
+			continue
+		}
+
+		if err := store.Set(provider, apiKey); err != nil {
+			return migrated, fmt.Errorf("error migrating %s API key: %w", provider, err)
+		}
+		c.updateProvider(provider, func(p *ProviderConfig) { p.APIKey = "" })
+		migrated = append(migrated, provider)
+	}
+
+	return migrated, nil
+}
+
+// lookupSecret resolves an API key from api_key_cmd or the secret store
+func (c *Config) lookupSecret(provider string) (string, Source, string, error) {
+	if command := c.Providers[provider].APIKeyCmd; command != "" {
+		apiKey, err := runKeyCommand(command)
+		if err != nil {
+			return "", SourceCommand, command, fmt.Errorf("error running api_key_cmd for %s: %w", provider, err)
+		}
+		return apiKey, SourceCommand, command, nil
+	}
+
+	store, err := c.SecretStore()
+	if err != nil || store == nil {
+		return "", SourceStore, "", err
+	}
+
+	apiKey, err := store.Get(provider)
+	if errors.Is(err, ErrSecretNotFound) {
+		return "", SourceStore, store.Name(), nil
+	}
+	if err != nil {
+		return "", SourceStore, store.Name(), fmt.Errorf("error reading %s API key from %s: %w", provider, store.Name(), err)
+	}
+	return apiKey, SourceStore, store.Name(), nil
+}
+
+// runKeyCommand runs a shell command printing an API key on stdout
+func runKeyCommand(command string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	// Tools like pass print the secret on the first line
+	apiKey, _, _ := strings.Cut(stdout.String(), "\n")
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return "", errors.New("command printed no API key")
+	}
+	return apiKey, nil
+}
+
+// fileStore keeps API keys in a passphrase-encrypted file using scrypt for key
+// derivation and XChaCha20-Poly1305 for encryption
+type fileStore struct {
+	path       string
+	passphrase string
+	secrets    map[string]string
+}
+
+// encryptedFile is the on-disk format of the secrets file
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// newFileStore creates a secret store backed by the encrypted file at path
+func newFileStore(path string) *fileStore {
+	return &fileStore{path: path}
+}
+
+// Name implements the SecretStore interface
+func (s *fileStore) Name() string {
+	return s.path
+}
+
+// Get implements the SecretStore interface
+func (s *fileStore) Get(provider string) (string, error) {
+	if err := s.load(); err != nil {
+		return "", err
+	}
+
+	apiKey, ok := s.secrets[provider]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return apiKey, nil
+}
+
+// Set implements the SecretStore interface
+func (s *fileStore) Set(provider, apiKey string) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	s.secrets[provider] = apiKey
+	return s.save()
+}
+
+// Delete implements the SecretStore interface
+func (s *fileStore) Delete(provider string) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	if _, ok := s.secrets[provider]; !ok {
+		return nil
+	}
+	delete(s.secrets, provider)
+	return s.save()
+}
+
+// load decrypts the secrets file once, prompting for the passphrase if needed
+func (s *fileStore) load() error {
+	if s.secrets != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		// Nothing stored yet, the passphrase is chosen on first save
+		s.secrets = make(map[string]string)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading secrets file: %w", err)
+	}
+
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("error parsing secrets file: %w", err)
+	}
+	if file.Version != 1 || file.KDF != "scrypt" {
+		return fmt.Errorf("unsupported secrets file format (version %d, kdf %q)", file.Version, file.KDF)
+	}
+
+	passphrase, err := s.getPassphrase(false)
+	if err != nil {
+		return err
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), file.Salt, file.N, file.R, file.P, chacha20poly1305.KeySize)
+	if err != nil {
+		return fmt.Errorf("error deriving key: %w", err)
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return errors.New("error decrypting secrets file: wrong passphrase or corrupted file")
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("error parsing decrypted secrets: %w", err)
+	}
+
+	s.secrets = secrets
+	return nil
+}
+
+// save encrypts the secrets with a fresh salt and nonce and writes the file
+func (s *fileStore) save() error {
+	passphrase, err := s.getPassphrase(true)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(s.secrets)
+	if err != nil {
+		return fmt.Errorf("error marshaling secrets: %w", err)
+	}
+
+	file := encryptedFile{
+		Version: 1,
+		KDF:     "scrypt",
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    make([]byte, 16),
+		Nonce:   make([]byte, chacha20poly1305.NonceSizeX),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return fmt.Errorf("error generating salt: %w", err)
+	}
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), file.Salt, file.N, file.R, file.P, chacha20poly1305.KeySize)
+	if err != nil {
+		return fmt.Errorf("error deriving key: %w", err)
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return fmt.Errorf("error creating cipher: %w", err)
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling secrets file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("error writing secrets file: %w", err)
+	}
+
+	return nil
+}
+
+// getPassphrase returns the cached passphrase, the environment variable or asks PassphraseFunc
+func (s *fileStore) getPassphrase(creating bool) (string, error) {
+	if s.passphrase != "" {
+		return s.passphrase, nil
+	}
+
+	passphrase := os.Getenv("GOLLM_SECRETS_PASSPHRASE")
+	if passphrase == "" {
+		// Only ask for confirmation when the file does not exist yet
+		_, statErr := os.Stat(s.path)
+		var err error
+		passphrase, err = PassphraseFunc(creating && os.IsNotExist(statErr))
+		if err != nil {
+			return "", err
+		}
+	}
+	if passphrase == "" {
+		return "", errors.New("empty passphrase for the secrets file")
+	}
+
+	s.passphrase = passphrase
+	return passphrase, nil
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFileStoreRoundTrip tests encrypting and decrypting the secrets file
+func TestFileStoreRoundTrip(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-secrets-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+	t.Setenv("GOLLM_SECRETS_PASSPHRASE", "correct horse")
+
+	path := filepath.Join(tmpDir, secretsFileName)
+	store := newFileStore(path)
+	if err := store.Set("anthropic", "secret-anthropic-key"); err != nil {
+		t.Fatalf("Failed to store secret: %v", err)
+	}
+
+	// The key must not be readable from the file
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read secrets file: %v", err)
+	}
+	if strings.Contains(string(data), "secret-anthropic-key") {
+		t.Error("Expected secrets file to be encrypted, found plaintext key")
+	}
+
+	// A fresh store decrypts the file with the same passphrase
+	apiKey, err := newFileStore(path).Get("anthropic")
+	if err != nil {
+		t.Fatalf("Failed to read secret: %v", err)
+	}
+	if apiKey != "secret-anthropic-key" {
+		t.Errorf("Expected 'secret-anthropic-key', got %q", apiKey)
+	}
+
+	if _, err := newFileStore(path).Get("google"); err != ErrSecretNotFound {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+
+	// A wrong passphrase fails to decrypt
+	t.Setenv("GOLLM_SECRETS_PASSPHRASE", "wrong")
+	if _, err := newFileStore(path).Get("anthropic"); err == nil {
+		t.Error("Expected error for wrong passphrase, got nil")
+	}
+}
+
+// TestMigrateSecretsAndResolve tests moving plaintext keys into the file store
+func TestMigrateSecretsAndResolve(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-migrate-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+	t.Setenv("GOLLM_CONFIG_DIR", tmpDir)
+	t.Setenv("GOLLM_SECRETS_PASSPHRASE", "passphrase")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("DEEPSEEK_API_KEY", "")
+
+	cfg, err := LoadUser()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.SetAPIKey("anthropic", "plaintext-key"); err != nil {
+		t.Fatalf("Failed to set API key: %v", err)
+	}
+	if err := cfg.Set("secrets.backend", BackendFile); err != nil {
+		t.Fatalf("Failed to set backend: %v", err)
+	}
+
+	store, err := cfg.SecretStore()
+	if err != nil {
+		t.Fatalf("Failed to open secret store: %v", err)
+	}
+
+	migrated, err := cfg.MigrateSecrets(store)
+	if err != nil {
+		t.Fatalf("Failed to migrate secrets: %v", err)
+	}
+	if len(migrated) != 1 || migrated[0] != "anthropic" {
+		t.Errorf("Expected anthropic to be migrated, got %v", migrated)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	// The plaintext key is gone from the config file
+	data, err := os.ReadFile(filepath.Join(tmpDir, "config.yml"))
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	if strings.Contains(string(data), "plaintext-key") {
+		t.Error("Expected plaintext key to be removed from config file")
+	}
+
+	// The key is still resolved through the store
+	loaded, err := LoadUser()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	setting, _ := loaded.Lookup("providers.anthropic.api_key", nil)
+	if setting.Value != "plaintext-key" || setting.Source != SourceStore {
+		t.Errorf("Expected key from store, got %q from %s (err %v)", setting.Value, setting.Source, setting.Err)
+	}
+
+	// api_key_cmd takes precedence over the store
+	if err := loaded.Set("providers.deepseek.api_key_cmd", "echo command-key"); err != nil {
+		t.Fatalf("Failed to set api_key_cmd: %v", err)
+	}
+	apiKey, err := loaded.LookupAPIKey("deepseek")
+	if err != nil {
+		t.Fatalf("Failed to look up API key: %v", err)
+	}
+	if apiKey != "command-key" {
+		t.Errorf("Expected 'command-key', got %q", apiKey)
+	}
+}
+
+// TestSetUnsetWithFileStore tests setting and unsetting API keys by name
+// when a secret store is configured
+func TestSetUnsetWithFileStore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-set-secret-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+	t.Setenv("GOLLM_CONFIG_DIR", tmpDir)
+	t.Setenv("GOLLM_SECRETS_PASSPHRASE", "passphrase")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	cfg, err := LoadUser()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Set("secrets.backend", BackendFile); err != nil {
+		t.Fatalf("Failed to set backend: %v", err)
+	}
+	if err := cfg.Set("providers.anthropic.api_key", "old-key"); err != nil {
+		t.Fatalf("Failed to set API key: %v", err)
+	}
+
+	// Replacing the key updates the store instead of the config file
+	if err := cfg.Set("providers.anthropic.api_key", "new-key"); err != nil {
+		t.Fatalf("Failed to replace API key: %v", err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "config.yml"))
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	if strings.Contains(string(data), "-key") {
+		t.Errorf("Expected no API key in config file, got:\n%s", data)
+	}
+
+	loaded, err := LoadUser()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	setting, _ := loaded.Lookup("providers.anthropic.api_key", nil)
+	if setting.Value != "new-key" || setting.Source != SourceStore {
+		t.Errorf("Expected new key from store, got %q from %s (err %v)", setting.Value, setting.Source, setting.Err)
+	}
+
+	// Unsetting the key removes it from the store
+	if err := loaded.Unset("providers.anthropic.api_key"); err != nil {
+		t.Fatalf("Failed to unset API key: %v", err)
+	}
+	if _, err := newFileStore(filepath.Join(tmpDir, secretsFileName)).Get("anthropic"); err != ErrSecretNotFound {
+		t.Errorf("Expected key to be removed from store, got error %v", err)
+	}
+	if apiKey := loaded.GetAPIKey("anthropic"); apiKey != "" {
+		t.Errorf("Expected no API key after unset, got %q", apiKey)
+	}
+}
+
+// TestKeyringNotFound tests telling missing keyring items from other failures
+func TestKeyringNotFound(t *testing.T) {
+	tests := []struct {
+		goos     string
+		exitCode int
+		stderr   string
+		expected bool
+	}{
+		{"darwin", securityNotFound, "security: SecKeychainSearchCopyNext: The specified item could not be found in the keychain.", true},
+		{"darwin", 51, "security: User interaction is not allowed.", false},
+		{"darwin", 1, "", false},
+		{"linux", 1, "", true},
+		{"linux", 1, "secret-tool: Cannot autolaunch D-Bus without X11 $DISPLAY", false},
+		{"linux", 2, "", false},
+	}
+
+	for _, tt := range tests {
+		if result := keyringNotFound(tt.goos, tt.exitCode, tt.stderr); result != tt.expected {
+			t.Errorf("keyringNotFound(%s, %d, %q) = %v, expected %v", tt.goos, tt.exitCode, tt.stderr, result, tt.expected)
+		}
+	}
+
+	if quoted := securityQuote(`sk-"a\b`); quoted != `"sk-\"a\\b"` {
+		t.Errorf("Expected quoted key, got %s", quoted)
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError describes a problem found in a configuration file
+type ValidationError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Error implements the error interface
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Validate checks raw YAML configuration data against the known keys and
+// returns every problem found along with its position in the file
+func Validate(data []byte) ([]ValidationError, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	// An empty document is a valid (empty) configuration
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	keys := Keys()
+
+	var errs []ValidationError
+	validateNode(doc.Content[0], "", keys, &errs)
+
+	return errs, nil
+}
+
+// validateNode recursively validates a mapping node found at path
+func validateNode(node *yaml.Node, path string, keys []Key, errs *[]ValidationError) {
+	if node.Kind != yaml.MappingNode {
+		name := path
+		if name == "" {
+			name = "configuration"
+		}
+		*errs = append(*errs, ValidationError{
+			Line:    node.Line,
+			Column:  node.Column,
+			Message: fmt.Sprintf("%s must be a mapping", name),
+		})
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+
+		name := keyNode.Value
+		if path != "" {
+			name = path + "." + keyNode.Value
+		}
+
+		// Leaf keys must hold a valid scalar value
+		if key, ok := findKey(keys, name); ok {
+			validateScalar(key, valueNode, errs)
+			continue
+		}
+
+		// Intermediate keys must be mappings of further keys
+		if hasKeyWithPrefix(keys, name+".") {
+			validateNode(valueNode, name, keys, errs)
+			continue
+		}
+
+		*errs = append(*errs, ValidationError{
+			Line:    keyNode.Line,
+			Column:  keyNode.Column,
+			Message: fmt.Sprintf("unknown key %q", name),
+		})
+	}
+}
+
+// validateScalar checks a leaf value by applying it to a scratch configuration
+func validateScalar(key Key, node *yaml.Node, errs *[]ValidationError) {
+	// List keys are written as sequences of scalars
+	if key.list {
+		if node.Kind != yaml.SequenceNode {
+			*errs = append(*errs, ValidationError{
+				Line:    node.Line,
+				Column:  node.Column,
+				Message: fmt.Sprintf("%s must be a list of values", key.Name),
+			})
+			return
+		}
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				*errs = append(*errs, ValidationError{
+					Line:    item.Line,
+					Column:  item.Column,
+					Message: fmt.Sprintf("%s must be a list of values", key.Name),
+				})
+			}
+		}
+		return
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		*errs = append(*errs, ValidationError{
+			Line:    node.Line,
+			Column:  node.Column,
+			Message: fmt.Sprintf("%s must be a single value", key.Name),
+		})
+		return
+	}
+
+	// Explicit nulls are treated as unset
+	if node.Tag == "!!null" {
+		return
+	}
+
+	if err := key.set(&Config{}, node.Value); err != nil {
+		*errs = append(*errs, ValidationError{
+			Line:    node.Line,
+			Column:  node.Column,
+			Message: fmt.Sprintf("invalid %s: %v", key.Name, err),
+		})
+	}
+}
+
+// hasKeyWithPrefix reports whether any known key starts with prefix
+func hasKeyWithPrefix(keys []Key, prefix string) bool {
+	for _, key := range keys {
+		if strings.HasPrefix(key.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zerobang-dev/gollm/pkg/codeblock"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// Querier sends a prompt to a model, as implemented by llm.Service
+type Querier interface {
+	QueryDetailed(ctx context.Context, prompt, modelName string, options ...llm.Option) (*llm.Response, time.Duration, error)
+}
+
+// maxPromptDiffBytes is the part of a diff sent to the model, the file
+// summary covering the rest
+const maxPromptDiffBytes = 100000
+
+// conventionalSummary matches a Conventional Commits summary line
+var conventionalSummary = regexp.MustCompile(`^(feat|fix|docs|style|refactor|perf|test|build|ci|chore|revert)(\([^)]+\))?!?: \S`)
+
+// StagedDiff returns the staged changes of the repository containing dir
+// and a summary of the files they touch
+func StagedDiff(ctx context.Context, dir string) (diff, stat string, err error) {
+	diff, err = Run(ctx, dir, "diff", "--cached", "--no-color", "--no-ext-diff")
+	if err != nil {
+		return "", "", err
+	}
+	stat, err = Run(ctx, dir, "diff", "--cached", "--no-color", "--stat")
+	if err != nil {
+		return "", "", err
+	}
+	return diff, stat, nil
+}
+
+// CommitPrompt builds the prompt asking for a Conventional Commits message
+// for a diff, truncating diffs too large to send whole
+func CommitPrompt(diff, stat string) string {
+	if len(diff) > maxPromptDiffBytes {
+		cut := strings.LastIndex(diff[:maxPromptDiffBytes], "\n") + 1
+		diff = diff[:cut] + "[diff truncated, see the files changed above]\n"
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(`Write a commit message for the staged changes below in the Conventional Commits format:
+
+<type>(<optional scope>): <summary>
+
+<optional body>
+
+The type is one of feat, fix, docs, style, refactor, perf, test, build, ci, chore or revert. The summary is in the imperative mood, under 72 characters and without a trailing period. Add a body wrapped at 72 characters only when the summary alone does not explain what changed and why. Answer with the commit message only, without code fences or commentary.
+
+Files changed:
+`)
+	prompt.WriteString(stat)
+	prompt.WriteString("\nDiff:\n")
+	prompt.WriteString(diff)
+	return prompt.String()
+}
+
+// CommitMessage asks the model for a commit message for the diff
+func CommitMessage(ctx context.Context, querier Querier, model, diff, stat string, options ...llm.Option) (string, error) {
+	if strings.TrimSpace(diff) == "" {
+		return "", fmt.Errorf("no changes to describe")
+	}
+
+	response, _, err := querier.QueryDetailed(ctx, CommitPrompt(diff, stat), model, options...)
+	if err != nil {
+		return "", err
+	}
+
+	message := CleanMessage(response.Text)
+	if message == "" {
+		return "", fmt.Errorf("the model returned an empty commit message")
+	}
+	return message, nil
+}
+
+// CleanMessage strips the code fences, trailing spaces and surrounding blank lines
+// models put around commit messages
+func CleanMessage(text string) string {
+	text = strings.TrimSpace(text)
+	if blocks := codeblock.Parse(text); len(blocks) > 0 && blocks[0].Line == 1 {
+		text = blocks[0].Content
+	}
+
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// IsConventional reports whether a message starts with a Conventional Commits summary
+func IsConventional(message string) bool {
+	summary, _, _ := strings.Cut(message, "\n")
+	return conventionalSummary.MatchString(summary)
+}
+
+// WriteMessage writes a commit message to a file such as .git/COMMIT_EDITMSG,
+// keeping the comment lines git already put there
+func WriteMessage(path, message string) error {
+	var comments []string
+	if existing, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(existing), "\n") {
+			if strings.HasPrefix(line, "#") {
+				comments = append(comments, line)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	content := message + "\n"
+	if len(comments) > 0 {
+		content += "\n" + strings.Join(comments, "\n") + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// hookMarker identifies hooks installed by InstallHook
+const hookMarker = "# Installed by gollm git commit-msg --install-hook"
+
+// hookScript fills in the message of plain commits, leaving messages given
+// with -m, templates, merges, squashes and amends alone
+const hookScript = `#!/bin/sh
+` + hookMarker + `
+if [ -z "$2" ]; then
+	gollm git commit-msg --write --quiet || true
+fi
+`
+
+// InstallHook installs a prepare-commit-msg hook proposing a message for
+// every commit, refusing to replace a hook it did not install
+func InstallHook(ctx context.Context, dir string) (string, error) {
+	hooks, err := Path(ctx, dir, "hooks")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(hooks, "prepare-commit-msg")
+
+	if existing, err := os.ReadFile(path); err == nil && !strings.Contains(string(existing), hookMarker) {
+		return "", fmt.Errorf("%s already exists, remove it or add gollm git commit-msg --write to it", path)
+	} else if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(hooks, 0755); err != nil {
+		return "", fmt.Errorf("error creating hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hookScript), 0755); err != nil {
+		return "", fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,194 @@
+// Package git reads diffs from a local git repository and asks a model to
+// write commit messages for them and review them.
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Run runs git with the arguments in dir and returns its output, or an error
+// with what git printed on stderr
+func Run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], message)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// Path returns the path of a file in the .git directory of the repository
+// containing dir, such as COMMIT_EDITMSG or hooks, honoring worktrees and core.hooksPath
+func Path(ctx context.Context, dir, name string) (string, error) {
+	path, err := Run(ctx, dir, "rev-parse", "--path-format=absolute", "--git-path", name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(path), nil
+}
+
+// FileDiff is the diff of one file
+type FileDiff struct {
+	OldPath string // "" for added files
+	NewPath string // "" for deleted files
+	Binary  bool
+	Hunks   []Hunk
+}
+
+// Path returns the path of the file after the change, or before it for deleted files
+func (f FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Hunk is a block of changed lines with its context
+type Hunk struct {
+	Header   string // The @@ line
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string // Lines starting with ' ', '+', '-' or '\'
+}
+
+// hunkHeader matches "@@ -1,5 +1,6 @@ func main() {"
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParseDiff parses the unified diff output of git diff into files and hunks
+func ParseDiff(diff string) ([]FileDiff, error) {
+	var files []FileDiff
+	var file *FileDiff
+	var hunk *Hunk
+
+	// flush appends the current hunk to the current file
+	flush := func() {
+		if hunk != nil && file != nil {
+			file.Hunks = append(file.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+
+	for i, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			files = append(files, FileDiff{})
+			file = &files[len(files)-1]
+			// Paths are refined by the ---/+++ lines, but binary and
+			// mode-only changes have none
+			if a, b, ok := splitGitPaths(strings.TrimPrefix(line, "diff --git ")); ok {
+				file.OldPath, file.NewPath = a, b
+			}
+
+		case file == nil:
+			// Text before the first file, such as a commit message
+			continue
+
+		case hunk == nil && strings.HasPrefix(line, "--- "):
+			file.OldPath = diffPath(strings.TrimPrefix(line, "--- "), "a/")
+
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			file.NewPath = diffPath(strings.TrimPrefix(line, "+++ "), "b/")
+
+		case hunk == nil && strings.HasPrefix(line, "new file mode"):
+			file.OldPath = ""
+
+		case hunk == nil && strings.HasPrefix(line, "deleted file mode"):
+			file.NewPath = ""
+
+		case hunk == nil && strings.HasPrefix(line, "Binary files "):
+			file.Binary = true
+
+		case strings.HasPrefix(line, "@@ "):
+			flush()
+			match := hunkHeader.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("invalid hunk header on line %d: %s", i+1, line)
+			}
+			hunk = &Hunk{
+				Header:   line,
+				OldStart: atoi(match[1]),
+				OldLines: atoiDefault(match[2], 1),
+				NewStart: atoi(match[3]),
+				NewLines: atoiDefault(match[4], 1),
+			}
+
+		case hunk != nil && (line == "" || strings.ContainsAny(line[:1], " +-\\")):
+			// Some tools strip the space of empty context lines
+			if line == "" {
+				line = " "
+			}
+			hunk.Lines = append(hunk.Lines, line)
+		}
+	}
+	flush()
+
+	return files, nil
+}
+
+// splitGitPaths splits "a/x b/y" from a diff --git line, when the paths contain no spaces
+func splitGitPaths(paths string) (string, string, bool) {
+	parts := strings.Split(paths, " ")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "a/") || !strings.HasPrefix(parts[1], "b/") {
+		return "", "", false
+	}
+	return parts[0][2:], parts[1][2:], true
+}
+
+// diffPath returns the path of a ---/+++ line, "" for /dev/null
+func diffPath(path, prefix string) string {
+	path = strings.TrimSuffix(path, "\t")
+	if path == "/dev/null" {
+		return ""
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		path = unquoted
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// atoi parses a number matched by a regular expression
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s) // Matched as digits
+	return n
+}
+
+// atoiDefault parses an optional number, returning def when it is absent
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	return atoi(s)
+}
+
+// Numbered returns the lines of the hunk prefixed with their line number in
+// the new version of the file, removed lines having no number
+func (h Hunk) Numbered() string {
+	var text strings.Builder
+	line := h.NewStart
+	for _, diffLine := range h.Lines {
+		switch diffLine[0] {
+		case '-', '\\':
+			fmt.Fprintf(&text, "%6s %s\n", "", diffLine)
+		default:
+			fmt.Fprintf(&text, "%6d %s\n", line, diffLine)
+			line++
+		}
+	}
+	return text.String()
+}
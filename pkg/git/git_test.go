@@ -0,0 +1,245 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// mockQuerier answers every prompt with the same text, recording the prompts,
+// and fails prompts containing fail when it is set
+type mockQuerier struct {
+	answer  string
+	fail    string
+	prompts []string
+}
+
+func (m *mockQuerier) QueryDetailed(ctx context.Context, prompt, modelName string, options ...llm.Option) (*llm.Response, time.Duration, error) {
+	m.prompts = append(m.prompts, prompt)
+	if m.fail != "" && strings.Contains(prompt, m.fail) {
+		return nil, 0, errors.New("prompt is too long")
+	}
+	return &llm.Response{Text: m.answer, Usage: llm.Usage{InputTokens: 10, OutputTokens: 5}}, time.Millisecond, nil
+}
+
+const testDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,5 @@
+ package main
+
+-func main() {}
++func main() {
++	panic("todo")
++}
+@@ -10 +11,2 @@ func helper() {
++// added
+ 	return
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+index 3333333..0000000
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+diff --git a/image.png b/image.png
+new file mode 100644
+index 0000000..4444444
+Binary files /dev/null and b/image.png differ
+`
+
+func TestParseDiff(t *testing.T) {
+	files, err := ParseDiff(testDiff)
+	if err != nil {
+		t.Fatalf("ParseDiff returned error: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %d", len(files))
+	}
+
+	main := files[0]
+	if main.Path() != "main.go" || len(main.Hunks) != 2 {
+		t.Fatalf("Expected main.go with 2 hunks, got %+v", main)
+	}
+	if hunk := main.Hunks[1]; hunk.NewStart != 11 || hunk.NewLines != 2 || hunk.OldLines != 1 {
+		t.Errorf("Expected second hunk at 11,2 replacing 1 line, got %+v", hunk)
+	}
+
+	numbered := main.Hunks[0].Numbered()
+	if !strings.Contains(numbered, "     3 +func main() {") || !strings.Contains(numbered, "       -func main() {}") {
+		t.Errorf("Expected added lines numbered and removed lines not, got:\n%s", numbered)
+	}
+
+	if files[1].NewPath != "" || files[1].Path() != "old.txt" {
+		t.Errorf("Expected deleted old.txt, got %+v", files[1])
+	}
+	if !files[2].Binary || files[2].OldPath != "" || files[2].Path() != "image.png" {
+		t.Errorf("Expected added binary image.png, got %+v", files[2])
+	}
+
+	if _, err := ParseDiff("diff --git a/x b/x\n@@ broken @@\n"); err == nil {
+		t.Error("Expected error for invalid hunk header")
+	}
+}
+
+func TestReviewDiff(t *testing.T) {
+	files, err := ParseDiff(testDiff)
+	if err != nil {
+		t.Fatalf("ParseDiff returned error: %v", err)
+	}
+
+	querier := &mockQuerier{answer: "Findings:\n- `main.go:12`: warning: helper comment says nothing\nmain.go:4: error: panics at startup\nother.go:1: error: not this file\nNONE"}
+	review, err := ReviewDiff(context.Background(), querier, "test-model", files, nil)
+	if err != nil {
+		t.Fatalf("ReviewDiff returned error: %v", err)
+	}
+
+	// Deleted and binary files are not sent
+	if len(querier.prompts) != 1 || review.Files != 1 || len(review.Skipped) != 2 {
+		t.Errorf("Expected one file reviewed and two skipped, got %d prompts and %+v", len(querier.prompts), review)
+	}
+	if !strings.Contains(querier.prompts[0], "Hunk 2 (lines 11-12):") {
+		t.Errorf("Expected hunks labeled with their lines, got:\n%s", querier.prompts[0])
+	}
+
+	if len(review.Findings) != 2 {
+		t.Fatalf("Expected 2 findings, got %+v", review.Findings)
+	}
+	if review.Findings[0].String() != "main.go:4: error: panics at startup" {
+		t.Errorf("Expected findings sorted by line, got %s", review.Findings[0])
+	}
+	if review.Findings[1].Severity != SeverityWarning || review.Findings[1].Line != 12 {
+		t.Errorf("Expected warning on line 12, got %+v", review.Findings[1])
+	}
+
+	// A failing file is skipped with the reason, the only file failing is an error
+	querier = &mockQuerier{answer: "NONE", fail: "main.go"}
+	if _, err := ReviewDiff(context.Background(), querier, "test-model", files, nil); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("Expected error when no file could be reviewed, got %v", err)
+	}
+	files = append(files, FileDiff{OldPath: "gen.go", NewPath: "gen.go", Hunks: files[0].Hunks})
+	querier.fail = "gen.go"
+	review, err = ReviewDiff(context.Background(), querier, "test-model", files, nil)
+	if err != nil {
+		t.Fatalf("ReviewDiff returned error: %v", err)
+	}
+	if review.Files != 1 || len(review.Skipped) != 3 || review.Skipped[2] != (SkippedFile{Path: "gen.go", Reason: "prompt is too long"}) {
+		t.Errorf("Expected gen.go skipped after reviewing main.go, got %+v", review)
+	}
+}
+
+func TestCommitMessage(t *testing.T) {
+	querier := &mockQuerier{answer: "```text\nfeat(cli): add git commands  \n\nExplain the change.\n```\n"}
+	message, err := CommitMessage(context.Background(), querier, "test-model", testDiff, " main.go | 4 +++-\n")
+	if err != nil {
+		t.Fatalf("CommitMessage returned error: %v", err)
+	}
+	if message != "feat(cli): add git commands\n\nExplain the change." {
+		t.Errorf("Expected message without fences and trailing spaces, got %q", message)
+	}
+	if !IsConventional(message) || IsConventional("Added git commands") {
+		t.Error("Expected only the conventional message to be recognized")
+	}
+	if !strings.Contains(querier.prompts[0], "Files changed:\n main.go | 4 +++-") {
+		t.Errorf("Expected file summary in prompt, got:\n%s", querier.prompts[0])
+	}
+
+	if _, err := CommitMessage(context.Background(), querier, "test-model", "", ""); err == nil {
+		t.Error("Expected error for empty diff")
+	}
+}
+
+func TestRepository(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-git-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	ctx := context.Background()
+	if _, err := Run(ctx, tmpDir, "init", "-q"); err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Run(ctx, tmpDir, "add", "a.txt"); err != nil {
+		t.Fatalf("git add failed: %v", err)
+	}
+
+	diff, stat, err := StagedDiff(ctx, tmpDir)
+	if err != nil {
+		t.Fatalf("StagedDiff returned error: %v", err)
+	}
+	files, err := ParseDiff(diff)
+	if err != nil || len(files) != 1 || files[0].Path() != "a.txt" || !strings.Contains(stat, "a.txt") {
+		t.Errorf("Expected staged a.txt, got %+v (%v) and stat %q", files, err, stat)
+	}
+
+	// Messages keep the comments git wrote
+	messagePath, err := Path(ctx, tmpDir, "COMMIT_EDITMSG")
+	if err != nil {
+		t.Fatalf("Path returned error: %v", err)
+	}
+	if err := os.WriteFile(messagePath, []byte("\n# Please enter the commit message\n"), 0644); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+	if err := WriteMessage(messagePath, "feat: add a"); err != nil {
+		t.Fatalf("WriteMessage returned error: %v", err)
+	}
+	if content, err := os.ReadFile(messagePath); err != nil || string(content) != "feat: add a\n\n# Please enter the commit message\n" {
+		t.Errorf("Expected message followed by comments, got %q (%v)", content, err)
+	}
+
+	// The hook can be reinstalled but does not replace other hooks
+	hookPath, err := InstallHook(ctx, tmpDir)
+	if err != nil {
+		t.Fatalf("InstallHook returned error: %v", err)
+	}
+	if _, err := InstallHook(ctx, tmpDir); err != nil {
+		t.Errorf("Expected reinstalling the hook to succeed, got %v", err)
+	}
+	if err := os.WriteFile(hookPath, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+	if _, err := InstallHook(ctx, tmpDir); err == nil {
+		t.Error("Expected error replacing another hook")
+	}
+
+	// A single revision reviews the changes made by that commit only
+	commit := func(message string) {
+		if _, err := Run(ctx, tmpDir, "-c", "user.name=Test", "-c", "user.email=test@example.com",
+			"commit", "-q", "--no-verify", "-am", message); err != nil {
+			t.Fatalf("git commit failed: %v", err)
+		}
+	}
+	commit("add a")
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("two\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	commit("change a")
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("three\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	diff, err = RangeDiff(ctx, tmpDir, "HEAD", false)
+	if err != nil {
+		t.Fatalf("RangeDiff returned error: %v", err)
+	}
+	if !strings.Contains(diff, "-one") || !strings.Contains(diff, "+two") || strings.Contains(diff, "three") {
+		t.Errorf("Expected only the changes of HEAD, got:\n%s", diff)
+	}
+	if diff, err = RangeDiff(ctx, tmpDir, "HEAD~1..HEAD", false); err != nil || !strings.Contains(diff, "+two") {
+		t.Errorf("Expected range diff with +two, got %q (%v)", diff, err)
+	}
+}
@@ -0,0 +1,183 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// Severities of review findings, from most to least serious
+const (
+	SeverityError      = "error"
+	SeverityWarning    = "warning"
+	SeveritySuggestion = "suggestion"
+)
+
+// Finding is a problem found in a diff
+type Finding struct {
+	Path     string
+	Line     int // Line in the new version of the file
+	Severity string
+	Message  string
+}
+
+// String formats the finding as path:line: severity: message
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", f.Path, f.Line, f.Severity, f.Message)
+}
+
+// Review is the outcome of reviewing a diff
+type Review struct {
+	Findings []Finding
+	Files    int // Files reviewed
+	Skipped  []SkippedFile
+	Usage    llm.Usage
+}
+
+// SkippedFile is a file left out of a review and why
+type SkippedFile struct {
+	Path   string
+	Reason string
+}
+
+// findingLine matches "path:line: severity: message" with optional list markers and backticks
+var findingLine = regexp.MustCompile("^[-*\\s`]*([^\\s:`]+):(\\d+)(?::\\d+)?`?:\\s*(error|warning|suggestion)\\s*:\\s*(.+)$")
+
+// RangeDiff returns the diff of a revision range such as main..feature, the
+// changes made by a single commit, or the uncommitted changes compared to
+// HEAD when the range is empty
+func RangeDiff(ctx context.Context, dir, revisions string, staged bool) (string, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff"}
+	switch {
+	case staged:
+		args = append(args, "--cached")
+	case revisions == "":
+		args = append(args, "HEAD")
+	default:
+		args = append(args, commitRange(revisions))
+	}
+	return Run(ctx, dir, args...)
+}
+
+// commitRange turns a single revision into the range of the changes it made,
+// since git diff compares a lone revision with the working tree
+func commitRange(revisions string) string {
+	if strings.Contains(revisions, "..") || strings.Contains(revisions, "^!") ||
+		strings.Contains(revisions, "^@") || strings.Contains(revisions, "^-") {
+		return revisions
+	}
+	return revisions + "^!"
+}
+
+// unreviewable returns why a file cannot be reviewed, or "" when it can
+func unreviewable(file FileDiff) string {
+	switch {
+	case file.NewPath == "":
+		return "deleted"
+	case file.Binary:
+		return "binary"
+	case len(file.Hunks) == 0:
+		return "mode change only"
+	}
+	return ""
+}
+
+// ReviewPrompt builds the prompt asking to review the hunks of a file, each
+// line numbered so findings can refer to it
+func ReviewPrompt(file FileDiff) string {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, `Review the following changes to %s hunk by hunk. Lines are prefixed with their line number in the new version of the file; removed lines have no number.
+
+Report each bug, security problem or clear improvement on its own line in exactly this format:
+%s:<line>: <severity>: <finding>
+
+The severity is error, warning or suggestion, and the line is a numbered line of the diff. Do not describe or summarize the change. If there is nothing to report, answer NONE.
+`, file.Path(), file.Path())
+
+	for i, hunk := range file.Hunks {
+		fmt.Fprintf(&prompt, "\nHunk %d (lines %d-%d):\n", i+1, hunk.NewStart, hunk.NewStart+max(hunk.NewLines-1, 0))
+		prompt.WriteString(hunk.Numbered())
+	}
+	return prompt.String()
+}
+
+// ParseFindings reads the findings for a file from a review answer, ignoring
+// lines in any other format
+func ParseFindings(path, text string) []Finding {
+	var findings []Finding
+	for _, line := range strings.Split(text, "\n") {
+		match := findingLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		// Models sometimes shorten the path to the file name
+		if match[1] != path && !strings.HasSuffix(path, "/"+match[1]) {
+			continue
+		}
+		lineNumber, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		findings = append(findings, Finding{
+			Path:     path,
+			Line:     lineNumber,
+			Severity: match[3],
+			Message:  strings.TrimSpace(match[4]),
+		})
+	}
+	return findings
+}
+
+// ReviewDiff asks the model to review each changed file of a diff and
+// returns the findings sorted by file and line. Deleted and binary files are
+// skipped. progress is called before each file if set.
+func ReviewDiff(ctx context.Context, querier Querier, model string, files []FileDiff, progress func(path string), options ...llm.Option) (*Review, error) {
+	review := &Review{}
+	var firstErr error
+	for _, file := range files {
+		if reason := unreviewable(file); reason != "" {
+			review.Skipped = append(review.Skipped, SkippedFile{Path: file.Path(), Reason: reason})
+			continue
+		}
+		if progress != nil {
+			progress(file.Path())
+		}
+
+		// A file that fails, e.g. a generated file too large for the model,
+		// does not cost the findings of the others
+		response, _, err := querier.QueryDetailed(ctx, ReviewPrompt(file), model, options...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("error reviewing %s: %w", file.Path(), err)
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error reviewing %s: %w", file.Path(), err)
+			}
+			review.Skipped = append(review.Skipped, SkippedFile{Path: file.Path(), Reason: err.Error()})
+			continue
+		}
+		review.Files++
+		review.Usage.InputTokens += response.Usage.InputTokens
+		review.Usage.OutputTokens += response.Usage.OutputTokens
+		review.Findings = append(review.Findings, ParseFindings(file.Path(), response.Text)...)
+	}
+
+	// Report the error when no file could be reviewed at all
+	if review.Files == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.SliceStable(review.Findings, func(i, j int) bool {
+		a, b := review.Findings[i], review.Findings[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Line < b.Line
+	})
+	return review, nil
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 // AnthropicProvider implements the Provider interface for Anthropic API
@@ -15,6 +16,7 @@ type AnthropicProvider struct {
 	apiKey     string
 	httpClient *http.Client
 	baseURL    string
+	headers    map[string]string // Extra headers sent with every request
 }
 
 // anthropicMessage represents a message in the Anthropic API
@@ -23,46 +25,91 @@ type anthropicMessage struct {
 	Content string `json:"content"`
 }
 
+// anthropicThinking enables extended thinking in the Anthropic API
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
 // anthropicRequest represents a request to the Anthropic API
 type anthropicRequest struct {
-	Model       string             `json:"model"`
-	MaxTokens   int                `json:"max_tokens"`
-	Messages    []anthropicMessage `json:"messages"`
-	System      string             `json:"system,omitempty"`
-	Temperature float64            `json:"temperature"`
-	TopP        float64            `json:"top_p,omitempty"`
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	Messages      []anthropicMessage `json:"messages"`
+	System        string             `json:"system,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          float64            `json:"top_p,omitempty"`
+	Thinking      *anthropicThinking `json:"thinking,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
 }
 
 // anthropicContentBlock represents a content block in the Anthropic API response
 type anthropicContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// anthropicUsage represents token usage in the Anthropic API response
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
 }
 
 // anthropicResponse represents a response from the Anthropic API
 type anthropicResponse struct {
-	Content []anthropicContentBlock `json:"content"`
-	Error   *struct {
+	ID         string                  `json:"id"`
+	Type       string                  `json:"type,omitempty"`
+	Role       string                  `json:"role,omitempty"`
+	Model      string                  `json:"model"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+	Error      *struct {
 		Type    string `json:"type"`
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
 // NewAnthropicProvider creates a new Anthropic provider
-func NewAnthropicProvider(apiKey string, httpClient *http.Client) *AnthropicProvider {
+func NewAnthropicProvider(apiKey string, httpClient *http.Client, options ...ProviderOption) *AnthropicProvider {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
 	}
 
+	settings := applyProviderOptions(options)
+
+	baseURL := "https://api.anthropic.com/v1/messages"
+	if settings.BaseURL != "" {
+		baseURL = settings.BaseURL + "/v1/messages"
+	}
+
 	return &AnthropicProvider{
 		apiKey:     apiKey,
 		httpClient: httpClient,
-		baseURL:    "https://api.anthropic.com/v1/messages",
+		baseURL:    baseURL,
+		headers:    settings.Headers,
 	}
 }
 
+// anthropicMinThinkingBudget is the smallest thinking budget the API accepts
+const anthropicMinThinkingBudget = 1024
+
+// anthropicMinThinkingTopP is the smallest top_p the API accepts with thinking enabled
+const anthropicMinThinkingTopP = 0.95
+
 // Query implements the Provider interface
 func (p *AnthropicProvider) Query(ctx context.Context, prompt string, options ...Option) (string, error) {
+	response, err := p.QueryDetailed(ctx, prompt, options...)
+	if err != nil {
+		return "", err
+	}
+	return response.Text, nil
+}
+
+// QueryDetailed implements the DetailedProvider interface
+func (p *AnthropicProvider) QueryDetailed(ctx context.Context, prompt string, options ...Option) (*Response, error) {
 	// Apply options
 	opts := &RequestOptions{
 		MaxTokens:   1000,
@@ -73,33 +120,16 @@ func (p *AnthropicProvider) Query(ctx context.Context, prompt string, options ..
 		option(opts)
 	}
 
-	// Model is required
-	if opts.Model == "" {
-		return "", errors.New("model is required for Anthropic provider")
-	}
-
 	// Create request payload
-	req := anthropicRequest{
-		Model:       opts.Model,
-		MaxTokens:   opts.MaxTokens,
-		Messages:    []anthropicMessage{{Role: "user", Content: prompt}},
-		Temperature: opts.Temperature,
-	}
-
-	// Add system prompt if specified
-	if system, ok := opts.CustomParams["system"].(string); ok && system != "" {
-		req.System = system
-	}
-
-	// Add top_p if specified
-	if topP, ok := opts.CustomParams["top_p"].(float64); ok {
-		req.TopP = topP
+	req, err := anthropicRequestFor(prompt, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	// Convert to JSON
 	jsonData, err := json.Marshal(req)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %w", err)
+		return nil, fmt.Errorf("error marshaling request: %w", err)
 	}
 
 	// Create HTTP request
@@ -110,18 +140,21 @@ func (p *AnthropicProvider) Query(ctx context.Context, prompt string, options ..
 		bytes.NewBuffer(jsonData),
 	)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
 	// Set headers
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("x-api-key", p.apiKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	for name, value := range p.headers {
+		httpReq.Header.Set(name, value)
+	}
 
 	// Send request
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("error sending request: %w", err)
+		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -133,35 +166,282 @@ func (p *AnthropicProvider) Query(ctx context.Context, prompt string, options ..
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response: %w", err)
+		return nil, fmt.Errorf("error reading response: %w", err)
 	}
 
 	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
 		var errResp anthropicResponse
 		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != nil {
-			return "", fmt.Errorf("API error (%s): %s", errResp.Error.Type, errResp.Error.Message)
+			return nil, fmt.Errorf("API error (%s): %s", errResp.Error.Type, errResp.Error.Message)
 		}
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	// Parse response
 	var result anthropicResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("error parsing response: %w", err)
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	response, err := anthropicResult(&result, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefer the request ID header, which Anthropic support asks for
+	requestID := resp.Header.Get("request-id")
+	if requestID == "" {
+		requestID = result.ID
+	}
+	response.Metadata.RequestID = requestID
+	response.Metadata.Created = responseTime(resp.Header)
+
+	return response, nil
+}
+
+// anthropicRequestFor builds the request payload for a prompt
+func anthropicRequestFor(prompt string, opts *RequestOptions) (anthropicRequest, error) {
+	// Model is required
+	if opts.Model == "" {
+		return anthropicRequest{}, errors.New("model is required for Anthropic provider")
+	}
+
+	req := anthropicRequest{
+		Model:     opts.Model,
+		MaxTokens: opts.MaxTokens,
+	}
+
+	// The API has no candidates or response formats, JSON is asked for with a prefill
+	if err := checkSingleCandidate(opts); err != nil {
+		return anthropicRequest{}, err
+	}
+	if opts.ResponseMIMEType != "" && opts.ResponseMIMEType != "text/plain" {
+		return anthropicRequest{}, invalidOption("%s does not support the response MIME type %q, prefill the response with \"{\" for JSON instead", opts.Model, opts.ResponseMIMEType)
+	}
+
+	// Add earlier turns, the prompt and the prefill
+	prefill := sentPrefill(opts)
+	if prefill != "" && opts.ThinkingBudget > 0 {
+		return anthropicRequest{}, invalidOption("prefilling the response is not supported with extended thinking")
+	}
+	req.Messages = anthropicMessages(prompt, opts)
+
+	if opts.ThinkingBudget > 0 {
+		if opts.ThinkingBudget < anthropicMinThinkingBudget {
+			return anthropicRequest{}, invalidOption("thinking budget must be at least %d tokens, got %d", anthropicMinThinkingBudget, opts.ThinkingBudget)
+		}
+
+		// Thinking counts towards max_tokens, so the answer keeps its own
+		// allowance on top of the budget. Temperature cannot be changed
+		// while thinking is enabled.
+		req.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: opts.ThinkingBudget}
+		req.MaxTokens += opts.ThinkingBudget
+	} else {
+		if err := checkTemperature(opts, 1); err != nil {
+			return anthropicRequest{}, err
+		}
+		req.Temperature = &opts.Temperature
+	}
+
+	// Add system prompt if specified
+	if system, ok := opts.CustomParams["system"].(string); ok && system != "" {
+		req.System = system
 	}
 
+	// Add top_p if specified, which thinking restricts like temperature
+	if topP, ok := opts.CustomParams["top_p"].(float64); ok && !anthropicDropsTopP(opts) {
+		req.TopP = topP
+	}
+
+	// Add stop sequences if specified
+	req.StopSequences = opts.StopSequences
+
+	return req, nil
+}
+
+// anthropicDropsTopP reports whether top_p is left out of a request because
+// thinking is enabled and the API would reject the value
+func anthropicDropsTopP(opts *RequestOptions) bool {
+	topP, ok := opts.CustomParams["top_p"].(float64)
+	return ok && opts.ThinkingBudget > 0 && topP < anthropicMinThinkingTopP
+}
+
+// anthropicResult converts a message from the API into a Response. The
+// request ID and creation time depend on how the message was fetched.
+func anthropicResult(result *anthropicResponse, opts *RequestOptions) (*Response, error) {
 	// Check for empty response
 	if len(result.Content) == 0 {
-		return "", errors.New("empty response from Anthropic API")
+		return nil, errors.New("empty response from Anthropic API")
 	}
 
-	// Find text content
+	// Collect the answer and thinking blocks
+	response := &Response{
+		Warnings: unknownParamWarnings("anthropic", opts.CustomParams, "system", "top_p"),
+	}
+	response.Warnings = append(response.Warnings, ignoredOptionWarnings("anthropic", opts, false)...)
+	if anthropicDropsTopP(opts) {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("top_p below %g is not supported with extended thinking and was not sent", anthropicMinThinkingTopP))
+	}
+	var text, thinking []string
 	for _, block := range result.Content {
-		if block.Type == "text" {
-			return block.Text, nil
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "thinking":
+			thinking = append(thinking, block.Thinking)
 		}
 	}
 
-	return "", errors.New("no text content in response")
+	// A prefilled response may legitimately stop without adding anything
+	prefill := sentPrefill(opts)
+	if len(text) == 0 && prefill == "" {
+		return nil, errors.New("no text content in response")
+	}
+	response.Text = prefill + strings.Join(text, "")
+	response.Thinking = strings.Join(thinking, "\n\n")
+
+	// The API reports thinking as part of the output tokens, so split out an estimate
+	response.Usage = Usage{
+		InputTokens:  result.Usage.InputTokens,
+		OutputTokens: result.Usage.OutputTokens,
+	}
+	if response.Thinking != "" {
+		response.Usage.ThinkingTokens = min(EstimateTokens(opts.Model, response.Thinking), response.Usage.OutputTokens)
+		response.Usage.OutputTokens -= response.Usage.ThinkingTokens
+	}
+
+	response.FinishReason = result.StopReason
+	response.Metadata = Metadata{
+		StopReason:   normalizeAnthropicStopReason(result.StopReason),
+		ModelVersion: result.Model,
+	}
+
+	return response, nil
+}
+
+// anthropicMessages builds the messages of a request: earlier turns, the
+// prompt and the prefill as a trailing assistant message the model continues
+func anthropicMessages(prompt string, opts *RequestOptions) []anthropicMessage {
+	// Reasoning is not needed to continue a conversation
+	var messages []anthropicMessage
+	for _, message := range opts.History {
+		messages = append(messages, anthropicMessage{Role: message.Role, Content: message.Content})
+	}
+	messages = append(messages, anthropicMessage{Role: "user", Content: prompt})
+
+	if prefill := sentPrefill(opts); prefill != "" {
+		messages = append(messages, anthropicMessage{Role: "assistant", Content: prefill})
+	}
+	return messages
+}
+
+// anthropicCountRequest represents a request to the Anthropic token counting API
+type anthropicCountRequest struct {
+	Model    string             `json:"model"`
+	Messages []anthropicMessage `json:"messages"`
+	System   string             `json:"system,omitempty"`
+}
+
+// anthropicCountResponse represents a response from the Anthropic token counting API
+type anthropicCountResponse struct {
+	InputTokens int `json:"input_tokens"`
+	Error       *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// CountTokens implements the TokenCounter interface using the count_tokens endpoint
+func (p *AnthropicProvider) CountTokens(ctx context.Context, prompt string, options ...Option) (int, error) {
+	// Apply options
+	opts := &RequestOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	// Model is required
+	if opts.Model == "" {
+		return 0, errors.New("model is required for Anthropic provider")
+	}
+
+	req := anthropicCountRequest{
+		Model:    opts.Model,
+		Messages: anthropicMessages(prompt, opts),
+	}
+	if system, ok := opts.CustomParams["system"].(string); ok && system != "" {
+		req.System = system
+	}
+
+	// Convert to JSON
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		p.baseURL+"/count_tokens",
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	// Set headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	for name, value := range p.headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	// Send request
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Just log the error, can't return it here
+			fmt.Printf("Error closing response body: %v\n", err)
+		}
+	}()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error reading response: %w", err)
+	}
+
+	// Parse response, checking for API errors
+	var result anthropicCountResponse
+	if err := json.Unmarshal(body, &result); err == nil && result.Error != nil {
+		return 0, fmt.Errorf("API error (%s): %s", result.Error.Type, result.Error.Message)
+	} else if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	} else if err != nil {
+		return 0, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	return result.InputTokens, nil
+}
+
+// normalizeAnthropicStopReason maps an Anthropic stop_reason to a StopReason constant
+func normalizeAnthropicStopReason(reason string) string {
+	switch reason {
+	case "end_turn":
+		return StopReasonEndTurn
+	case "max_tokens":
+		return StopReasonMaxTokens
+	case "stop_sequence":
+		return StopReasonStopSequence
+	case "tool_use":
+		return StopReasonToolUse
+	case "":
+		return ""
+	}
+	return StopReasonOther
 }
@@ -0,0 +1,294 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// anthropicCustomID matches the request IDs accepted by the Message Batches API
+var anthropicCustomID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// anthropicBatchRequest represents one request of a message batch
+type anthropicBatchRequest struct {
+	CustomID string           `json:"custom_id"`
+	Params   anthropicRequest `json:"params"`
+}
+
+// anthropicBatchCounts represents the request counts of a message batch
+type anthropicBatchCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// anthropicBatch represents a message batch in the Anthropic API
+type anthropicBatch struct {
+	ID                string               `json:"id"`
+	ProcessingStatus  string               `json:"processing_status"`
+	RequestCounts     anthropicBatchCounts `json:"request_counts"`
+	CreatedAt         time.Time            `json:"created_at"`
+	EndedAt           *time.Time           `json:"ended_at"`
+	CancelInitiatedAt *time.Time           `json:"cancel_initiated_at"`
+	ResultsURL        string               `json:"results_url"`
+}
+
+// anthropicBatchResult represents one line of the results of a message batch
+type anthropicBatchResult struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string            `json:"type"`
+		Message anthropicResponse `json:"message"`
+		Error   struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"error"`
+	} `json:"result"`
+}
+
+// SubmitBatch implements the BatchProvider interface using the Message Batches API
+func (p *AnthropicProvider) SubmitBatch(ctx context.Context, requests []BatchRequest) (*BatchJob, error) {
+	payload := struct {
+		Requests []anthropicBatchRequest `json:"requests"`
+	}{}
+
+	for _, request := range requests {
+		if !anthropicCustomID.MatchString(request.ID) {
+			return nil, fmt.Errorf("invalid batch request ID %q, use up to 64 letters, digits, '-' and '_'", request.ID)
+		}
+
+		// Apply options
+		opts := &RequestOptions{
+			MaxTokens:   1000,
+			Temperature: 0.7,
+		}
+		for _, option := range request.Options {
+			option(opts)
+		}
+
+		// Results do not say what was prefilled, so the answer could not be completed
+		if opts.Prefill != "" {
+			return nil, errors.New("prefilling the response is not supported in batch jobs")
+		}
+
+		params, err := anthropicRequestFor(request.Prompt, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error in batch request %s: %w", request.ID, err)
+		}
+		payload.Requests = append(payload.Requests, anthropicBatchRequest{CustomID: request.ID, Params: params})
+	}
+
+	var batch anthropicBatch
+	if err := p.sendBatchRequest(ctx, http.MethodPost, p.baseURL+"/batches", payload, &batch); err != nil {
+		return nil, err
+	}
+	return batch.job(), nil
+}
+
+// GetBatch implements the BatchProvider interface
+func (p *AnthropicProvider) GetBatch(ctx context.Context, id string) (*BatchJob, error) {
+	batch, err := p.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return batch.job(), nil
+}
+
+// BatchResults implements the BatchProvider interface by downloading the
+// JSONL results of an ended message batch
+func (p *AnthropicProvider) BatchResults(ctx context.Context, id string) ([]BatchResult, error) {
+	batch, err := p.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.ProcessingStatus != "ended" {
+		return nil, fmt.Errorf("batch %s is still %s, results are available once it has ended", id, batch.job().Status)
+	}
+
+	resultsURL := batch.ResultsURL
+	if resultsURL == "" {
+		resultsURL = p.baseURL + "/batches/" + id + "/results"
+	}
+
+	body, err := p.sendBatchRequestRaw(ctx, http.MethodGet, resultsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BatchResult
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry anthropicBatchResult
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("error parsing batch result: %w", err)
+		}
+
+		result := BatchResult{ID: entry.CustomID}
+		switch entry.Result.Type {
+		case "succeeded":
+			response, err := anthropicResult(&entry.Result.Message, &RequestOptions{})
+			if err != nil {
+				result.Error = err.Error()
+				break
+			}
+			response.Metadata.RequestID = entry.Result.Message.ID
+			if batch.EndedAt != nil {
+				response.Metadata.Created = *batch.EndedAt
+			}
+			result.Response = response
+		case "errored":
+			result.Error = fmt.Sprintf("API error (%s): %s", entry.Result.Error.Error.Type, entry.Result.Error.Error.Message)
+		default:
+			// Cancelled and expired requests were never processed
+			result.Error = "request " + entry.Result.Type
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading batch results: %w", err)
+	}
+
+	return results, nil
+}
+
+// CancelBatch implements the BatchProvider interface
+func (p *AnthropicProvider) CancelBatch(ctx context.Context, id string) (*BatchJob, error) {
+	var batch anthropicBatch
+	if err := p.sendBatchRequest(ctx, http.MethodPost, p.baseURL+"/batches/"+id+"/cancel", nil, &batch); err != nil {
+		return nil, err
+	}
+	return batch.job(), nil
+}
+
+// getBatch fetches a message batch
+func (p *AnthropicProvider) getBatch(ctx context.Context, id string) (*anthropicBatch, error) {
+	var batch anthropicBatch
+	if err := p.sendBatchRequest(ctx, http.MethodGet, p.baseURL+"/batches/"+id, nil, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// job converts a message batch into a BatchJob
+func (b *anthropicBatch) job() *BatchJob {
+	job := &BatchJob{
+		ID:        b.ID,
+		CreatedAt: b.CreatedAt,
+		Counts: BatchCounts{
+			Processing: b.RequestCounts.Processing,
+			Succeeded:  b.RequestCounts.Succeeded,
+			Errored:    b.RequestCounts.Errored,
+			Canceled:   b.RequestCounts.Canceled,
+			Expired:    b.RequestCounts.Expired,
+		},
+	}
+	if b.EndedAt != nil {
+		job.EndedAt = *b.EndedAt
+	}
+
+	// An ended batch reports cancellation and expiry only in its counts
+	switch b.ProcessingStatus {
+	case "in_progress":
+		job.Status = BatchStatusInProgress
+	case "canceling":
+		job.Status = BatchStatusCanceling
+	case "ended":
+		switch {
+		case b.CancelInitiatedAt != nil:
+			job.Status = BatchStatusCanceled
+		case b.RequestCounts.Expired > 0 && b.RequestCounts.Succeeded+b.RequestCounts.Errored == 0:
+			job.Status = BatchStatusExpired
+		default:
+			job.Status = BatchStatusEnded
+		}
+	default:
+		job.Status = b.ProcessingStatus
+	}
+	return job
+}
+
+// sendBatchRequest sends a request to the Message Batches API and parses the JSON response into result
+func (p *AnthropicProvider) sendBatchRequest(ctx context.Context, method, url string, payload, result interface{}) error {
+	body, err := p.sendBatchRequestRaw(ctx, method, url, payload)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("error parsing response: %w", err)
+	}
+	return nil
+}
+
+// sendBatchRequestRaw sends a request to the Message Batches API and returns the response body
+func (p *AnthropicProvider) sendBatchRequestRaw(ctx context.Context, method, url string, payload interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling request: %w", err)
+		}
+		reqBody = bytes.NewReader(jsonData)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	// Set headers
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	for name, value := range p.headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	// Send request
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Just log the error, can't return it here
+			fmt.Printf("Error closing response body: %v\n", err)
+		}
+	}()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	// Check for HTTP errors
+	if resp.StatusCode != http.StatusOK {
+		var errResp anthropicResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != nil {
+			return nil, fmt.Errorf("API error (%s): %s", errResp.Error.Type, errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
@@ -0,0 +1,262 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// anthropicInboundMessage is a message of a Messages API request received
+// from a client, whose content is a string or a list of content blocks
+type anthropicInboundMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// anthropicInboundRequest is a Messages API request received from a client.
+// The system prompt and message content may be content blocks, which the
+// provider never sends.
+type anthropicInboundRequest struct {
+	anthropicRequest
+	Messages []anthropicInboundMessage `json:"messages"`
+	System   json.RawMessage           `json:"system"`
+	TopK     *int                      `json:"top_k"`
+	Stream   bool                      `json:"stream"`
+}
+
+// AnthropicRequest is an Anthropic Messages API request converted into a
+// query, so Anthropic clients can be answered by any provider
+type AnthropicRequest struct {
+	Model   string   // Model named by the client
+	Prompt  string   // The last user message
+	Options []Option // Everything else in the request
+	Stream  bool     // Whether the client asked for server-sent events
+
+	// prefill is a trailing assistant message, which the client does not
+	// expect repeated in the answer
+	prefill string
+}
+
+// ParseAnthropicRequest converts a Messages API request body into a query.
+// It reverses what the Anthropic provider does to a query: earlier messages
+// become the history, a trailing assistant message the prefill and the
+// thinking budget is taken out of max_tokens.
+func ParseAnthropicRequest(body []byte) (*AnthropicRequest, error) {
+	var req anthropicInboundRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if req.MaxTokens <= 0 {
+		return nil, errors.New("max_tokens is required")
+	}
+
+	var messages []Message
+	for _, message := range req.Messages {
+		if message.Role != "user" && message.Role != "assistant" {
+			return nil, fmt.Errorf("messages with role %q are not supported", message.Role)
+		}
+		text, err := anthropicBlocksText(message.Content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s message: %w", message.Role, err)
+		}
+		messages = append(messages, Message{Role: message.Role, Content: text})
+	}
+
+	parsed := &AnthropicRequest{Model: req.Model, Stream: req.Stream}
+
+	// A trailing assistant message is the start of the answer
+	if len(messages) > 0 && messages[len(messages)-1].Role == "assistant" {
+		parsed.prefill = messages[len(messages)-1].Content
+		messages = messages[:len(messages)-1]
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return nil, errors.New("the last message must be from the user")
+	}
+	parsed.Prompt = messages[len(messages)-1].Content
+	history := messages[:len(messages)-1]
+
+	maxTokens := req.MaxTokens
+	if req.Thinking != nil && req.Thinking.Type == "enabled" {
+		// The budget counts towards max_tokens, the answer gets the rest
+		maxTokens -= req.Thinking.BudgetTokens
+		if maxTokens <= 0 {
+			return nil, errors.New("max_tokens must be greater than thinking.budget_tokens")
+		}
+		parsed.Options = append(parsed.Options, WithThinking(req.Thinking.BudgetTokens))
+	}
+	parsed.Options = append(parsed.Options, WithMaxTokens(maxTokens))
+
+	system, err := anthropicBlocksText(req.System)
+	if err != nil {
+		return nil, fmt.Errorf("invalid system prompt: %w", err)
+	}
+	if system != "" {
+		parsed.Options = append(parsed.Options, WithCustomParam("system", system))
+	}
+	if len(history) > 0 {
+		parsed.Options = append(parsed.Options, WithHistory(history))
+	}
+	if parsed.prefill != "" {
+		parsed.Options = append(parsed.Options, WithPrefill(parsed.prefill))
+	}
+	if req.Temperature != nil {
+		parsed.Options = append(parsed.Options, WithTemperature(*req.Temperature))
+	}
+	if req.TopP != 0 {
+		parsed.Options = append(parsed.Options, WithCustomParam("top_p", req.TopP))
+	}
+	if req.TopK != nil {
+		parsed.Options = append(parsed.Options, WithCustomParam("top_k", *req.TopK))
+	}
+	if len(req.StopSequences) > 0 {
+		parsed.Options = append(parsed.Options, WithStopSequences(req.StopSequences...))
+	}
+
+	return parsed, nil
+}
+
+// anthropicBlocksText returns the text of content given as a string or a
+// list of content blocks. Thinking blocks are dropped, like the provider
+// drops reasoning from the history.
+func anthropicBlocksText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+
+	var blocks []anthropicContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return "", errors.New("content must be a string or a list of content blocks")
+	}
+
+	var texts []string
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "thinking", "redacted_thinking":
+		default:
+			return "", fmt.Errorf("content block type %q is not supported, only text", block.Type)
+		}
+	}
+	return strings.Join(texts, ""), nil
+}
+
+// Message converts a response into a Messages API response body
+func (r *AnthropicRequest) Message(response *Response) ([]byte, error) {
+	return json.Marshal(r.message(response))
+}
+
+// message builds the Messages API message for a response
+func (r *AnthropicRequest) message(response *Response) *anthropicResponse {
+	message := &anthropicResponse{
+		ID:         "msg_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Type:       "message",
+		Role:       "assistant",
+		Model:      r.Model,
+		StopReason: anthropicStopReason(response.Metadata.StopReason),
+		Usage: anthropicUsage{
+			InputTokens:  response.Usage.InputTokens,
+			OutputTokens: response.Usage.OutputTokens + response.Usage.ThinkingTokens,
+		},
+	}
+
+	if response.Thinking != "" {
+		message.Content = append(message.Content, anthropicContentBlock{Type: "thinking", Thinking: response.Thinking})
+	}
+
+	// Anthropic answers continue the prefill without repeating it. The
+	// Anthropic provider returns the prefill without trailing whitespace.
+	text := response.Text
+	if strings.HasPrefix(text, r.prefill) {
+		text = text[len(r.prefill):]
+	} else {
+		text = strings.TrimPrefix(text, sentPrefill(&RequestOptions{Prefill: r.prefill}))
+	}
+	message.Content = append(message.Content, anthropicContentBlock{Type: "text", Text: text})
+
+	return message
+}
+
+// AnthropicEvent is a server-sent event of a streamed Messages API response
+type AnthropicEvent struct {
+	Name string
+	Data []byte
+}
+
+// Events converts a response into the server-sent events of a streamed
+// Messages API response. The response is complete, so every content block
+// is sent as a single delta.
+func (r *AnthropicRequest) Events(response *Response) ([]AnthropicEvent, error) {
+	message := r.message(response)
+
+	// The message starts empty and without a stop reason
+	start := map[string]interface{}{
+		"id":            message.ID,
+		"type":          message.Type,
+		"role":          message.Role,
+		"model":         message.Model,
+		"content":       []interface{}{},
+		"stop_reason":   nil,
+		"stop_sequence": nil,
+		"usage":         anthropicUsage{InputTokens: message.Usage.InputTokens},
+	}
+	events := []map[string]interface{}{{"type": "message_start", "message": start}}
+
+	for i, block := range message.Content {
+		var empty, delta map[string]interface{}
+		if block.Type == "thinking" {
+			empty = map[string]interface{}{"type": "thinking", "thinking": ""}
+			delta = map[string]interface{}{"type": "thinking_delta", "thinking": block.Thinking}
+		} else {
+			empty = map[string]interface{}{"type": "text", "text": ""}
+			delta = map[string]interface{}{"type": "text_delta", "text": block.Text}
+		}
+		events = append(events,
+			map[string]interface{}{"type": "content_block_start", "index": i, "content_block": empty},
+			map[string]interface{}{"type": "content_block_delta", "index": i, "delta": delta},
+			map[string]interface{}{"type": "content_block_stop", "index": i},
+		)
+	}
+
+	events = append(events,
+		map[string]interface{}{
+			"type":  "message_delta",
+			"delta": map[string]interface{}{"stop_reason": message.StopReason, "stop_sequence": nil},
+			"usage": map[string]interface{}{"output_tokens": message.Usage.OutputTokens},
+		},
+		map[string]interface{}{"type": "message_stop"},
+	)
+
+	converted := make([]AnthropicEvent, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling event: %w", err)
+		}
+		converted = append(converted, AnthropicEvent{Name: event["type"].(string), Data: data})
+	}
+	return converted, nil
+}
+
+// anthropicStopReason maps a StopReason constant to an Anthropic stop_reason
+func anthropicStopReason(stopReason string) string {
+	switch stopReason {
+	case StopReasonMaxTokens:
+		return "max_tokens"
+	case StopReasonStopSequence:
+		return "stop_sequence"
+	case StopReasonToolUse:
+		return "tool_use"
+	case StopReasonContentBlock:
+		return "refusal"
+	}
+	return "end_turn"
+}
@@ -0,0 +1,156 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseAnthropicRequest(t *testing.T) {
+	body := `{
+		"model": "claude-3-7-sonnet-latest",
+		"max_tokens": 3000,
+		"system": [{"type": "text", "text": "Be brief."}],
+		"messages": [
+			{"role": "user", "content": "Hi"},
+			{"role": "assistant", "content": [{"type": "thinking", "thinking": "Greet back"}, {"type": "text", "text": "Hello!"}]},
+			{"role": "user", "content": [{"type": "text", "text": "List three colors"}]},
+			{"role": "assistant", "content": "1."}
+		],
+		"thinking": {"type": "enabled", "budget_tokens": 2000},
+		"top_k": 40,
+		"stop_sequences": ["END"],
+		"stream": true
+	}`
+
+	request, err := ParseAnthropicRequest([]byte(body))
+	if err != nil {
+		t.Fatalf("ParseAnthropicRequest returned error: %v", err)
+	}
+	if request.Model != "claude-3-7-sonnet-latest" || request.Prompt != "List three colors" || !request.Stream {
+		t.Errorf("Expected model, prompt and stream, got %+v", request)
+	}
+
+	opts := &RequestOptions{}
+	for _, option := range request.Options {
+		option(opts)
+	}
+	if opts.MaxTokens != 1000 || opts.ThinkingBudget != 2000 {
+		t.Errorf("Expected the thinking budget out of max tokens, got %d and %d", opts.MaxTokens, opts.ThinkingBudget)
+	}
+	if opts.CustomParams["system"] != "Be brief." || opts.CustomParams["top_k"] != 40 || opts.Prefill != "1." {
+		t.Errorf("Expected system prompt, top_k and prefill, got %v and %q", opts.CustomParams, opts.Prefill)
+	}
+	if len(opts.History) != 2 || opts.History[1].Content != "Hello!" {
+		t.Errorf("Expected two earlier turns without thinking, got %+v", opts.History)
+	}
+	if len(opts.StopSequences) != 1 || opts.StopSequences[0] != "END" {
+		t.Errorf("Expected stop sequence END, got %v", opts.StopSequences)
+	}
+}
+
+func TestParseAnthropicRequestRoundTrip(t *testing.T) {
+	// A request built by the provider parses back into the same query
+	temperature := 0.3
+	opts := &RequestOptions{
+		Model:         "claude-3-7-sonnet-latest",
+		MaxTokens:     500,
+		Temperature:   temperature,
+		StopSequences: []string{"END"},
+		History:       []Message{{Role: "user", Content: "Hi"}, {Role: "assistant", Content: "Hello!"}},
+		CustomParams:  map[string]interface{}{"system": "Be brief.", "top_p": 0.9},
+	}
+	sent, err := anthropicRequestFor("What is Go?", opts)
+	if err != nil {
+		t.Fatalf("anthropicRequestFor returned error: %v", err)
+	}
+	body, err := json.Marshal(sent)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	request, err := ParseAnthropicRequest(body)
+	if err != nil {
+		t.Fatalf("ParseAnthropicRequest returned error: %v", err)
+	}
+	parsed := &RequestOptions{Model: request.Model}
+	for _, option := range request.Options {
+		option(parsed)
+	}
+	resent, err := anthropicRequestFor(request.Prompt, parsed)
+	if err != nil {
+		t.Fatalf("anthropicRequestFor returned error: %v", err)
+	}
+	resentBody, err := json.Marshal(resent)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	if string(resentBody) != string(body) {
+		t.Errorf("Expected the same request after a round trip:\n%s\n%s", body, resentBody)
+	}
+}
+
+func TestParseAnthropicRequestErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalid JSON":       `{"model":`,
+		"missing max tokens": `{"model": "claude-3-7-sonnet-latest", "messages": [{"role": "user", "content": "Hi"}]}`,
+		"no user message":    `{"model": "claude-3-7-sonnet-latest", "max_tokens": 10, "messages": [{"role": "assistant", "content": "Hi"}]}`,
+		"image block":        `{"model": "claude-3-7-sonnet-latest", "max_tokens": 10, "messages": [{"role": "user", "content": [{"type": "image"}]}]}`,
+		"budget too large":   `{"model": "claude-3-7-sonnet-latest", "max_tokens": 10, "thinking": {"type": "enabled", "budget_tokens": 2000}, "messages": [{"role": "user", "content": "Hi"}]}`,
+	}
+
+	for name, body := range tests {
+		if _, err := ParseAnthropicRequest([]byte(body)); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+func TestAnthropicRequestMessage(t *testing.T) {
+	request, err := ParseAnthropicRequest([]byte(`{"model": "gemini-2.0-flash", "max_tokens": 100, "messages": [{"role": "user", "content": "Count"}, {"role": "assistant", "content": "1, "}]}`))
+	if err != nil {
+		t.Fatalf("ParseAnthropicRequest returned error: %v", err)
+	}
+
+	response := &Response{
+		Text:     "1, 2, 3",
+		Thinking: "Counting",
+		Usage:    Usage{InputTokens: 10, OutputTokens: 4, ThinkingTokens: 2},
+		Metadata: Metadata{StopReason: StopReasonMaxTokens},
+	}
+
+	body, err := request.Message(response)
+	if err != nil {
+		t.Fatalf("Message returned error: %v", err)
+	}
+	var message anthropicResponse
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if message.Type != "message" || message.Role != "assistant" || !strings.HasPrefix(message.ID, "msg_") || message.StopReason != "max_tokens" {
+		t.Errorf("Expected an assistant message cut off at max tokens, got %+v", message)
+	}
+	if len(message.Content) != 2 || message.Content[0].Thinking != "Counting" || message.Content[1].Text != "2, 3" {
+		t.Errorf("Expected thinking and the answer after the prefill, got %+v", message.Content)
+	}
+	if message.Usage.InputTokens != 10 || message.Usage.OutputTokens != 6 {
+		t.Errorf("Expected thinking counted as output, got %+v", message.Usage)
+	}
+
+	events, err := request.Events(response)
+	if err != nil {
+		t.Fatalf("Events returned error: %v", err)
+	}
+	var names []string
+	for _, event := range events {
+		names = append(names, event.Name)
+	}
+	expected := "message_start content_block_start content_block_delta content_block_stop content_block_start content_block_delta content_block_stop message_delta message_stop"
+	if strings.Join(names, " ") != expected {
+		t.Errorf("Expected events %s, got %s", expected, strings.Join(names, " "))
+	}
+	if !strings.Contains(string(events[5].Data), `"text_delta"`) || !strings.Contains(string(events[5].Data), `"2, 3"`) {
+		t.Errorf("Expected text delta, got %s", events[5].Data)
+	}
+}
@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// setupAnthropicRecordingServer creates a mock Anthropic server that records the request body
+func setupAnthropicRecordingServer(t *testing.T, response anthropicResponse, lastRequest *anthropicRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(lastRequest); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode Anthropic mock response: %v", err)
+		}
+	}))
+}
+
+// TestAnthropicProviderIgnoredOptions tests that options the API lacks are left out with warnings
+func TestAnthropicProviderIgnoredOptions(t *testing.T) {
+	var lastRequest anthropicRequest
+	server := setupAnthropicRecordingServer(t, anthropicResponse{
+		Content: []anthropicContentBlock{{Type: "text", Text: "Hello!"}},
+	}, &lastRequest)
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", server.Client(), WithBaseURL(server.URL))
+
+	response, err := provider.QueryDetailed(context.Background(), "Say hello",
+		WithModel("claude-3-7-sonnet-latest"),
+		WithPresencePenalty(0.5),
+		WithSeed(42),
+	)
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+	if len(response.Warnings) != 2 || !strings.Contains(response.Warnings[0], "presence_penalty") || !strings.Contains(response.Warnings[1], "seed") {
+		t.Errorf("Expected warnings about presence_penalty and seed, got %v", response.Warnings)
+	}
+
+	if _, err := provider.QueryDetailed(context.Background(), "Say hello", WithModel("claude-3-7-sonnet-latest"), WithCandidateCount(2)); err == nil {
+		t.Error("Expected error for several candidates")
+	}
+}
+
+// TestAnthropicProviderThinking tests that extended thinking is requested and returned separately
+func TestAnthropicProviderThinking(t *testing.T) {
+	var lastRequest anthropicRequest
+	server := setupAnthropicRecordingServer(t, anthropicResponse{
+		Content: []anthropicContentBlock{
+			{Type: "thinking", Thinking: "The user wants a greeting. Keep it short.", Signature: "sig"},
+			{Type: "text", Text: "Hello!"},
+		},
+		Usage: anthropicUsage{InputTokens: 12, OutputTokens: 40},
+	}, &lastRequest)
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", server.Client(), WithBaseURL(server.URL))
+
+	response, err := provider.QueryDetailed(context.Background(), "Say hello",
+		WithModel("claude-3-7-sonnet-latest"),
+		WithMaxTokens(1000),
+		WithTemperature(0.5),
+		WithThinking(2048),
+		WithCustomParam("top_p", 0.9),
+	)
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	// Check the request
+	if lastRequest.Thinking == nil || lastRequest.Thinking.Type != "enabled" || lastRequest.Thinking.BudgetTokens != 2048 {
+		t.Errorf("Expected thinking enabled with budget 2048, got %+v", lastRequest.Thinking)
+	}
+	if lastRequest.MaxTokens != 3048 {
+		t.Errorf("Expected max_tokens 3048, got %d", lastRequest.MaxTokens)
+	}
+	if lastRequest.Temperature != nil {
+		t.Errorf("Expected temperature to be omitted with thinking, got %v", *lastRequest.Temperature)
+	}
+	if lastRequest.TopP != 0 || len(response.Warnings) != 1 || !strings.Contains(response.Warnings[0], "top_p") {
+		t.Errorf("Expected top_p to be omitted with a warning, got %v and warnings %v", lastRequest.TopP, response.Warnings)
+	}
+
+	// Check the response
+	if response.Text != "Hello!" {
+		t.Errorf("Expected text 'Hello!', got %q", response.Text)
+	}
+	if response.Thinking != "The user wants a greeting. Keep it short." {
+		t.Errorf("Expected thinking content, got %q", response.Thinking)
+	}
+
+	usage := response.Usage
+	if usage.InputTokens != 12 {
+		t.Errorf("Expected 12 input tokens, got %d", usage.InputTokens)
+	}
+	if usage.ThinkingTokens == 0 || usage.OutputTokens+usage.ThinkingTokens != 40 {
+		t.Errorf("Expected 40 output tokens split into answer and thinking, got %+v", usage)
+	}
+}
+
+// TestAnthropicProviderWithoutThinking tests that regular requests keep temperature and omit thinking
+func TestAnthropicProviderWithoutThinking(t *testing.T) {
+	var lastRequest anthropicRequest
+	server := setupAnthropicRecordingServer(t, anthropicResponse{
+		Content: []anthropicContentBlock{{Type: "text", Text: "Hi"}},
+		Usage:   anthropicUsage{InputTokens: 5, OutputTokens: 2},
+	}, &lastRequest)
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", server.Client(), WithBaseURL(server.URL))
+
+	response, err := provider.QueryDetailed(context.Background(), "Say hi",
+		WithModel("claude-3-7-sonnet-latest"),
+		WithTemperature(0.5),
+	)
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	if lastRequest.Thinking != nil {
+		t.Errorf("Expected no thinking parameter, got %+v", lastRequest.Thinking)
+	}
+	if lastRequest.Temperature == nil || *lastRequest.Temperature != 0.5 {
+		t.Errorf("Expected temperature 0.5, got %v", lastRequest.Temperature)
+	}
+	if response.Thinking != "" || response.Usage.ThinkingTokens != 0 {
+		t.Errorf("Expected no thinking, got %q (%d tokens)", response.Thinking, response.Usage.ThinkingTokens)
+	}
+	if response.Usage.OutputTokens != 2 {
+		t.Errorf("Expected 2 output tokens, got %d", response.Usage.OutputTokens)
+	}
+
+	// Budgets below the API minimum are rejected before sending
+	if _, err := provider.QueryDetailed(context.Background(), "Say hi",
+		WithModel("claude-3-7-sonnet-latest"),
+		WithThinking(100),
+	); err == nil {
+		t.Error("Expected error for thinking budget below minimum, got nil")
+	}
+}
+
+// TestAnthropicProviderMetadata tests that the stop reason, request ID and served model are returned
+func TestAnthropicProviderMetadata(t *testing.T) {
+	var lastRequest anthropicRequest
+	server := setupAnthropicRecordingServer(t, anthropicResponse{
+		ID:         "msg_123",
+		Model:      "claude-3-7-sonnet-20250219",
+		Content:    []anthropicContentBlock{{Type: "text", Text: "Once upon a"}},
+		StopReason: "max_tokens",
+	}, &lastRequest)
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", server.Client(), WithBaseURL(server.URL))
+
+	response, err := provider.QueryDetailed(context.Background(), "Tell a story", WithModel("claude-3-7-sonnet-latest"))
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	metadata := response.Metadata
+	if metadata.StopReason != StopReasonMaxTokens || !metadata.Truncated() {
+		t.Errorf("Expected truncated response with stop reason max_tokens, got %q", metadata.StopReason)
+	}
+	if metadata.RequestID != "msg_123" {
+		t.Errorf("Expected request ID msg_123, got %q", metadata.RequestID)
+	}
+	if metadata.ModelVersion != "claude-3-7-sonnet-20250219" {
+		t.Errorf("Expected model version claude-3-7-sonnet-20250219, got %q", metadata.ModelVersion)
+	}
+	if metadata.Created.IsZero() {
+		t.Error("Expected created time to be set")
+	}
+}
+
+// TestAnthropicProviderPrefillAndStopSequences tests that the prefill is sent as an assistant turn and returned
+func TestAnthropicProviderPrefillAndStopSequences(t *testing.T) {
+	var lastRequest anthropicRequest
+	server := setupAnthropicRecordingServer(t, anthropicResponse{
+		Content:    []anthropicContentBlock{{Type: "text", Text: `"name": "gollm"}`}},
+		StopReason: "end_turn",
+	}, &lastRequest)
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", server.Client(), WithBaseURL(server.URL))
+
+	response, err := provider.QueryDetailed(context.Background(), "Describe the project as JSON",
+		WithModel("claude-3-7-sonnet-latest"),
+		WithPrefill("{ \n"),
+		WithStopSequences("\n\n", "END"),
+	)
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	messages := lastRequest.Messages
+	if len(messages) != 2 || messages[1].Role != "assistant" || messages[1].Content != "{" {
+		t.Errorf("Expected prefill without trailing whitespace as assistant turn, got %+v", messages)
+	}
+	if len(lastRequest.StopSequences) != 2 || lastRequest.StopSequences[1] != "END" {
+		t.Errorf("Expected stop sequences in request, got %v", lastRequest.StopSequences)
+	}
+	if response.Text != `{"name": "gollm"}` {
+		t.Errorf("Expected response to include the prefill, got %q", response.Text)
+	}
+
+	// Thinking cannot be combined with a prefill
+	_, err = provider.QueryDetailed(context.Background(), "Describe the project as JSON",
+		WithModel("claude-3-7-sonnet-latest"),
+		WithPrefill("{"),
+		WithThinking(2048),
+	)
+	if err == nil {
+		t.Error("Expected error for prefill with extended thinking")
+	}
+}
@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BatchProvider is implemented by providers with an asynchronous batch API,
+// which answers many requests within a day at a discount
+type BatchProvider interface {
+	// SubmitBatch creates a batch job answering the requests, which all use the same model
+	SubmitBatch(ctx context.Context, requests []BatchRequest) (*BatchJob, error)
+	// GetBatch returns the current state of a batch job
+	GetBatch(ctx context.Context, id string) (*BatchJob, error)
+	// BatchResults returns the results of a batch job that has ended
+	BatchResults(ctx context.Context, id string) ([]BatchResult, error)
+	// CancelBatch asks for a batch job to stop, requests already answered keep their results
+	CancelBatch(ctx context.Context, id string) (*BatchJob, error)
+}
+
+// BatchRequest is one prompt of a batch job
+type BatchRequest struct {
+	ID      string   // Caller's ID for matching the result, unique within the job
+	Prompt  string   // The prompt to answer
+	Options []Option // Options for this request, including the model
+}
+
+// Batch job statuses normalized across providers
+const (
+	BatchStatusInProgress = "in_progress" // Requests are waiting or being processed
+	BatchStatusCanceling  = "canceling"   // Cancellation was requested and is in progress
+	BatchStatusEnded      = "ended"       // Processing finished, results can be downloaded
+	BatchStatusCanceled   = "canceled"    // The job was cancelled before it ended
+	BatchStatusExpired    = "expired"     // The job did not finish in time
+	BatchStatusFailed     = "failed"      // The job failed as a whole
+)
+
+// BatchCounts counts the requests of a batch job by state
+type BatchCounts struct {
+	Processing int // Requests not answered yet
+	Succeeded  int // Requests answered
+	Errored    int // Requests that failed
+	Canceled   int // Requests not processed because the job was cancelled
+	Expired    int // Requests not processed before the job expired
+}
+
+// Total returns the number of requests in the job
+func (c BatchCounts) Total() int {
+	return c.Processing + c.Succeeded + c.Errored + c.Canceled + c.Expired
+}
+
+// BatchJob describes a batch job as reported by the provider
+type BatchJob struct {
+	ID        string      // Provider's ID for the job
+	Provider  string      // Provider running the job
+	Model     string      // Model answering the requests
+	Status    string      // One of the BatchStatus constants
+	Counts    BatchCounts // Requests by state
+	CreatedAt time.Time   // When the job was submitted
+	EndedAt   time.Time   // When processing finished, zero while in progress
+}
+
+// Done reports whether the job has stopped processing requests
+func (j *BatchJob) Done() bool {
+	return j.Status != BatchStatusInProgress && j.Status != BatchStatusCanceling
+}
+
+// BatchResult is the outcome of one request of a batch job
+type BatchResult struct {
+	ID       string    // ID of the request
+	Response *Response // The answer, nil if the request failed
+	Error    string    // Why the request failed, empty if it succeeded
+}
+
+// SubmitBatch submits requests to the batch API of the model's provider.
+// Every request uses the model and the provider's defaults.
+func (s *Service) SubmitBatch(ctx context.Context, modelName string, requests []BatchRequest) (*BatchJob, error) {
+	// Validate model
+	if !IsValidModel(modelName) {
+		return nil, fmt.Errorf("unknown model: %s", modelName)
+	}
+
+	// Determine provider based on model name
+	providerName, _ := GetProviderForModel(modelName)
+
+	provider, err := s.batchProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(requests) == 0 {
+		return nil, errors.New("no requests to submit")
+	}
+
+	// Results are matched to requests by ID, and model and provider defaults
+	// come before the options of each request
+	seen := make(map[string]bool)
+	submitted := make([]BatchRequest, 0, len(requests))
+	for _, request := range requests {
+		if request.ID == "" {
+			return nil, errors.New("every batch request needs an ID")
+		}
+		if seen[request.ID] {
+			return nil, fmt.Errorf("duplicate batch request ID %q", request.ID)
+		}
+		seen[request.ID] = true
+
+		request.Options = s.providerOptions(providerName, modelName, request.Options)
+		submitted = append(submitted, request)
+	}
+
+	job, err := provider.SubmitBatch(ctx, submitted)
+	if err != nil {
+		return nil, err
+	}
+	job.Provider = providerName
+	job.Model = modelName
+	return job, nil
+}
+
+// GetBatch returns the current state of a batch job of a provider
+func (s *Service) GetBatch(ctx context.Context, providerName, id string) (*BatchJob, error) {
+	provider, err := s.batchProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := provider.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	job.Provider = providerName
+	return job, nil
+}
+
+// BatchResults returns the results of a batch job of a provider
+func (s *Service) BatchResults(ctx context.Context, providerName, id string) ([]BatchResult, error) {
+	provider, err := s.batchProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return provider.BatchResults(ctx, id)
+}
+
+// CancelBatch cancels a batch job of a provider
+func (s *Service) CancelBatch(ctx context.Context, providerName, id string) (*BatchJob, error) {
+	provider, err := s.batchProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := provider.CancelBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	job.Provider = providerName
+	return job, nil
+}
+
+// batchProvider returns a configured provider that supports batch jobs
+func (s *Service) batchProvider(providerName string) (BatchProvider, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, fmt.Errorf("provider %s not configured", providerName)
+	}
+
+	batchProvider, ok := provider.(BatchProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support batch jobs", providerName)
+	}
+	return batchProvider, nil
+}
@@ -0,0 +1,294 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupAnthropicBatchServer creates a stand-in for the Message Batches API
+// with one batch in the given processing status
+func setupAnthropicBatchServer(t *testing.T, status string, lastBody *map[string]interface{}) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("Expected API key header, got %q", r.Header.Get("x-api-key"))
+		}
+
+		batch := map[string]interface{}{
+			"id":                "msgbatch_123",
+			"type":              "message_batch",
+			"processing_status": status,
+			"request_counts":    map[string]int{"processing": 0, "succeeded": 1, "errored": 1, "canceled": 0, "expired": 0},
+			"created_at":        "2025-04-01T10:00:00Z",
+			"ended_at":          "2025-04-01T10:05:00Z",
+			"results_url":       server.URL + "/v1/messages/batches/msgbatch_123/results",
+		}
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/messages/batches":
+			if lastBody != nil {
+				if err := json.NewDecoder(r.Body).Decode(lastBody); err != nil {
+					t.Errorf("Failed to decode request body: %v", err)
+				}
+			}
+			batch["processing_status"] = "in_progress"
+			batch["request_counts"] = map[string]int{"processing": 2}
+			delete(batch, "ended_at")
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/messages/batches/msgbatch_123":
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/messages/batches/msgbatch_123/cancel":
+			batch["processing_status"] = "canceling"
+			batch["cancel_initiated_at"] = "2025-04-01T10:01:00Z"
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/messages/batches/msgbatch_123/results":
+			results := `{"custom_id":"a","result":{"type":"succeeded","message":{"id":"msg_1","model":"claude-3-7-sonnet-20250219","content":[{"type":"text","text":"Paris"}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":3}}}}
+{"custom_id":"b","result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens too large"}}}}
+`
+			if _, err := w.Write([]byte(results)); err != nil {
+				t.Errorf("Failed to write results: %v", err)
+			}
+			return
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(batch); err != nil {
+			t.Errorf("Failed to encode batch: %v", err)
+		}
+	}))
+	return server
+}
+
+func TestServiceSubmitBatchAnthropic(t *testing.T) {
+	var body map[string]interface{}
+	server := setupAnthropicBatchServer(t, "ended", &body)
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"anthropic": "test-key"}, server.Client(),
+		map[string]ProviderSettings{"anthropic": {BaseURL: server.URL}})
+
+	job, err := service.SubmitBatch(context.Background(), "claude-3-7-sonnet-latest", []BatchRequest{
+		{ID: "a", Prompt: "Capital of France?", Options: []Option{WithMaxTokens(50)}},
+		{ID: "b", Prompt: "Capital of Spain?", Options: []Option{WithCustomParam("system", "Be brief.")}},
+	})
+	if err != nil {
+		t.Fatalf("SubmitBatch returned error: %v", err)
+	}
+
+	if job.ID != "msgbatch_123" || job.Status != BatchStatusInProgress || job.Provider != "anthropic" || job.Model != "claude-3-7-sonnet-latest" {
+		t.Errorf("Expected in-progress Anthropic job, got %+v", job)
+	}
+	if job.Counts.Total() != 2 {
+		t.Errorf("Expected 2 requests, got %d", job.Counts.Total())
+	}
+
+	// Each request carries its ID and the full message parameters
+	requests, _ := body["requests"].([]interface{})
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests in body, got %v", body["requests"])
+	}
+	first := requests[0].(map[string]interface{})
+	params := first["params"].(map[string]interface{})
+	if first["custom_id"] != "a" || params["model"] != "claude-3-7-sonnet-latest" || params["max_tokens"] != float64(50) {
+		t.Errorf("Expected first request with ID, model and max tokens, got %v", first)
+	}
+	if second := requests[1].(map[string]interface{})["params"].(map[string]interface{}); second["system"] != "Be brief." {
+		t.Errorf("Expected system prompt in second request, got %v", second)
+	}
+}
+
+func TestServiceBatchResultsAnthropic(t *testing.T) {
+	server := setupAnthropicBatchServer(t, "ended", nil)
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"anthropic": "test-key"}, server.Client(),
+		map[string]ProviderSettings{"anthropic": {BaseURL: server.URL}})
+
+	job, err := service.GetBatch(context.Background(), "anthropic", "msgbatch_123")
+	if err != nil {
+		t.Fatalf("GetBatch returned error: %v", err)
+	}
+	if job.Status != BatchStatusEnded || !job.Done() || job.Counts.Succeeded != 1 || job.Counts.Errored != 1 {
+		t.Errorf("Expected ended job with 1 success and 1 error, got %+v", job)
+	}
+	if !job.EndedAt.Equal(time.Date(2025, 4, 1, 10, 5, 0, 0, time.UTC)) {
+		t.Errorf("Expected end time, got %v", job.EndedAt)
+	}
+
+	results, err := service.BatchResults(context.Background(), "anthropic", "msgbatch_123")
+	if err != nil {
+		t.Fatalf("BatchResults returned error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	if results[0].ID != "a" || results[0].Response == nil || results[0].Response.Text != "Paris" {
+		t.Errorf("Expected answer for a, got %+v", results[0])
+	} else {
+		metadata := results[0].Response.Metadata
+		if metadata.StopReason != StopReasonEndTurn || metadata.RequestID != "msg_1" || metadata.ModelVersion != "claude-3-7-sonnet-20250219" {
+			t.Errorf("Expected metadata of the message, got %+v", metadata)
+		}
+		if results[0].Response.Usage.InputTokens != 12 || results[0].Response.Usage.OutputTokens != 3 {
+			t.Errorf("Expected usage of the message, got %+v", results[0].Response.Usage)
+		}
+	}
+	if results[1].ID != "b" || results[1].Response != nil || !strings.Contains(results[1].Error, "max_tokens too large") {
+		t.Errorf("Expected error for b, got %+v", results[1])
+	}
+}
+
+func TestServiceCancelBatchAnthropic(t *testing.T) {
+	server := setupAnthropicBatchServer(t, "in_progress", nil)
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"anthropic": "test-key"}, server.Client(),
+		map[string]ProviderSettings{"anthropic": {BaseURL: server.URL}})
+
+	// Results are not available while the batch runs
+	if _, err := service.BatchResults(context.Background(), "anthropic", "msgbatch_123"); err == nil || !strings.Contains(err.Error(), "in_progress") {
+		t.Errorf("Expected error for batch in progress, got %v", err)
+	}
+
+	job, err := service.CancelBatch(context.Background(), "anthropic", "msgbatch_123")
+	if err != nil {
+		t.Fatalf("CancelBatch returned error: %v", err)
+	}
+	if job.Status != BatchStatusCanceling || job.Done() {
+		t.Errorf("Expected canceling job, got %+v", job)
+	}
+}
+
+func TestServiceSubmitBatchValidation(t *testing.T) {
+	service := NewService(map[string]string{"anthropic": "test-key", "deepseek": "test-key"}, nil)
+	ctx := context.Background()
+
+	if _, err := service.SubmitBatch(ctx, "deepseek-chat", []BatchRequest{{ID: "a", Prompt: "x"}}); err == nil || !strings.Contains(err.Error(), "does not support batch jobs") {
+		t.Errorf("Expected unsupported provider error, got %v", err)
+	}
+	if _, err := service.SubmitBatch(ctx, "claude-3-7-sonnet-latest", nil); err == nil {
+		t.Error("Expected error for empty batch")
+	}
+	if _, err := service.SubmitBatch(ctx, "claude-3-7-sonnet-latest", []BatchRequest{{ID: "a", Prompt: "x"}, {ID: "a", Prompt: "y"}}); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("Expected duplicate ID error, got %v", err)
+	}
+	if _, err := service.SubmitBatch(ctx, "claude-3-7-sonnet-latest", []BatchRequest{{ID: "not valid!", Prompt: "x"}}); err == nil || !strings.Contains(err.Error(), "invalid batch request ID") {
+		t.Errorf("Expected invalid ID error, got %v", err)
+	}
+}
+
+func TestServiceBatchGoogle(t *testing.T) {
+	var submitted map[string]interface{}
+	state := "BATCH_STATE_RUNNING"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "test-google-key" {
+			t.Errorf("Expected API key header, got %q", r.Header.Get("x-goog-api-key"))
+		}
+
+		var response string
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1beta/models/gemini-2.0-flash:batchGenerateContent":
+			if err := json.NewDecoder(r.Body).Decode(&submitted); err != nil {
+				t.Errorf("Failed to decode request body: %v", err)
+			}
+			response = `{"name":"batches/abc","metadata":{"model":"models/gemini-2.0-flash","state":"BATCH_STATE_PENDING","createTime":"2025-04-01T10:00:00Z","batchStats":{"requestCount":"2","pendingRequestCount":"2"}}}`
+		case r.Method == http.MethodGet && r.URL.Path == "/v1beta/batches/abc":
+			if state != "BATCH_STATE_SUCCEEDED" {
+				response = `{"name":"batches/abc","done":` + fmt.Sprint(state != "BATCH_STATE_RUNNING") + `,"metadata":{"model":"models/gemini-2.0-flash","state":"` + state + `","batchStats":{"requestCount":"2","pendingRequestCount":"2"}}}`
+				break
+			}
+			response = `{"name":"batches/abc","done":true,"metadata":{"model":"models/gemini-2.0-flash","state":"BATCH_STATE_SUCCEEDED","endTime":"2025-04-01T10:05:00Z","batchStats":{"requestCount":"2","successfulRequestCount":"1","failedRequestCount":"1"}},
+				"response":{"inlinedResponses":{"inlinedResponses":[
+					{"metadata":{"key":"a"},"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"Paris"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":2},"modelVersion":"gemini-2.0-flash-001","responseId":"resp-a"}},
+					{"metadata":{"key":"b"},"error":{"code":400,"message":"invalid argument","status":"INVALID_ARGUMENT"}}]}}}`
+		case r.Method == http.MethodPost && r.URL.Path == "/v1beta/batches/abc:cancel":
+			state = "BATCH_STATE_CANCELLED"
+			response = `{}`
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"google": "test-google-key"}, server.Client(),
+		map[string]ProviderSettings{"google": {BaseURL: server.URL}})
+	ctx := context.Background()
+
+	job, err := service.SubmitBatch(ctx, "gemini-2.0-flash", []BatchRequest{
+		{ID: "a", Prompt: "Capital of France?", Options: []Option{WithStopSequences("END")}},
+		{ID: "b", Prompt: "Capital of Spain?", Options: []Option{WithCustomParam("system", "Be brief.")}},
+	})
+	if err != nil {
+		t.Fatalf("SubmitBatch returned error: %v", err)
+	}
+	if job.ID != "batches/abc" || job.Status != BatchStatusInProgress || job.Counts.Processing != 2 {
+		t.Errorf("Expected pending job with 2 requests, got %+v", job)
+	}
+
+	// Requests are inlined with their keys and generation settings
+	requests := submitted["batch"].(map[string]interface{})["inputConfig"].(map[string]interface{})["requests"].(map[string]interface{})["requests"].([]interface{})
+	first := requests[0].(map[string]interface{})
+	if first["metadata"].(map[string]interface{})["key"] != "a" {
+		t.Errorf("Expected key of first request, got %v", first["metadata"])
+	}
+	config := first["request"].(map[string]interface{})["generationConfig"].(map[string]interface{})
+	if stops, _ := config["stopSequences"].([]interface{}); len(stops) != 1 || stops[0] != "END" {
+		t.Errorf("Expected stop sequence in generation config, got %v", config)
+	}
+	if system := requests[1].(map[string]interface{})["request"].(map[string]interface{})["systemInstruction"]; system == nil {
+		t.Error("Expected system instruction in second request")
+	}
+
+	// Results are not available while the batch runs
+	if _, err := service.BatchResults(ctx, "google", "batches/abc"); err == nil {
+		t.Error("Expected error for running batch")
+	}
+
+	state = "BATCH_STATE_SUCCEEDED"
+	results, err := service.BatchResults(ctx, "google", "abc")
+	if err != nil {
+		t.Fatalf("BatchResults returned error: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "a" || results[0].Response == nil || results[0].Response.Text != "Paris" {
+		t.Fatalf("Expected answer for a, got %+v", results)
+	}
+	if metadata := results[0].Response.Metadata; metadata.StopReason != StopReasonEndTurn || metadata.RequestID != "resp-a" || metadata.ModelVersion != "gemini-2.0-flash-001" {
+		t.Errorf("Expected metadata of the response, got %+v", metadata)
+	}
+	if results[1].ID != "b" || !strings.Contains(results[1].Error, "invalid argument") {
+		t.Errorf("Expected error for b, got %+v", results[1])
+	}
+
+	state = "BATCH_STATE_RUNNING"
+	job, err = service.CancelBatch(ctx, "google", "batches/abc")
+	if err != nil {
+		t.Fatalf("CancelBatch returned error: %v", err)
+	}
+	if job.Status != BatchStatusCanceled || job.Counts.Canceled != 2 {
+		t.Errorf("Expected cancelled job with 2 unprocessed requests, got %+v", job)
+	}
+}
+
+func TestEstimateBatchCost(t *testing.T) {
+	usage := Usage{InputTokens: 1000000, OutputTokens: 1000000}
+	cost, ok := EstimateBatchCost("claude-3-7-sonnet-latest", usage)
+	if !ok || cost != 9.00 {
+		t.Errorf("Expected $9.00 at half price, got $%f (%v)", cost, ok)
+	}
+}
@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// chunkSeparators are the boundaries text is split on, from the most to the
+// least natural: paragraphs, lines, sentences and words
+var chunkSeparators = []string{"\n\n", "\n", ". ", " "}
+
+// TextChunk is a chunk of a text and the byte offset where it starts
+type TextChunk struct {
+	Text   string
+	Offset int
+}
+
+// SplitText splits text into chunks of at most chunkTokens tokens for a model,
+// preferring natural boundaries. Neighbouring chunks share up to
+// overlapTokens tokens of text so context is not lost at the boundaries.
+func SplitText(model, text string, chunkTokens, overlapTokens int) []string {
+	var chunks []string
+	for _, chunk := range SplitTextChunks(model, text, chunkTokens, overlapTokens) {
+		chunks = append(chunks, chunk.Text)
+	}
+	return chunks
+}
+
+// SplitTextChunks splits text like SplitText, also returning where each chunk
+// starts in the text
+func SplitTextChunks(model, text string, chunkTokens, overlapTokens int) []TextChunk {
+	if text == "" {
+		return nil
+	}
+	if chunkTokens <= 0 || EstimateTokens(model, text) <= chunkTokens {
+		return []TextChunk{{Text: text}}
+	}
+
+	// Merge pieces into chunks, starting each chunk with the end of the previous one
+	var chunks []TextChunk
+	var current []string
+	var currentTokens, offset, start int
+	for _, piece := range splitPieces(model, text, chunkSeparators, chunkTokens) {
+		tokens := EstimateTokens(model, piece)
+
+		if currentTokens+tokens > chunkTokens && len(current) > 0 {
+			chunks = append(chunks, TextChunk{Text: strings.Join(current, ""), Offset: start})
+			current, currentTokens = overlapPieces(model, current, overlapTokens)
+
+			// Drop overlap that would leave no room for the new piece
+			for len(current) > 0 && currentTokens+tokens > chunkTokens {
+				currentTokens -= EstimateTokens(model, current[0])
+				current = current[1:]
+			}
+			start = offset
+			for _, overlap := range current {
+				start -= len(overlap)
+			}
+		}
+
+		current = append(current, piece)
+		currentTokens += tokens
+		offset += len(piece)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, TextChunk{Text: strings.Join(current, ""), Offset: start})
+	}
+
+	return chunks
+}
+
+// splitPieces splits text into pieces of at most maxTokens tokens, using the
+// first separator that makes them small enough. Separators are kept at the end
+// of each piece so joining the pieces restores the text.
+func splitPieces(model, text string, separators []string, maxTokens int) []string {
+	if EstimateTokens(model, text) <= maxTokens {
+		return []string{text}
+	}
+	if len(separators) == 0 {
+		return splitRunes(model, text, maxTokens)
+	}
+
+	var pieces []string
+	for _, part := range strings.SplitAfter(text, separators[0]) {
+		if part == "" {
+			continue
+		}
+		pieces = append(pieces, splitPieces(model, part, separators[1:], maxTokens)...)
+	}
+	return pieces
+}
+
+// splitRunes splits text without natural boundaries into pieces of at most
+// maxTokens tokens, never splitting a rune
+func splitRunes(model, text string, maxTokens int) []string {
+	ratio := modelTokenRatio(model)
+
+	var pieces []string
+	var ascii, wide, start int
+	for i := 0; i < len(text); {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		nextASCII, nextWide := ascii, wide
+		if size == 1 {
+			nextASCII++
+		} else {
+			nextWide++
+		}
+
+		// Start a new piece when this rune would not fit
+		if ratio.tokens(nextASCII, nextWide) > maxTokens && i > start {
+			pieces = append(pieces, text[start:i])
+			start = i
+			nextASCII, nextWide = 0, 0
+			if size == 1 {
+				nextASCII = 1
+			} else {
+				nextWide = 1
+			}
+		}
+
+		ascii, wide = nextASCII, nextWide
+		i += size
+	}
+	return append(pieces, text[start:])
+}
+
+// overlapPieces returns the trailing pieces of a chunk that fit in overlapTokens, and their tokens
+func overlapPieces(model string, pieces []string, overlapTokens int) ([]string, int) {
+	var tokens int
+	start := len(pieces)
+	for start > 0 {
+		pieceTokens := EstimateTokens(model, pieces[start-1])
+		if tokens+pieceTokens > overlapTokens {
+			break
+		}
+		tokens += pieceTokens
+		start--
+	}
+	return append([]string(nil), pieces[start:]...), tokens
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitTextFitsInOneChunk(t *testing.T) {
+	chunks := SplitText("gemini-2.0-flash", "Short text.", 100, 10)
+	if len(chunks) != 1 || chunks[0] != "Short text." {
+		t.Errorf("Expected a single chunk, got %q", chunks)
+	}
+
+	if chunks := SplitText("gemini-2.0-flash", "", 100, 10); len(chunks) != 0 {
+		t.Errorf("Expected no chunks for empty text, got %q", chunks)
+	}
+}
+
+func TestSplitTextOnParagraphs(t *testing.T) {
+	// Ten paragraphs of 10 tokens each with Gemini's four characters per token
+	var paragraphs []string
+	for i := 0; i < 10; i++ {
+		paragraphs = append(paragraphs, strings.Repeat(string(rune('a'+i)), 38))
+	}
+	text := strings.Join(paragraphs, "\n\n")
+
+	chunks := SplitText("gemini-2.0-flash", text, 30, 0)
+	if len(chunks) < 4 {
+		t.Fatalf("Expected at least 4 chunks, got %d", len(chunks))
+	}
+
+	// Chunks fit, end on paragraph boundaries and restore the text without overlap
+	for i, chunk := range chunks {
+		if tokens := EstimateTokens("gemini-2.0-flash", chunk); tokens > 30 {
+			t.Errorf("Expected chunk %d to be at most 30 tokens, got %d", i, tokens)
+		}
+		if i < len(chunks)-1 && !strings.HasSuffix(chunk, "\n\n") {
+			t.Errorf("Expected chunk %d to end on a paragraph boundary, got %q", i, chunk)
+		}
+	}
+	if strings.Join(chunks, "") != text {
+		t.Error("Expected chunks without overlap to restore the text")
+	}
+}
+
+func TestSplitTextOverlap(t *testing.T) {
+	text := strings.Repeat("One sentence here. ", 40)
+
+	chunks := SplitText("gemini-2.0-flash", text, 25, 5)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+
+	// Each chunk starts with the last sentence of the previous one
+	for i := 1; i < len(chunks); i++ {
+		if !strings.HasPrefix(chunks[i], "One sentence here. ") || !strings.HasSuffix(chunks[i-1], "One sentence here. ") {
+			t.Errorf("Expected chunk %d to overlap with the previous chunk, got %q", i, chunks[i])
+		}
+	}
+	if total := len(strings.Join(chunks, "")); total <= len(text) {
+		t.Errorf("Expected overlapping chunks to be longer than the text, got %d <= %d", total, len(text))
+	}
+}
+
+func TestSplitTextChunksOffsets(t *testing.T) {
+	// Repeated text must not confuse where each chunk starts
+	text := strings.Repeat("Same words again and again.\n", 60)
+
+	chunks := SplitTextChunks("gemini-2.0-flash", text, 40, 10)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.Offset+len(chunk.Text) > len(text) || text[chunk.Offset:chunk.Offset+len(chunk.Text)] != chunk.Text {
+			t.Errorf("Expected chunk %d to be found at offset %d", i, chunk.Offset)
+		}
+		if i > 0 && chunk.Offset <= chunks[i-1].Offset {
+			t.Errorf("Expected chunk %d to start after chunk %d, got %d <= %d", i, i-1, chunk.Offset, chunks[i-1].Offset)
+		}
+	}
+	if last := chunks[len(chunks)-1]; last.Offset+len(last.Text) != len(text) {
+		t.Errorf("Expected last chunk to end the text, got %d", last.Offset+len(last.Text))
+	}
+}
+
+func TestSplitTextWithoutBoundaries(t *testing.T) {
+	// A long run of CJK runes without spaces must be split between runes
+	text := strings.Repeat("你好世界", 50)
+
+	chunks := SplitText("gemini-2.0-flash", text, 30, 0)
+	if len(chunks) != 7 {
+		t.Errorf("Expected 7 chunks of at most 30 runes, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("Expected chunk %d to be valid UTF-8", i)
+		}
+	}
+	if strings.Join(chunks, "") != text {
+		t.Error("Expected chunks to restore the text")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // DeepseekProvider implements the Provider interface for Deepseek API
@@ -15,22 +17,37 @@ type DeepseekProvider struct {
 	apiKey     string
 	httpClient *http.Client
 	baseURL    string
+	prefixURL  string            // Endpoint for prefilled requests, which use the beta API
+	headers    map[string]string // Extra headers sent with every request
 }
 
-// deepseekMessage represents a message in the Deepseek API
+// deepseekMessage represents a message in the Deepseek API. ReasoningContent
+// is only returned by deepseek-reasoner and must not be sent back. Prefix marks
+// a final assistant message the model continues.
 type deepseekMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role             string `json:"role"`
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Prefix           bool   `json:"prefix,omitempty"`
 }
 
 // deepseekRequest represents a request to the Deepseek API
 type deepseekRequest struct {
-	Model       string            `json:"model"`
-	Messages    []deepseekMessage `json:"messages"`
-	MaxTokens   int               `json:"max_tokens,omitempty"`
-	Temperature float64           `json:"temperature,omitempty"`
-	TopP        float64           `json:"top_p,omitempty"`
-	Stream      bool              `json:"stream,omitempty"`
+	Model            string                  `json:"model"`
+	Messages         []deepseekMessage       `json:"messages"`
+	MaxTokens        int                     `json:"max_tokens,omitempty"`
+	Temperature      *float64                `json:"temperature,omitempty"`
+	TopP             float64                 `json:"top_p,omitempty"`
+	PresencePenalty  *float64                `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64                `json:"frequency_penalty,omitempty"`
+	ResponseFormat   *deepseekResponseFormat `json:"response_format,omitempty"`
+	Stop             []string                `json:"stop,omitempty"`
+	Stream           bool                    `json:"stream,omitempty"`
+}
+
+// deepseekResponseFormat selects plain text or JSON output
+type deepseekResponseFormat struct {
+	Type string `json:"type"`
 }
 
 // deepseekChoice represents a choice in the Deepseek API response
@@ -40,6 +57,15 @@ type deepseekChoice struct {
 	FinishReason string          `json:"finish_reason"`
 }
 
+// deepseekUsage represents token usage in the Deepseek API response
+type deepseekUsage struct {
+	PromptTokens            int `json:"prompt_tokens"`
+	CompletionTokens        int `json:"completion_tokens"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
 // deepseekResponse represents a response from the Deepseek API
 type deepseekResponse struct {
 	ID      string           `json:"id"`
@@ -47,6 +73,7 @@ type deepseekResponse struct {
 	Created int64            `json:"created"`
 	Model   string           `json:"model"`
 	Choices []deepseekChoice `json:"choices"`
+	Usage   deepseekUsage    `json:"usage"`
 	Error   *struct {
 		Message string `json:"message"`
 		Type    string `json:"type"`
@@ -55,20 +82,43 @@ type deepseekResponse struct {
 }
 
 // NewDeepseekProvider creates a new Deepseek provider
-func NewDeepseekProvider(apiKey string, httpClient *http.Client) *DeepseekProvider {
+func NewDeepseekProvider(apiKey string, httpClient *http.Client, options ...ProviderOption) *DeepseekProvider {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
 	}
 
+	settings := applyProviderOptions(options)
+
+	// Prefix completion is only served by the beta API, which custom
+	// endpoints are expected to serve next to /v1
+	baseURL := "https://api.deepseek.com/v1/chat/completions"
+	prefixURL := "https://api.deepseek.com/beta/chat/completions"
+	if settings.BaseURL != "" {
+		root := strings.TrimSuffix(strings.TrimSuffix(settings.BaseURL, "/"), "/v1")
+		baseURL = settings.BaseURL + "/chat/completions"
+		prefixURL = root + "/beta/chat/completions"
+	}
+
 	return &DeepseekProvider{
 		apiKey:     apiKey,
 		httpClient: httpClient,
-		baseURL:    "https://api.deepseek.com/v1/chat/completions",
+		baseURL:    baseURL,
+		prefixURL:  prefixURL,
+		headers:    settings.Headers,
 	}
 }
 
 // Query implements the Provider interface
 func (p *DeepseekProvider) Query(ctx context.Context, prompt string, options ...Option) (string, error) {
+	response, err := p.QueryDetailed(ctx, prompt, options...)
+	if err != nil {
+		return "", err
+	}
+	return response.Text, nil
+}
+
+// QueryDetailed implements the DetailedProvider interface
+func (p *DeepseekProvider) QueryDetailed(ctx context.Context, prompt string, options ...Option) (*Response, error) {
 	// Apply options
 	opts := &RequestOptions{
 		MaxTokens:   1000,
@@ -81,53 +131,112 @@ func (p *DeepseekProvider) Query(ctx context.Context, prompt string, options ...
 
 	// Model is required
 	if opts.Model == "" {
-		return "", errors.New("model is required for Deepseek provider")
+		return nil, errors.New("model is required for Deepseek provider")
 	}
 
 	// Create request payload
 	req := deepseekRequest{
-		Model:       opts.Model,
-		MaxTokens:   opts.MaxTokens,
-		Messages:    []deepseekMessage{{Role: "user", Content: prompt}},
-		Temperature: opts.Temperature,
-		Stream:      false,
+		Model:     opts.Model,
+		MaxTokens: opts.MaxTokens,
+		Stream:    false,
 	}
 
 	// Add system prompt if specified
 	if system, ok := opts.CustomParams["system"].(string); ok && system != "" {
-		req.Messages = append([]deepseekMessage{{Role: "system", Content: system}}, req.Messages...)
+		req.Messages = append(req.Messages, deepseekMessage{Role: "system", Content: system})
+	}
+
+	// Add earlier turns without their reasoning, which the API rejects as input
+	for _, message := range opts.History {
+		req.Messages = append(req.Messages, deepseekMessage{Role: message.Role, Content: message.Content})
 	}
+	req.Messages = append(req.Messages, deepseekMessage{Role: "user", Content: prompt})
 
-	// Add top_p if specified
-	if topP, ok := opts.CustomParams["top_p"].(float64); ok {
-		req.TopP = topP
+	// Prefill the answer using prefix completion
+	url := p.baseURL
+	prefill := sentPrefill(opts)
+	if prefill != "" {
+		req.Messages = append(req.Messages, deepseekMessage{Role: "assistant", Content: prefill, Prefix: true})
+		url = p.prefixURL
+	}
+
+	// Add stop sequences if specified
+	req.Stop = opts.StopSequences
+
+	// Only one response is generated, as text or as a JSON object
+	if err := checkSingleCandidate(opts); err != nil {
+		return nil, err
+	}
+	switch opts.ResponseMIMEType {
+	case "", "text/plain":
+	case "application/json":
+		req.ResponseFormat = &deepseekResponseFormat{Type: "json_object"}
+	default:
+		return nil, invalidOption("%s does not support the response MIME type %q, use application/json or text/plain", opts.Model, opts.ResponseMIMEType)
+	}
+
+	topP, hasTopP := opts.CustomParams["top_p"].(float64)
+
+	if reasoningModels[opts.Model] {
+		// Reasoning models ignore or reject sampling parameters, so fail
+		// loudly instead of silently dropping a setting the caller chose
+		if opts.temperatureSet {
+			return nil, invalidOption("%s does not support temperature, remove the temperature setting for this model", opts.Model)
+		}
+		if hasTopP {
+			return nil, invalidOption("%s does not support top_p, remove the top_p setting for this model", opts.Model)
+		}
+		if opts.PresencePenalty != nil || opts.FrequencyPenalty != nil {
+			return nil, invalidOption("%s does not support presence or frequency penalties, remove them for this model", opts.Model)
+		}
+	} else {
+		if err := checkTemperature(opts, 2); err != nil {
+			return nil, err
+		}
+		req.Temperature = &opts.Temperature
+		if hasTopP {
+			req.TopP = topP
+		}
+		for _, penalty := range []*float64{opts.PresencePenalty, opts.FrequencyPenalty} {
+			if penalty == nil {
+				continue
+			}
+			if err := ValidatePenalty(*penalty); err != nil {
+				return nil, err
+			}
+		}
+		req.PresencePenalty = opts.PresencePenalty
+		req.FrequencyPenalty = opts.FrequencyPenalty
 	}
 
 	// Convert to JSON
 	jsonData, err := json.Marshal(req)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %w", err)
+		return nil, fmt.Errorf("error marshaling request: %w", err)
 	}
 
 	// Create HTTP request
 	httpReq, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodPost,
-		p.baseURL,
+		url,
 		bytes.NewBuffer(jsonData),
 	)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
 	// Set headers
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	for name, value := range p.headers {
+		httpReq.Header.Set(name, value)
+	}
 
 	// Send request
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("error sending request: %w", err)
+		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -139,29 +248,68 @@ func (p *DeepseekProvider) Query(ctx context.Context, prompt string, options ...
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response: %w", err)
+		return nil, fmt.Errorf("error reading response: %w", err)
 	}
 
 	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
 		var errResp deepseekResponse
 		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != nil {
-			return "", fmt.Errorf("API error (%s): %s", errResp.Error.Type, errResp.Error.Message)
+			return nil, fmt.Errorf("API error (%s): %s", errResp.Error.Type, errResp.Error.Message)
 		}
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	// Parse response
 	var result deepseekResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("error parsing response: %w", err)
+		return nil, fmt.Errorf("error parsing response: %w", err)
 	}
 
 	// Check for empty choices
 	if len(result.Choices) == 0 {
-		return "", errors.New("empty response from Deepseek API")
+		return nil, errors.New("empty response from Deepseek API")
+	}
+
+	// Keep the reasoning separate from the answer in the first choice
+	message := result.Choices[0].Message
+	response := &Response{
+		Warnings: append(unknownParamWarnings("deepseek", opts.CustomParams, "system", "top_p"), ignoredOptionWarnings("deepseek", opts, true)...),
+		Text:     prefill + message.Content,
+		Thinking: message.ReasoningContent,
+		Usage: Usage{
+			InputTokens:    result.Usage.PromptTokens,
+			OutputTokens:   result.Usage.CompletionTokens - result.Usage.CompletionTokensDetails.ReasoningTokens,
+			ThinkingTokens: result.Usage.CompletionTokensDetails.ReasoningTokens,
+		},
+		FinishReason: result.Choices[0].FinishReason,
+		Metadata: Metadata{
+			StopReason:   normalizeOpenAIFinishReason(result.Choices[0].FinishReason),
+			RequestID:    result.ID,
+			ModelVersion: result.Model,
+			Created:      responseTime(resp.Header),
+		},
 	}
+	if result.Created > 0 {
+		response.Metadata.Created = time.Unix(result.Created, 0)
+	}
+
+	return response, nil
+}
 
-	// Return the content from the first choice
-	return result.Choices[0].Message.Content, nil
+// normalizeOpenAIFinishReason maps an OpenAI-style finish_reason to a StopReason constant
+func normalizeOpenAIFinishReason(reason string) string {
+	switch reason {
+	case "stop":
+		return StopReasonEndTurn
+	case "length":
+		return StopReasonMaxTokens
+	case "content_filter":
+		return StopReasonContentBlock
+	case "tool_calls", "function_call":
+		return StopReasonToolUse
+	case "":
+		return ""
+	}
+	return StopReasonOther
 }
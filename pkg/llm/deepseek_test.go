@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // TestNewDeepseekProvider ensures the provider is initialized correctly
@@ -374,3 +375,159 @@ func TestDeepseekProviderContext(t *testing.T) {
 		t.Fatalf("Query with context returned error: %v", err)
 	}
 }
+
+// TestDeepseekReasonerQuery tests that reasoning content is returned separately and never sent back
+func TestDeepseekReasonerQuery(t *testing.T) {
+	var lastRequest map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&lastRequest); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": "9.11 is smaller.", "reasoning_content": "Compare 9.11 and 9.8 digit by digit."}}],
+			"usage": {"prompt_tokens": 20, "completion_tokens": 50, "completion_tokens_details": {"reasoning_tokens": 42}}
+		}`))
+		if err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewDeepseekProvider("test-key", server.Client(), WithBaseURL(server.URL))
+
+	response, err := provider.QueryDetailed(context.Background(), "And is 9.8 bigger?",
+		WithModel("deepseek-reasoner"),
+		WithHistory([]Message{
+			{Role: "user", Content: "Which is smaller, 9.11 or 9.8?"},
+			{Role: "assistant", Content: "9.11 is smaller.", Reasoning: "Earlier chain of thought."},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	if response.Text != "9.11 is smaller." {
+		t.Errorf("Expected answer '9.11 is smaller.', got %q", response.Text)
+	}
+	if response.Thinking != "Compare 9.11 and 9.8 digit by digit." {
+		t.Errorf("Expected reasoning content, got %q", response.Thinking)
+	}
+	if response.Usage.ThinkingTokens != 42 || response.Usage.OutputTokens != 8 {
+		t.Errorf("Expected 42 reasoning and 8 answer tokens, got %+v", response.Usage)
+	}
+
+	// Earlier reasoning must be stripped and sampling parameters omitted
+	messages, ok := lastRequest["messages"].([]interface{})
+	if !ok || len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %v", lastRequest["messages"])
+	}
+	for _, message := range messages {
+		if _, ok := message.(map[string]interface{})["reasoning_content"]; ok {
+			t.Errorf("Expected reasoning_content to be stripped, got %v", message)
+		}
+	}
+	if _, ok := lastRequest["temperature"]; ok {
+		t.Errorf("Expected no temperature for deepseek-reasoner, got %v", lastRequest["temperature"])
+	}
+}
+
+// TestDeepseekReasonerRejectsSampling tests that temperature and top_p are rejected for deepseek-reasoner
+func TestDeepseekReasonerRejectsSampling(t *testing.T) {
+	provider := NewDeepseekProvider("test-key", nil)
+
+	_, err := provider.Query(context.Background(), "Test prompt", WithModel("deepseek-reasoner"), WithTemperature(0.5))
+	if err == nil || err.Error() != "deepseek-reasoner does not support temperature, remove the temperature setting for this model" {
+		t.Errorf("Expected temperature error, got %v", err)
+	}
+
+	_, err = provider.Query(context.Background(), "Test prompt", WithModel("deepseek-reasoner"), WithCustomParam("top_p", 0.9))
+	if err == nil || err.Error() != "deepseek-reasoner does not support top_p, remove the top_p setting for this model" {
+		t.Errorf("Expected top_p error, got %v", err)
+	}
+}
+
+// TestDeepseekProviderMetadata tests that the finish reason is normalized and response details are returned
+func TestDeepseekProviderMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{
+			"id": "chatcmpl-123",
+			"created": 1740000000,
+			"model": "deepseek-chat",
+			"choices": [{"message": {"role": "assistant", "content": "Done."}, "finish_reason": "stop"}]
+		}`))
+		if err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewDeepseekProvider("test-key", server.Client(), WithBaseURL(server.URL))
+
+	response, err := provider.QueryDetailed(context.Background(), "Test prompt", WithModel("deepseek-chat"))
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	metadata := response.Metadata
+	if metadata.StopReason != StopReasonEndTurn || metadata.Truncated() {
+		t.Errorf("Expected stop reason end_turn, got %q", metadata.StopReason)
+	}
+	if metadata.RequestID != "chatcmpl-123" {
+		t.Errorf("Expected request ID chatcmpl-123, got %q", metadata.RequestID)
+	}
+	if metadata.ModelVersion != "deepseek-chat" {
+		t.Errorf("Expected model version deepseek-chat, got %q", metadata.ModelVersion)
+	}
+	if !metadata.Created.Equal(time.Unix(1740000000, 0)) {
+		t.Errorf("Expected created time from the response, got %v", metadata.Created)
+	}
+}
+
+// TestDeepseekProviderPrefillAndStopSequences tests prefix completion and stop sequences
+func TestDeepseekProviderPrefillAndStopSequences(t *testing.T) {
+	var lastRequest deepseekRequest
+	var lastPath string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&lastRequest); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "\n\treturn nil\n}"}, "finish_reason": "stop"}]}`))
+		if err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewDeepseekProvider("test-key", server.Client(), WithBaseURL(server.URL+"/v1"))
+
+	response, err := provider.QueryDetailed(context.Background(), "Write a Go function that does nothing",
+		WithModel("deepseek-chat"),
+		WithPrefill("func noop() error {\n"),
+		WithStopSequences("```"),
+	)
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	if lastPath != "/beta/chat/completions" {
+		t.Errorf("Expected request to the beta endpoint next to the configured one, got %s", lastPath)
+	}
+	messages := lastRequest.Messages
+	if len(messages) != 2 || messages[1].Role != "assistant" || !messages[1].Prefix || messages[1].Content != "func noop() error {" {
+		t.Errorf("Expected prefix assistant message without trailing whitespace, got %+v", messages)
+	}
+	if len(lastRequest.Stop) != 1 || lastRequest.Stop[0] != "```" {
+		t.Errorf("Expected stop sequences in request, got %v", lastRequest.Stop)
+	}
+	if response.Text != "func noop() error {\n\treturn nil\n}" {
+		t.Errorf("Expected response to include the prefill, got %q", response.Text)
+	}
+}
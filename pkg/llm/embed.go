@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Embedder is implemented by providers that turn text into vectors
+type Embedder interface {
+	// Embed returns a vector for each text, in order. Callers keep batches
+	// within the MaxBatch of the model.
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
+
+// Embed returns a vector for each text using an embedding model, sending the
+// texts in batches the provider accepts
+func (s *Service) Embed(ctx context.Context, modelName string, texts []string) ([][]float32, error) {
+	// Validate model
+	model, providerName, ok := GetEmbeddingModel(modelName)
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding model: %s", modelName)
+	}
+
+	// Get embedder
+	embedder, ok := s.embedders[providerName]
+	if !ok {
+		return nil, fmt.Errorf("provider %s not configured", providerName)
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += model.MaxBatch {
+		end := min(start+model.MaxBatch, len(texts))
+
+		batch, err := embedder.Embed(ctx, modelName, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("error embedding texts %d to %d: %w", start+1, end, err)
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("expected %d vectors from %s, got %d", end-start, providerName, len(batch))
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	return vectors, nil
+}
+
+// DefaultEmbeddingModel returns the default embedding model of the first
+// configured provider that has one, preferring hosted providers over Ollama,
+// which is only chosen when its server answers
+func (s *Service) DefaultEmbeddingModel(ctx context.Context) (string, error) {
+	for _, provider := range []string{"google", "openai", "ollama"} {
+		embedder, ok := s.embedders[provider]
+		if !ok {
+			continue
+		}
+		if ollama, isOllama := embedder.(*OllamaEmbedder); isOllama && !ollama.Available(ctx) {
+			continue
+		}
+		if info, _ := lookupProvider(provider); len(info.EmbeddingModels) > 0 {
+			return info.EmbeddingModels[0].Name, nil
+		}
+	}
+	return "", fmt.Errorf("no embedding provider configured, set a Google or OpenAI API key or start an Ollama server")
+}
+
+// postEmbedRequest sends a JSON request to an embeddings endpoint and
+// returns the response body, or the error message the API reported
+func postEmbedRequest(ctx context.Context, httpClient *http.Client, url string, headers map[string]string, request interface{}, apiError func(body []byte) string) ([]byte, error) {
+	// Convert to JSON
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	// Set headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	// Send request
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Just log the error, can't return it here
+			fmt.Printf("Error closing response body: %v\n", err)
+		}
+	}()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	// Check for HTTP errors
+	if resp.StatusCode != http.StatusOK {
+		if message := apiError(body); message != "" {
+			return nil, fmt.Errorf("API error: %s", message)
+		}
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
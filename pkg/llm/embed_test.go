@@ -0,0 +1,212 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetEmbeddingModel(t *testing.T) {
+	model, provider, ok := GetEmbeddingModel("text-embedding-3-small")
+	if !ok || provider != "openai" || model.Dimensions != 1536 {
+		t.Errorf("Expected OpenAI model with 1536 dimensions, got %+v from %s", model, provider)
+	}
+
+	if _, _, ok := GetEmbeddingModel("claude-3-7-sonnet-latest"); ok {
+		t.Error("Expected generation model not to be an embedding model")
+	}
+}
+
+func TestServiceEmbedOpenAI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer test-openai-key" {
+			t.Errorf("Unexpected request %s with authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+
+		var req openAIEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			return
+		}
+		if req.Model != "text-embedding-3-small" || req.EncodingFormat != "float" {
+			t.Errorf("Expected float embeddings from text-embedding-3-small, got %+v", req)
+		}
+
+		// Answer out of order, vectors are matched by index
+		var data []string
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, fmt.Sprintf(`{"index": %d, "embedding": [%d, 0.5]}`, i, len(req.Input[i])))
+		}
+		if _, err := fmt.Fprintf(w, `{"data": [%s]}`, strings.Join(data, ",")); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"openai": "test-openai-key"}, nil, map[string]ProviderSettings{
+		"openai": {BaseURL: server.URL + "/v1"},
+	})
+
+	vectors, err := service.Embed(context.Background(), "text-embedding-3-small", []string{"a", "bbb"})
+	if err != nil {
+		t.Fatalf("Embed returned error: %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][0] != 3 || vectors[1][1] != 0.5 {
+		t.Errorf("Expected a vector per text in order, got %v", vectors)
+	}
+
+	if model, err := service.DefaultEmbeddingModel(context.Background()); err != nil || model != "text-embedding-3-small" {
+		t.Errorf("Expected OpenAI default model, got %q (%v)", model, err)
+	}
+}
+
+func TestServiceEmbedOllamaBatches(t *testing.T) {
+	var batches []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("Expected /api/embed, got %s", r.URL.Path)
+		}
+
+		var req ollamaEmbedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			return
+		}
+		if req.Model == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			if _, err := fmt.Fprint(w, `{"error": "model not found"}`); err != nil {
+				t.Errorf("Failed to write response: %v", err)
+			}
+			return
+		}
+		batches = append(batches, len(req.Input))
+
+		vectors := make([][]float32, len(req.Input))
+		for i := range vectors {
+			vectors[i] = []float32{float32(i)}
+		}
+		if err := json.NewEncoder(w).Encode(ollamaEmbedResponse{Embeddings: vectors}); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	// Ollama needs no key
+	service := NewServiceWithSettings(nil, nil, map[string]ProviderSettings{"ollama": {BaseURL: server.URL}})
+
+	texts := make([]string, 1030)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text %d", i)
+	}
+	vectors, err := service.Embed(context.Background(), "nomic-embed-text", texts)
+	if err != nil {
+		t.Fatalf("Embed returned error: %v", err)
+	}
+	if len(vectors) != 1030 {
+		t.Errorf("Expected 1030 vectors, got %d", len(vectors))
+	}
+	if len(batches) != 3 || batches[0] != 512 || batches[2] != 6 {
+		t.Errorf("Expected batches of 512, 512 and 6 texts, got %v", batches)
+	}
+
+	// API errors are reported
+	embedder := NewOllamaEmbedder(nil, WithBaseURL(server.URL))
+	if _, err := embedder.Embed(context.Background(), "missing", []string{"x"}); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Expected model not found error, got %v", err)
+	}
+
+	// Unknown models and providers without a key are rejected
+	if _, err := service.Embed(context.Background(), "unknown", texts); err == nil {
+		t.Error("Expected error for unknown embedding model")
+	}
+	if _, err := service.Embed(context.Background(), "text-embedding-004", texts); err == nil {
+		t.Error("Expected error for unconfigured provider")
+	}
+}
+
+func TestDefaultEmbeddingModelOllama(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/version" {
+			t.Errorf("Expected /api/version, got %s", r.URL.Path)
+		}
+		if _, err := fmt.Fprint(w, `{"version": "0.6.0"}`); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+
+	// A running Ollama server is the default without hosted providers
+	service := NewServiceWithSettings(nil, nil, map[string]ProviderSettings{"ollama": {BaseURL: server.URL}})
+	if model, err := service.DefaultEmbeddingModel(context.Background()); err != nil || model != "nomic-embed-text" {
+		t.Errorf("Expected Ollama default model, got %q (%v)", model, err)
+	}
+
+	// Without a server there is no default to fall back to
+	server.Close()
+	if model, err := service.DefaultEmbeddingModel(context.Background()); err == nil {
+		t.Errorf("Expected an error without a reachable Ollama server, got %q", model)
+	}
+}
+
+func TestEmbeddingProviders(t *testing.T) {
+	// Embedding-only providers are not chat providers
+	for _, provider := range []string{"ollama", "openai"} {
+		if IsValidProvider(provider) {
+			t.Errorf("Expected %s not to be a chat provider", provider)
+		}
+	}
+
+	tests := map[string]bool{
+		"anthropic": true,
+		"google":    true,
+		"openai":    true,
+		"ollama":    false,
+		"unknown":   false,
+	}
+	for provider, expected := range tests {
+		if UsesAPIKey(provider) != expected {
+			t.Errorf("Expected UsesAPIKey(%s) to be %v", provider, expected)
+		}
+	}
+}
+
+func TestGoogleEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response string
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/models/text-embedding-004:embedContent"):
+			response = `{"embedding": {"values": [0.1, 0.2]}}`
+		case strings.HasSuffix(r.URL.Path, "/models/text-embedding-004:batchEmbedContents"):
+			response = `{"embeddings": [{"values": [1]}, {"values": [2]}]}`
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewGoogleProvider("test-google-key", server.Client(), WithBaseURL(server.URL))
+
+	vectors, err := provider.Embed(context.Background(), "text-embedding-004", []string{"one"})
+	if err != nil {
+		t.Fatalf("Embed returned error: %v", err)
+	}
+	if len(vectors) != 1 || len(vectors[0]) != 2 {
+		t.Errorf("Expected one vector of 2 values, got %v", vectors)
+	}
+
+	vectors, err = provider.Embed(context.Background(), "text-embedding-004", []string{"one", "two"})
+	if err != nil {
+		t.Fatalf("Embed returned error: %v", err)
+	}
+	if len(vectors) != 2 || vectors[1][0] != 2 {
+		t.Errorf("Expected two vectors in order, got %v", vectors)
+	}
+}
@@ -1,43 +1,98 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
+	pb "cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
 // GoogleProvider implements the Provider interface for Google Vertex AI API
 type GoogleProvider struct {
-	apiKey string
-	client *genai.Client
+	apiKey     string
+	client     *genai.Client
+	httpClient *http.Client // Client for REST endpoints the SDK lacks, sending the key and headers
+	baseURL    string       // API root for REST endpoints the SDK lacks
 }
 
-// NewGoogleProvider creates a new Google Vertex AI provider
-func NewGoogleProvider(apiKey string, _ *http.Client) *GoogleProvider {
+// NewGoogleProvider creates a new Google Vertex AI provider. When an HTTP client
+// is given, requests go through it so proxy, TLS and timeout settings apply.
+func NewGoogleProvider(apiKey string, httpClient *http.Client, options ...ProviderOption) *GoogleProvider {
+	settings := applyProviderOptions(options)
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	// option.WithAPIKey is ignored when a custom HTTP client is supplied,
+	// so the key is sent as a header by the transport instead
+	headers := map[string]string{"x-goog-api-key": apiKey}
+	for name, value := range settings.Headers {
+		headers[name] = value
+	}
+
+	// Requests always go through our transports so custom headers, generation
+	// settings and response fields unknown to the SDK can be handled
+	transportClient := *httpClient
+	transportClient.Transport = &servedModelTransport{
+		base: &generationConfigTransport{
+			base: &headerTransport{base: httpClient.Transport, headers: headers},
+		},
+	}
+	clientOptions := []option.ClientOption{option.WithHTTPClient(&transportClient)}
+
+	// The cache client is created without the custom HTTP client, so it
+	// always needs the key option
+	clientOptions = append(clientOptions, option.WithAPIKey(apiKey))
+
+	baseURL := "https://generativelanguage.googleapis.com"
+	if settings.BaseURL != "" {
+		baseURL = settings.BaseURL
+		clientOptions = append(clientOptions, option.WithEndpoint(settings.BaseURL))
+	}
+
 	// Create a new genai client
-	client, err := genai.NewClient(context.Background(), option.WithAPIKey(apiKey))
+	client, err := genai.NewClient(context.Background(), clientOptions...)
 	if err != nil {
 		// Return empty provider that will fail on first use
 		return &GoogleProvider{
-			apiKey: apiKey,
+			apiKey:     apiKey,
+			httpClient: &transportClient,
+			baseURL:    baseURL,
 		}
 	}
 
 	return &GoogleProvider{
-		apiKey: apiKey,
-		client: client,
+		apiKey:     apiKey,
+		client:     client,
+		httpClient: &transportClient,
+		baseURL:    baseURL,
 	}
 }
 
 // Query implements the Provider interface
 func (p *GoogleProvider) Query(ctx context.Context, prompt string, options ...Option) (string, error) {
+	response, err := p.QueryDetailed(ctx, prompt, options...)
+	if err != nil {
+		return "", err
+	}
+	return response.Text, nil
+}
+
+// QueryDetailed implements the DetailedProvider interface
+func (p *GoogleProvider) QueryDetailed(ctx context.Context, prompt string, options ...Option) (*Response, error) {
 	// Check if client is initialized
 	if p.client == nil {
-		return "", errors.New("google client not initialized")
+		return nil, errors.New("google client not initialized")
 	}
 
 	// Apply options
@@ -52,7 +107,15 @@ func (p *GoogleProvider) Query(ctx context.Context, prompt string, options ...Op
 
 	// Model is required
 	if opts.Model == "" {
-		return "", errors.New("model is required for Google provider")
+		return nil, errors.New("model is required for Google provider")
+	}
+
+	// Gemini has no way to continue a partial answer
+	if opts.Prefill != "" {
+		return nil, invalidOption("%s does not support prefilling the response", opts.Model)
+	}
+	if err := checkTemperature(opts, 2); err != nil {
+		return nil, err
 	}
 
 	// Create a new model
@@ -67,11 +130,27 @@ func (p *GoogleProvider) Query(ctx context.Context, prompt string, options ...Op
 		model.SetTopP(float32(topP))
 	}
 
+	// Request several candidates if specified
+	if opts.CandidateCount > 0 {
+		model.SetCandidateCount(int32(opts.CandidateCount))
+	}
+
 	// Add top_k if specified
 	if topK, ok := opts.CustomParams["top_k"].(int); ok {
 		model.SetTopK(int32(topK))
 	}
 
+	// Apply safety settings, stop sequences and other generation settings
+	extra, err := applyGeminiOptions(model, opts.Model, opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withGenerationConfig(ctx, extra)
+
+	// Capture the response ID and served model version, which the SDK drops
+	served := &servedModel{}
+	ctx = context.WithValue(ctx, servedModelKey{}, served)
+
 	// Set system instructions if specified
 	if system, ok := opts.CustomParams["system"].(string); ok && system != "" {
 		model.SystemInstruction = &genai.Content{
@@ -83,24 +162,230 @@ func (p *GoogleProvider) Query(ctx context.Context, prompt string, options ...Op
 	// Create prompt part (single part)
 	promptPart := genai.Text(prompt)
 
-	// Generate content
-	resp, err := model.GenerateContent(ctx, promptPart)
+	// Generate content, continuing a chat when there are earlier turns
+	var resp *genai.GenerateContentResponse
+	if len(opts.History) > 0 {
+		session := model.StartChat()
+		for _, message := range opts.History {
+			// Gemini names the assistant role "model"
+			role := message.Role
+			if role == "assistant" {
+				role = "model"
+			}
+			session.History = append(session.History, &genai.Content{
+				Parts: []genai.Part{genai.Text(message.Content)},
+				Role:  role,
+			})
+		}
+		resp, err = session.SendMessage(ctx, promptPart)
+	} else {
+		resp, err = model.GenerateContent(ctx, promptPart)
+	}
 	if err != nil {
-		return "", fmt.Errorf("error generating content: %w", err)
+		return nil, googleError(err)
+	}
+
+	// A blocked prompt produces no candidates
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != genai.BlockReasonUnspecified {
+		return nil, &PromptBlockedError{
+			Reason:        pb.GenerateContentResponse_PromptFeedback_BlockReason(resp.PromptFeedback.BlockReason).String(),
+			SafetyRatings: googleSafetyRatings(resp.PromptFeedback.SafetyRatings),
+		}
 	}
 
 	// Process response
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return "", errors.New("empty response from Google API")
+	if len(resp.Candidates) == 0 {
+		return nil, errors.New("empty response from Google API")
+	}
+
+	response := &Response{
+		Warnings: unknownParamWarnings("google", opts.CustomParams, "system", "top_p", "top_k"),
+	}
+	for _, candidate := range resp.Candidates {
+		response.Candidates = append(response.Candidates, Candidate{
+			Text:          googleCandidateText(candidate),
+			FinishReason:  pb.Candidate_FinishReason(candidate.FinishReason).String(),
+			SafetyRatings: googleSafetyRatings(candidate.SafetyRatings),
+		})
+	}
+
+	// The first candidate is the answer
+	first := response.Candidates[0]
+	if first.Text == "" {
+		return nil, fmt.Errorf("no text in response from Google API (finish reason %s)", first.FinishReason)
+	}
+	response.Text = first.Text
+	response.FinishReason = first.FinishReason
+	response.SafetyRatings = first.SafetyRatings
+
+	// Only keep the candidate list when alternatives were requested
+	if len(response.Candidates) == 1 {
+		response.Candidates = nil
+	}
+
+	response.Metadata = Metadata{
+		StopReason:   normalizeGoogleFinishReason(resp.Candidates[0].FinishReason),
+		RequestID:    served.ResponseID,
+		ModelVersion: served.ModelVersion,
+		Created:      served.Created,
+	}
+	if response.Metadata.ModelVersion == "" {
+		response.Metadata.ModelVersion = opts.Model
+	}
+	if response.Metadata.Created.IsZero() {
+		response.Metadata.Created = time.Now()
+	}
+
+	if usage := resp.UsageMetadata; usage != nil {
+		response.Usage = Usage{
+			InputTokens:  int(usage.PromptTokenCount),
+			OutputTokens: int(usage.CandidatesTokenCount),
+		}
+	}
+
+	return response, nil
+}
+
+// CountTokens implements the TokenCounter interface using the countTokens endpoint
+func (p *GoogleProvider) CountTokens(ctx context.Context, prompt string, options ...Option) (int, error) {
+	// Check if client is initialized
+	if p.client == nil {
+		return 0, errors.New("google client not initialized")
+	}
+
+	// Apply options
+	opts := &RequestOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	// Model is required
+	if opts.Model == "" {
+		return 0, errors.New("model is required for Google provider")
+	}
+
+	model := p.client.GenerativeModel(opts.Model)
+	if system, ok := opts.CustomParams["system"].(string); ok && system != "" {
+		model.SystemInstruction = &genai.Content{
+			Parts: []genai.Part{genai.Text(system)},
+			Role:  "system",
+		}
+	}
+
+	// The endpoint counts a single turn, so earlier turns are sent as parts of it
+	var parts []genai.Part
+	for _, message := range opts.History {
+		parts = append(parts, genai.Text(message.Content))
+	}
+	parts = append(parts, genai.Text(prompt))
+
+	resp, err := model.CountTokens(ctx, parts...)
+	if err != nil {
+		return 0, fmt.Errorf("error counting tokens: %w", err)
+	}
+	return int(resp.TotalTokens), nil
+}
+
+// Embed implements the Embedder interface using the embedContent endpoint for
+// a single text and batchEmbedContents for several
+func (p *GoogleProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	// Check if client is initialized
+	if p.client == nil {
+		return nil, errors.New("google client not initialized")
+	}
+
+	embeddingModel := p.client.EmbeddingModel(model)
+
+	if len(texts) == 1 {
+		resp, err := embeddingModel.EmbedContent(ctx, genai.Text(texts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("error embedding content: %w", err)
+		}
+		if resp.Embedding == nil {
+			return nil, errors.New("no embedding in response from Google API")
+		}
+		return [][]float32{resp.Embedding.Values}, nil
+	}
+
+	batch := embeddingModel.NewBatch()
+	for _, text := range texts {
+		batch.AddContent(genai.Text(text))
+	}
+	resp, err := embeddingModel.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("error embedding content: %w", err)
+	}
+
+	vectors := make([][]float32, 0, len(resp.Embeddings))
+	for _, embedding := range resp.Embeddings {
+		vectors = append(vectors, embedding.Values)
+	}
+	return vectors, nil
+}
+
+// normalizeGoogleFinishReason maps a Gemini finish reason to a StopReason constant
+func normalizeGoogleFinishReason(reason genai.FinishReason) string {
+	switch pb.Candidate_FinishReason(reason) {
+	case pb.Candidate_STOP:
+		return StopReasonEndTurn
+	case pb.Candidate_MAX_TOKENS:
+		return StopReasonMaxTokens
+	case pb.Candidate_SAFETY, pb.Candidate_RECITATION:
+		return StopReasonContentBlock
+	case pb.Candidate_FINISH_REASON_UNSPECIFIED:
+		return ""
+	}
+	return StopReasonOther
+}
+
+// googleCandidateText concatenates the text parts of a candidate
+func googleCandidateText(candidate *genai.Candidate) string {
+	if candidate.Content == nil {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		if t, ok := part.(genai.Text); ok {
+			text.WriteString(string(t))
+		}
+	}
+	return text.String()
+}
+
+// googleSafetyRatings converts Gemini safety ratings using the API's enum names
+func googleSafetyRatings(ratings []*genai.SafetyRating) []SafetyRating {
+	var converted []SafetyRating
+	for _, rating := range ratings {
+		converted = append(converted, SafetyRating{
+			Category:    pb.HarmCategory(rating.Category).String(),
+			Probability: pb.SafetyRating_HarmProbability(rating.Probability).String(),
+			Blocked:     rating.Blocked,
+		})
+	}
+	return converted
+}
+
+// googleError converts errors from the Gemini client, turning safety blocks
+// into errors that say what was blocked instead of a generic failure
+func googleError(err error) error {
+	var blocked *genai.BlockedError
+	if !errors.As(err, &blocked) {
+		return fmt.Errorf("error generating content: %w", err)
 	}
 
-	// Return the text from the first candidate's first part
-	text, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
-	if !ok {
-		return "", errors.New("unexpected response type from Google API")
+	if feedback := blocked.PromptFeedback; feedback != nil {
+		return &PromptBlockedError{
+			Reason:        pb.GenerateContentResponse_PromptFeedback_BlockReason(feedback.BlockReason).String(),
+			SafetyRatings: googleSafetyRatings(feedback.SafetyRatings),
+		}
 	}
 
-	return string(text), nil
+	reason := "unknown"
+	if blocked.Candidate != nil {
+		reason = pb.Candidate_FinishReason(blocked.Candidate.FinishReason).String()
+	}
+	return fmt.Errorf("response blocked by Google API (finish reason %s)", reason)
 }
 
 // Close closes the provider's resources
@@ -112,3 +397,74 @@ func (p *GoogleProvider) Close() error {
 	}
 	return nil
 }
+
+// headerTransport adds fixed headers to every request
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// Requests must not be modified by a RoundTripper, so work on a clone
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+
+	return base.RoundTrip(req)
+}
+
+// servedModelKey is the context key for the servedModel filled in by servedModelTransport
+type servedModelKey struct{}
+
+// servedModel holds response fields the genai SDK does not expose
+type servedModel struct {
+	ResponseID   string    `json:"responseId"`
+	ModelVersion string    `json:"modelVersion"`
+	Created      time.Time `json:"-"`
+}
+
+// servedModelTransport records the response ID and model version of Gemini
+// responses in the servedModel found in the request context
+type servedModelTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *servedModelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	served, ok := req.Context().Value(servedModelKey{}).(*servedModel)
+	if err != nil || !ok || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if closeErr := resp.Body.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Streamed responses are arrays of chunks which all carry the same fields
+	trimmed := bytes.TrimSpace(body)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var chunks []json.RawMessage
+		if json.Unmarshal(trimmed, &chunks) == nil && len(chunks) > 0 {
+			trimmed = chunks[0]
+		}
+	}
+
+	// Missing fields are not an error, the metadata is best effort
+	_ = json.Unmarshal(trimmed, served)
+	served.Created = responseTime(resp.Header)
+
+	return resp, nil
+}
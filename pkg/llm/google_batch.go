@@ -0,0 +1,440 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	pb "cloud.google.com/go/ai/generativelanguage/apiv1beta/generativelanguagepb"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// googleContent represents a turn of a Gemini REST request or response
+type googleContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []googlePart `json:"parts"`
+}
+
+// googlePart represents a text part of a Gemini REST request or response
+type googlePart struct {
+	Text    string `json:"text"`
+	Thought bool   `json:"thought,omitempty"`
+}
+
+// googleSafetySetting represents a safety setting of a Gemini REST request.
+// Enums are sent as numbers like the SDK does.
+type googleSafetySetting struct {
+	Category  int32 `json:"category"`
+	Threshold int32 `json:"threshold"`
+}
+
+// googleGenerateRequest represents a generateContent request in the Gemini REST API
+type googleGenerateRequest struct {
+	Contents          []googleContent        `json:"contents"`
+	SystemInstruction *googleContent         `json:"systemInstruction,omitempty"`
+	SafetySettings    []googleSafetySetting  `json:"safetySettings,omitempty"`
+	GenerationConfig  map[string]interface{} `json:"generationConfig,omitempty"`
+}
+
+// googleGenerateResponse represents a generateContent response in the Gemini REST API
+type googleGenerateResponse struct {
+	Candidates []struct {
+		Content      googleContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+	ResponseID   string `json:"responseId"`
+}
+
+// googleStatus represents an error in the Gemini REST API
+type googleStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// googleBatchRequest represents one request of a Gemini batch with its key
+type googleBatchRequest struct {
+	Request  googleGenerateRequest `json:"request"`
+	Metadata map[string]string     `json:"metadata"`
+}
+
+// googleBatch represents a Gemini batch operation. The API encodes 64-bit
+// counts as strings.
+type googleBatch struct {
+	Name     string `json:"name"`
+	Done     bool   `json:"done"`
+	Metadata struct {
+		Model      string    `json:"model"`
+		State      string    `json:"state"`
+		CreateTime time.Time `json:"createTime"`
+		EndTime    time.Time `json:"endTime"`
+		BatchStats struct {
+			RequestCount           int64 `json:"requestCount,string"`
+			SuccessfulRequestCount int64 `json:"successfulRequestCount,string"`
+			FailedRequestCount     int64 `json:"failedRequestCount,string"`
+			PendingRequestCount    int64 `json:"pendingRequestCount,string"`
+		} `json:"batchStats"`
+	} `json:"metadata"`
+	Error    *googleStatus `json:"error"`
+	Response *struct {
+		InlinedResponses struct {
+			InlinedResponses []struct {
+				Response *googleGenerateResponse `json:"response"`
+				Error    *googleStatus           `json:"error"`
+				Metadata map[string]string       `json:"metadata"`
+			} `json:"inlinedResponses"`
+		} `json:"inlinedResponses"`
+	} `json:"response"`
+}
+
+// SubmitBatch implements the BatchProvider interface using the Gemini batch mode
+func (p *GoogleProvider) SubmitBatch(ctx context.Context, requests []BatchRequest) (*BatchJob, error) {
+	var model string
+	var inlined []googleBatchRequest
+	for _, request := range requests {
+		// Apply options
+		opts := &RequestOptions{
+			MaxTokens:   1024,
+			Temperature: 0.7,
+		}
+		for _, option := range request.Options {
+			option(opts)
+		}
+
+		// A batch runs on a single model
+		if model == "" {
+			model = opts.Model
+		} else if opts.Model != model {
+			return nil, fmt.Errorf("batch request %s uses %s, but a Gemini batch runs on a single model", request.ID, opts.Model)
+		}
+
+		content, err := googleRequestFor(request.Prompt, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error in batch request %s: %w", request.ID, err)
+		}
+		inlined = append(inlined, googleBatchRequest{Request: content, Metadata: map[string]string{"key": request.ID}})
+	}
+
+	payload := map[string]interface{}{
+		"batch": map[string]interface{}{
+			"displayName": "gollm",
+			"inputConfig": map[string]interface{}{
+				"requests": map[string]interface{}{"requests": inlined},
+			},
+		},
+	}
+
+	var batch googleBatch
+	if err := p.sendBatchRequest(ctx, http.MethodPost, p.baseURL+"/v1beta/models/"+model+":batchGenerateContent", payload, &batch); err != nil {
+		return nil, err
+	}
+
+	// The operation is named after the batch it creates, which may not report
+	// its requests yet
+	job := batch.job()
+	if job.Counts.Total() == 0 {
+		job.Counts.Processing = len(requests)
+	}
+	return job, nil
+}
+
+// GetBatch implements the BatchProvider interface
+func (p *GoogleProvider) GetBatch(ctx context.Context, id string) (*BatchJob, error) {
+	batch, err := p.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return batch.job(), nil
+}
+
+// BatchResults implements the BatchProvider interface with the responses
+// inlined in a finished batch
+func (p *GoogleProvider) BatchResults(ctx context.Context, id string) ([]BatchResult, error) {
+	batch, err := p.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Error != nil {
+		return nil, fmt.Errorf("batch %s failed: %s", id, batch.Error.Message)
+	}
+	if !batch.Done || batch.Response == nil {
+		return nil, fmt.Errorf("batch %s is still %s, results are available once it has ended", id, batch.job().Status)
+	}
+
+	var results []BatchResult
+	for i, entry := range batch.Response.InlinedResponses.InlinedResponses {
+		// Responses are in request order, the key is set by SubmitBatch
+		result := BatchResult{ID: entry.Metadata["key"]}
+		if result.ID == "" {
+			result.ID = fmt.Sprint(i + 1)
+		}
+
+		switch {
+		case entry.Error != nil:
+			result.Error = fmt.Sprintf("API error (%s): %s", entry.Error.Status, entry.Error.Message)
+		case entry.Response == nil:
+			result.Error = "no response in batch result"
+		default:
+			response, err := googleResult(entry.Response)
+			if err != nil {
+				result.Error = err.Error()
+				break
+			}
+			if response.Metadata.ModelVersion == "" {
+				response.Metadata.ModelVersion = strings.TrimPrefix(batch.Metadata.Model, "models/")
+			}
+			response.Metadata.Created = batch.Metadata.EndTime
+			result.Response = response
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// CancelBatch implements the BatchProvider interface
+func (p *GoogleProvider) CancelBatch(ctx context.Context, id string) (*BatchJob, error) {
+	var empty struct{}
+	if err := p.sendBatchRequest(ctx, http.MethodPost, p.baseURL+"/v1beta/"+googleBatchName(id)+":cancel", nil, &empty); err != nil {
+		return nil, err
+	}
+	return p.GetBatch(ctx, id)
+}
+
+// getBatch fetches a batch operation
+func (p *GoogleProvider) getBatch(ctx context.Context, id string) (*googleBatch, error) {
+	var batch googleBatch
+	if err := p.sendBatchRequest(ctx, http.MethodGet, p.baseURL+"/v1beta/"+googleBatchName(id), nil, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// googleBatchName returns the resource name of a batch, accepting IDs without the batches/ prefix
+func googleBatchName(id string) string {
+	if strings.HasPrefix(id, "batches/") {
+		return id
+	}
+	return "batches/" + id
+}
+
+// job converts a batch operation into a BatchJob
+func (b *googleBatch) job() *BatchJob {
+	stats := b.Metadata.BatchStats
+	job := &BatchJob{
+		ID:        b.Name,
+		CreatedAt: b.Metadata.CreateTime,
+		EndedAt:   b.Metadata.EndTime,
+		Counts: BatchCounts{
+			Processing: int(stats.PendingRequestCount),
+			Succeeded:  int(stats.SuccessfulRequestCount),
+			Errored:    int(stats.FailedRequestCount),
+		},
+	}
+
+	// Requests of a stopped batch that were never answered did not run
+	unfinished := int(stats.RequestCount - stats.SuccessfulRequestCount - stats.FailedRequestCount)
+
+	// The REST API and the SDKs use different prefixes for the same states
+	state := strings.TrimPrefix(strings.TrimPrefix(b.Metadata.State, "BATCH_STATE_"), "JOB_STATE_")
+	switch state {
+	case "SUCCEEDED":
+		job.Status = BatchStatusEnded
+	case "FAILED":
+		job.Status = BatchStatusFailed
+	case "CANCELLED":
+		job.Status = BatchStatusCanceled
+		job.Counts.Processing = 0
+		job.Counts.Canceled = unfinished
+	case "EXPIRED":
+		job.Status = BatchStatusExpired
+		job.Counts.Processing = 0
+		job.Counts.Expired = unfinished
+	default:
+		job.Status = BatchStatusInProgress
+		if job.Counts.Processing == 0 {
+			job.Counts.Processing = unfinished
+		}
+	}
+	return job
+}
+
+// googleRequestFor builds the REST generateContent request for a prompt,
+// validating generation settings like QueryDetailed does
+func googleRequestFor(prompt string, opts *RequestOptions) (googleGenerateRequest, error) {
+	// Model is required
+	if opts.Model == "" {
+		return googleGenerateRequest{}, errors.New("model is required for Google provider")
+	}
+
+	// Gemini has no way to continue a partial answer
+	if opts.Prefill != "" {
+		return googleGenerateRequest{}, fmt.Errorf("%s does not support prefilling the response", opts.Model)
+	}
+
+	// Validate safety settings, stop sequences and other settings on a model
+	// that is never sent, then copy them into the request
+	model := &genai.GenerativeModel{}
+	extra, err := applyGeminiOptions(model, opts.Model, opts)
+	if err != nil {
+		return googleGenerateRequest{}, err
+	}
+
+	config := map[string]interface{}{
+		"temperature":     opts.Temperature,
+		"maxOutputTokens": opts.MaxTokens,
+	}
+	if topP, ok := opts.CustomParams["top_p"].(float64); ok {
+		config["topP"] = topP
+	}
+	if topK, ok := opts.CustomParams["top_k"].(int); ok {
+		config["topK"] = topK
+	}
+	if opts.CandidateCount > 0 {
+		config["candidateCount"] = opts.CandidateCount
+	}
+	if len(model.StopSequences) > 0 {
+		config["stopSequences"] = model.StopSequences
+	}
+	if model.ResponseMIMEType != "" {
+		config["responseMimeType"] = model.ResponseMIMEType
+	}
+	for name, value := range extra {
+		config[name] = value
+	}
+
+	req := googleGenerateRequest{GenerationConfig: config}
+	for _, setting := range model.SafetySettings {
+		req.SafetySettings = append(req.SafetySettings, googleSafetySetting{
+			Category:  int32(setting.Category),
+			Threshold: int32(setting.Threshold),
+		})
+	}
+
+	// Set system instructions if specified
+	if system, ok := opts.CustomParams["system"].(string); ok && system != "" {
+		req.SystemInstruction = &googleContent{Parts: []googlePart{{Text: system}}}
+	}
+
+	// Earlier turns, with Gemini naming the assistant role "model", then the prompt
+	for _, message := range opts.History {
+		role := message.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		req.Contents = append(req.Contents, googleContent{Role: role, Parts: []googlePart{{Text: message.Content}}})
+	}
+	req.Contents = append(req.Contents, googleContent{Role: "user", Parts: []googlePart{{Text: prompt}}})
+
+	return req, nil
+}
+
+// googleResult converts a REST generateContent response into a Response
+func googleResult(resp *googleGenerateResponse) (*Response, error) {
+	// A blocked prompt produces no candidates
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return nil, &PromptBlockedError{Reason: resp.PromptFeedback.BlockReason}
+	}
+	if len(resp.Candidates) == 0 {
+		return nil, errors.New("empty response from Google API")
+	}
+
+	// The first candidate is the answer, thought summaries are reasoning
+	candidate := resp.Candidates[0]
+	var text, thinking strings.Builder
+	for _, part := range candidate.Content.Parts {
+		if part.Thought {
+			thinking.WriteString(part.Text)
+		} else {
+			text.WriteString(part.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no text in response from Google API (finish reason %s)", candidate.FinishReason)
+	}
+
+	finishReason := genai.FinishReason(pb.Candidate_FinishReason_value[candidate.FinishReason])
+	return &Response{
+		Text:         text.String(),
+		Thinking:     thinking.String(),
+		FinishReason: candidate.FinishReason,
+		Usage: Usage{
+			InputTokens:  resp.UsageMetadata.PromptTokenCount,
+			OutputTokens: resp.UsageMetadata.CandidatesTokenCount,
+		},
+		Metadata: Metadata{
+			StopReason:   normalizeGoogleFinishReason(finishReason),
+			RequestID:    resp.ResponseID,
+			ModelVersion: resp.ModelVersion,
+		},
+	}, nil
+}
+
+// sendBatchRequest sends a request to the Gemini REST API and parses the JSON response into result
+func (p *GoogleProvider) sendBatchRequest(ctx context.Context, method, url string, payload, result interface{}) error {
+	var reqBody io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("error marshaling request: %w", err)
+		}
+		reqBody = bytes.NewReader(jsonData)
+	}
+
+	// Create HTTP request, the client's transport adds the key and headers
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	// Send request
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Just log the error, can't return it here
+			fmt.Printf("Error closing response body: %v\n", err)
+		}
+	}()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+
+	// Check for HTTP errors
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error *googleStatus `json:"error"`
+		}
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != nil {
+			return fmt.Errorf("API error (%s): %s", errResp.Error.Status, errResp.Error.Message)
+		}
+		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("error parsing response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// geminiHarmCategories maps Gemini harm category names to SDK values. The SDK
+// predates civic integrity and the OFF threshold, so their API enum numbers are used.
+var geminiHarmCategories = map[string]genai.HarmCategory{
+	"HARM_CATEGORY_HARASSMENT":        genai.HarmCategoryHarassment,
+	"HARM_CATEGORY_HATE_SPEECH":       genai.HarmCategoryHateSpeech,
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT": genai.HarmCategorySexuallyExplicit,
+	"HARM_CATEGORY_DANGEROUS_CONTENT": genai.HarmCategoryDangerousContent,
+	"HARM_CATEGORY_CIVIC_INTEGRITY":   genai.HarmCategory(11),
+}
+
+// geminiBlockThresholds maps Gemini block threshold names to SDK values
+var geminiBlockThresholds = map[string]genai.HarmBlockThreshold{
+	"BLOCK_LOW_AND_ABOVE":    genai.HarmBlockLowAndAbove,
+	"BLOCK_MEDIUM_AND_ABOVE": genai.HarmBlockMediumAndAbove,
+	"BLOCK_ONLY_HIGH":        genai.HarmBlockOnlyHigh,
+	"BLOCK_NONE":             genai.HarmBlockNone,
+	"OFF":                    genai.HarmBlockThreshold(5),
+}
+
+// geminiResponseMIMETypes lists the response MIME types accepted by Gemini
+var geminiResponseMIMETypes = []string{"text/plain", "application/json", "text/x.enum"}
+
+// geminiMaxStopSequences is the largest number of stop sequences Gemini accepts
+const geminiMaxStopSequences = 5
+
+// geminiModelLimits describes generation settings a Gemini model does not support
+type geminiModelLimits struct {
+	noCivicIntegrity bool // HARM_CATEGORY_CIVIC_INTEGRITY is unknown to the model
+	noOffThreshold   bool // The OFF threshold is unknown to the model
+	noPenalties      bool // Presence and frequency penalties are rejected
+}
+
+// geminiLimits lists the models with restrictions, models not listed support every setting
+var geminiLimits = map[string]geminiModelLimits{
+	"gemini-2.5-pro-exp-03-25": {noPenalties: true},
+	"gemini-2.0-flash-lite":    {noPenalties: true},
+	"gemini-1.5-flash":         {noCivicIntegrity: true, noOffThreshold: true},
+	"gemini-1.5-flash-8b":      {noCivicIntegrity: true, noOffThreshold: true},
+}
+
+// NormalizeHarmCategory returns the Gemini name for a harm category, accepting
+// short lowercase names such as "hate_speech"
+func NormalizeHarmCategory(category string) (string, error) {
+	name := strings.ToUpper(strings.TrimSpace(category))
+	if !strings.HasPrefix(name, "HARM_CATEGORY_") {
+		name = "HARM_CATEGORY_" + name
+	}
+	if _, ok := geminiHarmCategories[name]; !ok {
+		return "", invalidOption("unknown harm category %q, expected one of harassment, hate_speech, sexually_explicit, dangerous_content or civic_integrity", category)
+	}
+	return name, nil
+}
+
+// NormalizeBlockThreshold returns the Gemini name for a block threshold,
+// accepting lowercase names such as "block_only_high"
+func NormalizeBlockThreshold(threshold string) (string, error) {
+	name := strings.ToUpper(strings.TrimSpace(threshold))
+	if _, ok := geminiBlockThresholds[name]; !ok {
+		return "", invalidOption("unknown block threshold %q, expected one of block_none, block_only_high, block_medium_and_above, block_low_and_above or off", threshold)
+	}
+	return name, nil
+}
+
+// ValidateResponseMIMEType checks that Gemini can produce responses of a MIME type
+func ValidateResponseMIMEType(mimeType string) error {
+	if !slices.Contains(geminiResponseMIMETypes, mimeType) {
+		return invalidOption("unsupported response MIME type %q, expected one of %s", mimeType, strings.Join(geminiResponseMIMETypes, ", "))
+	}
+	return nil
+}
+
+// ValidatePenalty checks that a presence or frequency penalty is in Gemini's range
+func ValidatePenalty(penalty float64) error {
+	if penalty < -2 || penalty >= 2 {
+		return invalidOption("penalty must be at least -2.0 and less than 2.0, got %g", penalty)
+	}
+	return nil
+}
+
+// applyGeminiOptions validates the generation settings against the model and
+// applies them. Settings the SDK has no fields for are returned so they can
+// be added to the request body by generationConfigTransport.
+func applyGeminiOptions(model *genai.GenerativeModel, modelName string, opts *RequestOptions) (map[string]interface{}, error) {
+	limits := geminiLimits[modelName]
+
+	// Safety thresholds per harm category
+	for category, threshold := range opts.SafetySettings {
+		categoryName, err := NormalizeHarmCategory(category)
+		if err != nil {
+			return nil, err
+		}
+		thresholdName, err := NormalizeBlockThreshold(threshold)
+		if err != nil {
+			return nil, err
+		}
+		if limits.noCivicIntegrity && categoryName == "HARM_CATEGORY_CIVIC_INTEGRITY" {
+			return nil, invalidOption("%s does not support the civic_integrity harm category", modelName)
+		}
+		if limits.noOffThreshold && thresholdName == "OFF" {
+			return nil, invalidOption("%s does not support the off threshold, use block_none instead", modelName)
+		}
+
+		model.SafetySettings = append(model.SafetySettings, &genai.SafetySetting{
+			Category:  geminiHarmCategories[categoryName],
+			Threshold: geminiBlockThresholds[thresholdName],
+		})
+	}
+
+	if len(opts.StopSequences) > geminiMaxStopSequences {
+		return nil, invalidOption("gemini accepts at most %d stop sequences, got %d", geminiMaxStopSequences, len(opts.StopSequences))
+	}
+	model.StopSequences = opts.StopSequences
+
+	if opts.ResponseMIMEType != "" {
+		if err := ValidateResponseMIMEType(opts.ResponseMIMEType); err != nil {
+			return nil, err
+		}
+		model.ResponseMIMEType = opts.ResponseMIMEType
+	}
+
+	extra := make(map[string]interface{})
+	for name, penalty := range map[string]*float64{
+		"presencePenalty":  opts.PresencePenalty,
+		"frequencyPenalty": opts.FrequencyPenalty,
+	} {
+		if penalty == nil {
+			continue
+		}
+		if limits.noPenalties {
+			return nil, invalidOption("%s does not support presence or frequency penalties", modelName)
+		}
+		if err := ValidatePenalty(*penalty); err != nil {
+			return nil, err
+		}
+		extra[name] = *penalty
+	}
+	if opts.Seed != nil {
+		extra["seed"] = *opts.Seed
+	}
+
+	return extra, nil
+}
+
+// generationConfigKey is the context key for generation settings added by generationConfigTransport
+type generationConfigKey struct{}
+
+// generationConfigTransport adds generation settings from the request context
+// to the generationConfig of Gemini request bodies. The genai SDK has no
+// fields for some settings the API accepts, such as penalties and seed.
+type generationConfigTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *generationConfigTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	extra, ok := req.Context().Value(generationConfigKey{}).(map[string]interface{})
+	if !ok || len(extra) == 0 || req.Body == nil || req.Method != http.MethodPost {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if closeErr := req.Body.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+
+	// Decode numbers as json.Number so values pass through unchanged
+	var payload map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("error parsing request body: %w", err)
+	}
+
+	config, _ := payload["generationConfig"].(map[string]interface{})
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	for name, value := range extra {
+		config[name] = value
+	}
+	payload["generationConfig"] = config
+
+	body, err = json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request body: %w", err)
+	}
+
+	// Requests must not be modified by a RoundTripper, so work on a clone
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+
+	return t.base.RoundTrip(req)
+}
+
+// withGenerationConfig returns a context carrying extra generation settings for generationConfigTransport
+func withGenerationConfig(ctx context.Context, extra map[string]interface{}) context.Context {
+	if len(extra) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, generationConfigKey{}, extra)
+}
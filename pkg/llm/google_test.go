@@ -0,0 +1,300 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// setupGoogleMockServer creates a mock server for the Gemini REST API that
+// returns the given response body and records the last request
+func setupGoogleMockServer(t *testing.T, response string, lastRequest **http.Request, lastBody *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lastRequest != nil {
+			*lastRequest = r.Clone(context.Background())
+		}
+		if lastBody != nil {
+			body := make(map[string]interface{})
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Failed to decode request body: %v", err)
+			}
+			*lastBody = body
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Errorf("Failed to write Google mock response: %v", err)
+		}
+	}))
+}
+
+// TestGoogleProviderCustomEndpoint tests that base URL, headers and the HTTP client are honored
+func TestGoogleProviderCustomEndpoint(t *testing.T) {
+	var lastRequest *http.Request
+	server := setupGoogleMockServer(t, `{"candidates":[{"content":{"role":"model","parts":[{"text":"Mock Google response"}]},"finishReason":"STOP"}]}`, &lastRequest, nil)
+	defer server.Close()
+
+	provider := NewGoogleProvider(
+		"test-google-key",
+		server.Client(),
+		WithBaseURL(server.URL),
+		WithHeaders(map[string]string{"X-Gateway-Team": "platform"}),
+	)
+	defer func() {
+		if err := provider.Close(); err != nil {
+			t.Errorf("Failed to close provider: %v", err)
+		}
+	}()
+
+	response, err := provider.Query(context.Background(), "Test prompt", WithModel("gemini-2.0-flash"))
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+
+	if response != "Mock Google response" {
+		t.Errorf("Expected response 'Mock Google response', got %q", response)
+	}
+
+	if lastRequest == nil {
+		t.Fatal("Expected request to reach the mock server")
+	}
+	if !strings.Contains(lastRequest.URL.Path, "gemini-2.0-flash:generateContent") {
+		t.Errorf("Expected generateContent request, got path %s", lastRequest.URL.Path)
+	}
+	if lastRequest.Header.Get("x-goog-api-key") != "test-google-key" {
+		t.Errorf("Expected API key header, got %q", lastRequest.Header.Get("x-goog-api-key"))
+	}
+	if lastRequest.Header.Get("X-Gateway-Team") != "platform" {
+		t.Errorf("Expected custom header, got %q", lastRequest.Header.Get("X-Gateway-Team"))
+	}
+}
+
+// TestGoogleProviderCandidates tests concatenating text parts and returning every candidate
+func TestGoogleProviderCandidates(t *testing.T) {
+	var lastBody map[string]interface{}
+	server := setupGoogleMockServer(t, `{
+		"candidates": [
+			{"index": 0, "content": {"role": "model", "parts": [{"text": "First part, "}, {"text": "second part."}]}, "finishReason": "STOP",
+			 "safetyRatings": [{"category": "HARM_CATEGORY_HARASSMENT", "probability": "NEGLIGIBLE"}]},
+			{"index": 1, "content": {"role": "model", "parts": [{"text": "Alternative"}]}, "finishReason": "MAX_TOKENS"}
+		],
+		"usageMetadata": {"promptTokenCount": 7, "candidatesTokenCount": 9, "totalTokenCount": 16}
+	}`, nil, &lastBody)
+	defer server.Close()
+
+	provider := NewGoogleProvider("test-google-key", server.Client(), WithBaseURL(server.URL))
+	defer func() {
+		if err := provider.Close(); err != nil {
+			t.Errorf("Failed to close provider: %v", err)
+		}
+	}()
+
+	response, err := provider.QueryDetailed(context.Background(), "Test prompt",
+		WithModel("gemini-2.0-flash"),
+		WithCandidateCount(2),
+	)
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	config, _ := lastBody["generationConfig"].(map[string]interface{})
+	if config["candidateCount"] != float64(2) {
+		t.Errorf("Expected candidateCount 2 in request, got %v", config["candidateCount"])
+	}
+
+	if response.Text != "First part, second part." {
+		t.Errorf("Expected concatenated text, got %q", response.Text)
+	}
+	if response.FinishReason != "STOP" {
+		t.Errorf("Expected finish reason STOP, got %q", response.FinishReason)
+	}
+	if len(response.SafetyRatings) != 1 || response.SafetyRatings[0].Category != "HARM_CATEGORY_HARASSMENT" || response.SafetyRatings[0].Probability != "NEGLIGIBLE" {
+		t.Errorf("Expected harassment safety rating, got %+v", response.SafetyRatings)
+	}
+	if len(response.Candidates) != 2 {
+		t.Fatalf("Expected 2 candidates, got %d", len(response.Candidates))
+	}
+	if response.Candidates[1].Text != "Alternative" || response.Candidates[1].FinishReason != "MAX_TOKENS" {
+		t.Errorf("Expected second candidate 'Alternative' (MAX_TOKENS), got %+v", response.Candidates[1])
+	}
+	if response.Usage.InputTokens != 7 || response.Usage.OutputTokens != 9 {
+		t.Errorf("Expected 7 input and 9 output tokens, got %+v", response.Usage)
+	}
+}
+
+// TestGoogleProviderPromptBlocked tests that a blocked prompt returns a PromptBlockedError
+func TestGoogleProviderPromptBlocked(t *testing.T) {
+	server := setupGoogleMockServer(t, `{
+		"promptFeedback": {"blockReason": "SAFETY", "safetyRatings": [
+			{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH", "blocked": true}
+		]}
+	}`, nil, nil)
+	defer server.Close()
+
+	provider := NewGoogleProvider("test-google-key", server.Client(), WithBaseURL(server.URL))
+	defer func() {
+		if err := provider.Close(); err != nil {
+			t.Errorf("Failed to close provider: %v", err)
+		}
+	}()
+
+	_, err := provider.Query(context.Background(), "Test prompt", WithModel("gemini-2.0-flash"))
+
+	var blocked *PromptBlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("Expected PromptBlockedError, got %v", err)
+	}
+	if blocked.Reason != "SAFETY" {
+		t.Errorf("Expected block reason SAFETY, got %q", blocked.Reason)
+	}
+	if !strings.Contains(err.Error(), "HARM_CATEGORY_DANGEROUS_CONTENT") {
+		t.Errorf("Expected error to name the blocked category, got %q", err.Error())
+	}
+}
+
+// TestGoogleProviderGenerationSettings tests that safety settings and generation options reach the API
+func TestGoogleProviderGenerationSettings(t *testing.T) {
+	var lastBody map[string]interface{}
+	server := setupGoogleMockServer(t, `{"candidates":[{"content":{"role":"model","parts":[{"text":"{}"}]},"finishReason":"STOP"}]}`, nil, &lastBody)
+	defer server.Close()
+
+	provider := NewGoogleProvider("test-google-key", server.Client(), WithBaseURL(server.URL))
+	defer func() {
+		if err := provider.Close(); err != nil {
+			t.Errorf("Failed to close provider: %v", err)
+		}
+	}()
+
+	response, err := provider.QueryDetailed(context.Background(), "Test prompt",
+		WithModel("gemini-2.0-flash"),
+		WithSafetySetting("harassment", "block_only_high"),
+		WithStopSequences("END"),
+		WithPresencePenalty(0.5),
+		WithFrequencyPenalty(-0.5),
+		WithSeed(42),
+		WithResponseMIMEType("application/json"),
+		WithCustomParam("top_q", 0.3),
+	)
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	config, _ := lastBody["generationConfig"].(map[string]interface{})
+	expected := map[string]interface{}{
+		"presencePenalty":  0.5,
+		"frequencyPenalty": -0.5,
+		"seed":             float64(42),
+		"responseMimeType": "application/json",
+	}
+	for name, value := range expected {
+		if config[name] != value {
+			t.Errorf("Expected generationConfig.%s %v, got %v", name, value, config[name])
+		}
+	}
+	if stops, _ := config["stopSequences"].([]interface{}); len(stops) != 1 || stops[0] != "END" {
+		t.Errorf("Expected stop sequence END, got %v", config["stopSequences"])
+	}
+
+	safety, _ := lastBody["safetySettings"].([]interface{})
+	if len(safety) != 1 {
+		t.Fatalf("Expected 1 safety setting, got %v", lastBody["safetySettings"])
+	}
+	// The REST client sends enums as numbers
+	setting, _ := safety[0].(map[string]interface{})
+	if setting["category"] != float64(genai.HarmCategoryHarassment) || setting["threshold"] != float64(genai.HarmBlockOnlyHigh) {
+		t.Errorf("Expected harassment BLOCK_ONLY_HIGH, got %v", setting)
+	}
+
+	// Unknown custom parameters produce a warning instead of being dropped silently
+	if len(response.Warnings) != 1 || !strings.Contains(response.Warnings[0], "top_q") {
+		t.Errorf("Expected warning about top_q, got %v", response.Warnings)
+	}
+}
+
+// TestGoogleProviderValidatesSettings tests that settings are checked against the model before sending
+func TestGoogleProviderValidatesSettings(t *testing.T) {
+	provider := NewGoogleProvider("test-google-key", nil)
+	defer func() {
+		if err := provider.Close(); err != nil {
+			t.Errorf("Failed to close provider: %v", err)
+		}
+	}()
+
+	tests := []struct {
+		name   string
+		model  string
+		option Option
+	}{
+		{"unknown category", "gemini-2.0-flash", WithSafetySetting("spam", "block_none")},
+		{"unknown threshold", "gemini-2.0-flash", WithSafetySetting("harassment", "sometimes")},
+		{"off on 1.5", "gemini-1.5-flash", WithSafetySetting("harassment", "off")},
+		{"civic integrity on 1.5", "gemini-1.5-flash", WithSafetySetting("civic_integrity", "block_none")},
+		{"penalty out of range", "gemini-2.0-flash", WithPresencePenalty(2.5)},
+		{"penalty unsupported", "gemini-2.0-flash-lite", WithFrequencyPenalty(0.5)},
+		{"MIME type", "gemini-2.0-flash", WithResponseMIMEType("text/html")},
+		{"too many stop sequences", "gemini-2.0-flash", WithStopSequences("a", "b", "c", "d", "e", "f")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := provider.Query(context.Background(), "Test prompt", WithModel(test.model), test.option); err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+		})
+	}
+}
+
+// TestGoogleProviderMetadata tests that the response ID and served model version are captured
+func TestGoogleProviderMetadata(t *testing.T) {
+	server := setupGoogleMockServer(t, `{
+		"candidates": [{"content": {"role": "model", "parts": [{"text": "Once upon a"}]}, "finishReason": "MAX_TOKENS"}],
+		"modelVersion": "gemini-2.0-flash-001",
+		"responseId": "resp-123"
+	}`, nil, nil)
+	defer server.Close()
+
+	provider := NewGoogleProvider("test-google-key", server.Client(), WithBaseURL(server.URL))
+	defer func() {
+		if err := provider.Close(); err != nil {
+			t.Errorf("Failed to close provider: %v", err)
+		}
+	}()
+
+	response, err := provider.QueryDetailed(context.Background(), "Tell a story", WithModel("gemini-2.0-flash"))
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	metadata := response.Metadata
+	if metadata.StopReason != StopReasonMaxTokens || !metadata.Truncated() {
+		t.Errorf("Expected truncated response with stop reason max_tokens, got %q", metadata.StopReason)
+	}
+	if metadata.RequestID != "resp-123" {
+		t.Errorf("Expected request ID resp-123, got %q", metadata.RequestID)
+	}
+	if metadata.ModelVersion != "gemini-2.0-flash-001" {
+		t.Errorf("Expected model version gemini-2.0-flash-001, got %q", metadata.ModelVersion)
+	}
+}
+
+// TestGoogleProviderRejectsPrefill tests that a prefill is rejected instead of silently ignored
+func TestGoogleProviderRejectsPrefill(t *testing.T) {
+	provider := NewGoogleProvider("test-google-key", nil)
+	defer func() {
+		if err := provider.Close(); err != nil {
+			t.Errorf("Failed to close provider: %v", err)
+		}
+	}()
+
+	_, err := provider.QueryDetailed(context.Background(), "Test prompt", WithModel("gemini-2.0-flash"), WithPrefill("{"))
+	if err == nil || !strings.Contains(err.Error(), "prefilling") {
+		t.Errorf("Expected prefill error, got %v", err)
+	}
+}
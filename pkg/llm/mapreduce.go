@@ -0,0 +1,225 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// MapReduceOptions configures how Service.MapReduce processes an oversized input
+type MapReduceOptions struct {
+	ChunkTokens   int    // Tokens per chunk, 0 to fit the model's context window
+	OverlapTokens int    // Tokens shared by neighbouring chunks, 0 for a twentieth of a chunk
+	Concurrency   int    // Chunks processed at the same time, 0 for 4
+	CombinePrompt string // Instructions for combining partial results, empty for the default
+}
+
+// MapReduceResult is the combined answer of a map-reduce query
+type MapReduceResult struct {
+	Response    *Response     // The combined answer, Usage covers every call
+	Chunks      int           // Number of chunks the input was split into
+	Calls       int           // Number of queries sent, including combine steps
+	Cost        float64       // Estimated cost in US dollars, 0 if pricing is unknown
+	ElapsedTime time.Duration // Time taken for all calls
+}
+
+// defaultChunkTokens is the chunk size for models with an unknown context window
+const defaultChunkTokens = 8000
+
+// defaultMapConcurrency is the number of chunks processed at the same time by default
+const defaultMapConcurrency = 4
+
+// defaultCombinePrompt introduces the partial results in the combine step
+const defaultCombinePrompt = "Combine them into a single answer to the request for the whole input. Do not mention the parts."
+
+// MapReduce answers a prompt about an input too large for the model's context
+// window. The input is split into overlapping chunks on natural boundaries, the
+// prompt is run over each chunk concurrently, and the partial results are
+// combined with a final prompt, in several rounds if they are large.
+func (s *Service) MapReduce(ctx context.Context, prompt, input, modelName string, mapOptions MapReduceOptions, options ...Option) (*MapReduceResult, error) {
+	// Validate model
+	if !IsValidModel(modelName) {
+		return nil, fmt.Errorf("unknown model: %s", modelName)
+	}
+
+	// Determine provider based on model name
+	providerName, _ := GetProviderForModel(modelName)
+
+	// Get provider
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, fmt.Errorf("provider %s not configured", providerName)
+	}
+
+	// Add model and provider defaults to options
+	options = s.providerOptions(providerName, modelName, options)
+	opts := &RequestOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	// Chunks must leave room for the prompt and the answer
+	chunkTokens := mapOptions.ChunkTokens
+	if chunkTokens <= 0 {
+		chunkTokens = mapChunkTokens(prompt, opts)
+	}
+	overlapTokens := mapOptions.OverlapTokens
+	if overlapTokens <= 0 {
+		overlapTokens = chunkTokens / 20
+	}
+	concurrency := mapOptions.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultMapConcurrency
+	}
+	combinePrompt := mapOptions.CombinePrompt
+	if combinePrompt == "" {
+		combinePrompt = defaultCombinePrompt
+	}
+
+	chunks := SplitText(modelName, input, chunkTokens, overlapTokens)
+	if len(chunks) == 0 {
+		return nil, errors.New("no input to process")
+	}
+
+	result := &MapReduceResult{Chunks: len(chunks)}
+	var usage Usage
+	startTime := time.Now()
+
+	// query sends one request and adds its usage to the total
+	var usageMutex sync.Mutex
+	query := func(ctx context.Context, text string) (*Response, error) {
+		response, err := s.queryProvider(ctx, providerName, provider, text, options)
+		if err != nil {
+			return nil, err
+		}
+
+		usageMutex.Lock()
+		defer usageMutex.Unlock()
+		usage.InputTokens += response.Usage.InputTokens
+		usage.OutputTokens += response.Usage.OutputTokens
+		usage.ThinkingTokens += response.Usage.ThinkingTokens
+		result.Calls++
+		return response, nil
+	}
+
+	// Map: run the prompt over every chunk
+	var prompts []string
+	if len(chunks) == 1 {
+		prompts = []string{prompt + "\n\n" + chunks[0]}
+	} else {
+		for i, chunk := range chunks {
+			prompts = append(prompts, fmt.Sprintf("%s\n\nThe input is split into %d parts. This is part %d of %d:\n\n%s", prompt, len(chunks), i+1, len(chunks), chunk))
+		}
+	}
+	responses, err := queryConcurrently(ctx, prompts, concurrency, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reduce: combine the partial results, in groups while they do not fit
+	// together. Small chunks may still be combined into a full context window.
+	combineTokens := max(chunkTokens, mapChunkTokens(prompt, opts))
+	for len(responses) > 1 {
+		var groups [][]string
+		var group []string
+		for _, response := range responses {
+			candidate := append(append([]string(nil), group...), response.Text)
+			if len(group) > 0 && EstimateTokens(modelName, combineInput(prompt, combinePrompt, candidate)) > combineTokens {
+				groups = append(groups, group)
+				candidate = []string{response.Text}
+			}
+			group = candidate
+		}
+		groups = append(groups, group)
+
+		// Each group must combine at least two results to make progress
+		if len(groups) == len(responses) {
+			return nil, errors.New("partial results are too large to combine, use smaller chunks or a larger max tokens")
+		}
+
+		prompts = prompts[:0]
+		for _, group := range groups {
+			prompts = append(prompts, combineInput(prompt, combinePrompt, group))
+		}
+		responses, err = queryConcurrently(ctx, prompts, concurrency, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.ElapsedTime = time.Since(startTime)
+	result.Response = responses[0]
+	result.Response.Usage = usage
+	result.Cost, _ = EstimateCost(modelName, usage)
+
+	// Log the combined answer as a single query
+	if s.logger != nil {
+		go func() {
+			if logErr := s.logger.LogQueryWithMetadata(prompt, modelName, result.Response.Text, result.ElapsedTime, opts.Temperature, loggedMetadata(result.Response.Metadata)); logErr != nil {
+				// Just print the error but don't fail the request
+				fmt.Fprintf(os.Stderr, "Failed to log query: %v\n", logErr)
+			}
+		}()
+	}
+
+	return result, nil
+}
+
+// mapChunkTokens returns a chunk size that leaves room in the model's context
+// window for the prompt and the answer, with a margin for estimation errors
+func mapChunkTokens(prompt string, opts *RequestOptions) int {
+	window := ContextWindow(opts.Model)
+	if window == 0 {
+		return defaultChunkTokens
+	}
+
+	available := window - opts.MaxTokens - estimateRequestTokens(prompt, opts)
+	return max(available*3/4, 1)
+}
+
+// combineInput builds the prompt combining partial results
+func combineInput(prompt, combinePrompt string, partials []string) string {
+	var input strings.Builder
+	input.WriteString("The following are results of this request applied to consecutive parts of a larger input:\n\n")
+	input.WriteString(prompt)
+	input.WriteString("\n\n")
+	input.WriteString(combinePrompt)
+	for i, partial := range partials {
+		fmt.Fprintf(&input, "\n\nPart %d:\n%s", i+1, partial)
+	}
+	return input.String()
+}
+
+// queryConcurrently sends every prompt with at most concurrency queries in
+// flight and returns the responses in order, or the first error. Once a
+// query fails the others are canceled and no more are sent.
+func queryConcurrently(ctx context.Context, prompts []string, concurrency int, query func(context.Context, string) (*Response, error)) ([]*Response, error) {
+	responses := make([]*Response, len(prompts))
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(concurrency)
+	for i, prompt := range prompts {
+		group.Go(func() error {
+			// Parts waiting for a slot are not sent after a failure
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			response, err := query(ctx, prompt)
+			if err != nil {
+				return fmt.Errorf("error processing part %d: %w", i+1, err)
+			}
+			responses[i] = response
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestServiceMapReduce(t *testing.T) {
+	var mutex sync.Mutex
+	var prompts []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req deepseekRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		prompt := req.Messages[len(req.Messages)-1].Content
+
+		mutex.Lock()
+		prompts = append(prompts, prompt)
+		mutex.Unlock()
+
+		// Summarize parts by their first word, and combine steps as a whole
+		answer := "combined"
+		if !strings.HasPrefix(prompt, "The following are results") {
+			answer = "summary of " + strings.Fields(prompt[strings.LastIndex(prompt, ":\n\n")+3:])[0]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(deepseekResponse{
+			Choices: []deepseekChoice{{Message: deepseekMessage{Content: answer}, FinishReason: "stop"}},
+			Usage:   deepseekUsage{PromptTokens: 100, CompletionTokens: 10},
+		}); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"deepseek": "d-key"}, server.Client(),
+		map[string]ProviderSettings{"deepseek": {BaseURL: server.URL}})
+
+	// Three paragraphs that each fill a chunk
+	input := strings.Join([]string{
+		"alpha " + strings.Repeat("word ", 60),
+		"beta " + strings.Repeat("word ", 60),
+		"gamma " + strings.Repeat("word ", 60),
+	}, "\n\n")
+
+	result, err := service.MapReduce(context.Background(), "Summarize this.", input, "deepseek-chat",
+		MapReduceOptions{ChunkTokens: 100, OverlapTokens: 1, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("MapReduce returned error: %v", err)
+	}
+
+	if result.Chunks != 3 || result.Calls != 4 {
+		t.Errorf("Expected 3 chunks and 4 calls, got %d chunks and %d calls", result.Chunks, result.Calls)
+	}
+	if result.Response.Text != "combined" {
+		t.Errorf("Expected combined answer, got %q", result.Response.Text)
+	}
+	if result.Response.Usage.InputTokens != 400 || result.Response.Usage.OutputTokens != 40 {
+		t.Errorf("Expected usage summed over 4 calls, got %+v", result.Response.Usage)
+	}
+	if expected, _ := EstimateCost("deepseek-chat", result.Response.Usage); result.Cost != expected || result.Cost == 0 {
+		t.Errorf("Expected cost %f, got %f", expected, result.Cost)
+	}
+
+	// The combine step sees every partial result in order
+	combine := prompts[len(prompts)-1]
+	for _, part := range []string{"Part 1:\nsummary of alpha", "Part 2:\nsummary of beta", "Part 3:\nsummary of gamma"} {
+		if !strings.Contains(combine, part) {
+			t.Errorf("Expected combine prompt to contain %q, got %q", part, combine)
+		}
+	}
+}
+
+func TestServiceMapReduceSingleChunk(t *testing.T) {
+	server := setupDeepseekMockServer(t)
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"deepseek": "d-key"}, server.Client(),
+		map[string]ProviderSettings{"deepseek": {BaseURL: server.URL}})
+
+	result, err := service.MapReduce(context.Background(), "Summarize this.", "A short input.", "deepseek-chat", MapReduceOptions{})
+	if err != nil {
+		t.Fatalf("MapReduce returned error: %v", err)
+	}
+
+	if result.Chunks != 1 || result.Calls != 1 {
+		t.Errorf("Expected a single call without combining, got %d chunks and %d calls", result.Chunks, result.Calls)
+	}
+	if result.Response.Text != "Mock Deepseek response" {
+		t.Errorf("Expected the single response, got %q", result.Response.Text)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	cost, ok := EstimateCost("claude-3-7-sonnet-latest", Usage{InputTokens: 1000000, OutputTokens: 500000, ThinkingTokens: 500000})
+	if !ok || cost != 18.00 {
+		t.Errorf("Expected $18.00, got $%f (%v)", cost, ok)
+	}
+
+	if _, ok := EstimateCost("unknown-model", Usage{InputTokens: 10}); ok {
+		t.Error("Expected unknown pricing for unknown model")
+	}
+}
+
+func TestServiceMapReduceStopsOnError(t *testing.T) {
+	var mutex sync.Mutex
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+
+		// Every part fails, only the first one should be sent
+		w.WriteHeader(http.StatusInternalServerError)
+		if _, err := w.Write([]byte(`{"error": {"message": "overloaded", "type": "server_error"}}`)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"deepseek": "d-key"}, server.Client(),
+		map[string]ProviderSettings{"deepseek": {BaseURL: server.URL}})
+
+	var paragraphs []string
+	for i := 0; i < 5; i++ {
+		paragraphs = append(paragraphs, "part "+strings.Repeat("word ", 60))
+	}
+	_, err := service.MapReduce(context.Background(), "Summarize this.", strings.Join(paragraphs, "\n\n"), "deepseek-chat",
+		MapReduceOptions{ChunkTokens: 100, OverlapTokens: 1, Concurrency: 1})
+	if err == nil || !strings.Contains(err.Error(), "error processing part 1") || !strings.Contains(err.Error(), "overloaded") {
+		t.Errorf("Expected the error of part 1, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected no parts sent after the failure, got %d requests", requests)
+	}
+}
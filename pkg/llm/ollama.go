@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// OllamaEmbedder implements the Embedder interface for a local Ollama server
+type OllamaEmbedder struct {
+	httpClient *http.Client
+	baseURL    string
+	versionURL string            // Endpoint answered by any running server
+	headers    map[string]string // Extra headers sent with every request
+}
+
+// ollamaProbeTimeout limits how long Available waits for the server
+const ollamaProbeTimeout = 2 * time.Second
+
+// ollamaEmbedRequest represents a request to the Ollama embed API
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// ollamaEmbedResponse represents a response from the Ollama embed API
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// NewOllamaEmbedder creates a new Ollama embedder, which needs no API key
+func NewOllamaEmbedder(httpClient *http.Client, options ...ProviderOption) *OllamaEmbedder {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	settings := applyProviderOptions(options)
+
+	root := "http://localhost:11434"
+	if settings.BaseURL != "" {
+		root = settings.BaseURL
+	}
+
+	return &OllamaEmbedder{
+		httpClient: httpClient,
+		baseURL:    root + "/api/embed",
+		versionURL: root + "/api/version",
+		headers:    settings.Headers,
+	}
+}
+
+// Available reports whether an Ollama server answers at the configured address
+func (e *OllamaEmbedder) Available(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, ollamaProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.versionURL, nil)
+	if err != nil {
+		return false
+	}
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Just log the error, can't return it here
+			fmt.Printf("Error closing response body: %v\n", err)
+		}
+	}()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Embed implements the Embedder interface
+func (e *OllamaEmbedder) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	req := ollamaEmbedRequest{Model: model, Input: texts}
+	body, err := postEmbedRequest(ctx, e.httpClient, e.baseURL, e.headers, req, func(body []byte) string {
+		var errResp ollamaEmbedResponse
+		if err := json.Unmarshal(body, &errResp); err == nil {
+			return errResp.Error
+		}
+		return ""
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse response
+	var result ollamaEmbedResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	return result.Embeddings, nil
+}
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// OpenAIEmbedder implements the Embedder interface for the OpenAI embeddings
+// API and compatible endpoints
+type OpenAIEmbedder struct {
+	apiKey     string
+	httpClient *http.Client
+	baseURL    string
+	headers    map[string]string // Extra headers sent with every request
+}
+
+// openAIEmbeddingRequest represents a request to the embeddings API
+type openAIEmbeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	EncodingFormat string   `json:"encoding_format"`
+}
+
+// openAIEmbeddingResponse represents a response from the embeddings API
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error,omitempty"`
+}
+
+// NewOpenAIEmbedder creates a new OpenAI embedder
+func NewOpenAIEmbedder(apiKey string, httpClient *http.Client, options ...ProviderOption) *OpenAIEmbedder {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	settings := applyProviderOptions(options)
+
+	baseURL := "https://api.openai.com/v1/embeddings"
+	if settings.BaseURL != "" {
+		baseURL = settings.BaseURL + "/embeddings"
+	}
+
+	return &OpenAIEmbedder{
+		apiKey:     apiKey,
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		headers:    settings.Headers,
+	}
+}
+
+// Embed implements the Embedder interface
+func (e *OpenAIEmbedder) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	headers := map[string]string{"Authorization": "Bearer " + e.apiKey}
+	for name, value := range e.headers {
+		headers[name] = value
+	}
+
+	req := openAIEmbeddingRequest{Model: model, Input: texts, EncodingFormat: "float"}
+	body, err := postEmbedRequest(ctx, e.httpClient, e.baseURL, headers, req, func(body []byte) string {
+		var errResp openAIEmbeddingResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != nil {
+			return fmt.Sprintf("(%s) %s", errResp.Error.Type, errResp.Error.Message)
+		}
+		return ""
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse response
+	var result openAIEmbeddingResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	// Vectors are returned with the index of their input
+	vectors := make([][]float32, len(texts))
+	for _, data := range result.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("unexpected embedding index %d", data.Index)
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("no embedding for input %d", i+1)
+		}
+	}
+
+	return vectors, nil
+}
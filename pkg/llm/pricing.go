@@ -0,0 +1,45 @@
+package llm
+
+// Pricing is the price of a model in US dollars per million tokens
+type Pricing struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// modelPricing lists the standard API prices of each model. Experimental
+// models are free while in preview.
+var modelPricing = map[string]Pricing{
+	"claude-3-7-sonnet-latest": {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"deepseek-coder":           {InputPerMillion: 0.27, OutputPerMillion: 1.10},
+	"deepseek-chat":            {InputPerMillion: 0.27, OutputPerMillion: 1.10},
+	"deepseek-reasoner":        {InputPerMillion: 0.55, OutputPerMillion: 2.19},
+	"gemini-2.5-pro-exp-03-25": {InputPerMillion: 0, OutputPerMillion: 0},
+	"gemini-2.0-flash":         {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"gemini-2.0-flash-lite":    {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-1.5-flash":         {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-1.5-flash-8b":      {InputPerMillion: 0.0375, OutputPerMillion: 0.15},
+}
+
+// EstimateCost returns the cost of token usage with a model in US dollars.
+// Thinking tokens are billed as output. It reports false if the model's
+// pricing is unknown.
+func EstimateCost(model string, usage Usage) (float64, bool) {
+	pricing, ok := modelPricing[model]
+	if !ok {
+		return 0, false
+	}
+
+	input := float64(usage.InputTokens) * pricing.InputPerMillion
+	output := float64(usage.OutputTokens+usage.ThinkingTokens) * pricing.OutputPerMillion
+	return (input + output) / 1e6, true
+}
+
+// batchDiscount is the share of the standard price charged for batch jobs
+const batchDiscount = 0.5
+
+// EstimateBatchCost returns the cost of token usage in a batch job, which
+// providers bill at half the standard price
+func EstimateBatchCost(model string, usage Usage) (float64, bool) {
+	cost, ok := EstimateCost(model, usage)
+	return cost * batchDiscount, ok
+}
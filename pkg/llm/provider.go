@@ -2,6 +2,12 @@ package llm
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 )
 
 // Provider defines the interface for LLM providers
@@ -10,15 +16,221 @@ type Provider interface {
 	Query(ctx context.Context, prompt string, options ...Option) (string, error)
 }
 
+// DetailedProvider is implemented by providers that return more than the response text
+type DetailedProvider interface {
+	Provider
+	// QueryDetailed sends a prompt to the LLM and returns the full response
+	QueryDetailed(ctx context.Context, prompt string, options ...Option) (*Response, error)
+}
+
+// Response is a provider response with everything besides the answer text
+type Response struct {
+	Text          string         // The final answer, from the first candidate
+	Thinking      string         // Reasoning produced before the answer, if requested
+	Usage         Usage          // Token usage reported by the provider
+	FinishReason  string         // Why the model stopped, as reported by the provider
+	SafetyRatings []SafetyRating // Safety ratings of the first candidate, if any
+	Candidates    []Candidate    // All candidates when more than one was requested
+	Warnings      []string       // Problems with the request that did not stop it, e.g. ignored parameters
+	Metadata      Metadata       // Stop reason and details of the request as served
+}
+
+// Stop reasons normalized across providers
+const (
+	StopReasonEndTurn      = "end_turn"       // The model finished its answer
+	StopReasonMaxTokens    = "max_tokens"     // The answer was cut off at the token limit
+	StopReasonStopSequence = "stop_sequence"  // A stop sequence was generated
+	StopReasonContentBlock = "content_filter" // The answer was blocked by safety filters
+	StopReasonToolUse      = "tool_use"       // The model wants to call a tool
+	StopReasonOther        = "other"          // Any other reason reported by the provider
+)
+
+// Metadata describes how a request was served
+type Metadata struct {
+	StopReason   string    // Why the model stopped, one of the StopReason constants
+	RequestID    string    // Provider's ID for the request or response, for support tickets
+	ModelVersion string    // Model version that actually served the request
+	Created      time.Time // When the response was created
+}
+
+// Truncated reports whether the answer was cut off at the token limit
+func (m Metadata) Truncated() bool {
+	return m.StopReason == StopReasonMaxTokens
+}
+
+// Candidate is one of several alternative responses to the same prompt
+type Candidate struct {
+	Text          string
+	FinishReason  string
+	SafetyRatings []SafetyRating
+}
+
+// SafetyRating is a provider's assessment of a harm category for a prompt or response
+type SafetyRating struct {
+	Category    string // e.g. HARM_CATEGORY_HARASSMENT
+	Probability string // e.g. NEGLIGIBLE, LOW, MEDIUM or HIGH
+	Blocked     bool   // Whether content was blocked because of this rating
+}
+
+// PromptBlockedError is returned when the provider refuses to answer because
+// of the prompt itself rather than the generated response
+type PromptBlockedError struct {
+	Reason        string
+	SafetyRatings []SafetyRating
+}
+
+// Error implements the error interface
+func (e *PromptBlockedError) Error() string {
+	var categories []string
+	for _, rating := range e.SafetyRatings {
+		if rating.Blocked {
+			categories = append(categories, rating.Category)
+		}
+	}
+
+	if len(categories) == 0 {
+		return fmt.Sprintf("prompt blocked by the provider (%s)", e.Reason)
+	}
+	return fmt.Sprintf("prompt blocked by the provider (%s): %s", e.Reason, strings.Join(categories, ", "))
+}
+
+// Usage holds token counts for a request. Thinking tokens are not included in OutputTokens.
+type Usage struct {
+	InputTokens    int
+	OutputTokens   int
+	ThinkingTokens int
+}
+
+// Total returns the total number of tokens used
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens + u.ThinkingTokens
+}
+
+// responseTime returns when a response was created according to its Date
+// header, falling back to the current time
+func responseTime(header http.Header) time.Time {
+	if created, err := http.ParseTime(header.Get("Date")); err == nil {
+		return created
+	}
+	return time.Now()
+}
+
+// unknownParamWarnings returns a warning for every custom parameter a provider does not use
+func unknownParamWarnings(provider string, params map[string]interface{}, known ...string) []string {
+	var warnings []string
+	for name := range params {
+		if !slices.Contains(known, name) {
+			warnings = append(warnings, fmt.Sprintf("%s ignores unknown parameter %q", provider, name))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// ignoredOptionWarnings returns warnings for the seed and, unless the provider
+// supports them, the penalties, which are left out of its requests
+func ignoredOptionWarnings(provider string, opts *RequestOptions, penalties bool) []string {
+	var warnings []string
+	if !penalties && opts.PresencePenalty != nil {
+		warnings = append(warnings, fmt.Sprintf("%s does not support presence_penalty and it was not sent", provider))
+	}
+	if !penalties && opts.FrequencyPenalty != nil {
+		warnings = append(warnings, fmt.Sprintf("%s does not support frequency_penalty and it was not sent", provider))
+	}
+	if opts.Seed != nil {
+		warnings = append(warnings, fmt.Sprintf("%s does not support seed and it was not sent", provider))
+	}
+	return warnings
+}
+
+// checkSingleCandidate returns an InvalidOptionError when several candidates
+// are requested from a model that only returns one response
+func checkSingleCandidate(opts *RequestOptions) error {
+	if opts.CandidateCount > 1 {
+		return invalidOption("%s returns a single response, %d candidates are not supported", opts.Model, opts.CandidateCount)
+	}
+	return nil
+}
+
+// queryDetailed queries a provider, wrapping plain text responses for providers
+// that do not implement DetailedProvider
+func queryDetailed(ctx context.Context, provider Provider, prompt string, options ...Option) (*Response, error) {
+	if detailed, ok := provider.(DetailedProvider); ok {
+		return detailed.QueryDetailed(ctx, prompt, options...)
+	}
+
+	text, err := provider.Query(ctx, prompt, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Text: text}, nil
+}
+
 // Option is a functional option for configuring LLM requests
 type Option func(*RequestOptions)
 
+// InvalidOptionError reports request options that a provider or model does
+// not accept, a mistake of the caller rather than a failure of the provider
+type InvalidOptionError struct {
+	Message string
+}
+
+// Error implements the error interface
+func (e *InvalidOptionError) Error() string {
+	return e.Message
+}
+
+// invalidOption returns an InvalidOptionError with a formatted message
+func invalidOption(format string, args ...interface{}) error {
+	return &InvalidOptionError{Message: fmt.Sprintf(format, args...)}
+}
+
+// checkTemperature returns an InvalidOptionError for a temperature outside
+// the range a provider accepts
+func checkTemperature(opts *RequestOptions, max float64) error {
+	if opts.Temperature < 0 || opts.Temperature > max {
+		return invalidOption("temperature must be between 0 and %g for %s, got %g", max, opts.Model, opts.Temperature)
+	}
+	return nil
+}
+
 // RequestOptions contains configuration for an LLM request
 type RequestOptions struct {
 	Model       string
 	MaxTokens   int
 	Temperature float64
 
+	// StopSequences are sequences that stop generation when produced
+	StopSequences []string
+
+	// Prefill is the start of the assistant's answer, which the model
+	// continues. Returned text includes it.
+	Prefill string
+
+	// Generation settings, currently honored by the Google provider
+	SafetySettings   map[string]string // Harm category to block threshold
+	PresencePenalty  *float64          // Penalty for tokens already present in the output
+	FrequencyPenalty *float64          // Penalty scaled by how often tokens were used
+	Seed             *int64            // Seed for reproducible sampling
+	ResponseMIMEType string            // MIME type of the response, e.g. application/json
+
+	// CandidateCount is the number of alternative responses to generate, 0 for the provider default
+	CandidateCount int
+
+	// History holds earlier turns of the conversation, oldest first
+	History []Message
+
+	// temperatureSet records whether the caller chose a temperature
+	temperatureSet bool
+
+	// ThinkingBudget is the number of tokens the model may spend reasoning
+	// before answering, 0 disables extended thinking
+	ThinkingBudget int
+
+	// AutoContinue is the number of times Service asks the model to carry on
+	// with a response cut off at the token limit, 0 disables it
+	AutoContinue int
+
 	// Provider-specific parameters stored as key-value pairs
 	CustomParams map[string]interface{}
 }
@@ -41,9 +253,108 @@ func WithMaxTokens(maxTokens int) Option {
 func WithTemperature(temperature float64) Option {
 	return func(o *RequestOptions) {
 		o.Temperature = temperature
+		o.temperatureSet = true
+	}
+}
+
+// Message is a single turn of a conversation
+type Message struct {
+	Role      string // "user" or "assistant"
+	Content   string
+	Reasoning string // Reasoning behind an assistant turn, never sent back to the provider
+}
+
+// WithHistory adds earlier turns of the conversation before the prompt
+func WithHistory(messages []Message) Option {
+	return func(o *RequestOptions) {
+		o.History = append(o.History, messages...)
+	}
+}
+
+// WithSafetySetting sets the block threshold for a harm category, e.g.
+// ("HARM_CATEGORY_HARASSMENT", "BLOCK_ONLY_HIGH"). Short names such as
+// "harassment" are accepted.
+func WithSafetySetting(category, threshold string) Option {
+	return func(o *RequestOptions) {
+		if o.SafetySettings == nil {
+			o.SafetySettings = make(map[string]string)
+		}
+		o.SafetySettings[category] = threshold
+	}
+}
+
+// WithStopSequences sets sequences that stop generation when produced
+func WithStopSequences(sequences ...string) Option {
+	return func(o *RequestOptions) {
+		o.StopSequences = sequences
+	}
+}
+
+// WithPresencePenalty penalizes tokens that already appear in the output
+func WithPresencePenalty(penalty float64) Option {
+	return func(o *RequestOptions) {
+		o.PresencePenalty = &penalty
+	}
+}
+
+// WithFrequencyPenalty penalizes tokens by how often they appear in the output
+func WithFrequencyPenalty(penalty float64) Option {
+	return func(o *RequestOptions) {
+		o.FrequencyPenalty = &penalty
+	}
+}
+
+// WithSeed sets the sampling seed for reproducible responses
+func WithSeed(seed int64) Option {
+	return func(o *RequestOptions) {
+		o.Seed = &seed
+	}
+}
+
+// WithResponseMIMEType sets the MIME type of the response, e.g. application/json
+func WithResponseMIMEType(mimeType string) Option {
+	return func(o *RequestOptions) {
+		o.ResponseMIMEType = mimeType
+	}
+}
+
+// WithCandidateCount requests several alternative responses, returned in Response.Candidates
+func WithCandidateCount(count int) Option {
+	return func(o *RequestOptions) {
+		o.CandidateCount = count
 	}
 }
 
+// WithThinking enables extended thinking with a budget of reasoning tokens.
+// The budget is spent in addition to the max tokens for the answer.
+func WithThinking(budgetTokens int) Option {
+	return func(o *RequestOptions) {
+		o.ThinkingBudget = budgetTokens
+	}
+}
+
+// WithAutoContinue makes Service continue responses cut off at the token
+// limit up to n times, stitching the parts into a single response
+func WithAutoContinue(n int) Option {
+	return func(o *RequestOptions) {
+		o.AutoContinue = n
+	}
+}
+
+// WithPrefill starts the assistant's answer with the given text, e.g. "{" to
+// get JSON. The model continues from it and the returned text includes it.
+func WithPrefill(text string) Option {
+	return func(o *RequestOptions) {
+		o.Prefill = text
+	}
+}
+
+// sentPrefill returns the prefill sent to a provider. Anthropic rejects
+// prefills ending in whitespace, so it is trimmed for every provider alike.
+func sentPrefill(opts *RequestOptions) string {
+	return strings.TrimRight(opts.Prefill, " \t\r\n")
+}
+
 // WithCustomParam sets a provider-specific parameter
 func WithCustomParam(key string, value interface{}) Option {
 	return func(o *RequestOptions) {
@@ -53,3 +364,56 @@ func WithCustomParam(key string, value interface{}) Option {
 		o.CustomParams[key] = value
 	}
 }
+
+// ProviderSettings holds connection settings for a provider
+type ProviderSettings struct {
+	BaseURL    string            // API root replacing the provider's default endpoint
+	Headers    map[string]string // Extra HTTP headers sent with every request
+	HTTPClient *http.Client      // Client used for this provider, nil to use the service client. Without a timeout it gets the service client's.
+	Defaults   []Option          // Request options applied before the options of each call
+}
+
+// ProviderOption is a functional option for configuring a provider at construction
+type ProviderOption func(*ProviderSettings)
+
+// WithBaseURL sets the API root used by a provider, e.g. an internal API gateway.
+// For Anthropic it replaces https://api.anthropic.com, for Deepseek
+// https://api.deepseek.com/v1 and for Google the Generative Language endpoint.
+func WithBaseURL(baseURL string) ProviderOption {
+	return func(s *ProviderSettings) {
+		s.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHeaders sets extra HTTP headers sent with every request
+func WithHeaders(headers map[string]string) ProviderOption {
+	return func(s *ProviderSettings) {
+		if s.Headers == nil {
+			s.Headers = make(map[string]string)
+		}
+		for name, value := range headers {
+			s.Headers[name] = value
+		}
+	}
+}
+
+// options converts the settings into provider options
+func (s ProviderSettings) options() []ProviderOption {
+	var options []ProviderOption
+	if s.BaseURL != "" {
+		options = append(options, WithBaseURL(s.BaseURL))
+	}
+	if len(s.Headers) > 0 {
+		options = append(options, WithHeaders(s.Headers))
+	}
+	return options
+}
+
+// applyProviderOptions collects provider options into settings
+func applyProviderOptions(options []ProviderOption) ProviderSettings {
+	var settings ProviderSettings
+	for _, option := range options {
+		option(&settings)
+	}
+	return settings
+}
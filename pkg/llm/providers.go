@@ -4,6 +4,19 @@ package llm
 type ProviderModel struct {
 	// Models supported by this provider
 	Models []string
+
+	// EmbeddingModels turn text into vectors, the first is the default
+	EmbeddingModels []EmbeddingModel
+
+	// Keyless providers, such as a local server, need no API key
+	Keyless bool
+}
+
+// EmbeddingModel describes a model that turns text into vectors
+type EmbeddingModel struct {
+	Name       string
+	Dimensions int // Length of the vectors
+	MaxBatch   int // Most texts embedded in one request
 }
 
 // SupportedProviders maps provider names to their information
@@ -17,6 +30,7 @@ var SupportedProviders = map[string]ProviderModel{
 		Models: []string{
 			"deepseek-coder",
 			"deepseek-chat",
+			"deepseek-reasoner",
 		},
 	},
 	"google": {
@@ -27,29 +41,111 @@ var SupportedProviders = map[string]ProviderModel{
 			"gemini-1.5-flash",
 			"gemini-1.5-flash-8b",
 		},
+		EmbeddingModels: []EmbeddingModel{
+			{Name: "text-embedding-004", Dimensions: 768, MaxBatch: 100},
+		},
 	},
 	// Add more providers here
 }
 
+// EmbeddingProviders maps providers only used for embeddings to their
+// information, kept apart from the chat providers above
+var EmbeddingProviders = map[string]ProviderModel{
+	// Ollama runs models locally
+	"ollama": {
+		EmbeddingModels: []EmbeddingModel{
+			{Name: "nomic-embed-text", Dimensions: 768, MaxBatch: 512},
+			{Name: "mxbai-embed-large", Dimensions: 1024, MaxBatch: 512},
+			{Name: "all-minilm", Dimensions: 384, MaxBatch: 512},
+		},
+		Keyless: true,
+	},
+	// OpenAI and compatible APIs
+	"openai": {
+		EmbeddingModels: []EmbeddingModel{
+			{Name: "text-embedding-3-small", Dimensions: 1536, MaxBatch: 2048},
+			{Name: "text-embedding-3-large", Dimensions: 3072, MaxBatch: 2048},
+		},
+	},
+}
+
+// reasoningModels lists models that always reason before answering and do not
+// accept sampling parameters such as temperature and top_p
+var reasoningModels = map[string]bool{
+	"deepseek-reasoner": true,
+}
+
+// prefillProviders lists providers that continue a trailing assistant message,
+// which lets a truncated response be continued seamlessly
+var prefillProviders = map[string]bool{
+	"anthropic": true,
+}
+
 // ModelToProvider maps from model name to provider name (generated at init)
 var ModelToProvider map[string]string
 
+// EmbeddingModelToProvider maps from embedding model name to provider name (generated at init)
+var EmbeddingModelToProvider map[string]string
+
 func init() {
-	// Build model to provider map
+	// Build model to provider maps
 	ModelToProvider = make(map[string]string)
+	EmbeddingModelToProvider = make(map[string]string)
 	for provider, info := range SupportedProviders {
 		for _, model := range info.Models {
 			ModelToProvider[model] = provider
 		}
+		for _, model := range info.EmbeddingModels {
+			EmbeddingModelToProvider[model.Name] = provider
+		}
+	}
+	for provider, info := range EmbeddingProviders {
+		for _, model := range info.EmbeddingModels {
+			EmbeddingModelToProvider[model.Name] = provider
+		}
 	}
 }
 
-// IsValidProvider checks if a provider is supported
+// IsValidProvider checks if a chat provider is supported
 func IsValidProvider(provider string) bool {
 	_, ok := SupportedProviders[provider]
 	return ok
 }
 
+// UsesAPIKey reports whether a chat or embedding provider is supported and
+// needs an API key
+func UsesAPIKey(provider string) bool {
+	info, ok := lookupProvider(provider)
+	return ok && !info.Keyless
+}
+
+// AllProviders returns the names of the chat and embedding providers
+func AllProviders() []string {
+	providers := make([]string, 0, len(SupportedProviders)+len(EmbeddingProviders))
+	for provider := range SupportedProviders {
+		providers = append(providers, provider)
+	}
+	for provider := range EmbeddingProviders {
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// EmbeddingModelsForProvider returns the embedding models of a chat or embedding provider
+func EmbeddingModelsForProvider(provider string) []EmbeddingModel {
+	info, _ := lookupProvider(provider)
+	return info.EmbeddingModels
+}
+
+// lookupProvider returns the information of a chat or embedding provider
+func lookupProvider(provider string) (ProviderModel, bool) {
+	if info, ok := SupportedProviders[provider]; ok {
+		return info, true
+	}
+	info, ok := EmbeddingProviders[provider]
+	return info, ok
+}
+
 // GetModelsForProvider returns the models for a provider
 func GetModelsForProvider(provider string) []string {
 	if info, ok := SupportedProviders[provider]; ok {
@@ -69,3 +165,23 @@ func IsValidModel(model string) bool {
 	_, ok := ModelToProvider[model]
 	return ok
 }
+
+// SupportsSampling reports whether a model accepts temperature and top_p
+func SupportsSampling(model string) bool {
+	return !reasoningModels[model]
+}
+
+// GetEmbeddingModel returns an embedding model and its provider
+func GetEmbeddingModel(model string) (EmbeddingModel, string, bool) {
+	provider, ok := EmbeddingModelToProvider[model]
+	if !ok {
+		return EmbeddingModel{}, "", false
+	}
+	providerInfo, _ := lookupProvider(provider)
+	for _, info := range providerInfo.EmbeddingModels {
+		if info.Name == model {
+			return info, provider, true
+		}
+	}
+	return EmbeddingModel{}, "", false
+}
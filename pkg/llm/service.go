@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +16,9 @@ import (
 // ProviderResponse represents a response from a provider along with metadata
 type ProviderResponse struct {
 	Response    string        // The text response from the provider
+	Thinking    string        // Reasoning produced before the response, if requested
+	Usage       Usage         // Token usage reported by the provider
+	Metadata    Metadata      // Stop reason, request ID and served model version
 	Model       string        // The model used for the response
 	Provider    string        // The provider name
 	Error       error         // Error, if any occurred during the query
@@ -23,37 +28,97 @@ type ProviderResponse struct {
 // Service manages LLM providers
 type Service struct {
 	providers  map[string]Provider
+	embedders  map[string]Embedder
+	defaults   map[string][]Option // Per-provider request options applied before each call's options
 	httpClient *http.Client
 	logger     *logger.Logger // Optional query logger
 }
 
 // NewService creates a new LLM service with API keys and an optional HTTP client
 func NewService(apiKeys map[string]string, httpClient *http.Client) *Service {
+	return NewServiceWithSettings(apiKeys, httpClient, nil)
+}
+
+// NewServiceWithSettings creates a new LLM service with API keys, an optional
+// HTTP client and per-provider connection settings
+func NewServiceWithSettings(apiKeys map[string]string, httpClient *http.Client, settings map[string]ProviderSettings) *Service {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
 	}
 
 	service := &Service{
 		providers:  make(map[string]Provider),
+		embedders:  make(map[string]Embedder),
+		defaults:   make(map[string][]Option),
 		httpClient: httpClient,
 	}
 
+	// providerClient returns the HTTP client for a provider, preferring its
+	// own settings. A provider client without a timeout, e.g. one only set up
+	// for a proxy, keeps the timeout of the service client.
+	providerClient := func(provider string) *http.Client {
+		client := settings[provider].HTTPClient
+		if client == nil {
+			return httpClient
+		}
+		if client.Timeout == 0 && httpClient.Timeout > 0 {
+			withTimeout := *client
+			withTimeout.Timeout = httpClient.Timeout
+			return &withTimeout
+		}
+		return client
+	}
+
 	// Initialize providers with their respective API keys
 	if anthropicKey, ok := apiKeys["anthropic"]; ok && anthropicKey != "" {
-		service.providers["anthropic"] = NewAnthropicProvider(anthropicKey, httpClient)
+		service.providers["anthropic"] = NewAnthropicProvider(anthropicKey, providerClient("anthropic"), settings["anthropic"].options()...)
 	}
 
 	if deepseekKey, ok := apiKeys["deepseek"]; ok && deepseekKey != "" {
-		service.providers["deepseek"] = NewDeepseekProvider(deepseekKey, httpClient)
+		service.providers["deepseek"] = NewDeepseekProvider(deepseekKey, providerClient("deepseek"), settings["deepseek"].options()...)
 	}
 
 	if googleKey, ok := apiKeys["google"]; ok && googleKey != "" {
-		service.providers["google"] = NewGoogleProvider(googleKey, httpClient)
+		google := NewGoogleProvider(googleKey, providerClient("google"), settings["google"].options()...)
+		service.providers["google"] = google
+		service.embedders["google"] = google
+	}
+
+	// Embedding-only providers, Ollama runs locally without a key
+	if openaiKey, ok := apiKeys["openai"]; ok && openaiKey != "" {
+		service.embedders["openai"] = NewOpenAIEmbedder(openaiKey, providerClient("openai"), settings["openai"].options()...)
+	}
+	service.embedders["ollama"] = NewOllamaEmbedder(providerClient("ollama"), settings["ollama"].options()...)
+
+	// Remember per-provider request defaults such as Gemini safety settings
+	for provider, providerSettings := range settings {
+		if len(providerSettings.Defaults) > 0 {
+			service.defaults[provider] = providerSettings.Defaults
+		}
 	}
 
 	return service
 }
 
+// providerOptions returns the options for a query to a provider: the model,
+// then the provider's defaults, then the options of the call
+func (s *Service) providerOptions(providerName, model string, options []Option) []Option {
+	providerOptions := make([]Option, 0, len(options)+len(s.defaults[providerName])+1)
+	providerOptions = append(providerOptions, WithModel(model))
+	providerOptions = append(providerOptions, s.defaults[providerName]...)
+	return append(providerOptions, options...)
+}
+
+// ConfiguredProviders returns the names of the providers with an API key, sorted
+func (s *Service) ConfiguredProviders() []string {
+	providers := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
 // SetLogger sets the query logger for the service
 func (s *Service) SetLogger(l *logger.Logger) {
 	s.logger = l
@@ -61,9 +126,19 @@ func (s *Service) SetLogger(l *logger.Logger) {
 
 // QueryWithTiming sends a prompt to the model and returns the response with timing information
 func (s *Service) QueryWithTiming(ctx context.Context, prompt, modelName string, options ...Option) (string, time.Duration, error) {
+	response, elapsedTime, err := s.QueryDetailed(ctx, prompt, modelName, options...)
+	if err != nil {
+		return "", elapsedTime, err
+	}
+	return response.Text, elapsedTime, nil
+}
+
+// QueryDetailed sends a prompt to the model and returns the full response,
+// including thinking content and token usage, with timing information
+func (s *Service) QueryDetailed(ctx context.Context, prompt, modelName string, options ...Option) (*Response, time.Duration, error) {
 	// Validate model
 	if !IsValidModel(modelName) {
-		return "", 0, fmt.Errorf("unknown model: %s", modelName)
+		return nil, 0, fmt.Errorf("unknown model: %s", modelName)
 	}
 
 	// Determine provider based on model name
@@ -72,11 +147,22 @@ func (s *Service) QueryWithTiming(ctx context.Context, prompt, modelName string,
 	// Get provider
 	provider, ok := s.providers[providerName]
 	if !ok {
-		return "", 0, fmt.Errorf("provider %s not configured", providerName)
+		return nil, 0, fmt.Errorf("provider %s not configured", providerName)
 	}
 
-	// Add model to options
-	options = append([]Option{WithModel(modelName)}, options...)
+	// Add model and provider defaults to options
+	options = s.providerOptions(providerName, modelName, options)
+
+	// Only Anthropic models think on request, others would silently skip it
+	opts := &RequestOptions{}
+	for _, option := range options {
+		if option != nil {
+			option(opts)
+		}
+	}
+	if opts.ThinkingBudget > 0 && providerName != "anthropic" {
+		return nil, 0, invalidOption("extended thinking is not supported by %s", modelName)
+	}
 
 	// Extract temperature for logging
 	temperature := 0.7 // default
@@ -94,18 +180,18 @@ func (s *Service) QueryWithTiming(ctx context.Context, prompt, modelName string,
 	// Start the timer
 	startTime := time.Now()
 
-	// Query the provider
-	response, err := provider.Query(ctx, prompt, options...)
+	// Query the provider, continuing truncated responses if requested
+	response, err := s.queryProvider(ctx, providerName, provider, prompt, options)
 
 	// Calculate elapsed time
 	elapsedTime := time.Since(startTime)
 
-	// Log query if logger is configured
+	// Log query if logger is configured, continuations included
 	if err == nil && s.logger != nil {
 		// Only log successful queries
 		// Use a goroutine to avoid blocking the response
 		go func() {
-			if logErr := s.logger.LogQuery(prompt, modelName, response, elapsedTime, temperature); logErr != nil {
+			if logErr := s.logger.LogQueryWithMetadata(prompt, modelName, response.Text, elapsedTime, temperature, loggedMetadata(response.Metadata)); logErr != nil {
 				// Just print the error but don't fail the request
 				fmt.Fprintf(os.Stderr, "Failed to log query: %v\n", logErr)
 			}
@@ -115,12 +201,140 @@ func (s *Service) QueryWithTiming(ctx context.Context, prompt, modelName string,
 	return response, elapsedTime, err
 }
 
+// ContinuePrompt asks a model to carry on with a response cut off at the token limit
+const ContinuePrompt = "Continue exactly where you stopped, without repeating anything."
+
+// queryProvider queries a provider and, when auto-continue is enabled, asks
+// it to continue a response cut off at the token limit until it finishes or
+// the continuation limit is reached. The parts are stitched into one response.
+func (s *Service) queryProvider(ctx context.Context, providerName string, provider Provider, prompt string, options []Option) (*Response, error) {
+	opts := &RequestOptions{}
+	for _, option := range options {
+		if option != nil {
+			option(opts)
+		}
+	}
+
+	// Fail early on requests too large for the model instead of after sending
+	// them, asking the provider for the count when the estimate is close
+	tokens, exact := requestTokens(ctx, provider, prompt, opts, options)
+	warning, err := checkContextWindow(tokens, exact, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := queryDetailed(ctx, provider, prompt, options...)
+	if err != nil {
+		return nil, err
+	}
+	if warning != "" {
+		response.Warnings = append(response.Warnings, warning)
+	}
+
+	// Alternative candidates cannot be continued as one response
+	if opts.CandidateCount > 1 {
+		return response, nil
+	}
+
+	for i := 0; i < opts.AutoContinue && response.Metadata.Truncated(); i++ {
+		var continued *Response
+		if prefillProviders[providerName] && opts.ThinkingBudget == 0 {
+			// Replay the prompt with the partial answer as the start of the
+			// assistant turn, the returned text includes it
+			continued, err = queryDetailed(ctx, provider, prompt, append(options, WithPrefill(response.Text))...)
+			if err != nil {
+				return nil, fmt.Errorf("error continuing response: %w", err)
+			}
+		} else {
+			// Send the partial answer back as an assistant turn and ask for the rest
+			history := WithHistory([]Message{
+				{Role: "user", Content: prompt},
+				{Role: "assistant", Content: response.Text},
+			})
+			continued, err = queryDetailed(ctx, provider, ContinuePrompt, append(options, history, withoutPrefill())...)
+			if err != nil {
+				return nil, fmt.Errorf("error continuing response: %w", err)
+			}
+			continued.Text = response.Text + continued.Text
+		}
+
+		response = stitchResponses(response, continued)
+	}
+
+	return response, nil
+}
+
+// withoutPrefill drops the prefill from a continuation, as the partial
+// answer sent back already starts with it
+func withoutPrefill() Option {
+	return func(o *RequestOptions) {
+		o.Prefill = ""
+	}
+}
+
+// stitchResponses combines a response with its continuation, whose text
+// already includes the earlier part. Usage is summed and the metadata of the
+// continuation describes how the combined response ended.
+func stitchResponses(response, continued *Response) *Response {
+	stitched := *continued
+	stitched.Thinking = strings.TrimSpace(response.Thinking + "\n\n" + continued.Thinking)
+	stitched.Usage = Usage{
+		InputTokens:    response.Usage.InputTokens + continued.Usage.InputTokens,
+		OutputTokens:   response.Usage.OutputTokens + continued.Usage.OutputTokens,
+		ThinkingTokens: response.Usage.ThinkingTokens + continued.Usage.ThinkingTokens,
+	}
+	stitched.Warnings = response.Warnings
+	return &stitched
+}
+
+// loggedMetadata converts response metadata for the query logger
+func loggedMetadata(metadata Metadata) logger.Metadata {
+	return logger.Metadata{
+		StopReason:   metadata.StopReason,
+		RequestID:    metadata.RequestID,
+		ModelVersion: metadata.ModelVersion,
+		Created:      metadata.Created,
+	}
+}
+
 // Query sends a prompt to the model using the appropriate provider
 func (s *Service) Query(ctx context.Context, prompt, modelName string, options ...Option) (string, error) {
 	response, _, err := s.QueryWithTiming(ctx, prompt, modelName, options...)
 	return response, err
 }
 
+// CountTokens returns the input tokens a prompt would use with a model. The
+// provider counts them when it is configured and supports counting, otherwise
+// they are estimated locally.
+func (s *Service) CountTokens(ctx context.Context, prompt, modelName string, options ...Option) (TokenCount, error) {
+	// Validate model
+	if !IsValidModel(modelName) {
+		return TokenCount{}, fmt.Errorf("unknown model: %s", modelName)
+	}
+
+	count := TokenCount{Model: modelName, ContextWindow: ContextWindow(modelName)}
+
+	providerName, _ := GetProviderForModel(modelName)
+	options = s.providerOptions(providerName, modelName, options)
+
+	if counter, ok := s.providers[providerName].(TokenCounter); ok {
+		tokens, err := counter.CountTokens(ctx, prompt, options...)
+		if err != nil {
+			return count, err
+		}
+		count.Tokens = tokens
+		count.Exact = true
+		return count, nil
+	}
+
+	opts := &RequestOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	count.Tokens = estimateRequestTokens(prompt, opts)
+	return count, nil
+}
+
 // GetDefaultModelForProvider returns the first (default) model for a provider
 func GetDefaultModelForProvider(providerName string) (string, error) {
 	models := GetModelsForProvider(providerName)
@@ -159,16 +373,17 @@ func (s *Service) QueryAll(ctx context.Context, prompt string, options ...Option
 				return
 			}
 
-			// Create a copy of options and add the model
-			providerOptions := make([]Option, len(options)+1)
-			providerOptions[0] = WithModel(defaultModel)
-			copy(providerOptions[1:], options)
+			// Add the model and provider defaults to the options
+			providerOptions := s.providerOptions(providerName, defaultModel, options)
 
 			// Start the timer
 			startTime := time.Now()
 
-			// Query the provider
-			response, err := provider.Query(ctx, prompt, providerOptions...)
+			// Query the provider, continuing truncated responses if requested
+			response, err := s.queryProvider(ctx, providerName, provider, prompt, providerOptions)
+			if response == nil {
+				response = &Response{}
+			}
 
 			// Calculate elapsed time
 			elapsedTime := time.Since(startTime)
@@ -176,7 +391,10 @@ func (s *Service) QueryAll(ctx context.Context, prompt string, options ...Option
 			// Store the result
 			resultsMutex.Lock()
 			results[providerName] = ProviderResponse{
-				Response:    response,
+				Response:    response.Text,
+				Thinking:    response.Thinking,
+				Usage:       response.Usage,
+				Metadata:    response.Metadata,
 				Model:       defaultModel,
 				Provider:    providerName,
 				Error:       err,
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 // setupAnthropicMockServer creates a mock server for Anthropic API
@@ -272,6 +274,11 @@ func TestNewService(t *testing.T) {
 	if service.httpClient == nil {
 		t.Error("Expected HTTP client to be initialized")
 	}
+
+	// Configured providers are listed in order
+	if providers := service.ConfiguredProviders(); len(providers) != 2 || providers[0] != "anthropic" || providers[1] != "deepseek" {
+		t.Errorf("Expected anthropic and deepseek, got %v", providers)
+	}
 }
 
 // TestNewServiceWithMissingKeys tests service creation with missing API keys
@@ -362,3 +369,244 @@ func TestGetDefaultModelForProvider(t *testing.T) {
 		}
 	})
 }
+
+// TestNewServiceWithSettings tests that per-provider base URLs and headers are applied
+func TestNewServiceWithSettings(t *testing.T) {
+	var anthropicPath, anthropicHeader, deepseekPath string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.URL.Path, "/anthropic") {
+			anthropicPath = r.URL.Path
+			anthropicHeader = r.Header.Get("X-Team")
+			if err := json.NewEncoder(w).Encode(anthropicResponse{
+				Content: []anthropicContentBlock{{Type: "text", Text: "anthropic"}},
+			}); err != nil {
+				t.Errorf("Failed to encode response: %v", err)
+			}
+			return
+		}
+
+		deepseekPath = r.URL.Path
+		if err := json.NewEncoder(w).Encode(deepseekResponse{
+			Choices: []deepseekChoice{{Message: deepseekMessage{Content: "deepseek"}}},
+		}); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	service := NewServiceWithSettings(
+		map[string]string{"anthropic": "a-key", "deepseek": "d-key"},
+		server.Client(),
+		map[string]ProviderSettings{
+			"anthropic": {BaseURL: server.URL + "/anthropic", Headers: map[string]string{"X-Team": "platform"}},
+			"deepseek":  {BaseURL: server.URL + "/deepseek/v1"},
+		},
+	)
+
+	if _, err := service.Query(context.Background(), "Test prompt", "claude-3-7-sonnet-latest"); err != nil {
+		t.Fatalf("Anthropic query returned error: %v", err)
+	}
+	if _, err := service.Query(context.Background(), "Test prompt", "deepseek-chat"); err != nil {
+		t.Fatalf("Deepseek query returned error: %v", err)
+	}
+
+	if anthropicPath != "/anthropic/v1/messages" {
+		t.Errorf("Expected Anthropic path /anthropic/v1/messages, got %q", anthropicPath)
+	}
+	if anthropicHeader != "platform" {
+		t.Errorf("Expected X-Team header 'platform', got %q", anthropicHeader)
+	}
+	if deepseekPath != "/deepseek/v1/chat/completions" {
+		t.Errorf("Expected Deepseek path /deepseek/v1/chat/completions, got %q", deepseekPath)
+	}
+}
+
+func TestProviderClientTimeout(t *testing.T) {
+	proxied := &http.Client{Transport: http.DefaultTransport}
+	service := NewServiceWithSettings(
+		map[string]string{"anthropic": "a-key", "deepseek": "d-key"},
+		&http.Client{Timeout: 30 * time.Second},
+		map[string]ProviderSettings{
+			"anthropic": {HTTPClient: proxied},
+			"deepseek":  {HTTPClient: &http.Client{Timeout: 5 * time.Minute}},
+		},
+	)
+
+	// A client only set up for a proxy keeps the service timeout, not none at all
+	anthropic := service.providers["anthropic"].(*AnthropicProvider)
+	if anthropic.httpClient.Timeout != 30*time.Second || anthropic.httpClient.Transport != http.DefaultTransport {
+		t.Errorf("Expected the proxy client with the service timeout, got %+v", anthropic.httpClient)
+	}
+	if proxied.Timeout != 0 {
+		t.Errorf("Expected the provider settings to be left alone, got timeout %v", proxied.Timeout)
+	}
+
+	// A configured timeout wins
+	if deepseek := service.providers["deepseek"].(*DeepseekProvider); deepseek.httpClient.Timeout != 5*time.Minute {
+		t.Errorf("Expected the provider timeout of 5m, got %v", deepseek.httpClient.Timeout)
+	}
+}
+
+// TestServiceAutoContinueWithPrefill tests that Anthropic continuations prefill the partial answer
+func TestServiceAutoContinueWithPrefill(t *testing.T) {
+	var requests []anthropicRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		requests = append(requests, req)
+
+		// The first two parts are cut off, the third one finishes
+		response := anthropicResponse{
+			Content:    []anthropicContentBlock{{Type: "text", Text: []string{"func main() {", " fmt.Println(", "\"hi\") }"}[len(requests)-1]}},
+			StopReason: "max_tokens",
+			Usage:      anthropicUsage{InputTokens: 10, OutputTokens: 5},
+		}
+		if len(requests) == 3 {
+			response.StopReason = "end_turn"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"anthropic": "a-key"}, server.Client(),
+		map[string]ProviderSettings{"anthropic": {BaseURL: server.URL}})
+
+	response, _, err := service.QueryDetailed(context.Background(), "Write a program", "claude-3-7-sonnet-latest", WithAutoContinue(3))
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(requests))
+	}
+	if response.Text != "func main() { fmt.Println(\"hi\") }" {
+		t.Errorf("Expected stitched program, got %q", response.Text)
+	}
+	if response.Metadata.Truncated() {
+		t.Error("Expected the stitched response not to be truncated")
+	}
+	if response.Usage.InputTokens != 30 || response.Usage.OutputTokens != 15 {
+		t.Errorf("Expected usage summed over 3 requests, got %+v", response.Usage)
+	}
+
+	// The last request prefills everything generated so far
+	last := requests[2].Messages
+	if len(last) != 2 || last[1].Role != "assistant" || last[1].Content != "func main() { fmt.Println(" {
+		t.Errorf("Expected the partial answer as assistant prefill, got %+v", last)
+	}
+}
+
+// TestServiceAutoContinueWithHistory tests continuations through an assistant turn and a limit on them
+func TestServiceAutoContinueWithHistory(t *testing.T) {
+	var requests []deepseekRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req deepseekRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		requests = append(requests, req)
+
+		// Every part is cut off, so only the limit stops the continuations
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(deepseekResponse{
+			Choices: []deepseekChoice{{Message: deepseekMessage{Content: "part "}, FinishReason: "length"}},
+		}); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"deepseek": "d-key"}, server.Client(),
+		map[string]ProviderSettings{"deepseek": {BaseURL: server.URL}})
+
+	response, _, err := service.QueryDetailed(context.Background(), "Write a long text", "deepseek-chat", WithAutoContinue(2))
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(requests))
+	}
+	if response.Text != "part part part " {
+		t.Errorf("Expected stitched text, got %q", response.Text)
+	}
+	if !response.Metadata.Truncated() {
+		t.Error("Expected the response to stay truncated after the continuation limit")
+	}
+
+	// Continuations replay the prompt and the partial answer before asking to continue
+	messages := requests[2].Messages
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %+v", messages)
+	}
+	if messages[0].Content != "Write a long text" || messages[1].Role != "assistant" || messages[1].Content != "part part " || messages[2].Content != ContinuePrompt {
+		t.Errorf("Expected prompt, partial answer and continue prompt, got %+v", messages)
+	}
+}
+
+// TestServiceAutoContinueWithPrefillAndHistory tests that a prefill is sent
+// once and included once when a Deepseek answer is continued
+func TestServiceAutoContinueWithPrefillAndHistory(t *testing.T) {
+	var requests []deepseekRequest
+	var paths []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req deepseekRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		requests = append(requests, req)
+		paths = append(paths, r.URL.Path)
+
+		// The first part is cut off, the second one finishes
+		response := deepseekResponse{
+			Choices: []deepseekChoice{{Message: deepseekMessage{Content: []string{`"a": 1,`, ` "b": 2}`}[len(requests)-1]}, FinishReason: "length"}},
+		}
+		if len(requests) == 2 {
+			response.Choices[0].FinishReason = "stop"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"deepseek": "d-key"}, server.Client(),
+		map[string]ProviderSettings{"deepseek": {BaseURL: server.URL + "/v1"}})
+
+	response, _, err := service.QueryDetailed(context.Background(), "Answer in JSON", "deepseek-chat", WithPrefill("{"), WithAutoContinue(1))
+	if err != nil {
+		t.Fatalf("QueryDetailed returned error: %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	if response.Text != `{"a": 1, "b": 2}` {
+		t.Errorf("Expected the prefill once at the start, got %q", response.Text)
+	}
+	if paths[0] != "/beta/chat/completions" || paths[1] != "/v1/chat/completions" {
+		t.Errorf("Expected the prefilled request on the beta endpoint and the continuation on v1, got %v", paths)
+	}
+
+	// The continuation sends the partial answer, prefill included, but no prefix message
+	messages := requests[1].Messages
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %+v", messages)
+	}
+	if messages[1].Content != `{"a": 1,` || messages[2].Role != "user" || messages[2].Content != ContinuePrompt {
+		t.Errorf("Expected prompt, partial answer and continue prompt, got %+v", messages)
+	}
+}
@@ -0,0 +1,181 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// TokenCounter is implemented by providers that can count the tokens of a
+// request exactly, using the provider's own tokenizer
+type TokenCounter interface {
+	// CountTokens returns the number of input tokens the prompt, system
+	// prompt and history would use
+	CountTokens(ctx context.Context, prompt string, options ...Option) (int, error)
+}
+
+// TokenCount describes how much of a model's context window a request uses
+type TokenCount struct {
+	Model         string
+	Tokens        int  // Input tokens of the request
+	Exact         bool // Counted by the provider rather than estimated locally
+	ContextWindow int  // Context window of the model, 0 if unknown
+}
+
+// Headroom returns the number of tokens left in the context window for the
+// output, negative when the input alone does not fit
+func (c TokenCount) Headroom() int {
+	return c.ContextWindow - c.Tokens
+}
+
+// ContextWindowError is returned when a request does not fit in the model's context window
+type ContextWindowError struct {
+	Model         string
+	Tokens        int  // Input tokens, estimated unless Exact
+	Exact         bool // Tokens were counted by the provider
+	ContextWindow int
+}
+
+// Error implements the error interface
+func (e *ContextWindowError) Error() string {
+	about := "about "
+	if e.Exact {
+		about = ""
+	}
+	return fmt.Sprintf("prompt is %s%d tokens, more than the %d token context window of %s", about, e.Tokens, e.ContextWindow, e.Model)
+}
+
+// contextWindows lists the context window of each model in tokens
+var contextWindows = map[string]int{
+	"claude-3-7-sonnet-latest": 200000,
+	"deepseek-coder":           64000,
+	"deepseek-chat":            64000,
+	"deepseek-reasoner":        64000,
+	"gemini-2.5-pro-exp-03-25": 1048576,
+	"gemini-2.0-flash":         1048576,
+	"gemini-2.0-flash-lite":    1048576,
+	"gemini-1.5-flash":         1048576,
+	"gemini-1.5-flash-8b":      1048576,
+}
+
+// ContextWindow returns the context window of a model in tokens, 0 if unknown
+func ContextWindow(model string) int {
+	return contextWindows[model]
+}
+
+// tokenRatio approximates a tokenizer: ASCII text is split into tokens of a
+// few characters, while other scripts such as CJK use about a token per rune
+type tokenRatio struct {
+	charsPerToken     float64 // ASCII characters per token
+	tokensPerWideRune float64 // Tokens per non-ASCII rune
+}
+
+// tokenRatios approximates the tokenizer of each provider's model family
+var tokenRatios = map[string]tokenRatio{
+	"anthropic": {charsPerToken: 3.5, tokensPerWideRune: 1.0},
+	"deepseek":  {charsPerToken: 3.3, tokensPerWideRune: 0.6},
+	"google":    {charsPerToken: 4.0, tokensPerWideRune: 1.0},
+}
+
+// defaultTokenRatio is used for models of unknown providers
+var defaultTokenRatio = tokenRatio{charsPerToken: 4.0, tokensPerWideRune: 1.0}
+
+// EstimateTokens returns a local estimate of the number of tokens text uses
+// with a model, approximating the tokenizer of its family
+func EstimateTokens(model, text string) int {
+	var ascii, wide int
+	for i := 0; i < len(text); {
+		if text[i] < utf8.RuneSelf {
+			ascii++
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		wide++
+		i += size
+	}
+
+	return modelTokenRatio(model).tokens(ascii, wide)
+}
+
+// modelTokenRatio returns the tokenizer approximation for a model's family
+func modelTokenRatio(model string) tokenRatio {
+	if provider, ok := GetProviderForModel(model); ok {
+		if ratio, ok := tokenRatios[provider]; ok {
+			return ratio
+		}
+	}
+	return defaultTokenRatio
+}
+
+// tokens returns the estimated tokens of text with the given numbers of ASCII and other runes
+func (r tokenRatio) tokens(ascii, wide int) int {
+	return int(math.Ceil(float64(ascii)/r.charsPerToken + float64(wide)*r.tokensPerWideRune))
+}
+
+// estimateRequestTokens estimates the input tokens of a request, including
+// the system prompt, earlier turns and prefill
+func estimateRequestTokens(prompt string, opts *RequestOptions) int {
+	tokens := EstimateTokens(opts.Model, prompt) + EstimateTokens(opts.Model, opts.Prefill)
+	if system, ok := opts.CustomParams["system"].(string); ok {
+		tokens += EstimateTokens(opts.Model, system)
+	}
+	for _, message := range opts.History {
+		tokens += EstimateTokens(opts.Model, message.Content)
+	}
+	return tokens
+}
+
+// contextWindowMargin is how far local estimates may be off, as a share of
+// the context window. Estimates within the margin of the window are checked
+// with the provider's count where available, and otherwise only warned about.
+const contextWindowMargin = 0.1
+
+// requestTokens returns the input tokens of a request and whether the
+// provider counted them. Estimates close to the context window are replaced
+// by the provider's count when it can count tokens.
+func requestTokens(ctx context.Context, provider Provider, prompt string, opts *RequestOptions, options []Option) (int, bool) {
+	tokens := estimateRequestTokens(prompt, opts)
+	window := ContextWindow(opts.Model)
+	if window == 0 || float64(tokens) < float64(window)*(1-contextWindowMargin) {
+		return tokens, false
+	}
+
+	// Fall back to the estimate when counting fails, the query reports real problems
+	if counter, ok := provider.(TokenCounter); ok {
+		if counted, err := counter.CountTokens(ctx, prompt, options...); err == nil {
+			return counted, true
+		}
+	}
+	return tokens, false
+}
+
+// checkContextWindow checks the size of a request before it is sent. It fails
+// when the input does not fit in the model's context window, or for estimates
+// when it exceeds the window by more than the margin, and warns when the
+// output may be cut off because less than max tokens remain.
+func checkContextWindow(tokens int, exact bool, opts *RequestOptions) (string, error) {
+	window := ContextWindow(opts.Model)
+	if window == 0 {
+		return "", nil
+	}
+
+	if tokens > window {
+		if exact || float64(tokens) > float64(window)*(1+contextWindowMargin) {
+			return "", &ContextWindowError{Model: opts.Model, Tokens: tokens, Exact: exact, ContextWindow: window}
+		}
+		return fmt.Sprintf("prompt is estimated at about %d tokens, more than the %d token context window of %s, but was sent as the estimate may be off",
+			tokens, window, opts.Model), nil
+	}
+
+	about := "about "
+	if exact {
+		about = ""
+	}
+	if headroom := window - tokens; opts.MaxTokens > headroom {
+		return fmt.Sprintf("prompt is %s%d tokens, leaving %d of the %d token context window of %s for output, less than max tokens %d",
+			about, tokens, headroom, window, opts.Model, opts.MaxTokens), nil
+	}
+	return "", nil
+}
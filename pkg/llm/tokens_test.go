@@ -0,0 +1,203 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if tokens := EstimateTokens("gemini-2.0-flash", ""); tokens != 0 {
+		t.Errorf("Expected 0 tokens for empty text, got %d", tokens)
+	}
+
+	// Four ASCII characters per token for Gemini
+	if tokens := EstimateTokens("gemini-2.0-flash", strings.Repeat("a", 400)); tokens != 100 {
+		t.Errorf("Expected 100 tokens, got %d", tokens)
+	}
+
+	// Anthropic's tokenizer produces more tokens for the same text
+	if tokens := EstimateTokens("claude-3-7-sonnet-latest", strings.Repeat("a", 400)); tokens != 115 {
+		t.Errorf("Expected 115 tokens, got %d", tokens)
+	}
+
+	// CJK runes cost more than ASCII characters
+	if tokens := EstimateTokens("deepseek-chat", "你好世界你好世界你好"); tokens != 6 {
+		t.Errorf("Expected 6 tokens for 10 CJK runes, got %d", tokens)
+	}
+}
+
+func TestCheckContextWindow(t *testing.T) {
+	// Too large for deepseek-chat's 64000 token window even if the estimate is off
+	opts := &RequestOptions{Model: "deepseek-chat", MaxTokens: 1000}
+	_, err := checkContextWindow(estimateRequestTokens(strings.Repeat("word ", 80000), opts), false, opts)
+	var windowErr *ContextWindowError
+	if !errors.As(err, &windowErr) {
+		t.Fatalf("Expected ContextWindowError, got %v", err)
+	}
+	if windowErr.ContextWindow != 64000 || windowErr.Tokens <= 64000 {
+		t.Errorf("Expected estimate above the 64000 token window, got %+v", windowErr)
+	}
+
+	// Estimates just above the window are sent with a warning, counts are not
+	warning, err := checkContextWindow(66000, false, opts)
+	if err != nil || !strings.Contains(warning, "estimate may be off") {
+		t.Errorf("Expected a warning for an estimate within the margin, got %q, %v", warning, err)
+	}
+	if _, err := checkContextWindow(66000, true, opts); !errors.As(err, &windowErr) || !windowErr.Exact {
+		t.Errorf("Expected ContextWindowError for a count above the window, got %v", err)
+	}
+
+	// Fits, but leaves less than max tokens for the output
+	opts = &RequestOptions{Model: "deepseek-chat", MaxTokens: 8000}
+	warning, err = checkContextWindow(60000, false, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(warning, "less than max tokens 8000") {
+		t.Errorf("Expected headroom warning, got %q", warning)
+	}
+
+	// Small prompts pass silently
+	warning, err = checkContextWindow(10, false, opts)
+	if err != nil || warning != "" {
+		t.Errorf("Expected no warning or error, got %q, %v", warning, err)
+	}
+}
+
+// countingProvider answers token counts with a fixed number, recording the calls
+type countingProvider struct {
+	tokens int
+	calls  int
+}
+
+func (p *countingProvider) Query(ctx context.Context, prompt string, options ...Option) (string, error) {
+	return "", nil
+}
+
+func (p *countingProvider) CountTokens(ctx context.Context, prompt string, options ...Option) (int, error) {
+	p.calls++
+	return p.tokens, nil
+}
+
+func TestRequestTokens(t *testing.T) {
+	provider := &countingProvider{tokens: 190000}
+	opts := &RequestOptions{Model: "claude-3-7-sonnet-latest"}
+
+	// Estimates far from the window are used without asking the provider
+	if tokens, exact := requestTokens(context.Background(), provider, "Hello", opts, nil); exact || tokens == 0 || provider.calls != 0 {
+		t.Errorf("Expected a local estimate, got %d tokens (exact %v) and %d calls", tokens, exact, provider.calls)
+	}
+
+	// Estimates close to the window are replaced by the provider's count
+	prompt := strings.Repeat("a", 700000)
+	if tokens, exact := requestTokens(context.Background(), provider, prompt, opts, nil); !exact || tokens != 190000 || provider.calls != 1 {
+		t.Errorf("Expected the provider's count, got %d tokens (exact %v) and %d calls", tokens, exact, provider.calls)
+	}
+}
+
+func TestAnthropicProviderCountTokens(t *testing.T) {
+	var lastPath string
+	var lastRequest anthropicCountRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&lastRequest); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(`{"input_tokens": 42}`)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", server.Client(), WithBaseURL(server.URL))
+
+	tokens, err := provider.CountTokens(context.Background(), "Count me",
+		WithModel("claude-3-7-sonnet-latest"),
+		WithCustomParam("system", "Be brief"),
+	)
+	if err != nil {
+		t.Fatalf("CountTokens returned error: %v", err)
+	}
+
+	if tokens != 42 {
+		t.Errorf("Expected 42 tokens, got %d", tokens)
+	}
+	if lastPath != "/v1/messages/count_tokens" {
+		t.Errorf("Expected count_tokens endpoint, got %s", lastPath)
+	}
+	if lastRequest.System != "Be brief" || len(lastRequest.Messages) != 1 || lastRequest.Messages[0].Content != "Count me" {
+		t.Errorf("Expected system prompt and message in request, got %+v", lastRequest)
+	}
+}
+
+func TestGoogleProviderCountTokens(t *testing.T) {
+	var lastRequest *http.Request
+	server := setupGoogleMockServer(t, `{"totalTokens": 17}`, &lastRequest, nil)
+	defer server.Close()
+
+	provider := NewGoogleProvider("test-google-key", server.Client(), WithBaseURL(server.URL))
+	defer func() {
+		if err := provider.Close(); err != nil {
+			t.Errorf("Failed to close provider: %v", err)
+		}
+	}()
+
+	tokens, err := provider.CountTokens(context.Background(), "Count me", WithModel("gemini-2.0-flash"))
+	if err != nil {
+		t.Fatalf("CountTokens returned error: %v", err)
+	}
+
+	if tokens != 17 {
+		t.Errorf("Expected 17 tokens, got %d", tokens)
+	}
+	if lastRequest == nil || !strings.HasSuffix(lastRequest.URL.Path, "gemini-2.0-flash:countTokens") {
+		t.Errorf("Expected countTokens request, got %v", lastRequest)
+	}
+}
+
+func TestServiceCountTokens(t *testing.T) {
+	// Without a configured provider the count is estimated
+	service := NewService(map[string]string{}, nil)
+
+	count, err := service.CountTokens(context.Background(), strings.Repeat("a", 400), "gemini-2.0-flash",
+		WithCustomParam("system", strings.Repeat("b", 40)))
+	if err != nil {
+		t.Fatalf("CountTokens returned error: %v", err)
+	}
+
+	if count.Exact {
+		t.Error("Expected an estimated count")
+	}
+	if count.Tokens != 110 {
+		t.Errorf("Expected 110 tokens, got %d", count.Tokens)
+	}
+	if count.ContextWindow != 1048576 || count.Headroom() != 1048576-110 {
+		t.Errorf("Expected headroom in the 1048576 token window, got %+v", count)
+	}
+
+	if _, err := service.CountTokens(context.Background(), "Hello", "unknown-model"); err == nil {
+		t.Error("Expected error for unknown model")
+	}
+}
+
+func TestServiceRejectsOversizedPrompt(t *testing.T) {
+	server := setupDeepseekMockServer(t)
+	defer server.Close()
+
+	service := NewServiceWithSettings(map[string]string{"deepseek": "d-key"}, server.Client(),
+		map[string]ProviderSettings{"deepseek": {BaseURL: server.URL}})
+
+	_, _, err := service.QueryDetailed(context.Background(), strings.Repeat("word ", 50000), "deepseek-chat")
+	var windowErr *ContextWindowError
+	if !errors.As(err, &windowErr) {
+		t.Errorf("Expected ContextWindowError before sending, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// createCommandsTable creates the table of suggested shell commands if it doesn't exist
+func createCommandsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS commands (
+			id TEXT PRIMARY KEY,
+			timestamp TEXT NOT NULL,
+			request TEXT NOT NULL,
+			command TEXT NOT NULL,
+			model TEXT NOT NULL,
+			executed INTEGER NOT NULL,
+			exit_code INTEGER NOT NULL,
+			error TEXT,
+			duration_ms INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create commands table: %w", err)
+	}
+	return nil
+}
+
+// LogCommand records a suggested shell command and whether and how it ran
+func (l *Logger) LogCommand(command Command) error {
+	if command.ID == "" {
+		command.ID = uuid.New().String()
+	}
+	if command.Timestamp.IsZero() {
+		command.Timestamp = time.Now()
+	}
+
+	_, err := l.db.Exec(
+		`INSERT INTO commands (id, timestamp, request, command, model, executed, exit_code, error, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		command.ID, command.Timestamp.Format(time.RFC3339), command.Request, command.Command, command.Model,
+		command.Executed, command.ExitCode, command.Error, command.Duration,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to log command: %w", err)
+	}
+	return nil
+}
+
+// GetRecentCommands returns recorded shell commands, most recent first
+func (l *Logger) GetRecentCommands(limit int) ([]Command, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := l.db.Query(
+		`SELECT id, timestamp, request, command, model, executed, exit_code, COALESCE(error, ''), duration_ms
+		FROM commands ORDER BY timestamp DESC, rowid DESC LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch commands: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing rows: %v\n", err)
+		}
+	}()
+
+	var commands []Command
+	for rows.Next() {
+		var command Command
+		var timestamp string
+		if err := rows.Scan(&command.ID, &timestamp, &command.Request, &command.Command, &command.Model,
+			&command.Executed, &command.ExitCode, &command.Error, &command.Duration); err != nil {
+			return nil, fmt.Errorf("failed to scan command: %w", err)
+		}
+		command.Timestamp, err = time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		commands = append(commands, command)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch commands: %w", err)
+	}
+
+	return commands, nil
+}
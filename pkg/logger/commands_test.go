@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCommands(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-commands-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	logger, err := NewLogger(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Errorf("Failed to close logger: %v", err)
+		}
+	}()
+
+	// Record a declined command and, a minute later, one that ran
+	suggested := time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)
+	commands := []Command{
+		{Timestamp: suggested, Request: "delete build output", Command: "rm -rf build", Model: "gpt-4o", ExitCode: -1},
+		{Timestamp: suggested.Add(time.Minute), Request: "list go files", Command: "ls *.go", Model: "gpt-4o", Executed: true, ExitCode: 2, Duration: 15},
+	}
+	for _, command := range commands {
+		if err := logger.LogCommand(command); err != nil {
+			t.Fatalf("Failed to log command: %v", err)
+		}
+	}
+
+	recent, err := logger.GetRecentCommands(10)
+	if err != nil {
+		t.Fatalf("Failed to get commands: %v", err)
+	}
+	if len(recent) != 2 || recent[0].Command != "ls *.go" || recent[1].Command != "rm -rf build" {
+		t.Fatalf("Expected 2 commands, most recent first, got %+v", recent)
+	}
+	if !recent[0].Executed || recent[0].ExitCode != 2 || recent[0].Duration != 15 || recent[0].ID == "" {
+		t.Errorf("Expected executed command with exit code 2, got %+v", recent[0])
+	}
+	if recent[1].Executed || recent[1].ExitCode != -1 || !recent[1].Timestamp.Equal(suggested) {
+		t.Errorf("Expected declined command, got %+v", recent[1])
+	}
+}
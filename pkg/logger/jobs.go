@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// createJobsTable creates the table of submitted batch jobs if it doesn't exist
+func createJobsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS jobs (
+			id TEXT PRIMARY KEY,
+			provider TEXT NOT NULL,
+			model TEXT NOT NULL,
+			status TEXT NOT NULL,
+			requests INTEGER NOT NULL,
+			input TEXT,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create jobs table: %w", err)
+	}
+	return nil
+}
+
+// SaveJob records a submitted batch job, replacing an earlier record with the same ID
+func (l *Logger) SaveJob(job Job) error {
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	if job.UpdatedAt.IsZero() {
+		job.UpdatedAt = job.CreatedAt
+	}
+
+	_, err := l.db.Exec(
+		`INSERT OR REPLACE INTO jobs (id, provider, model, status, requests, input, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.Provider, job.Model, job.Status, job.Requests, job.Input,
+		job.CreatedAt.Format(time.RFC3339), job.UpdatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+	return nil
+}
+
+// UpdateJobStatus records the latest status of a batch job
+func (l *Logger) UpdateJobStatus(id, status string) error {
+	result, err := l.db.Exec(
+		"UPDATE jobs SET status = ?, updated_at = ? WHERE id = ?",
+		status, time.Now().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return fmt.Errorf("job %s not found", id)
+	}
+	return nil
+}
+
+// GetJob returns a recorded batch job, or nil if there is none with the ID
+func (l *Logger) GetJob(id string) (*Job, error) {
+	row := l.db.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ?", id)
+
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ListJobs returns recorded batch jobs, most recent first
+func (l *Logger) ListJobs(limit int) ([]Job, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := l.db.Query("SELECT "+jobColumns+" FROM jobs ORDER BY created_at DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jobs: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing rows: %v\n", err)
+		}
+	}()
+
+	var jobs []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// jobColumns are the columns read by scanJob
+const jobColumns = "id, provider, model, status, requests, COALESCE(input, ''), created_at, updated_at"
+
+// scanJob reads a job selected with jobColumns from a row
+func scanJob(row interface{ Scan(...interface{}) error }) (*Job, error) {
+	var job Job
+	var createdAt, updatedAt string
+
+	err := row.Scan(&job.ID, &job.Provider, &job.Model, &job.Status, &job.Requests, &job.Input, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan job: %w", err)
+	}
+
+	job.CreatedAt, err = time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created time: %w", err)
+	}
+	job.UpdatedAt, err = time.Parse(time.RFC3339, updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated time: %w", err)
+	}
+
+	return &job, nil
+}
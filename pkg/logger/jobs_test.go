@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJobs(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-jobs-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	logger, err := NewLogger(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Errorf("Failed to close logger: %v", err)
+		}
+	}()
+
+	// Record two jobs submitted a minute apart
+	submitted := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	jobs := []Job{
+		{ID: "msgbatch_1", Provider: "anthropic", Model: "claude-3-7-sonnet-latest", Status: "in_progress", Requests: 3, Input: "a.jsonl", CreatedAt: submitted},
+		{ID: "batches/2", Provider: "google", Model: "gemini-2.0-flash", Status: "in_progress", Requests: 5, CreatedAt: submitted.Add(time.Minute)},
+	}
+	for _, job := range jobs {
+		if err := logger.SaveJob(job); err != nil {
+			t.Fatalf("Failed to save job: %v", err)
+		}
+	}
+
+	listed, err := logger.ListJobs(10)
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(listed) != 2 || listed[0].ID != "batches/2" || listed[1].ID != "msgbatch_1" {
+		t.Fatalf("Expected 2 jobs, most recent first, got %+v", listed)
+	}
+	if listed[1].Input != "a.jsonl" || listed[1].Requests != 3 || !listed[1].CreatedAt.Equal(submitted) {
+		t.Errorf("Expected recorded job details, got %+v", listed[1])
+	}
+
+	// Status updates are recorded
+	if err := logger.UpdateJobStatus("msgbatch_1", "ended"); err != nil {
+		t.Fatalf("Failed to update job: %v", err)
+	}
+	job, err := logger.GetJob("msgbatch_1")
+	if err != nil || job == nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.Status != "ended" || job.Model != "claude-3-7-sonnet-latest" {
+		t.Errorf("Expected ended job, got %+v", job)
+	}
+
+	// Unknown jobs are reported
+	if job, err := logger.GetJob("unknown"); err != nil || job != nil {
+		t.Errorf("Expected no job for unknown ID, got %+v (%v)", job, err)
+	}
+	if err := logger.UpdateJobStatus("unknown", "ended"); err == nil {
+		t.Error("Expected error updating unknown job")
+	}
+}
@@ -50,9 +50,77 @@ func NewLogger(configDir string) (*Logger, error) {
 		return nil, fmt.Errorf("failed to create table: %w", err)
 	}
 
+	// Add columns introduced after the table was first created
+	if err := migrate(db); err != nil {
+		if err := db.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing database: %v\n", err)
+		}
+		return nil, err
+	}
+
+	// Create the table of submitted batch jobs
+	if err := createJobsTable(db); err != nil {
+		if err := db.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing database: %v\n", err)
+		}
+		return nil, err
+	}
+
+	// Create the table of suggested shell commands
+	if err := createCommandsTable(db); err != nil {
+		if err := db.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing database: %v\n", err)
+		}
+		return nil, err
+	}
+
 	return &Logger{db: db}, nil
 }
 
+// metadataColumns are the response metadata columns added to the queries table
+var metadataColumns = []string{"stop_reason", "request_id", "model_version", "created"}
+
+// migrate adds missing metadata columns to a queries table created by an older version
+func migrate(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(queries)")
+	if err != nil {
+		return fmt.Errorf("failed to read table schema: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing rows: %v\n", err)
+		}
+	}()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid          int
+			name, typ    string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan table schema: %w", err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read table schema: %w", err)
+	}
+
+	for _, column := range metadataColumns {
+		if existing[column] {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE queries ADD COLUMN " + column + " TEXT"); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", column, err)
+		}
+	}
+
+	return nil
+}
+
 // Close closes the database connection
 func (l *Logger) Close() error {
 	if l.db != nil {
@@ -63,13 +131,26 @@ func (l *Logger) Close() error {
 
 // LogQuery logs a query to the database
 func (l *Logger) LogQuery(prompt, model, response string, duration time.Duration, temperature float64) error {
+	return l.LogQueryWithMetadata(prompt, model, response, duration, temperature, Metadata{})
+}
+
+// LogQueryWithMetadata logs a query to the database along with the response metadata
+func (l *Logger) LogQueryWithMetadata(prompt, model, response string, duration time.Duration, temperature float64, metadata Metadata) error {
 	// Generate a unique ID
 	id := uuid.New().String()
 
+	// Only record the creation time when the provider reported one
+	var created string
+	if !metadata.Created.IsZero() {
+		created = metadata.Created.Format(time.RFC3339)
+	}
+
 	// Insert query record
 	_, err := l.db.Exec(
-		"INSERT INTO queries (id, timestamp, prompt, model, response, duration_ms, temperature) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		`INSERT INTO queries (id, timestamp, prompt, model, response, duration_ms, temperature, stop_reason, request_id, model_version, created)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id, time.Now().Format(time.RFC3339), prompt, model, response, duration.Milliseconds(), temperature,
+		metadata.StopReason, metadata.RequestID, metadata.ModelVersion, created,
 	)
 
 	if err != nil {
@@ -86,7 +167,7 @@ func (l *Logger) GetRecentQueries(limit int) ([]Query, error) {
 	}
 
 	rows, err := l.db.Query(
-		"SELECT id, timestamp, prompt, model, response, duration_ms, temperature FROM queries ORDER BY timestamp DESC LIMIT ?",
+		"SELECT "+queryColumns+" FROM queries ORDER BY timestamp DESC LIMIT ?",
 		limit,
 	)
 	if err != nil {
@@ -98,26 +179,7 @@ func (l *Logger) GetRecentQueries(limit int) ([]Query, error) {
 		}
 	}()
 
-	var queries []Query
-	for rows.Next() {
-		var q Query
-		var timestamp string
-
-		err := rows.Scan(&q.ID, &timestamp, &q.Prompt, &q.Model, &q.Response, &q.Duration, &q.Temperature)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		// Parse timestamp
-		q.Timestamp, err = time.Parse(time.RFC3339, timestamp)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
-		}
-
-		queries = append(queries, q)
-	}
-
-	return queries, nil
+	return scanQueries(rows)
 }
 
 // SearchQueries searches for queries containing the given text
@@ -131,8 +193,8 @@ func (l *Logger) SearchQueries(text string, limit int) ([]Query, error) {
 
 	// Query with search criteria
 	rows, err := l.db.Query(
-		`SELECT id, timestamp, prompt, model, response, duration_ms, temperature 
-		FROM queries 
+		`SELECT `+queryColumns+`
+		FROM queries
 		WHERE prompt LIKE ? OR response LIKE ?
 		ORDER BY timestamp DESC LIMIT ?`,
 		pattern, pattern, limit,
@@ -146,12 +208,23 @@ func (l *Logger) SearchQueries(text string, limit int) ([]Query, error) {
 		}
 	}()
 
+	return scanQueries(rows)
+}
+
+// queryColumns are the columns read by scanQueries. Metadata columns are
+// NULL for queries logged before they were added.
+const queryColumns = `id, timestamp, prompt, model, response, duration_ms, temperature,
+	COALESCE(stop_reason, ''), COALESCE(request_id, ''), COALESCE(model_version, ''), COALESCE(created, '')`
+
+// scanQueries reads queries selected with queryColumns
+func scanQueries(rows *sql.Rows) ([]Query, error) {
 	var queries []Query
 	for rows.Next() {
 		var q Query
-		var timestamp string
+		var timestamp, created string
 
-		err := rows.Scan(&q.ID, &timestamp, &q.Prompt, &q.Model, &q.Response, &q.Duration, &q.Temperature)
+		err := rows.Scan(&q.ID, &timestamp, &q.Prompt, &q.Model, &q.Response, &q.Duration, &q.Temperature,
+			&q.Metadata.StopReason, &q.Metadata.RequestID, &q.Metadata.ModelVersion, &created)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
@@ -162,6 +235,13 @@ func (l *Logger) SearchQueries(text string, limit int) ([]Query, error) {
 			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
 		}
 
+		if created != "" {
+			q.Metadata.Created, err = time.Parse(time.RFC3339, created)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse created time: %w", err)
+			}
+		}
+
 		queries = append(queries, q)
 	}
 
@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"database/sql"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -95,3 +97,122 @@ func TestLogger(t *testing.T) {
 		t.Errorf("Expected to find query with 'golang', got: %q", searchResults[0].Prompt)
 	}
 }
+
+func TestLogQueryWithMetadata(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-logger-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	logger, err := NewLogger(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Errorf("Failed to close logger: %v", err)
+		}
+	}()
+
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	metadata := Metadata{
+		StopReason:   "max_tokens",
+		RequestID:    "req_123",
+		ModelVersion: "test-model-20250301",
+		Created:      created,
+	}
+	if err := logger.LogQueryWithMetadata("test prompt", "test-model", "test response", 100*time.Millisecond, 0.7, metadata); err != nil {
+		t.Fatalf("Failed to log query: %v", err)
+	}
+
+	queries, err := logger.GetRecentQueries(10)
+	if err != nil {
+		t.Fatalf("Failed to retrieve queries: %v", err)
+	}
+	if len(queries) != 1 {
+		t.Fatalf("Expected 1 query, got %d", len(queries))
+	}
+
+	got := queries[0].Metadata
+	if got.StopReason != metadata.StopReason {
+		t.Errorf("Expected stop reason %q, got %q", metadata.StopReason, got.StopReason)
+	}
+	if got.RequestID != metadata.RequestID {
+		t.Errorf("Expected request ID %q, got %q", metadata.RequestID, got.RequestID)
+	}
+	if got.ModelVersion != metadata.ModelVersion {
+		t.Errorf("Expected model version %q, got %q", metadata.ModelVersion, got.ModelVersion)
+	}
+	if !got.Created.Equal(created) {
+		t.Errorf("Expected created %v, got %v", created, got.Created)
+	}
+}
+
+func TestLoggerMigratesOldTable(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-logger-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	// Create a database with the table layout of earlier versions
+	db, err := sql.Open("sqlite3", filepath.Join(tmpDir, "queries.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE queries (
+			id TEXT PRIMARY KEY,
+			timestamp TEXT NOT NULL,
+			prompt TEXT NOT NULL,
+			model TEXT NOT NULL,
+			response TEXT,
+			duration_ms INTEGER,
+			temperature REAL
+		);
+		INSERT INTO queries VALUES ('old', '2025-01-01T00:00:00Z', 'old prompt', 'old-model', 'old response', 10, 0.5);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create old table: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	logger, err := NewLogger(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer func() {
+		if err := logger.Close(); err != nil {
+			t.Errorf("Failed to close logger: %v", err)
+		}
+	}()
+
+	if err := logger.LogQueryWithMetadata("new prompt", "new-model", "new response", 20*time.Millisecond, 0.7, Metadata{StopReason: "end_turn"}); err != nil {
+		t.Fatalf("Failed to log query after migration: %v", err)
+	}
+
+	queries, err := logger.GetRecentQueries(10)
+	if err != nil {
+		t.Fatalf("Failed to retrieve queries: %v", err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("Expected 2 queries, got %d", len(queries))
+	}
+	if queries[0].Metadata.StopReason != "end_turn" {
+		t.Errorf("Expected stop reason end_turn, got %q", queries[0].Metadata.StopReason)
+	}
+	if queries[1].Prompt != "old prompt" || queries[1].Metadata.StopReason != "" {
+		t.Errorf("Expected old query without metadata, got %+v", queries[1])
+	}
+}
@@ -13,4 +13,38 @@ type Query struct {
 	Response    string    `json:"response"`
 	Duration    int64     `json:"duration_ms"`
 	Temperature float64   `json:"temperature"`
+	Metadata    Metadata  `json:"metadata"`
+}
+
+// Metadata describes the response a query received
+type Metadata struct {
+	StopReason   string    `json:"stop_reason,omitempty"`   // Normalized reason the model stopped
+	RequestID    string    `json:"request_id,omitempty"`    // Provider request ID
+	ModelVersion string    `json:"model_version,omitempty"` // Model version that served the query
+	Created      time.Time `json:"created,omitempty"`       // Time the provider created the response
+}
+
+// Job represents a provider batch job submitted with gollm
+type Job struct {
+	ID        string    `json:"id"`         // Provider's ID for the job
+	Provider  string    `json:"provider"`   // Provider running the job
+	Model     string    `json:"model"`      // Model answering the requests
+	Status    string    `json:"status"`     // Status when the job was last checked
+	Requests  int       `json:"requests"`   // Number of requests in the job
+	Input     string    `json:"input"`      // File the requests were read from
+	CreatedAt time.Time `json:"created_at"` // When the job was submitted
+	UpdatedAt time.Time `json:"updated_at"` // When the status was last checked
+}
+
+// Command represents a shell command suggested with gollm cmd and its outcome
+type Command struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Request   string    `json:"request"`   // What the command was asked to do
+	Command   string    `json:"command"`   // Command the model suggested
+	Model     string    `json:"model"`     // Model suggesting the command
+	Executed  bool      `json:"executed"`  // Whether the command was confirmed and run
+	ExitCode  int       `json:"exit_code"` // Exit code, -1 if not run or not started
+	Error     string    `json:"error"`     // Why the command could not be started
+	Duration  int64     `json:"duration_ms"`
 }
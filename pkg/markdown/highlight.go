@@ -0,0 +1,332 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+)
+
+// language describes what the highlighter needs to know about a programming language
+type language struct {
+	keywords     []string
+	literals     []string // Highlighted like numbers
+	lineComments []string
+	blockComment [2]string
+	quotes       string // Characters starting string literals
+	multiline    string // Quotes whose strings may span lines
+}
+
+// cKeywords are the keywords of C, which C++ extends
+const cKeywords = "auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while"
+
+var (
+	cLike = language{
+		keywords:     words(cKeywords),
+		literals:     words("NULL true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+	cpp = language{
+		keywords:     words(cKeywords + " bool catch class constexpr delete explicit friend namespace new noexcept operator override private protected public template this throw try typename using virtual"),
+		literals:     words("nullptr NULL true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+	goLang = language{
+		keywords:     words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var"),
+		literals:     words("nil true false iota"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		multiline:    "`",
+	}
+	javaScript = language{
+		keywords:     words("async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof interface let new of return static super switch this throw try type typeof var void while yield"),
+		literals:     words("null undefined true false NaN"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		multiline:    "`",
+	}
+	java = language{
+		keywords:     words("abstract boolean break byte case catch char class continue default do double else enum extends final finally float for if implements import instanceof int interface long new package private protected public return short static super switch synchronized this throw throws try void volatile while var record"),
+		literals:     words("null true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+	kotlin = language{
+		keywords:     words("as break class continue do else for fun if import in interface is object package return super this throw try typealias val var when while data sealed override private public internal"),
+		literals:     words("null true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+	cSharp = language{
+		keywords:     words("abstract as async await base bool break case catch class const continue decimal default do double else enum event explicit false finally float for foreach if implicit in int interface internal is lock long namespace new object out override private protected public readonly ref return sealed static string struct switch this throw try typeof using var virtual void while"),
+		literals:     words("null true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+	rust = language{
+		keywords:     words("as async await break const continue crate dyn else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while"),
+		literals:     words("true false None Some Ok Err"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"`,
+	}
+	swift = language{
+		keywords:     words("as break case catch class continue default defer do else enum extension for func guard if import in init let protocol return self static struct switch throw throws try var where while"),
+		literals:     words("nil true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"`,
+	}
+	python = language{
+		keywords:     words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield self"),
+		literals:     words("None True False"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	ruby = language{
+		keywords:     words("alias and begin break case class def defined? do else elsif end ensure for if in module next not or redo rescue retry return self super then unless until when while yield require attr_accessor"),
+		literals:     words("nil true false"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	php = language{
+		keywords:     words("abstract array as break case catch class const continue declare default do echo else elseif extends final finally for foreach function global if implements include interface namespace new private protected public require return static switch throw trait try use while"),
+		literals:     words("null true false NULL TRUE FALSE"),
+		lineComments: []string{"//", "#"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+	shell = language{
+		keywords:     words("if then else elif fi case esac for while until do done in function return local export readonly declare set unset shift exit source alias echo cd"),
+		literals:     words("true false"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	powerShell = language{
+		keywords:     words("begin break catch class continue data do dynamicparam else elseif end exit filter finally for foreach function if in param process return switch throw trap try until while"),
+		literals:     words("$true $false $null"),
+		lineComments: []string{"#"},
+		blockComment: [2]string{"<#", "#>"},
+		quotes:       `"'`,
+	}
+	sql = language{
+		keywords:     caseInsensitive("select from where and or not insert into values update set delete create table alter drop index view join inner left right outer full on as group by order having limit offset union all distinct case when then else end primary key foreign references default exists in is like between begin commit rollback with returning"),
+		literals:     caseInsensitive("null true false"),
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `'"`,
+	}
+	lua = language{
+		keywords:     words("and break do else elseif end for function goto if in local not or repeat return then until while"),
+		literals:     words("nil true false"),
+		lineComments: []string{"--"},
+		quotes:       `"'`,
+	}
+	yaml = language{
+		literals:     words("true false null yes no on off"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	json = language{
+		literals: words("true false null"),
+		quotes:   `"`,
+	}
+	toml = language{
+		literals:     words("true false"),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+)
+
+// languages maps the names used in code fence info strings to languages
+var languages = map[string]*language{
+	"c": &cLike, "h": &cLike,
+	"cpp": &cpp, "c++": &cpp, "cc": &cpp, "hpp": &cpp, "cxx": &cpp,
+	"go": &goLang, "golang": &goLang,
+	"javascript": &javaScript, "js": &javaScript, "jsx": &javaScript, "mjs": &javaScript,
+	"typescript": &javaScript, "ts": &javaScript, "tsx": &javaScript,
+	"java": &java, "kotlin": &kotlin, "kt": &kotlin, "scala": &java,
+	"csharp": &cSharp, "cs": &cSharp, "c#": &cSharp,
+	"rust": &rust, "rs": &rust, "swift": &swift,
+	"python": &python, "py": &python, "python3": &python,
+	"ruby": &ruby, "rb": &ruby, "php": &php,
+	"bash": &shell, "sh": &shell, "shell": &shell, "zsh": &shell, "console": &shell, "fish": &shell,
+	"powershell": &powerShell, "ps1": &powerShell, "pwsh": &powerShell,
+	"sql": &sql, "lua": &lua,
+	"yaml": &yaml, "yml": &yaml, "json": &json, "jsonc": &json, "toml": &toml, "ini": &toml,
+	"dockerfile": &shell, "makefile": &shell, "make": &shell,
+}
+
+// words splits a space separated list
+func words(list string) []string {
+	return strings.Fields(list)
+}
+
+// caseInsensitive lists words in lower and upper case
+func caseInsensitive(list string) []string {
+	var all []string
+	for _, word := range strings.Fields(list) {
+		all = append(all, word, strings.ToUpper(word))
+	}
+	return all
+}
+
+// highlighter colors the lines of a code block, keeping the state of block
+// comments and multiline strings between lines
+type highlighter struct {
+	lang     *language
+	theme    Theme
+	keywords map[string]string // Word to style
+	inside   string            // Closing delimiter of an open comment or string
+	style    string            // Style of the open comment or string
+}
+
+// newHighlighter returns a highlighter for a code fence language, or nil when
+// the language is unknown
+func newHighlighter(name string, theme Theme) *highlighter {
+	lang, ok := languages[strings.ToLower(name)]
+	if !ok {
+		return nil
+	}
+	keywords := make(map[string]string)
+	for _, word := range lang.keywords {
+		keywords[word] = theme.Keyword
+	}
+	for _, word := range lang.literals {
+		keywords[word] = theme.Number
+	}
+	return &highlighter{lang: lang, theme: theme, keywords: keywords}
+}
+
+// line highlights one line of code
+func (h *highlighter) line(line string) string {
+	var out strings.Builder
+	i := 0
+
+	// Continue a comment or string from an earlier line
+	if h.inside != "" {
+		end := strings.Index(line, h.inside)
+		if end < 0 {
+			return styled(line, h.style)
+		}
+		end += len(h.inside)
+		out.WriteString(styled(line[:end], h.style))
+		h.inside = ""
+		i = end
+	}
+
+	for i < len(line) {
+		rest := line[i:]
+
+		// Comments
+		if comment := h.lineComment(rest); comment {
+			out.WriteString(styled(rest, h.theme.Comment))
+			break
+		}
+		if open := h.lang.blockComment[0]; open != "" && strings.HasPrefix(rest, open) {
+			closing := h.lang.blockComment[1]
+			end := strings.Index(rest[len(open):], closing)
+			if end < 0 {
+				out.WriteString(styled(rest, h.theme.Comment))
+				h.inside, h.style = closing, h.theme.Comment
+				break
+			}
+			end += len(open) + len(closing)
+			out.WriteString(styled(rest[:end], h.theme.Comment))
+			i += end
+			continue
+		}
+
+		c := rest[0]
+		switch {
+		case strings.IndexByte(h.lang.quotes, c) >= 0:
+			end := stringEnd(rest)
+			if end < 0 {
+				out.WriteString(styled(rest, h.theme.String))
+				if strings.IndexByte(h.lang.multiline, c) >= 0 {
+					h.inside, h.style = string(c), h.theme.String
+				}
+				return out.String()
+			}
+			out.WriteString(styled(rest[:end], h.theme.String))
+			i += end
+		case c >= '0' && c <= '9' && (i == 0 || !isWordByte(line[i-1])):
+			end := 1
+			for end < len(rest) && (isWordByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			out.WriteString(styled(rest[:end], h.theme.Number))
+			i += end
+		case isWordByte(c) || c == '$':
+			end := 1
+			for end < len(rest) && (isWordByte(rest[end]) || rest[end] == '?') {
+				end++
+			}
+			word := rest[:end]
+			if style, ok := h.keywords[word]; ok {
+				out.WriteString(styled(word, style))
+			} else {
+				out.WriteString(word)
+			}
+			i += end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
+
+// lineComment reports whether the text starts with a line comment
+func (h *highlighter) lineComment(text string) bool {
+	for _, marker := range h.lang.lineComments {
+		if strings.HasPrefix(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringEnd returns the length of the string literal at the start of the
+// text, or -1 if it is not closed on this line
+func stringEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// isWordByte reports whether a byte can be part of an identifier
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// highlightDiff colors added, removed and hunk header lines of a diff
+func highlightDiff(line string, theme Theme) string {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return styled(line, theme.Strong)
+	case strings.HasPrefix(line, "+"):
+		return styled(line, theme.Inserted)
+	case strings.HasPrefix(line, "-"):
+		return styled(line, theme.Deleted)
+	case strings.HasPrefix(line, "@@"):
+		return styled(line, theme.DiffHeader)
+	}
+	return line
+}
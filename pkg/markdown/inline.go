@@ -0,0 +1,290 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ansiSequence matches the SGR escape sequences styles are written with
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// autolink matches links written as <https://example.com>
+var autolink = regexp.MustCompile(`^<((?:https?|mailto|ftp):[^>\s]+)>`)
+
+// inline styles the inline markup of text: code spans, strong, emphasis,
+// strikethrough and links. base is the style of the surrounding block,
+// restored after each styled span.
+func (r *Renderer) inline(text, base string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		c := rest[0]
+
+		switch {
+		case c == '\\' && len(rest) > 1 && unicode.IsPunct(rune(rest[1])):
+			out.WriteByte(rest[1])
+			i += 2
+			continue
+
+		case c == '`':
+			run := len(rest) - len(strings.TrimLeft(rest, "`"))
+			end := strings.Index(rest[run:], rest[:run])
+			if end >= 0 {
+				code := rest[run : run+end]
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				out.WriteString(span(code, r.Theme.Code, base))
+				i += 2*run + end
+				continue
+			}
+			out.WriteString(rest[:run])
+			i += run
+			continue
+
+		case strings.HasPrefix(rest, "**"), strings.HasPrefix(rest, "__") && !wordBefore(text, i):
+			if end := closingDelimiter(rest, rest[:2]); end > 0 {
+				out.WriteString(r.styledSpan(rest[2:end], r.Theme.Strong, base))
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(rest, "~~"):
+			if end := closingDelimiter(rest, "~~"); end > 0 {
+				out.WriteString(r.styledSpan(rest[2:end], r.Theme.Strike, base))
+				i += end + 2
+				continue
+			}
+
+		case c == '*', c == '_' && !wordBefore(text, i):
+			if end := closingDelimiter(rest, rest[:1]); end > 0 {
+				out.WriteString(r.styledSpan(rest[1:end], r.Theme.Emphasis, base))
+				i += end + 1
+				continue
+			}
+
+		case c == '[', c == '!' && strings.HasPrefix(rest, "!["):
+			if label, url, length := link(rest); length > 0 {
+				out.WriteString(r.styledSpan(label, r.Theme.Link, base))
+				if url != label {
+					out.WriteString(" " + span("("+url+")", r.Theme.URL, base))
+				}
+				i += length
+				continue
+			}
+
+		case c == '<':
+			if match := autolink.FindStringSubmatch(rest); match != nil {
+				out.WriteString(span(match[1], r.Theme.Link, base))
+				i += len(match[0])
+				continue
+			}
+		}
+
+		out.WriteByte(c)
+		i++
+	}
+	return out.String()
+}
+
+// styledSpan styles text whose own inline markup is styled too
+func (r *Renderer) styledSpan(text, style, base string) string {
+	return span(r.inline(text, combine(base, style)), style, base)
+}
+
+// closingDelimiter returns the position of the delimiter closing the one
+// text starts with, or -1. Opening delimiters must be followed and closing
+// ones preceded by a non-space, and single underscores must end a word.
+func closingDelimiter(text, delim string) int {
+	n := len(delim)
+	if len(text) <= n || text[n] == ' ' || text[n] == delim[0] && n == 1 {
+		return -1
+	}
+	for i := n + 1; i+n <= len(text); i++ {
+		switch {
+		case text[i] == '`':
+			// Delimiters in code spans do not count
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				i += end + 1
+			}
+		case strings.HasPrefix(text[i:], delim) && text[i-1] != ' ':
+			if n == 1 && i+1 < len(text) && text[i+1] == delim[0] {
+				// Skip the doubled delimiter of a nested strong span
+				i++
+				continue
+			}
+			if delim[0] == '_' && i+n < len(text) && isWordRune(text[i+n:]) {
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// link parses a [label](url) link or ![alt](url) image at the start of text,
+// returning a length of 0 when there is none
+func link(text string) (label, url string, length int) {
+	start := 0
+	if text[0] == '!' {
+		start = 1
+	}
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if i+1 >= len(text) || text[i+1] != '(' {
+				return "", "", 0
+			}
+			end := strings.IndexByte(text[i+2:], ')')
+			if end < 0 {
+				return "", "", 0
+			}
+			url = strings.TrimSpace(text[i+2 : i+2+end])
+			// Drop link titles such as [a](https://example.com "Example")
+			if space := strings.IndexByte(url, ' '); space >= 0 {
+				url = url[:space]
+			}
+			return text[start+1 : i], url, i + 3 + end
+		}
+	}
+	return "", "", 0
+}
+
+// wordBefore reports whether the character before position i is part of a word
+func wordBefore(text string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	c, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// isWordRune reports whether text starts with a letter or digit
+func isWordRune(text string) bool {
+	c, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// styled wraps text in a style, leaving it unchanged for an empty style
+func styled(text, style string) string {
+	if style == "" || text == "" {
+		return text
+	}
+	return "\x1b[" + style + "m" + text + "\x1b[0m"
+}
+
+// span styles text inside a block styled with base, restoring the base style after it
+func span(text, style, base string) string {
+	if style == "" || text == "" {
+		return text
+	}
+	out := "\x1b[" + style + "m" + text + "\x1b[0m"
+	if base != "" {
+		out += "\x1b[" + base + "m"
+	}
+	return out
+}
+
+// combine joins two styles so both apply
+func combine(base, style string) string {
+	switch {
+	case base == "":
+		return style
+	case style == "":
+		return base
+	}
+	return base + ";" + style
+}
+
+// visibleWidth returns the number of columns text takes in a terminal,
+// ignoring escape sequences and counting wide characters twice
+func visibleWidth(text string) int {
+	width := 0
+	for _, c := range ansiSequence.ReplaceAllString(text, "") {
+		width += runeWidth(c)
+	}
+	return width
+}
+
+// runeWidth returns the columns a character takes: two for East Asian wide
+// characters and emoji, none for combining marks
+func runeWidth(c rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, c), c == 0x200d, c >= 0xfe00 && c <= 0xfe0f:
+		return 0
+	case c >= 0x1100 && c <= 0x115f,
+		c >= 0x2e80 && c <= 0xa4cf,
+		c >= 0xac00 && c <= 0xd7a3,
+		c >= 0xf900 && c <= 0xfaff,
+		c >= 0xfe30 && c <= 0xfe4f,
+		c >= 0xff00 && c <= 0xff60,
+		c >= 0xffe0 && c <= 0xffe6,
+		c >= 0x1f300 && c <= 0x1f64f,
+		c >= 0x1f900 && c <= 0x1f9ff,
+		c >= 0x20000 && c <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+// wrap breaks text into lines of at most width columns at spaces, keeping
+// its line breaks. Styles spanning a break are closed at the end of the line
+// and reopened on the next, so each line can be prefixed on its own.
+func wrap(text string, width int) []string {
+	var lines []string
+	active := ""
+
+	for _, hardLine := range strings.Split(text, "\n") {
+		var line strings.Builder
+		line.WriteString(active)
+		lineWidth := 0
+
+		for _, word := range strings.Fields(hardLine) {
+			wordWidth := visibleWidth(word)
+			if lineWidth > 0 && lineWidth+1+wordWidth > width {
+				lines = append(lines, closeLine(line.String(), active))
+				line.Reset()
+				line.WriteString(active)
+				lineWidth = 0
+			}
+			if lineWidth > 0 {
+				line.WriteByte(' ')
+				lineWidth++
+			}
+			line.WriteString(word)
+			lineWidth += wordWidth
+			active = activeStyle(active, word)
+		}
+		lines = append(lines, closeLine(line.String(), active))
+	}
+	return lines
+}
+
+// closeLine resets the styles still active at the end of a line
+func closeLine(line, active string) string {
+	if active == "" {
+		return line
+	}
+	return line + "\x1b[0m"
+}
+
+// activeStyle returns the escape sequences in effect after text, starting from active
+func activeStyle(active, text string) string {
+	for _, sequence := range ansiSequence.FindAllString(text, -1) {
+		if sequence == "\x1b[0m" {
+			active = ""
+		} else {
+			active += sequence
+		}
+	}
+	return active
+}
@@ -0,0 +1,119 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	input := `# Title
+
+Some *text* that is long enough to wrap at thirty columns.
+
+- one
+- two
+  - nested
+
+1. first
+2. second
+
+` + "```go\nfunc main() {\n\tprintln(\"a  b\")\n}\n```" + `
+
+> quoted
+
+| Name | Size |
+|------|-----:|
+| a | 1 |
+| bb | 22 |`
+
+	// Without styles the structure can be compared as plain text
+	got := New(30, Theme{}).Render(input)
+	want := `# Title
+
+Some text that is long enough
+to wrap at thirty columns.
+
+• one
+• two
+  ◦ nested
+
+1. first
+2. second
+
+go
+  func main() {
+      println("a  b")
+  }
+
+│ quoted
+
+Name │ Size
+─────┼─────
+a    │    1
+bb   │   22`
+	if got != want {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", want, got)
+	}
+}
+
+func TestRenderStyles(t *testing.T) {
+	got := New(80, Dark).Render("Use **bold `code`** and [docs](https://example.com).")
+	want := "Use \x1b[1mbold \x1b[93mcode\x1b[0m\x1b[1m\x1b[0m and \x1b[4;94mdocs\x1b[0m \x1b[2m(https://example.com)\x1b[0m."
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// Underscores inside words and unclosed markers stay as they are
+	if got := New(80, Dark).Render("snake_case_name and 2 * 3"); got != "snake_case_name and 2 * 3" {
+		t.Errorf("Expected text without emphasis, got %q", got)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := wrap("plain \x1b[1mbold words here\x1b[0m end", 12)
+	want := []string{
+		"plain \x1b[1mbold\x1b[0m",
+		"\x1b[1mwords here\x1b[0m",
+		"end",
+	}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected styles closed and reopened at breaks %q, got %q", want, lines)
+	}
+
+	if width := visibleWidth("\x1b[1m日本\x1b[0m"); width != 4 {
+		t.Errorf("Expected wide characters to take two columns, got %d", width)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	h := newHighlighter("go", Dark)
+	got := h.line(`if x := "if"; x != nil { // done`)
+	want := "\x1b[95mif\x1b[0m x := \x1b[92m\"if\"\x1b[0m; x != \x1b[96mnil\x1b[0m { \x1b[90;3m// done\x1b[0m"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// Block comments continue on the following lines
+	h.line("/* start")
+	if got := h.line("return */ return"); got != "\x1b[90;3mreturn */\x1b[0m \x1b[95mreturn\x1b[0m" {
+		t.Errorf("Expected comment closed before the keyword, got %q", got)
+	}
+
+	if newHighlighter("brainfuck", Dark) != nil {
+		t.Error("Expected no highlighter for an unknown language")
+	}
+}
+
+func TestThemeNamed(t *testing.T) {
+	t.Setenv("COLORFGBG", "0;15")
+	if theme, err := ThemeNamed(ThemeAuto); err != nil || theme != Light {
+		t.Errorf("Expected light theme on a light background, got %v", err)
+	}
+	t.Setenv("COLORFGBG", "")
+	if theme, err := ThemeNamed(ThemeAuto); err != nil || theme != Dark {
+		t.Errorf("Expected dark theme by default, got %v", err)
+	}
+	if _, err := ThemeNamed("solarized"); err == nil {
+		t.Error("Expected error for unknown theme")
+	}
+}
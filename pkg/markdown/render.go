@@ -0,0 +1,325 @@
+// Package markdown renders markdown for the terminal, styling it with ANSI
+// escape sequences, wrapping text to the terminal width and highlighting
+// code blocks.
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// minWidth is the narrowest width text is wrapped to
+const minWidth = 20
+
+var (
+	headingLine   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	ruleLine      = regexp.MustCompile(`^ {0,3}(?:(?:\*\s*){3,}|(?:-\s*){3,}|(?:_\s*){3,})$`)
+	listItemLine  = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])(?:\s+(.*))?$`)
+	tableDivider  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?\s*$`)
+	fenceLine     = regexp.MustCompile("^( {0,3})(```+|~~~+)\\s*([^`\\s]*)(.*)$")
+	setextDivider = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+)
+
+// Renderer renders markdown for a terminal
+type Renderer struct {
+	Width int // Columns text is wrapped to
+	Theme Theme
+}
+
+// New returns a renderer wrapping text to width columns
+func New(width int, theme Theme) *Renderer {
+	return &Renderer{Width: width, Theme: theme}
+}
+
+// Render renders a markdown document
+func (r *Renderer) Render(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = expandIndent(line)
+	}
+	return strings.Join(r.blocks(lines, max(r.Width, minWidth), 0), "\n\n")
+}
+
+// blocks renders the blocks making up lines, with depth the nesting of lists
+func (r *Renderer) blocks(lines []string, width, depth int) []string {
+	var blocks []string
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case fenceLine.MatchString(line):
+			match := fenceLine.FindStringSubmatch(line)
+			indent, fence, info := len(match[1]), match[2], match[3]
+			var code []string
+			for i++; i < len(lines); i++ {
+				if closing := strings.TrimSpace(lines[i]); strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]) == "" {
+					i++
+					break
+				}
+				code = append(code, dedent(lines[i], indent))
+			}
+			blocks = append(blocks, r.codeBlock(info, code))
+
+		case headingLine.MatchString(line):
+			match := headingLine.FindStringSubmatch(line)
+			blocks = append(blocks, r.heading(len(match[1]), match[2], width))
+			i++
+
+		case ruleLine.MatchString(line):
+			blocks = append(blocks, styled(strings.Repeat("─", width), r.Theme.Rule))
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				content := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(content, " "))
+			}
+			blocks = append(blocks, r.quote(quoted, width, depth))
+
+		case listItemLine.MatchString(line):
+			start := i
+			for i++; i < len(lines); i++ {
+				if !continuesList(lines, start, i) {
+					break
+				}
+			}
+			blocks = append(blocks, r.list(lines[start:i], width, depth))
+
+		case isTableStart(lines, i):
+			start := i
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+			}
+			blocks = append(blocks, r.table(lines[start:i], width))
+
+		default:
+			var paragraph []string
+			level := 0
+			for ; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) == "" || (len(paragraph) > 0 && startsBlock(lines, i)) {
+					break
+				}
+				if len(paragraph) > 0 && setextDivider.MatchString(lines[i]) {
+					level = 2
+					if strings.TrimSpace(lines[i])[0] == '=' {
+						level = 1
+					}
+					i++
+					break
+				}
+				paragraph = append(paragraph, lines[i])
+			}
+			if level > 0 {
+				blocks = append(blocks, r.heading(level, strings.Join(trimAll(paragraph), " "), width))
+			} else {
+				blocks = append(blocks, r.paragraph(paragraph, width))
+			}
+		}
+	}
+	return blocks
+}
+
+// startsBlock reports whether a line starts a block other than a paragraph
+func startsBlock(lines []string, i int) bool {
+	line := lines[i]
+	return fenceLine.MatchString(line) ||
+		headingLine.MatchString(line) ||
+		ruleLine.MatchString(line) ||
+		strings.HasPrefix(strings.TrimSpace(line), ">") ||
+		listItemLine.MatchString(line) ||
+		isTableStart(lines, i)
+}
+
+// continuesList reports whether line i belongs to the list starting at line
+// start: another item of the same kind, an indented line, a lazy continuation
+// of the previous line, or a blank line followed by any of these
+func continuesList(lines []string, start, i int) bool {
+	line := lines[i]
+	if strings.TrimSpace(line) == "" {
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) != "" {
+				return sameList(lines[start], lines[j]) || leadingSpaces(lines[j]) > 0
+			}
+		}
+		return false
+	}
+	if listItemLine.MatchString(line) {
+		return leadingSpaces(line) > leadingSpaces(lines[start]) || sameList(lines[start], line)
+	}
+	if leadingSpaces(line) > 0 {
+		return true
+	}
+	return strings.TrimSpace(lines[i-1]) != "" && !startsBlock(lines, i)
+}
+
+// sameList reports whether two list items belong to the same list, which
+// changing between numbers and bullets or between bullet characters ends
+func sameList(first, item string) bool {
+	a, b := listItemLine.FindStringSubmatch(first), listItemLine.FindStringSubmatch(item)
+	if a == nil || b == nil {
+		return false
+	}
+	return listMarkerKind(a[2]) == listMarkerKind(b[2])
+}
+
+// listMarkerKind returns the bullet character of a marker, or the delimiter of a number
+func listMarkerKind(marker string) byte {
+	return marker[len(marker)-1]
+}
+
+// isTableStart reports whether a table with a header row starts at line i
+func isTableStart(lines []string, i int) bool {
+	return i+1 < len(lines) &&
+		strings.Contains(lines[i], "|") &&
+		strings.Contains(lines[i+1], "|") &&
+		tableDivider.MatchString(lines[i+1])
+}
+
+// heading renders a heading, keeping its hashes to show the level
+func (r *Renderer) heading(level int, text string, width int) string {
+	prefix := strings.Repeat("#", level) + " "
+	content := r.inline(prefix+text, r.Theme.Heading)
+	return strings.Join(wrap(styled(content, r.Theme.Heading), width), "\n")
+}
+
+// paragraph renders lines of text as one wrapped paragraph, keeping hard
+// line breaks written as two trailing spaces or a backslash
+func (r *Renderer) paragraph(lines []string, width int) string {
+	var text strings.Builder
+	for i, line := range lines {
+		hardBreak := strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\")
+		text.WriteString(strings.TrimSuffix(strings.TrimSpace(line), "\\"))
+		if i < len(lines)-1 {
+			if hardBreak {
+				text.WriteByte('\n')
+			} else {
+				text.WriteByte(' ')
+			}
+		}
+	}
+	return strings.Join(wrap(r.inline(text.String(), ""), width), "\n")
+}
+
+// quote renders the blocks of a block quote behind a bar
+func (r *Renderer) quote(lines []string, width, depth int) string {
+	bar := styled("│", r.Theme.Quote) + " "
+	inner := strings.Join(r.blocks(lines, max(width-2, minWidth), depth), "\n\n")
+
+	var out []string
+	for _, line := range strings.Split(inner, "\n") {
+		out = append(out, bar+line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// listItem is an item of a list with its marker and dedented lines
+type listItem struct {
+	marker string
+	lines  []string
+}
+
+// bullets are the markers of unordered list items by nesting depth
+var bullets = []string{"•", "◦", "▪"}
+
+// list renders a list, rendering the content of each item as blocks so
+// items can hold paragraphs, code and nested lists
+func (r *Renderer) list(lines []string, width, depth int) string {
+	var items []listItem
+	contentIndent := 0
+	for _, line := range lines {
+		match := listItemLine.FindStringSubmatch(line)
+		if match != nil && (len(items) == 0 || len(match[1]) < contentIndent) {
+			marker := match[2]
+			if !strings.ContainsAny(marker[len(marker)-1:], ".)") {
+				marker = bullets[min(depth, len(bullets)-1)]
+			}
+			content := match[3]
+			switch {
+			case strings.HasPrefix(content, "[ ] "):
+				content = "☐ " + content[4:]
+			case strings.HasPrefix(content, "[x] "), strings.HasPrefix(content, "[X] "):
+				content = "☑ " + content[4:]
+			}
+			items = append(items, listItem{marker: marker, lines: []string{content}})
+			contentIndent = len(match[1]) + len(match[2]) + 1
+			continue
+		}
+		item := &items[len(items)-1]
+		item.lines = append(item.lines, dedent(line, contentIndent))
+	}
+
+	// Markers of ordered lists are padded to the widest number
+	markerWidth := 0
+	for _, item := range items {
+		markerWidth = max(markerWidth, visibleWidth(item.marker))
+	}
+
+	var out []string
+	for _, item := range items {
+		marker := item.marker + strings.Repeat(" ", markerWidth-visibleWidth(item.marker)) + " "
+		indent := strings.Repeat(" ", visibleWidth(marker))
+		content := strings.Join(r.blocks(item.lines, max(width-len(indent), minWidth), depth+1), "\n")
+		for i, line := range strings.Split(content, "\n") {
+			switch {
+			case i == 0:
+				out = append(out, styled(marker, r.Theme.ListMarker)+line)
+			case line == "":
+				out = append(out, "")
+			default:
+				out = append(out, indent+line)
+			}
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// codeBlock renders a fenced code block below its language, highlighted when the language is known
+func (r *Renderer) codeBlock(info string, code []string) string {
+	var out []string
+	if info != "" {
+		out = append(out, styled(info, r.Theme.Language))
+	}
+
+	isDiff := info == "diff" || info == "patch"
+	highlighter := newHighlighter(info, r.Theme)
+	for _, line := range code {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		switch {
+		case isDiff:
+			line = highlightDiff(line, r.Theme)
+		case highlighter != nil:
+			line = highlighter.line(line)
+		}
+		out = append(out, "  "+line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// dedent removes up to n leading spaces from a line
+func dedent(line string, n int) string {
+	return line[min(leadingSpaces(line), n):]
+}
+
+// leadingSpaces counts the spaces a line starts with
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// expandIndent replaces the tabs a line is indented with by four spaces each
+func expandIndent(line string) string {
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	return strings.ReplaceAll(line[:indent], "\t", "    ") + line[indent:]
+}
+
+// trimAll trims the spaces around each line
+func trimAll(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	return trimmed
+}
@@ -0,0 +1,152 @@
+package markdown
+
+import (
+	"strings"
+)
+
+// Alignments of table columns
+const (
+	alignLeft = iota
+	alignCenter
+	alignRight
+)
+
+// minColumnWidth is the narrowest columns are shrunk to when a table is wider than the terminal
+const minColumnWidth = 6
+
+// table renders a table with a header row, shrinking the widest columns
+// and wrapping their cells when it does not fit the width
+func (r *Renderer) table(lines []string, width int) string {
+	header := splitRow(lines[0])
+	columns := len(header)
+
+	var aligns []int
+	for _, cell := range splitRow(lines[1]) {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, alignCenter)
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, alignRight)
+		default:
+			aligns = append(aligns, alignLeft)
+		}
+	}
+
+	// Style the cells and measure the columns
+	rows := [][]string{}
+	for i, line := range append(lines[:1:1], lines[2:]...) {
+		cells := splitRow(line)
+		row := make([]string, columns)
+		for j := range row {
+			if j >= len(cells) {
+				continue
+			}
+			if i == 0 {
+				row[j] = styled(r.inline(cells[j], r.Theme.TableHeader), r.Theme.TableHeader)
+			} else {
+				row[j] = r.inline(cells[j], "")
+			}
+		}
+		rows = append(rows, row)
+	}
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for j, cell := range row {
+			widths[j] = max(widths[j], visibleWidth(cell))
+		}
+	}
+
+	// Shrink the widest column until the table fits
+	available := width - 3*(columns-1)
+	for {
+		total, widest := 0, 0
+		for j, w := range widths {
+			total += w
+			if w > widths[widest] {
+				widest = j
+			}
+		}
+		if total <= available || widths[widest] <= minColumnWidth {
+			break
+		}
+		widths[widest]--
+	}
+
+	separator := styled(" │ ", r.Theme.Rule)
+	var out []string
+	for i, row := range rows {
+		// Wrap each cell to its column, the row as high as its tallest cell
+		wrapped := make([][]string, columns)
+		height := 1
+		for j, cell := range row {
+			wrapped[j] = wrap(cell, widths[j])
+			height = max(height, len(wrapped[j]))
+		}
+		for k := 0; k < height; k++ {
+			cells := make([]string, columns)
+			for j := range cells {
+				text := ""
+				if k < len(wrapped[j]) {
+					text = wrapped[j][k]
+				}
+				align := alignLeft
+				if j < len(aligns) {
+					align = aligns[j]
+				}
+				cells[j] = pad(text, widths[j], align)
+			}
+			out = append(out, strings.TrimRight(strings.Join(cells, separator), " "))
+		}
+
+		if i == 0 {
+			var rules []string
+			for _, w := range widths {
+				rules = append(rules, strings.Repeat("─", w))
+			}
+			out = append(out, styled(strings.Join(rules, "─┼─"), r.Theme.Rule))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// splitRow splits a table row into its trimmed cells, ignoring escaped
+// pipes and pipes in code spans
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	inCode := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case c == '`':
+			inCode = !inCode
+			cell.WriteByte(c)
+		case c == '|' && !inCode:
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(c)
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// pad aligns text in a column of the given width
+func pad(text string, width, align int) string {
+	space := max(width-visibleWidth(text), 0)
+	switch align {
+	case alignRight:
+		return strings.Repeat(" ", space) + text
+	case alignCenter:
+		return strings.Repeat(" ", space/2) + text + strings.Repeat(" ", space-space/2)
+	}
+	return text + strings.Repeat(" ", space)
+}
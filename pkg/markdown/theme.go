@@ -0,0 +1,109 @@
+package markdown
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Theme names, the values of the render.theme config key
+const (
+	ThemeAuto  = "auto"
+	ThemeDark  = "dark"
+	ThemeLight = "light"
+)
+
+// Theme holds the styles markdown is rendered with, as ANSI SGR parameters
+// such as "1;36" for bold cyan. Empty styles leave text unstyled.
+type Theme struct {
+	Heading     string
+	Strong      string
+	Emphasis    string
+	Strike      string
+	Code        string // Inline code spans
+	Link        string
+	URL         string // Link targets shown after the link text
+	Quote       string // Bar in front of block quotes
+	Rule        string // Horizontal rules and table borders
+	Language    string // Label above code blocks
+	Keyword     string
+	String      string
+	Number      string // Numbers and literals such as true and nil
+	Comment     string
+	Inserted    string // Added lines in diffs
+	Deleted     string // Removed lines in diffs
+	DiffHeader  string // Hunk headers in diffs
+	ListMarker  string
+	TableHeader string
+}
+
+// Dark is the theme for terminals with a dark background
+var Dark = Theme{
+	Heading:     "1;96",
+	Strong:      "1",
+	Emphasis:    "3",
+	Strike:      "9",
+	Code:        "93",
+	Link:        "4;94",
+	URL:         "2",
+	Quote:       "90",
+	Rule:        "90",
+	Language:    "2;3",
+	Keyword:     "95",
+	String:      "92",
+	Number:      "96",
+	Comment:     "90;3",
+	Inserted:    "92",
+	Deleted:     "91",
+	DiffHeader:  "96",
+	ListMarker:  "96",
+	TableHeader: "1",
+}
+
+// Light is the theme for terminals with a light background
+var Light = Theme{
+	Heading:     "1;34",
+	Strong:      "1",
+	Emphasis:    "3",
+	Strike:      "9",
+	Code:        "35",
+	Link:        "4;34",
+	URL:         "2",
+	Quote:       "37",
+	Rule:        "37",
+	Language:    "2;3",
+	Keyword:     "34",
+	String:      "32",
+	Number:      "36",
+	Comment:     "90;3",
+	Inserted:    "32",
+	Deleted:     "31",
+	DiffHeader:  "36",
+	ListMarker:  "34",
+	TableHeader: "1",
+}
+
+// ThemeNamed returns the theme with a name, detecting the terminal
+// background for auto
+func ThemeNamed(name string) (Theme, error) {
+	switch name {
+	case ThemeDark:
+		return Dark, nil
+	case ThemeLight:
+		return Light, nil
+	case ThemeAuto, "":
+		return DetectTheme(), nil
+	}
+	return Theme{}, fmt.Errorf("unknown theme %q, use %s, %s or %s", name, ThemeAuto, ThemeDark, ThemeLight)
+}
+
+// DetectTheme picks the theme for the terminal background from COLORFGBG,
+// which terminals such as rxvt, Konsole and iTerm2 set, falling back to dark
+func DetectTheme() Theme {
+	colors := strings.Split(os.Getenv("COLORFGBG"), ";")
+	switch colors[len(colors)-1] {
+	case "7", "15":
+		return Light
+	}
+	return Dark
+}
@@ -0,0 +1,486 @@
+// Package rag indexes a directory of documents as embedding vectors in SQLite
+// and retrieves the chunks most similar to a question, to answer it with
+// citations.
+package rag
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// Chunk sizes in tokens, small enough for every embedding model
+const (
+	chunkTokens   = 400
+	overlapTokens = 50
+)
+
+// maxFileBytes is the size above which files are not indexed
+const maxFileBytes = 1 << 20
+
+// embedGroupChunks is the number of chunks embedded and saved together, so
+// an interrupted run keeps most of its work
+const embedGroupChunks = 100
+
+// Embedder turns texts into vectors, implemented by llm.Service
+type Embedder interface {
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
+
+// Chunk is a part of an indexed file
+type Chunk struct {
+	Path      string // Path relative to the indexed directory, with forward slashes
+	StartLine int
+	EndLine   int
+	Text      string
+}
+
+// Citation returns the path and line range of the chunk
+func (c Chunk) Citation() string {
+	if c.StartLine == c.EndLine {
+		return fmt.Sprintf("%s:%d", c.Path, c.StartLine)
+	}
+	return fmt.Sprintf("%s:%d-%d", c.Path, c.StartLine, c.EndLine)
+}
+
+// Stats describes what an update of an index did
+type Stats struct {
+	Files     int // Files found in the directory
+	Indexed   int // New or changed files that were embedded
+	Unchanged int
+	Removed   int // Files no longer in the directory
+	Chunks    int // Chunks embedded
+}
+
+// Index is a SQLite database of chunk vectors for one directory
+type Index struct {
+	db *sql.DB
+}
+
+// indexNamePattern matches valid index names
+var indexNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// Path returns the database path of a named index in the config directory
+func Path(configDir, name string) (string, error) {
+	if !indexNamePattern.MatchString(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid index name %q, use letters, digits, '.', '-' and '_'", name)
+	}
+	return filepath.Join(configDir, "indexes", name+".db"), nil
+}
+
+// Open opens or creates the index database at path
+func Open(path string) (*Index, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS settings (
+			name TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS files (
+			path TEXT PRIMARY KEY,
+			mod_time INTEGER NOT NULL,
+			hash TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS chunks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			path TEXT NOT NULL,
+			start_line INTEGER NOT NULL,
+			end_line INTEGER NOT NULL,
+			text TEXT NOT NULL,
+			vector BLOB NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS chunks_path ON chunks (path);
+	`)
+	if err != nil {
+		if err := db.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing database: %v\n", err)
+		}
+		return nil, fmt.Errorf("failed to create index tables: %w", err)
+	}
+
+	return &Index{db: db}, nil
+}
+
+// Close closes the index database
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// Setting returns a stored setting such as the directory or embedding model, "" if unset
+func (ix *Index) Setting(name string) (string, error) {
+	var value string
+	err := ix.db.QueryRow("SELECT value FROM settings WHERE name = ?", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read index setting %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// setSetting stores a setting
+func (ix *Index) setSetting(name, value string) error {
+	if _, err := ix.db.Exec("INSERT OR REPLACE INTO settings (name, value) VALUES (?, ?)", name, value); err != nil {
+		return fmt.Errorf("failed to save index setting %s: %w", name, err)
+	}
+	return nil
+}
+
+// indexedFile is the state of a file when it was last indexed
+type indexedFile struct {
+	modTime int64
+	hash    string
+}
+
+// pendingFile is a new or changed file waiting to be embedded
+type pendingFile struct {
+	path    string
+	modTime int64
+	hash    string
+	chunks  []Chunk
+}
+
+// Update brings the index of a directory up to date. Files whose
+// modification time and content hash are unchanged are skipped, files no
+// longer in the directory are removed. Changing the model re-embeds everything.
+func (ix *Index) Update(ctx context.Context, embedder Embedder, root, model string, progress func(done, total int)) (Stats, error) {
+	var stats Stats
+
+	// Vectors of different models cannot be compared, so start over
+	storedModel, err := ix.Setting("model")
+	if err != nil {
+		return stats, err
+	}
+	if storedModel != "" && storedModel != model {
+		if _, err := ix.db.Exec("DELETE FROM chunks; DELETE FROM files;"); err != nil {
+			return stats, fmt.Errorf("failed to clear index: %w", err)
+		}
+	}
+	if err := ix.setSetting("model", model); err != nil {
+		return stats, err
+	}
+	if err := ix.setSetting("root", root); err != nil {
+		return stats, err
+	}
+
+	indexed, err := ix.files()
+	if err != nil {
+		return stats, err
+	}
+
+	paths, err := collectFiles(root)
+	if err != nil {
+		return stats, err
+	}
+	stats.Files = len(paths)
+
+	// Find new and changed files
+	var pending []pendingFile
+	present := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		present[path] = true
+
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			return stats, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		modTime := info.ModTime().UnixNano()
+		previous, seen := indexed[path]
+		if seen && previous.modTime == modTime {
+			stats.Unchanged++
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			return stats, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+
+		// A touched but unchanged file only needs its time updated
+		if seen && previous.hash == hash {
+			if _, err := ix.db.Exec("UPDATE files SET mod_time = ? WHERE path = ?", modTime, path); err != nil {
+				return stats, fmt.Errorf("failed to update %s: %w", path, err)
+			}
+			stats.Unchanged++
+			continue
+		}
+
+		pending = append(pending, pendingFile{
+			path:    path,
+			modTime: modTime,
+			hash:    hash,
+			chunks:  SplitFile(path, string(content)),
+		})
+	}
+
+	// Remove files that are gone
+	for path := range indexed {
+		if present[path] {
+			continue
+		}
+		if err := ix.removeFile(path); err != nil {
+			return stats, err
+		}
+		stats.Removed++
+	}
+
+	// Embed and save changed files in groups
+	for start := 0; start < len(pending); {
+		end, chunks := start, 0
+		for end < len(pending) && (end == start || chunks+len(pending[end].chunks) <= embedGroupChunks) {
+			chunks += len(pending[end].chunks)
+			end++
+		}
+
+		if err := ix.saveFiles(ctx, embedder, model, pending[start:end]); err != nil {
+			return stats, err
+		}
+		stats.Indexed += end - start
+		stats.Chunks += chunks
+		if progress != nil {
+			progress(stats.Indexed, len(pending))
+		}
+		start = end
+	}
+
+	return stats, nil
+}
+
+// files returns the indexed files by path
+func (ix *Index) files() (map[string]indexedFile, error) {
+	rows, err := ix.db.Query("SELECT path, mod_time, hash FROM files")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexed files: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing rows: %v\n", err)
+		}
+	}()
+
+	files := make(map[string]indexedFile)
+	for rows.Next() {
+		var path string
+		var file indexedFile
+		if err := rows.Scan(&path, &file.modTime, &file.hash); err != nil {
+			return nil, fmt.Errorf("failed to read indexed file: %w", err)
+		}
+		files[path] = file
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read indexed files: %w", err)
+	}
+	return files, nil
+}
+
+// removeFile deletes a file and its chunks from the index
+func (ix *Index) removeFile(path string) error {
+	if _, err := ix.db.Exec("DELETE FROM chunks WHERE path = ?", path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	if _, err := ix.db.Exec("DELETE FROM files WHERE path = ?", path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// saveFiles embeds the chunks of files and replaces their earlier chunks in one transaction
+func (ix *Index) saveFiles(ctx context.Context, embedder Embedder, model string, files []pendingFile) error {
+	var texts []string
+	for _, file := range files {
+		for _, chunk := range file.chunks {
+			texts = append(texts, chunk.Text)
+		}
+	}
+
+	var vectors [][]float32
+	if len(texts) > 0 {
+		var err error
+		vectors, err = embedder.Embed(ctx, model, texts)
+		if err != nil {
+			return err
+		}
+		if len(vectors) != len(texts) {
+			return fmt.Errorf("expected %d vectors, got %d", len(texts), len(vectors))
+		}
+	}
+
+	tx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		// Rolling back after a commit does nothing
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			fmt.Fprintf(os.Stderr, "Error rolling back: %v\n", err)
+		}
+	}()
+
+	i := 0
+	for _, file := range files {
+		if _, err := tx.Exec("DELETE FROM chunks WHERE path = ?", file.path); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", file.path, err)
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO files (path, mod_time, hash) VALUES (?, ?, ?)", file.path, file.modTime, file.hash); err != nil {
+			return fmt.Errorf("failed to save %s: %w", file.path, err)
+		}
+		for _, chunk := range file.chunks {
+			_, err := tx.Exec(
+				"INSERT INTO chunks (path, start_line, end_line, text, vector) VALUES (?, ?, ?, ?, ?)",
+				chunk.Path, chunk.StartLine, chunk.EndLine, chunk.Text, encodeVector(vectors[i]),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to save chunk of %s: %w", file.path, err)
+			}
+			i++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	return nil
+}
+
+// SplitFile splits the content of a file into chunks with line numbers,
+// using the same splitting as chunked queries
+func SplitFile(path, content string) []Chunk {
+	var chunks []Chunk
+	for _, chunk := range llm.SplitTextChunks("", content, chunkTokens, overlapTokens) {
+		if strings.TrimSpace(chunk.Text) == "" {
+			continue
+		}
+		startLine := strings.Count(content[:chunk.Offset], "\n") + 1
+		chunks = append(chunks, Chunk{
+			Path:      path,
+			StartLine: startLine,
+			EndLine:   startLine + strings.Count(strings.TrimRight(chunk.Text, "\n"), "\n"),
+			Text:      chunk.Text,
+		})
+	}
+	return chunks
+}
+
+// textExtensions are the extensions of files indexed as text
+var textExtensions = map[string]bool{
+	".md": true, ".markdown": true, ".txt": true, ".rst": true, ".adoc": true, ".org": true,
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".java": true,
+	".kt": true, ".scala": true, ".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true,
+	".cs": true, ".rs": true, ".rb": true, ".php": true, ".swift": true, ".m": true, ".lua": true,
+	".sh": true, ".bash": true, ".zsh": true, ".sql": true, ".proto": true, ".graphql": true,
+	".html": true, ".css": true, ".scss": true, ".vue": true, ".svelte": true,
+	".yaml": true, ".yml": true, ".toml": true, ".json": true, ".xml": true, ".ini": true, ".tf": true,
+}
+
+// textNames are files without a text extension that are indexed
+var textNames = map[string]bool{
+	"Makefile": true, "Dockerfile": true, "README": true, "LICENSE": true,
+}
+
+// skippedDirs are directories of dependencies and build output
+var skippedDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "build": true, "target": true, "__pycache__": true,
+}
+
+// collectFiles returns the text files below root as slash-separated relative
+// paths, skipping hidden entries, dependency directories, large and binary files
+func collectFiles(root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if path != root && strings.HasPrefix(name, ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if skippedDirs[name] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || (!textExtensions[strings.ToLower(filepath.Ext(name))] && !textNames[name]) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > maxFileBytes {
+			return nil
+		}
+		if binary, err := isBinary(path); err != nil || binary {
+			return err
+		}
+
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(relative))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", root, err)
+	}
+	return paths, nil
+}
+
+// isBinary reports whether a file looks binary: it has NUL bytes or is not UTF-8
+func isBinary(path string) (bool, error) {
+	// Files are small enough to check whole
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return bytes.IndexByte(content, 0) >= 0 || !utf8.Valid(content), nil
+}
+
+// encodeVector encodes a vector as little-endian float32 values
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return data
+}
+
+// decodeVector decodes a vector encoded by encodeVector
+func decodeVector(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector
+}
@@ -0,0 +1,203 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wordEmbedder embeds texts as counts of a few words, recording what it embedded
+type wordEmbedder struct {
+	words    []string
+	embedded []string
+}
+
+func (e *wordEmbedder) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		e.embedded = append(e.embedded, text)
+		vectors[i] = make([]float32, len(e.words))
+		for j, word := range e.words {
+			vectors[i][j] = float32(strings.Count(strings.ToLower(text), word))
+		}
+	}
+	return vectors, nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestIndexUpdateAndSearch(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "gollm-rag-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	root := filepath.Join(tmpDir, "docs")
+	writeFile(t, filepath.Join(root, "cats.md"), "# Cats\n\nCats purr and chase mice.\n")
+	writeFile(t, filepath.Join(root, "src", "dogs.go"), "package dogs\n\n// Dogs bark at the mail carrier\n")
+	writeFile(t, filepath.Join(root, "image.png"), "not indexed")
+	writeFile(t, filepath.Join(root, "binary.txt"), "dogs\x00dogs")
+	writeFile(t, filepath.Join(root, ".git", "config"), "dogs")
+	writeFile(t, filepath.Join(root, "node_modules", "dep.js"), "dogs")
+
+	path, err := Path(tmpDir, "docs")
+	if err != nil {
+		t.Fatalf("Path returned error: %v", err)
+	}
+	index, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer func() {
+		if err := index.Close(); err != nil {
+			t.Errorf("Failed to close index: %v", err)
+		}
+	}()
+
+	embedder := &wordEmbedder{words: []string{"cats", "dogs", "bark"}}
+	stats, err := index.Update(context.Background(), embedder, root, "test-model", nil)
+	if err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if stats.Files != 2 || stats.Indexed != 2 || stats.Chunks != 2 {
+		t.Errorf("Expected 2 files indexed in 2 chunks, got %+v", stats)
+	}
+
+	matches, err := index.Search(context.Background(), embedder, "why do dogs bark?", 1)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Path != "src/dogs.go" || matches[0].Citation() != "src/dogs.go:1-3" {
+		t.Errorf("Expected src/dogs.go:1-3 to match, got %+v", matches)
+	}
+
+	prompt := Prompt("why do dogs bark?", matches)
+	if !strings.Contains(prompt, "[1] src/dogs.go:1-3\npackage dogs") || !strings.HasSuffix(prompt, "Question: why do dogs bark?") {
+		t.Errorf("Expected numbered context and question in prompt, got %q", prompt)
+	}
+
+	// Unchanged files are not embedded again
+	embedder.embedded = nil
+	stats, err = index.Update(context.Background(), embedder, root, "test-model", nil)
+	if err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if stats.Unchanged != 2 || stats.Indexed != 0 || len(embedder.embedded) != 0 {
+		t.Errorf("Expected nothing re-embedded, got %+v and %d texts", stats, len(embedder.embedded))
+	}
+
+	// Touched files with the same content are not embedded again either
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "cats.md"), later, later); err != nil {
+		t.Fatalf("Failed to touch file: %v", err)
+	}
+	stats, err = index.Update(context.Background(), embedder, root, "test-model", nil)
+	if err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if stats.Unchanged != 2 || len(embedder.embedded) != 0 {
+		t.Errorf("Expected touched file not re-embedded, got %+v", stats)
+	}
+
+	// Changed files are re-embedded and removed files dropped
+	writeFile(t, filepath.Join(root, "cats.md"), "# Cats\n\nCats and dogs.\n")
+	if err := os.Chtimes(filepath.Join(root, "cats.md"), later.Add(time.Hour), later.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to touch file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(root, "src", "dogs.go")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	stats, err = index.Update(context.Background(), embedder, root, "test-model", nil)
+	if err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if stats.Indexed != 1 || stats.Removed != 1 || len(embedder.embedded) != 1 {
+		t.Errorf("Expected 1 file re-embedded and 1 removed, got %+v", stats)
+	}
+
+	matches, err = index.Search(context.Background(), embedder, "dogs", 5)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Path != "cats.md" {
+		t.Errorf("Expected only cats.md left, got %+v", matches)
+	}
+
+	// Another model re-embeds everything
+	embedder.embedded = nil
+	stats, err = index.Update(context.Background(), embedder, root, "other-model", nil)
+	if err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if stats.Indexed != 1 || len(embedder.embedded) != 1 {
+		t.Errorf("Expected model change to re-embed, got %+v", stats)
+	}
+}
+
+func TestSplitFile(t *testing.T) {
+	var content strings.Builder
+	for i := 1; i <= 400; i++ {
+		content.WriteString("line of some words to fill the chunk\n")
+	}
+
+	chunks := SplitFile("long.txt", content.String())
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+	if chunks[0].StartLine != 1 {
+		t.Errorf("Expected first chunk to start at line 1, got %d", chunks[0].StartLine)
+	}
+	last := chunks[len(chunks)-1]
+	if last.EndLine != 400 {
+		t.Errorf("Expected last chunk to end at line 400, got %d", last.EndLine)
+	}
+	lines := strings.SplitAfter(content.String(), "\n")
+	for i, chunk := range chunks {
+		if text := strings.Join(lines[chunk.StartLine-1:chunk.EndLine], ""); text != chunk.Text {
+			t.Errorf("Expected chunk %d to hold lines %d-%d, got %q", i, chunk.StartLine, chunk.EndLine, chunk.Text)
+		}
+	}
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].StartLine <= chunks[i-1].StartLine || chunks[i].StartLine > chunks[i-1].EndLine+1 {
+			t.Errorf("Expected chunk %d to follow chunk %d, got %d-%d after %d-%d", i, i-1,
+				chunks[i].StartLine, chunks[i].EndLine, chunks[i-1].StartLine, chunks[i-1].EndLine)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	if _, err := Path("/config", "../escape"); err == nil {
+		t.Error("Expected error for name with a path")
+	}
+	if path, err := Path("/config", "my-docs"); err != nil || path != filepath.Join("/config", "indexes", "my-docs.db") {
+		t.Errorf("Expected index in indexes directory, got %q (%v)", path, err)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if score := cosine([]float32{1, 0}, []float32{2, 0}); score < 0.999 {
+		t.Errorf("Expected parallel vectors to score 1, got %f", score)
+	}
+	if score := cosine([]float32{1, 0}, []float32{0, 1}); score != 0 {
+		t.Errorf("Expected orthogonal vectors to score 0, got %f", score)
+	}
+	if score := cosine([]float32{1}, []float32{1, 1}); score != 0 {
+		t.Errorf("Expected vectors of different length to score 0, got %f", score)
+	}
+}
@@ -0,0 +1,106 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Match is a chunk similar to a question
+type Match struct {
+	Chunk
+	Score float64 // Cosine similarity to the question
+}
+
+// Search returns the k chunks most similar to the question, embedding it
+// with the model the index was built with
+func (ix *Index) Search(ctx context.Context, embedder Embedder, question string, k int) ([]Match, error) {
+	model, err := ix.Setting("model")
+	if err != nil {
+		return nil, err
+	}
+	if model == "" {
+		return nil, fmt.Errorf("index is empty, run gollm index first")
+	}
+
+	vectors, err := embedder.Embed(ctx, model, []string{question})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("expected 1 vector, got %d", len(vectors))
+	}
+	query := vectors[0]
+
+	rows, err := ix.db.QueryContext(ctx, "SELECT path, start_line, end_line, text, vector FROM chunks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing rows: %v\n", err)
+		}
+	}()
+
+	var matches []Match
+	for rows.Next() {
+		var match Match
+		var vector []byte
+		if err := rows.Scan(&match.Path, &match.StartLine, &match.EndLine, &match.Text, &vector); err != nil {
+			return nil, fmt.Errorf("failed to read chunk: %w", err)
+		}
+		match.Score = cosine(query, decodeVector(vector))
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// cosine returns the cosine similarity of two vectors, 0 if they differ in
+// length or either is zero
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Prompt builds a prompt asking to answer the question from the matched
+// chunks, citing them by number
+func Prompt(question string, matches []Match) string {
+	var prompt strings.Builder
+	prompt.WriteString("Answer the question using the numbered context below. ")
+	prompt.WriteString("Cite the context you use by its number, like [1] or [2]. ")
+	prompt.WriteString("If the context does not contain the answer, say so.\n\n")
+
+	for i, match := range matches {
+		fmt.Fprintf(&prompt, "[%d] %s\n", i+1, match.Citation())
+		prompt.WriteString(strings.TrimRight(match.Text, "\n"))
+		prompt.WriteString("\n\n")
+	}
+
+	prompt.WriteString("Question: ")
+	prompt.WriteString(question)
+	return prompt.String()
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// handleMessages answers an Anthropic Messages API request with any configured provider
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		writeAnthropicError(w, invalidRequest("error reading request body: "+err.Error()))
+		return
+	}
+
+	request, err := llm.ParseAnthropicRequest(body)
+	if err != nil {
+		writeAnthropicError(w, invalidRequest(err.Error()))
+		return
+	}
+
+	model, err := s.resolveModel(request.Model)
+	if err != nil {
+		writeAnthropicError(w, queryError(err))
+		return
+	}
+	request.Model = model
+
+	if request.Stream {
+		response, err := s.queryStream(w, r, request.Prompt, model, request.Options)
+		if err != nil {
+			if r.Context().Err() == nil {
+				writeAnthropicStreamError(w, queryError(err))
+			}
+			return
+		}
+
+		// The answer is complete, so it is sent as one delta per content block
+		events, err := request.Events(response)
+		if err != nil {
+			writeAnthropicStreamError(w, queryError(err))
+			return
+		}
+		for _, event := range events {
+			if !writeEvent(w, event.Name, event.Data) {
+				return
+			}
+		}
+		return
+	}
+
+	response, _, err := s.service.QueryDetailed(r.Context(), request.Prompt, model, request.Options...)
+	if err != nil {
+		if r.Context().Err() != nil {
+			// The client went away
+			return
+		}
+		writeAnthropicError(w, queryError(err))
+		return
+	}
+
+	message, err := request.Message(response)
+	if err != nil {
+		writeAnthropicError(w, queryError(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	// The client may have gone away, there is nobody left to tell
+	_, _ = w.Write(message)
+}
+
+// writeAnthropicError writes an error in the Anthropic error format
+func writeAnthropicError(w http.ResponseWriter, err *apiError) {
+	writeJSON(w, err.Status, anthropicErrorBody(err))
+}
+
+// writeAnthropicStreamError ends a messages stream with an error event
+func writeAnthropicStreamError(w http.ResponseWriter, err *apiError) {
+	data, marshalErr := json.Marshal(anthropicErrorBody(err))
+	if marshalErr != nil {
+		return
+	}
+	writeEvent(w, "error", data)
+}
+
+// anthropicErrorBody returns an error in the Anthropic error format
+func anthropicErrorBody(err *apiError) map[string]interface{} {
+	errorType := "api_error"
+	switch err.Status {
+	case http.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errorType = "authentication_error"
+	case http.StatusNotFound:
+		errorType = "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		errorType = "request_too_large"
+	}
+
+	return map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    errorType,
+			"message": err.Message,
+		},
+	}
+}
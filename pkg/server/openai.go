@@ -0,0 +1,404 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// chatRequest is an OpenAI chat completion request
+type chatRequest struct {
+	Model               string          `json:"model"`
+	Messages            []chatMessage   `json:"messages"`
+	MaxTokens           int             `json:"max_tokens"`
+	MaxCompletionTokens int             `json:"max_completion_tokens"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	Stop                json.RawMessage `json:"stop"`
+	N                   int             `json:"n"`
+	PresencePenalty     *float64        `json:"presence_penalty"`
+	FrequencyPenalty    *float64        `json:"frequency_penalty"`
+	Seed                *int64          `json:"seed"`
+	ResponseFormat      *struct {
+		Type string `json:"type"`
+	} `json:"response_format"`
+	Stream        bool `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+// chatMessage is a message of a chat completion request, its content is a
+// string or a list of content parts
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text returns the text of a message, joining text content parts
+func (m chatMessage) text() (string, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return "", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return text, nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", fmt.Errorf("content of %s message must be a string or a list of content parts", m.Role)
+	}
+
+	var texts []string
+	for _, part := range parts {
+		if part.Type != "text" {
+			return "", fmt.Errorf("content part type %q is not supported, only text", part.Type)
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// chatResponse is an OpenAI chat completion or, when streamed, one of its chunks
+type chatResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+// chatChoice is one answer of a chat completion
+type chatChoice struct {
+	Index        int                  `json:"index"`
+	Message      *chatResponseMessage `json:"message,omitempty"`
+	Delta        *chatResponseMessage `json:"delta,omitempty"`
+	FinishReason *string              `json:"finish_reason"`
+}
+
+// chatResponseMessage is the assistant message of a choice
+type chatResponseMessage struct {
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+// chatUsage is the token usage of a chat completion
+type chatUsage struct {
+	PromptTokens            int `json:"prompt_tokens"`
+	CompletionTokens        int `json:"completion_tokens"`
+	TotalTokens             int `json:"total_tokens"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+// modelObject is a model in the OpenAI model list
+type modelObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// handleModels lists the models of the configured providers
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	models := []modelObject{}
+	for _, provider := range s.service.ConfiguredProviders() {
+		for _, model := range llm.GetModelsForProvider(provider) {
+			models = append(models, modelObject{ID: model, Object: "model", OwnedBy: provider})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   models,
+	})
+}
+
+// handleModel describes a single model
+func (s *Server) handleModel(w http.ResponseWriter, r *http.Request) {
+	model, err := s.resolveModel(r.PathValue("model"))
+	if err != nil {
+		writeError(w, queryError(err))
+		return
+	}
+
+	provider, _ := llm.GetProviderForModel(model)
+	writeJSON(w, http.StatusOK, modelObject{ID: model, Object: "model", OwnedBy: provider})
+}
+
+// handleChatCompletions answers an OpenAI chat completion request
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var request chatRequest
+	if err := decodeRequest(w, r, &request); err != nil {
+		writeError(w, queryError(err))
+		return
+	}
+
+	model, err := s.resolveModel(request.Model)
+	if err != nil {
+		writeError(w, queryError(err))
+		return
+	}
+
+	prompt, options, err := s.chatOptions(&request)
+	if err != nil {
+		writeError(w, queryError(err))
+		return
+	}
+
+	if request.Stream {
+		response, err := s.queryStream(w, r, prompt, model, options)
+		if err != nil {
+			if r.Context().Err() == nil {
+				writeStreamError(w, queryError(err))
+			}
+			return
+		}
+		includeUsage := request.StreamOptions != nil && request.StreamOptions.IncludeUsage
+		streamChatCompletion(w, chatCompletion(model, response), includeUsage)
+		return
+	}
+
+	response, _, err := s.service.QueryDetailed(r.Context(), prompt, model, options...)
+	if err != nil {
+		if r.Context().Err() != nil {
+			// The client went away
+			return
+		}
+		writeError(w, queryError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, chatCompletion(model, response))
+}
+
+// chatOptions converts a chat completion request into a prompt and query
+// options. System messages become the system prompt, the last message is the
+// prompt and the messages before it are the conversation history.
+func (s *Server) chatOptions(request *chatRequest) (string, []llm.Option, error) {
+	var system []string
+	var history []llm.Message
+	for _, message := range request.Messages {
+		text, err := message.text()
+		if err != nil {
+			return "", nil, invalidRequest(err.Error())
+		}
+
+		switch message.Role {
+		case "system", "developer":
+			system = append(system, text)
+		case "user", "assistant":
+			history = append(history, llm.Message{Role: message.Role, Content: text})
+		default:
+			return "", nil, invalidRequest(fmt.Sprintf("messages with role %q are not supported", message.Role))
+		}
+	}
+
+	if len(history) == 0 || history[len(history)-1].Role != "user" {
+		return "", nil, invalidRequest("the last message must be from the user")
+	}
+	prompt := history[len(history)-1].Content
+	history = history[:len(history)-1]
+
+	maxTokens := s.maxTokens
+	if request.MaxCompletionTokens > 0 {
+		maxTokens = request.MaxCompletionTokens
+	} else if request.MaxTokens > 0 {
+		maxTokens = request.MaxTokens
+	}
+
+	options := []llm.Option{llm.WithMaxTokens(maxTokens)}
+	if len(system) > 0 {
+		options = append(options, llm.WithCustomParam("system", strings.Join(system, "\n\n")))
+	}
+	if len(history) > 0 {
+		options = append(options, llm.WithHistory(history))
+	}
+	if request.Temperature != nil {
+		options = append(options, llm.WithTemperature(*request.Temperature))
+	}
+	if request.TopP != nil {
+		options = append(options, llm.WithCustomParam("top_p", *request.TopP))
+	}
+	if request.PresencePenalty != nil {
+		options = append(options, llm.WithPresencePenalty(*request.PresencePenalty))
+	}
+	if request.FrequencyPenalty != nil {
+		options = append(options, llm.WithFrequencyPenalty(*request.FrequencyPenalty))
+	}
+	if request.Seed != nil {
+		options = append(options, llm.WithSeed(*request.Seed))
+	}
+	if request.N > 1 {
+		options = append(options, llm.WithCandidateCount(request.N))
+	}
+	if request.ResponseFormat != nil && request.ResponseFormat.Type == "json_object" {
+		options = append(options, llm.WithResponseMIMEType("application/json"))
+	}
+
+	stop, err := stopSequences(request.Stop)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(stop) > 0 {
+		options = append(options, llm.WithStopSequences(stop...))
+	}
+
+	return prompt, options, nil
+}
+
+// stopSequences parses the stop parameter, a string or a list of strings
+func stopSequences(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var stop string
+	if err := json.Unmarshal(raw, &stop); err == nil {
+		return []string{stop}, nil
+	}
+
+	var stops []string
+	if err := json.Unmarshal(raw, &stops); err != nil {
+		return nil, invalidRequest("stop must be a string or a list of strings")
+	}
+	return stops, nil
+}
+
+// chatCompletion converts a response into a chat completion
+func chatCompletion(model string, response *llm.Response) *chatResponse {
+	created := response.Metadata.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	completion := &chatResponse{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Object:  "chat.completion",
+		Created: created.Unix(),
+		Model:   model,
+		Usage: &chatUsage{
+			PromptTokens:     response.Usage.InputTokens,
+			CompletionTokens: response.Usage.OutputTokens + response.Usage.ThinkingTokens,
+			TotalTokens:      response.Usage.Total(),
+		},
+	}
+	completion.Usage.CompletionTokensDetails.ReasoningTokens = response.Usage.ThinkingTokens
+
+	// The first choice is the answer, alternatives follow when several were requested
+	completion.Choices = append(completion.Choices, chatChoice{
+		Message:      &chatResponseMessage{Role: "assistant", Content: response.Text, ReasoningContent: response.Thinking},
+		FinishReason: finishReason(response.Metadata.StopReason),
+	})
+	for i, candidate := range response.Candidates {
+		if i == 0 {
+			continue
+		}
+		completion.Choices = append(completion.Choices, chatChoice{
+			Index:        i,
+			Message:      &chatResponseMessage{Role: "assistant", Content: candidate.Text},
+			FinishReason: finishReason(candidate.FinishReason),
+		})
+	}
+
+	return completion
+}
+
+// finishReason maps a stop reason to an OpenAI finish reason. Candidates
+// carry the provider's own finish reason, which is mapped as well.
+func finishReason(stopReason string) *string {
+	reason := "stop"
+	switch stopReason {
+	case llm.StopReasonMaxTokens, "MAX_TOKENS":
+		reason = "length"
+	case llm.StopReasonContentBlock, "SAFETY", "RECITATION":
+		reason = "content_filter"
+	case llm.StopReasonToolUse:
+		reason = "tool_calls"
+	}
+	return &reason
+}
+
+// streamChatCompletion sends a completion on a stream started by
+// queryStream. The answer is complete, so each choice arrives as a single chunk.
+func streamChatCompletion(w http.ResponseWriter, completion *chatResponse, includeUsage bool) {
+	chunk := func(choices []chatChoice, usage *chatUsage) *chatResponse {
+		return &chatResponse{
+			ID:      completion.ID,
+			Object:  "chat.completion.chunk",
+			Created: completion.Created,
+			Model:   completion.Model,
+			Choices: choices,
+			Usage:   usage,
+		}
+	}
+
+	var events []*chatResponse
+	for _, choice := range completion.Choices {
+		events = append(events,
+			chunk([]chatChoice{{Index: choice.Index, Delta: choice.Message}}, nil),
+			chunk([]chatChoice{{Index: choice.Index, Delta: &chatResponseMessage{}, FinishReason: choice.FinishReason}}, nil),
+		)
+	}
+	if includeUsage {
+		events = append(events, chunk([]chatChoice{}, completion.Usage))
+	}
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		if !writeEvent(w, "", data) {
+			return
+		}
+	}
+	writeEvent(w, "", []byte("[DONE]"))
+}
+
+// writeEvent writes a server-sent event and flushes it to the client,
+// reporting whether the client is still there
+func writeEvent(w http.ResponseWriter, event string, data []byte) bool {
+	if event != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+			return false
+		}
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return false
+	}
+	flush(w)
+	return true
+}
+
+// writeStreamError ends a chat completion stream with an error in the OpenAI error format
+func writeStreamError(w http.ResponseWriter, err *apiError) {
+	data, marshalErr := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": err.Message,
+			"type":    err.Type,
+			"code":    nullable(err.Code),
+		},
+	})
+	if marshalErr != nil {
+		return
+	}
+	writeEvent(w, "", data)
+}
+
+// invalidRequest returns a bad request error with a message
+func invalidRequest(message string) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Type: "invalid_request_error", Message: message}
+}
@@ -0,0 +1,299 @@
+// Package server exposes an llm.Service over HTTP with OpenAI- and
+// Anthropic-compatible APIs, so tools written against either client can use
+// any configured provider.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// maxRequestBytes limits the size of a request body
+const maxRequestBytes = 32 << 20
+
+// keepAliveInterval is how often a comment is sent on a stream while the
+// provider is still answering
+var keepAliveInterval = 15 * time.Second
+
+// Server answers API requests with an llm.Service
+type Server struct {
+	service      *llm.Service
+	token        string            // Bearer token clients must send, empty to accept any client
+	defaultModel string            // Model for requests that do not name one
+	maxTokens    int               // Max tokens for requests that do not set them
+	aliases      map[string]string // Model names clients use mapped to models to answer with
+	accessLog    io.Writer         // Where to write a line per request, nil to disable
+	mux          *http.ServeMux
+}
+
+// Option is a functional option for configuring a Server
+type Option func(*Server)
+
+// WithToken requires clients to authenticate with a bearer token
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithDefaultModel sets the model for requests that do not name one
+func WithDefaultModel(model string) Option {
+	return func(s *Server) {
+		s.defaultModel = model
+	}
+}
+
+// WithMaxTokens sets the max tokens for requests that do not set them
+func WithMaxTokens(maxTokens int) Option {
+	return func(s *Server) {
+		s.maxTokens = maxTokens
+	}
+}
+
+// WithModelAlias answers requests for a model name with another model, e.g.
+// to send an Anthropic client's requests for a Claude model to Gemini
+func WithModelAlias(name, model string) Option {
+	return func(s *Server) {
+		if s.aliases == nil {
+			s.aliases = make(map[string]string)
+		}
+		s.aliases[name] = model
+	}
+}
+
+// WithAccessLog writes a line for every request to w
+func WithAccessLog(w io.Writer) Option {
+	return func(s *Server) {
+		s.accessLog = w
+	}
+}
+
+// New creates a server answering requests with the service
+func New(service *llm.Service, options ...Option) *Server {
+	s := &Server{
+		service:   service,
+		maxTokens: 1000,
+		mux:       http.NewServeMux(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	s.mux.HandleFunc("GET /v1/models", s.handleModels)
+	s.mux.HandleFunc("GET /v1/models/{model}", s.handleModel)
+	s.mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	s.mux.HandleFunc("POST /v1/messages", s.handleMessages)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler, checking the token and logging the request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	if s.authorized(r) {
+		s.mux.ServeHTTP(recorder, r)
+	} else {
+		err := &apiError{Status: http.StatusUnauthorized, Type: "authentication_error", Code: "invalid_api_key", Message: "invalid or missing API key"}
+		if r.URL.Path == "/v1/messages" {
+			writeAnthropicError(recorder, err)
+		} else {
+			writeError(recorder, err)
+		}
+	}
+
+	if s.accessLog != nil {
+		// A failed log line should not fail the request
+		_, _ = fmt.Fprintf(s.accessLog, "%s %s %s %d %s\n", startTime.Format(time.RFC3339), r.Method, r.URL.Path, recorder.status, time.Since(startTime).Round(time.Millisecond))
+	}
+}
+
+// authorized reports whether a request carries the server's token, as a
+// bearer token for OpenAI clients or an x-api-key header for Anthropic clients
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.Header.Get("x-api-key")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// resolveModel returns the model for a request, checking that its provider is configured
+func (s *Server) resolveModel(model string) (string, error) {
+	if model == "" {
+		model = s.defaultModel
+	}
+	if model == "" {
+		return "", &apiError{Status: http.StatusBadRequest, Type: "invalid_request_error", Message: "model is required"}
+	}
+	if alias, ok := s.aliases[model]; ok {
+		model = alias
+	}
+
+	provider, ok := llm.GetProviderForModel(model)
+	if !ok {
+		return "", &apiError{Status: http.StatusNotFound, Type: "invalid_request_error", Code: "model_not_found", Message: fmt.Sprintf("unknown model: %s", model)}
+	}
+	if !s.configured(provider) {
+		return "", &apiError{Status: http.StatusNotFound, Type: "invalid_request_error", Code: "model_not_found", Message: fmt.Sprintf("model %s is not available, provider %s is not configured", model, provider)}
+	}
+	return model, nil
+}
+
+// configured reports whether the service has an API key for a provider
+func (s *Server) configured(provider string) bool {
+	for _, name := range s.service.ConfiguredProviders() {
+		if name == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// queryStream starts a server-sent event stream and queries the service.
+// Providers are not queried with streaming, so streams are buffered: comments
+// keep the connection alive until the complete answer can be sent.
+func (s *Server) queryStream(w http.ResponseWriter, r *http.Request, prompt, model string, options []llm.Option) (*llm.Response, error) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(keepAliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flush(w)
+			}
+		}
+	}()
+
+	response, _, err := s.service.QueryDetailed(r.Context(), prompt, model, options...)
+
+	// Stop the keep-alives before the caller writes the answer
+	close(done)
+	<-stopped
+	return response, err
+}
+
+// flush sends buffered output to the client
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// apiError is an error returned to the client with its HTTP status
+type apiError struct {
+	Status  int
+	Type    string
+	Code    string
+	Message string
+}
+
+// Error implements the error interface
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// queryError converts an error from the service into an API error
+func queryError(err error) *apiError {
+	var apiErr *apiError
+	var contextErr *llm.ContextWindowError
+	var blockedErr *llm.PromptBlockedError
+	var optionErr *llm.InvalidOptionError
+
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &contextErr):
+		return &apiError{Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "context_length_exceeded", Message: err.Error()}
+	case errors.As(err, &optionErr):
+		return &apiError{Status: http.StatusBadRequest, Type: "invalid_request_error", Message: err.Error()}
+	case errors.As(err, &blockedErr):
+		return &apiError{Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "content_filter", Message: err.Error()}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &apiError{Status: http.StatusGatewayTimeout, Type: "api_error", Code: "timeout", Message: err.Error()}
+	}
+	return &apiError{Status: http.StatusBadGateway, Type: "api_error", Message: err.Error()}
+}
+
+// decodeRequest reads a JSON request body into v
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	body := http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return &apiError{Status: http.StatusBadRequest, Type: "invalid_request_error", Message: fmt.Sprintf("invalid request body: %v", err)}
+	}
+	return nil
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// The client may have gone away, there is nobody left to tell
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the OpenAI error format
+func writeError(w http.ResponseWriter, err *apiError) {
+	body := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": err.Message,
+			"type":    err.Type,
+			"code":    nullable(err.Code),
+		},
+	}
+	writeJSON(w, err.Status, body)
+}
+
+// nullable returns nil for an empty string, which encodes as JSON null
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// statusRecorder remembers the status code written for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher for streamed responses
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
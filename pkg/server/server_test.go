@@ -0,0 +1,444 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// newTestServer returns a server backed by a stand-in Deepseek API, which
+// answers with the number of messages it received and records the last request
+func newTestServer(t *testing.T, options ...Option) (*Server, *map[string]interface{}) {
+	t.Helper()
+
+	var lastRequest map[string]interface{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&lastRequest); err != nil {
+			t.Errorf("Failed to decode backend request: %v", err)
+		}
+		messages, _ := lastRequest["messages"].([]interface{})
+
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := io.WriteString(w, `{
+			"id": "ds-1",
+			"choices": [{"message": {"role": "assistant", "content": "`+strings.Repeat("x", len(messages))+`"}, "finish_reason": "length"}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 3}
+		}`); err != nil {
+			t.Errorf("Failed to write backend response: %v", err)
+		}
+	}))
+	t.Cleanup(backend.Close)
+
+	service := llm.NewServiceWithSettings(map[string]string{"deepseek": "test-key"}, nil, map[string]llm.ProviderSettings{
+		"deepseek": {BaseURL: backend.URL},
+	})
+	return New(service, options...), &lastRequest
+}
+
+// do sends a request to the server and returns the recorded response
+func do(s *Server, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, value := range header {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestModels(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	recorder := do(s, http.MethodGet, "/v1/models", "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+
+	var list struct {
+		Object string        `json:"object"`
+		Data   []modelObject `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to parse model list: %v", err)
+	}
+	if list.Object != "list" || len(list.Data) != len(llm.GetModelsForProvider("deepseek")) || list.Data[0].OwnedBy != "deepseek" {
+		t.Errorf("Expected the Deepseek models only, got %+v", list)
+	}
+
+	// Models of providers without a key are not available
+	if recorder := do(s, http.MethodGet, "/v1/models/claude-3-7-sonnet-latest", "", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unconfigured provider, got %d", recorder.Code)
+	}
+	if recorder := do(s, http.MethodGet, "/v1/models/deepseek-chat", "", nil); recorder.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for configured model, got %d", recorder.Code)
+	}
+}
+
+func TestChatCompletions(t *testing.T) {
+	s, lastRequest := newTestServer(t)
+
+	body := `{
+		"model": "deepseek-chat",
+		"messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "Hi"},
+			{"role": "assistant", "content": "Hello!"},
+			{"role": "user", "content": [{"type": "text", "text": "What is Go?"}]}
+		],
+		"max_tokens": 50,
+		"temperature": 0.2,
+		"stop": "END"
+	}`
+	recorder := do(s, http.MethodPost, "/v1/chat/completions", body, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var completion chatResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &completion); err != nil {
+		t.Fatalf("Failed to parse completion: %v", err)
+	}
+	if completion.Object != "chat.completion" || completion.Model != "deepseek-chat" || !strings.HasPrefix(completion.ID, "chatcmpl-") {
+		t.Errorf("Expected a chat completion for deepseek-chat, got %+v", completion)
+	}
+
+	// The system prompt, history and prompt all reach the provider
+	if len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "xxxx" {
+		t.Fatalf("Expected an answer to 4 messages, got %+v", completion.Choices)
+	}
+	if *completion.Choices[0].FinishReason != "length" {
+		t.Errorf("Expected finish reason length, got %s", *completion.Choices[0].FinishReason)
+	}
+	if completion.Usage.PromptTokens != 12 || completion.Usage.CompletionTokens != 3 || completion.Usage.TotalTokens != 15 {
+		t.Errorf("Expected usage of 12 and 3 tokens, got %+v", completion.Usage)
+	}
+
+	if (*lastRequest)["max_tokens"] != float64(50) || (*lastRequest)["temperature"] != 0.2 {
+		t.Errorf("Expected request settings to reach the provider, got %v", *lastRequest)
+	}
+	if stop, _ := (*lastRequest)["stop"].([]interface{}); len(stop) != 1 || stop[0] != "END" {
+		t.Errorf("Expected stop sequence END, got %v", (*lastRequest)["stop"])
+	}
+}
+
+func TestChatCompletionsStream(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	body := `{"model": "deepseek-chat", "messages": [{"role": "user", "content": "Hi"}], "stream": true, "stream_options": {"include_usage": true}}`
+	recorder := do(s, http.MethodPost, "/v1/chat/completions", body, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected event stream, got %s", contentType)
+	}
+
+	var events []string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	if len(events) != 4 || events[3] != "[DONE]" {
+		t.Fatalf("Expected content, finish and usage chunks then [DONE], got %v", events)
+	}
+
+	var content, usage chatResponse
+	if err := json.Unmarshal([]byte(events[0]), &content); err != nil {
+		t.Fatalf("Failed to parse chunk: %v", err)
+	}
+	if content.Object != "chat.completion.chunk" || content.Choices[0].Delta.Content != "x" {
+		t.Errorf("Expected content chunk, got %+v", content)
+	}
+	if err := json.Unmarshal([]byte(events[2]), &usage); err != nil {
+		t.Fatalf("Failed to parse chunk: %v", err)
+	}
+	if usage.Usage == nil || usage.Usage.TotalTokens != 15 || len(usage.Choices) != 0 {
+		t.Errorf("Expected usage chunk, got %+v", usage)
+	}
+}
+
+func TestStreamKeepAliveAndErrors(t *testing.T) {
+	defer func(interval time.Duration) { keepAliveInterval = interval }(keepAliveInterval)
+	keepAliveInterval = 5 * time.Millisecond
+
+	// A slow backend that fails after the stream has started
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if _, err := io.WriteString(w, `{"error": {"message": "overloaded", "type": "server_error"}}`); err != nil {
+			t.Errorf("Failed to write backend response: %v", err)
+		}
+	}))
+	defer backend.Close()
+
+	service := llm.NewServiceWithSettings(map[string]string{"deepseek": "test-key"}, nil, map[string]llm.ProviderSettings{
+		"deepseek": {BaseURL: backend.URL},
+	})
+	s := New(service)
+
+	body := `{"model": "deepseek-chat", "max_tokens": 10, "messages": [{"role": "user", "content": "Hi"}], "stream": true}`
+	recorder := do(s, http.MethodPost, "/v1/chat/completions", body, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for a started stream, got %d", recorder.Code)
+	}
+	if !strings.HasPrefix(recorder.Body.String(), ": keep-alive\n\n") {
+		t.Errorf("Expected keep-alive comments while waiting, got %s", recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), `data: {"error":{"code":null,"message":`) || !strings.Contains(recorder.Body.String(), "overloaded") {
+		t.Errorf("Expected the stream to end with an error, got %s", recorder.Body.String())
+	}
+
+	recorder = do(s, http.MethodPost, "/v1/messages", body, nil)
+	if !strings.HasPrefix(recorder.Body.String(), ": keep-alive\n\n") || !strings.Contains(recorder.Body.String(), "event: error\ndata: {\"error\":{\"message\":") {
+		t.Errorf("Expected keep-alive comments and an error event, got %s", recorder.Body.String())
+	}
+}
+
+func TestChatCompletionsErrors(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"invalid JSON", `{"model":`, http.StatusBadRequest, ""},
+		{"unknown model", `{"model": "gpt-4o", "messages": [{"role": "user", "content": "Hi"}]}`, http.StatusNotFound, "model_not_found"},
+		{"missing model", `{"messages": [{"role": "user", "content": "Hi"}]}`, http.StatusBadRequest, ""},
+		{"last message from assistant", `{"model": "deepseek-chat", "messages": [{"role": "assistant", "content": "Hi"}]}`, http.StatusBadRequest, ""},
+		{"image content", `{"model": "deepseek-chat", "messages": [{"role": "user", "content": [{"type": "image_url"}]}]}`, http.StatusBadRequest, ""},
+		{"too long", `{"model": "deepseek-chat", "messages": [{"role": "user", "content": "` + strings.Repeat("word ", 80000) + `"}]}`, http.StatusBadRequest, "context_length_exceeded"},
+		{"temperature out of range", `{"model": "deepseek-chat", "temperature": 5, "messages": [{"role": "user", "content": "Hi"}]}`, http.StatusBadRequest, ""},
+		{"top_p on a reasoning model", `{"model": "deepseek-reasoner", "top_p": 0.5, "messages": [{"role": "user", "content": "Hi"}]}`, http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		recorder := do(s, http.MethodPost, "/v1/chat/completions", test.body, nil)
+		if recorder.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, recorder.Code)
+			continue
+		}
+
+		var body struct {
+			Error struct {
+				Message string  `json:"message"`
+				Code    *string `json:"code"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || body.Error.Message == "" {
+			t.Errorf("%s: expected an OpenAI error body, got %s", test.name, recorder.Body.String())
+			continue
+		}
+		if test.code != "" && (body.Error.Code == nil || *body.Error.Code != test.code) {
+			t.Errorf("%s: expected error code %s, got %v", test.name, test.code, body.Error.Code)
+		}
+	}
+}
+
+func TestDeepseekOptions(t *testing.T) {
+	s, lastRequest := newTestServer(t)
+
+	// Penalties and JSON output are forwarded to Deepseek
+	body := `{
+		"model": "deepseek-chat",
+		"presence_penalty": 0.5,
+		"frequency_penalty": -0.5,
+		"response_format": {"type": "json_object"},
+		"messages": [{"role": "user", "content": "Hi"}]
+	}`
+	recorder := do(s, http.MethodPost, "/v1/chat/completions", body, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if (*lastRequest)["presence_penalty"] != 0.5 || (*lastRequest)["frequency_penalty"] != -0.5 {
+		t.Errorf("Expected penalties to reach the provider, got %v", *lastRequest)
+	}
+	if format, _ := (*lastRequest)["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("Expected JSON response format, got %v", (*lastRequest)["response_format"])
+	}
+
+	// Several choices cannot be generated
+	recorder = do(s, http.MethodPost, "/v1/chat/completions", `{"model": "deepseek-chat", "n": 3, "messages": [{"role": "user", "content": "Hi"}]}`, nil)
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "invalid_request_error") {
+		t.Errorf("Expected status 400 for several choices, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestAnthropicOptionErrors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected unsupported options to be rejected before sending, got %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(backend.Close)
+
+	service := llm.NewServiceWithSettings(map[string]string{"anthropic": "test-key"}, nil, map[string]llm.ProviderSettings{
+		"anthropic": {BaseURL: backend.URL},
+	})
+	s := New(service)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"several choices", `{"model": "claude-3-7-sonnet-latest", "n": 3, "messages": [{"role": "user", "content": "Hi"}]}`},
+		{"JSON output", `{"model": "claude-3-7-sonnet-latest", "response_format": {"type": "json_object"}, "messages": [{"role": "user", "content": "Hi"}]}`},
+	}
+
+	for _, test := range tests {
+		recorder := do(s, http.MethodPost, "/v1/chat/completions", test.body, nil)
+		if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "invalid_request_error") {
+			t.Errorf("%s: expected status 400 with invalid_request_error, got %d: %s", test.name, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestGeminiOptionErrors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected invalid options to be rejected before sending, got %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(backend.Close)
+
+	service := llm.NewServiceWithSettings(map[string]string{"google": "test-key"}, nil, map[string]llm.ProviderSettings{
+		"google": {BaseURL: backend.URL},
+	})
+	s := New(service)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"too many stop sequences", `{"model": "gemini-2.0-flash", "stop": ["a", "b", "c", "d", "e", "f"], "messages": [{"role": "user", "content": "Hi"}]}`},
+		{"penalty on a model without penalties", `{"model": "gemini-2.0-flash-lite", "presence_penalty": 0.5, "messages": [{"role": "user", "content": "Hi"}]}`},
+		{"penalty out of range", `{"model": "gemini-2.0-flash", "frequency_penalty": 3, "messages": [{"role": "user", "content": "Hi"}]}`},
+	}
+
+	for _, test := range tests {
+		recorder := do(s, http.MethodPost, "/v1/chat/completions", test.body, nil)
+		if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "invalid_request_error") {
+			t.Errorf("%s: expected status 400 with invalid_request_error, got %d: %s", test.name, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestServerToken(t *testing.T) {
+	var accessLog bytes.Buffer
+	s, _ := newTestServer(t, WithToken("secret"), WithAccessLog(&accessLog))
+
+	if recorder := do(s, http.MethodGet, "/v1/models", "", nil); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", recorder.Code)
+	}
+	if recorder := do(s, http.MethodGet, "/v1/models", "", map[string]string{"Authorization": "Bearer wrong"}); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with wrong token, got %d", recorder.Code)
+	}
+	if recorder := do(s, http.MethodGet, "/v1/models", "", map[string]string{"Authorization": "Bearer secret"}); recorder.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with token, got %d", recorder.Code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(accessLog.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "GET /v1/models 401") || !strings.Contains(lines[2], "GET /v1/models 200") {
+		t.Errorf("Expected a log line per request, got %q", accessLog.String())
+	}
+}
+
+func TestDefaultModel(t *testing.T) {
+	s, lastRequest := newTestServer(t, WithDefaultModel("deepseek-chat"), WithMaxTokens(77))
+
+	recorder := do(s, http.MethodPost, "/v1/chat/completions", `{"messages": [{"role": "user", "content": "Hi"}]}`, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if (*lastRequest)["model"] != "deepseek-chat" || (*lastRequest)["max_tokens"] != float64(77) {
+		t.Errorf("Expected default model and max tokens, got %v", *lastRequest)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	s, lastRequest := newTestServer(t, WithToken("secret"), WithModelAlias("claude-3-7-sonnet-latest", "deepseek-chat"))
+	header := map[string]string{"x-api-key": "secret", "anthropic-version": "2023-06-01"}
+
+	// A request for a Claude model is answered by Deepseek
+	body := `{
+		"model": "claude-3-7-sonnet-latest",
+		"max_tokens": 64,
+		"system": "Be brief.",
+		"messages": [{"role": "user", "content": "Hi"}]
+	}`
+	recorder := do(s, http.MethodPost, "/v1/messages", body, header)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var message struct {
+		Type    string `json:"type"`
+		Model   string `json:"model"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &message); err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if message.Type != "message" || message.Model != "deepseek-chat" || len(message.Content) != 1 || message.Content[0].Text != "xx" {
+		t.Errorf("Expected a Deepseek answer to the system prompt and message, got %+v", message)
+	}
+	if message.StopReason != "max_tokens" || message.Usage.InputTokens != 12 || message.Usage.OutputTokens != 3 {
+		t.Errorf("Expected stop reason and usage, got %+v", message)
+	}
+	if (*lastRequest)["model"] != "deepseek-chat" || (*lastRequest)["max_tokens"] != float64(64) {
+		t.Errorf("Expected the aliased model and max tokens, got %v", *lastRequest)
+	}
+
+	// Streamed answers use Anthropic's events
+	recorder = do(s, http.MethodPost, "/v1/messages", strings.Replace(body, `"max_tokens"`, `"stream": true, "max_tokens"`, 1), header)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if !strings.HasPrefix(recorder.Body.String(), "event: message_start\ndata: ") || !strings.HasSuffix(recorder.Body.String(), "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n") {
+		t.Errorf("Expected a stream from message_start to message_stop, got %s", recorder.Body.String())
+	}
+
+	// Errors use Anthropic's format
+	for name, test := range map[string]struct {
+		body      string
+		header    map[string]string
+		status    int
+		errorType string
+	}{
+		"wrong key":     {body, map[string]string{"x-api-key": "wrong"}, http.StatusUnauthorized, "authentication_error"},
+		"no max tokens": {`{"model": "deepseek-chat", "messages": [{"role": "user", "content": "Hi"}]}`, header, http.StatusBadRequest, "invalid_request_error"},
+		"unknown model": {`{"model": "claude-2", "max_tokens": 10, "messages": [{"role": "user", "content": "Hi"}]}`, header, http.StatusNotFound, "not_found_error"},
+		"thinking":      {`{"model": "deepseek-chat", "max_tokens": 4096, "thinking": {"type": "enabled", "budget_tokens": 2048}, "messages": [{"role": "user", "content": "Hi"}]}`, header, http.StatusBadRequest, "invalid_request_error"},
+	} {
+		recorder := do(s, http.MethodPost, "/v1/messages", test.body, test.header)
+		var errorBody struct {
+			Type  string `json:"type"`
+			Error struct {
+				Type string `json:"type"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &errorBody); err != nil {
+			t.Errorf("%s: failed to parse error: %v", name, err)
+			continue
+		}
+		if recorder.Code != test.status || errorBody.Type != "error" || errorBody.Error.Type != test.errorType {
+			t.Errorf("%s: expected status %d with %s, got %d and %s", name, test.status, test.errorType, recorder.Code, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package shell
+
+import "regexp"
+
+// risk is a pattern of destructive commands
+type risk struct {
+	pattern     *regexp.Regexp
+	description string
+}
+
+// risks are the destructive patterns commands are checked for
+var risks = []risk{
+	{regexp.MustCompile(`\brm\s+(?:\S+\s+)*-(?:-recursive\b|-force\b|[a-zA-Z]*[rRf])`), "deletes files recursively or without asking (rm -r/-f)"},
+	{regexp.MustCompile(`\bfind\b.*\s(?:-delete\b|-exec\s+rm\b)`), "deletes the files it finds"},
+	{regexp.MustCompile(`\bdd\s`), "writes raw data to files or devices (dd)"},
+	{regexp.MustCompile(`\bmkfs(?:\.\w+)?\b|\bwipefs\b|\bshred\b`), "erases or formats a disk or file"},
+	{regexp.MustCompile(`>\s*/dev/(?:sd|hd|nvme|disk|mmcblk)`), "overwrites a disk device"},
+	{regexp.MustCompile(`\bgit\s+push\b.*(?:\s--force(?:-with-lease)?\b|\s-[a-zA-Z]*f\b|\s\+\S)`), "force pushes, rewriting remote history"},
+	{regexp.MustCompile(`\bgit\s+reset\s+(?:\S+\s+)*--hard\b`), "discards uncommitted changes (git reset --hard)"},
+	{regexp.MustCompile(`\bgit\s+clean\s+(?:\S+\s+)*-[a-zA-Z]*f`), "deletes untracked files (git clean)"},
+	{regexp.MustCompile(`\bgit\s+(?:checkout|restore)\s+(?:\S+\s+)*\.(?:\s|$)`), "discards uncommitted changes"},
+	{regexp.MustCompile(`\bchmod\s+(?:\S+\s+)*(?:-R\b|777\b)|\bchown\s+(?:\S+\s+)*-R\b`), "changes permissions or owners broadly"},
+	{regexp.MustCompile(`\b(?:curl|wget)\b[^|]*\|\s*(?:sudo\s+)?(?:ba|z|da)?sh\b`), "runs a script downloaded from the internet"},
+	{regexp.MustCompile(`\bsudo\b|\bdoas\b`), "runs with root privileges"},
+	{regexp.MustCompile(`:\(\)\s*\{.*\};\s*:`), "is a fork bomb"},
+	{regexp.MustCompile(`\b(?:shutdown|reboot|halt|poweroff)\b`), "shuts down or restarts the machine"},
+	{regexp.MustCompile(`\bkill(?:all)?\s+(?:\S+\s+)*-(?:9|KILL)\b`), "force kills processes"},
+	{regexp.MustCompile(`(?i)\b(?:drop\s+(?:table|database|schema)|truncate\s+table)\b`), "deletes database tables or data"},
+	{regexp.MustCompile(`(?i)\bremove-item\b.*-recurse\b|\b(?:del|erase)\s+(?:\S+\s+)*/[sq]\b|\b(?:rd|rmdir)\s+(?:\S+\s+)*/s\b|\bformat\s+[a-z]:`), "deletes files recursively or formats a drive"},
+}
+
+// Risks returns descriptions of the destructive patterns in a command
+func Risks(command string) []string {
+	var found []string
+	for _, risk := range risks {
+		if risk.pattern.MatchString(command) {
+			found = append(found, risk.description)
+		}
+	}
+	return found
+}
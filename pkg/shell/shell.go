@@ -0,0 +1,205 @@
+// Package shell asks a model for a shell command doing what a request
+// describes, warns about destructive commands and runs them.
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/zerobang-dev/gollm/pkg/codeblock"
+	"github.com/zerobang-dev/gollm/pkg/llm"
+)
+
+// Querier sends a prompt to a model, as implemented by llm.Service
+type Querier interface {
+	QueryDetailed(ctx context.Context, prompt, modelName string, options ...llm.Option) (*llm.Response, time.Duration, error)
+}
+
+// Environment is the operating system and shell commands are written for
+type Environment struct {
+	OS    string // runtime.GOOS value such as linux, darwin or windows
+	Shell string // Shell name such as bash, zsh, fish, powershell or cmd
+}
+
+// DetectEnvironment returns the current operating system and the user's shell
+func DetectEnvironment() Environment {
+	env := Environment{OS: runtime.GOOS}
+	switch {
+	case os.Getenv("SHELL") != "":
+		env.Shell = filepath.Base(os.Getenv("SHELL"))
+	case runtime.GOOS == "windows" && os.Getenv("PSModulePath") != "":
+		env.Shell = "powershell"
+	case runtime.GOOS == "windows":
+		env.Shell = "cmd"
+	default:
+		env.Shell = "sh"
+	}
+	return env
+}
+
+// osNames are the names models know operating systems by
+var osNames = map[string]string{
+	"darwin":  "macOS",
+	"linux":   "Linux",
+	"windows": "Windows",
+	"freebsd": "FreeBSD",
+}
+
+// String describes the environment, e.g. "zsh on macOS"
+func (e Environment) String() string {
+	name, ok := osNames[e.OS]
+	if !ok {
+		name = e.OS
+	}
+	return e.Shell + " on " + name
+}
+
+// Suggestion is a command suggested by a model
+type Suggestion struct {
+	Command     string
+	Explanation string
+	Risks       []string // Destructive patterns found in the command
+}
+
+// Prompt builds the prompt asking for a single command for the environment
+func Prompt(request string, env Environment) string {
+	return fmt.Sprintf(`Write a single %s command that does the following: %s
+
+Answer in exactly this format, without code fences:
+COMMAND: <the command on one line>
+EXPLANATION: <what the command does and what each part is for, in a few sentences>
+
+Prefer standard tools available on %s. If the request cannot be done with a command, answer COMMAND: NONE and explain why.`,
+		env, request, env)
+}
+
+// Parse reads the command and explanation from an answer in the format
+// Prompt asks for, falling back to the first code block for models that
+// ignore it
+func Parse(text string) (Suggestion, error) {
+	var suggestion Suggestion
+	var explanation []string
+	inExplanation := false
+
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		trimmed := strings.TrimSpace(line)
+		command, isCommand := cutLabel(trimmed, "COMMAND")
+		text, isExplanation := cutLabel(trimmed, "EXPLANATION")
+		switch {
+		case suggestion.Command == "" && isCommand:
+			suggestion.Command = unquoteCommand(command)
+			inExplanation = false
+		case isExplanation:
+			explanation = append(explanation, strings.TrimSpace(text))
+			inExplanation = true
+		case inExplanation:
+			explanation = append(explanation, line)
+		}
+	}
+	suggestion.Explanation = strings.TrimSpace(strings.Join(explanation, "\n"))
+
+	// A block cut off before its closing fence may hold half a command
+	if suggestion.Command == "" {
+		for _, block := range codeblock.Parse(text) {
+			if block.Closed {
+				suggestion.Command = strings.TrimSpace(block.Content)
+				break
+			}
+		}
+	}
+	if strings.EqualFold(suggestion.Command, "NONE") {
+		if suggestion.Explanation == "" {
+			return Suggestion{}, errors.New("the model could not suggest a command")
+		}
+		return Suggestion{}, fmt.Errorf("the model could not suggest a command: %s", suggestion.Explanation)
+	}
+	if suggestion.Command == "" {
+		return Suggestion{}, errors.New("no command found in the answer")
+	}
+
+	suggestion.Risks = Risks(suggestion.Command)
+	return suggestion, nil
+}
+
+// cutLabel returns the rest of a line starting with a label and a colon,
+// ignoring case and markdown emphasis such as **COMMAND:** or __COMMAND__:
+func cutLabel(line, label string) (string, bool) {
+	trimmed := strings.TrimLeft(line, "*_")
+	emphasis := line[:len(line)-len(trimmed)]
+	if len(trimmed) < len(label) || !strings.EqualFold(trimmed[:len(label)], label) {
+		return "", false
+	}
+
+	// The emphasis closes before or after the colon
+	rest := strings.TrimPrefix(trimmed[len(label):], emphasis)
+	rest, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return "", false
+	}
+	if emphasis != "" {
+		rest = strings.TrimPrefix(rest, emphasis)
+	}
+	return rest, true
+}
+
+// unquoteCommand strips the backticks models put around commands
+func unquoteCommand(command string) string {
+	command = strings.TrimSpace(command)
+	if len(command) >= 2 && strings.HasPrefix(command, "`") && strings.HasSuffix(command, "`") {
+		command = strings.Trim(command, "`")
+	}
+	return strings.TrimSpace(command)
+}
+
+// Suggest asks the model for a command doing what the request describes
+func Suggest(ctx context.Context, querier Querier, model, request string, env Environment, options ...llm.Option) (Suggestion, *llm.Response, error) {
+	response, _, err := querier.QueryDetailed(ctx, Prompt(request, env), model, options...)
+	if err != nil {
+		return Suggestion{}, nil, err
+	}
+	suggestion, err := Parse(response.Text)
+	if err != nil {
+		return Suggestion{}, response, err
+	}
+	return suggestion, response, nil
+}
+
+// Run runs a command with the environment's shell, connected to the given
+// input and outputs, and returns its exit code. An error is only returned
+// when the command could not be started.
+func Run(ctx context.Context, env Environment, command string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	var cmd *exec.Cmd
+	switch env.Shell {
+	case "cmd":
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	case "powershell", "pwsh":
+		cmd = exec.CommandContext(ctx, env.Shell, "-NoProfile", "-Command", command)
+	default:
+		shell, err := exec.LookPath(env.Shell)
+		if err != nil {
+			shell = "/bin/sh"
+		}
+		cmd = exec.CommandContext(ctx, shell, "-c", command)
+	}
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, fmt.Errorf("error running command: %w", err)
+	}
+	return 0, nil
+}